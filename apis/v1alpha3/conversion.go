@@ -27,3 +27,69 @@ import (
 func Convert_v1beta1_VirtualMachineCloneSpec_To_v1alpha3_VirtualMachineCloneSpec(in *v1beta1.VirtualMachineCloneSpec, out *VirtualMachineCloneSpec, s conversion.Scope) error {
 	return autoConvert_v1beta1_VirtualMachineCloneSpec_To_v1alpha3_VirtualMachineCloneSpec(in, out, s)
 }
+
+// restoreVirtualMachineCloneSpec restores the fields of a v1beta1
+// VirtualMachineCloneSpec which do not exist in v1alpha3.
+func restoreVirtualMachineCloneSpec(src, dst *v1beta1.VirtualMachineCloneSpec) {
+	dst.TagIDs = src.TagIDs
+	dst.AdditionalDisksGiB = src.AdditionalDisksGiB
+	dst.PciDevices = src.PciDevices
+	dst.DataDisks = src.DataDisks
+	dst.DatastoreCluster = src.DatastoreCluster
+	dst.PlacementPolicy = src.PlacementPolicy
+	dst.ResizePolicy = src.ResizePolicy
+	dst.MetadataTemplate = src.MetadataTemplate
+	for i := range dst.Network.Devices {
+		if i < len(src.Network.Devices) {
+			dst.Network.Devices[i].AddressesFromPools = src.Network.Devices[i].AddressesFromPools
+		}
+	}
+}
+
+// Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(in *v1beta1.NetworkDeviceSpec, out *NetworkDeviceSpec, s conversion.Scope) error {
+	return autoConvert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(in, out, s)
+}
+
+// Convert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(in *v1beta1.VSphereVMStatus, out *VSphereVMStatus, s conversion.Scope) error {
+	return autoConvert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(in, out, s)
+}
+
+// Convert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(in *v1beta1.VSphereClusterSpec, out *VSphereClusterSpec, s conversion.Scope) error {
+	return autoConvert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(in, out, s)
+}
+
+// Convert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(in *v1beta1.VSphereClusterIdentitySpec, out *VSphereClusterIdentitySpec, s conversion.Scope) error {
+	return autoConvert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(in, out, s)
+}
+
+// Convert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(in *v1beta1.VSphereClusterStatus, out *VSphereClusterStatus, s conversion.Scope) error {
+	return autoConvert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(in, out, s)
+}
+
+// Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(in *v1beta1.FailureDomainHosts, out *FailureDomainHosts, s conversion.Scope) error {
+	return autoConvert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(in, out, s)
+}
+
+// Convert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec(in *v1beta1.VSphereDeploymentZoneSpec, out *VSphereDeploymentZoneSpec, s conversion.Scope) error {
+	return autoConvert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec(in, out, s)
+}
+
+// Convert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus is an autogenerated conversion function.
+//nolint:golint,revive,stylecheck
+func Convert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(in *v1beta1.VSphereDeploymentZoneStatus, out *VSphereDeploymentZoneStatus, s conversion.Scope) error {
+	return autoConvert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(in, out, s)
+}
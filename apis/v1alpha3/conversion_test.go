@@ -43,6 +43,11 @@ func TestFuzzyConversion(t *testing.T) {
 		Spoke:       &VSphereCluster{},
 		FuzzerFuncs: []fuzzer.FuzzerFuncs{overrideVSphereClusterDeprecatedFieldsFuncs},
 	}))
+	t.Run("for VSphereClusterIdentity", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Scheme: scheme,
+		Hub:    &nextver.VSphereClusterIdentity{},
+		Spoke:  &VSphereClusterIdentity{},
+	}))
 	t.Run("for VSphereMachine", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
 		Scheme: scheme,
 		Hub:    &nextver.VSphereMachine{},
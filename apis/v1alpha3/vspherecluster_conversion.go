@@ -40,6 +40,9 @@ func (src *VSphereCluster) ConvertTo(dstRaw conversion.Hub) error {
 	if restored.Spec.IdentityRef != nil {
 		dst.Spec.IdentityRef = restored.Spec.IdentityRef
 	}
+	dst.Spec.CABundle = restored.Spec.CABundle
+	dst.Spec.FailureDomainSelection = restored.Spec.FailureDomainSelection
+	dst.Status.ClusterModules = restored.Status.ClusterModules
 	return nil
 }
 
@@ -36,8 +36,12 @@ func (src *VSphereVM) ConvertTo(dstRaw conversion.Hub) error {
 	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
 		return err
 	}
-	dst.Spec.TagIDs = restored.Spec.TagIDs
-	dst.Spec.AdditionalDisksGiB = restored.Spec.AdditionalDisksGiB
+	restoreVirtualMachineCloneSpec(&restored.Spec.VirtualMachineCloneSpec, &dst.Spec.VirtualMachineCloneSpec)
+	dst.Status.Placement = restored.Status.Placement
+	dst.Status.Hardware = restored.Status.Hardware
+	dst.Status.DataDiskUnitNumbers = restored.Status.DataDiskUnitNumbers
+	dst.Status.TaskRetries = restored.Status.TaskRetries
+	dst.Status.ClusterModule = restored.Status.ClusterModule
 
 	return nil
 }
@@ -75,11 +75,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.FailureDomainHosts)(nil), (*FailureDomainHosts)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(a.(*v1beta1.FailureDomainHosts), b.(*FailureDomainHosts), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*Network)(nil), (*v1beta1.Network)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_Network_To_v1beta1_Network(a.(*Network), b.(*v1beta1.Network), scope)
 	}); err != nil {
@@ -95,11 +90,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NetworkDeviceSpec)(nil), (*NetworkDeviceSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(a.(*v1beta1.NetworkDeviceSpec), b.(*NetworkDeviceSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkRouteSpec)(nil), (*v1beta1.NetworkRouteSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_NetworkRouteSpec_To_v1beta1_NetworkRouteSpec(a.(*NetworkRouteSpec), b.(*v1beta1.NetworkRouteSpec), scope)
 	}); err != nil {
@@ -195,11 +185,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.VSphereClusterIdentitySpec)(nil), (*VSphereClusterIdentitySpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(a.(*v1beta1.VSphereClusterIdentitySpec), b.(*VSphereClusterIdentitySpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*VSphereClusterIdentityStatus)(nil), (*v1beta1.VSphereClusterIdentityStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VSphereClusterIdentityStatus_To_v1beta1_VSphereClusterIdentityStatus(a.(*VSphereClusterIdentityStatus), b.(*v1beta1.VSphereClusterIdentityStatus), scope)
 	}); err != nil {
@@ -220,21 +205,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.VSphereClusterSpec)(nil), (*VSphereClusterSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(a.(*v1beta1.VSphereClusterSpec), b.(*VSphereClusterSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*VSphereClusterStatus)(nil), (*v1beta1.VSphereClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VSphereClusterStatus_To_v1beta1_VSphereClusterStatus(a.(*VSphereClusterStatus), b.(*v1beta1.VSphereClusterStatus), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.VSphereClusterStatus)(nil), (*VSphereClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(a.(*v1beta1.VSphereClusterStatus), b.(*VSphereClusterStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*VSphereDeploymentZone)(nil), (*v1beta1.VSphereDeploymentZone)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VSphereDeploymentZone_To_v1beta1_VSphereDeploymentZone(a.(*VSphereDeploymentZone), b.(*v1beta1.VSphereDeploymentZone), scope)
 	}); err != nil {
@@ -260,21 +235,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.VSphereDeploymentZoneSpec)(nil), (*VSphereDeploymentZoneSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec(a.(*v1beta1.VSphereDeploymentZoneSpec), b.(*VSphereDeploymentZoneSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*VSphereDeploymentZoneStatus)(nil), (*v1beta1.VSphereDeploymentZoneStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VSphereDeploymentZoneStatus_To_v1beta1_VSphereDeploymentZoneStatus(a.(*VSphereDeploymentZoneStatus), b.(*v1beta1.VSphereDeploymentZoneStatus), scope)
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.VSphereDeploymentZoneStatus)(nil), (*VSphereDeploymentZoneStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(a.(*v1beta1.VSphereDeploymentZoneStatus), b.(*VSphereDeploymentZoneStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*VSphereFailureDomain)(nil), (*v1beta1.VSphereFailureDomain)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VSphereFailureDomain_To_v1beta1_VSphereFailureDomain(a.(*VSphereFailureDomain), b.(*v1beta1.VSphereFailureDomain), scope)
 	}); err != nil {
@@ -430,11 +395,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.VSphereVMStatus)(nil), (*VSphereVMStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(a.(*v1beta1.VSphereVMStatus), b.(*VSphereVMStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*VirtualMachine)(nil), (*v1beta1.VirtualMachine)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VirtualMachine_To_v1beta1_VirtualMachine(a.(*VirtualMachine), b.(*v1beta1.VirtualMachine), scope)
 	}); err != nil {
@@ -460,11 +420,51 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.FailureDomainHosts)(nil), (*FailureDomainHosts)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(a.(*v1beta1.FailureDomainHosts), b.(*FailureDomainHosts), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NetworkDeviceSpec)(nil), (*NetworkDeviceSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(a.(*v1beta1.NetworkDeviceSpec), b.(*NetworkDeviceSpec), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*apiv1beta1.ObjectMeta)(nil), (*apiv1alpha3.ObjectMeta)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_ObjectMeta_To_v1alpha3_ObjectMeta(a.(*apiv1beta1.ObjectMeta), b.(*apiv1alpha3.ObjectMeta), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.VSphereClusterIdentitySpec)(nil), (*VSphereClusterIdentitySpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(a.(*v1beta1.VSphereClusterIdentitySpec), b.(*VSphereClusterIdentitySpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.VSphereClusterSpec)(nil), (*VSphereClusterSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(a.(*v1beta1.VSphereClusterSpec), b.(*VSphereClusterSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.VSphereClusterStatus)(nil), (*VSphereClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(a.(*v1beta1.VSphereClusterStatus), b.(*VSphereClusterStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.VSphereDeploymentZoneSpec)(nil), (*VSphereDeploymentZoneSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec(a.(*v1beta1.VSphereDeploymentZoneSpec), b.(*VSphereDeploymentZoneSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.VSphereDeploymentZoneStatus)(nil), (*VSphereDeploymentZoneStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(a.(*v1beta1.VSphereDeploymentZoneStatus), b.(*VSphereDeploymentZoneStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.VSphereVMStatus)(nil), (*VSphereVMStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(a.(*v1beta1.VSphereVMStatus), b.(*VSphereVMStatus), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.VirtualMachineCloneSpec)(nil), (*VirtualMachineCloneSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_VirtualMachineCloneSpec_To_v1alpha3_VirtualMachineCloneSpec(a.(*v1beta1.VirtualMachineCloneSpec), b.(*VirtualMachineCloneSpec), scope)
 	}); err != nil {
@@ -555,14 +555,10 @@ func Convert_v1alpha3_FailureDomainHosts_To_v1beta1_FailureDomainHosts(in *Failu
 func autoConvert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(in *v1beta1.FailureDomainHosts, out *FailureDomainHosts, s conversion.Scope) error {
 	out.VMGroupName = in.VMGroupName
 	out.HostGroupName = in.HostGroupName
+	// WARNING: in.AffinityRulePolicy requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts is an autogenerated conversion function.
-func Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(in *v1beta1.FailureDomainHosts, out *FailureDomainHosts, s conversion.Scope) error {
-	return autoConvert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(in, out, s)
-}
-
 func autoConvert_v1alpha3_Network_To_v1beta1_Network(in *Network, out *v1beta1.Network, s conversion.Scope) error {
 	out.Name = in.Name
 	out.DHCP4 = (*bool)(unsafe.Pointer(in.DHCP4))
@@ -621,14 +617,10 @@ func autoConvert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(in *v1b
 	out.Nameservers = *(*[]string)(unsafe.Pointer(&in.Nameservers))
 	out.Routes = *(*[]NetworkRouteSpec)(unsafe.Pointer(&in.Routes))
 	out.SearchDomains = *(*[]string)(unsafe.Pointer(&in.SearchDomains))
+	// WARNING: in.AddressesFromPools requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec is an autogenerated conversion function.
-func Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(in *v1beta1.NetworkDeviceSpec, out *NetworkDeviceSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(in, out, s)
-}
-
 func autoConvert_v1alpha3_NetworkRouteSpec_To_v1beta1_NetworkRouteSpec(in *NetworkRouteSpec, out *v1beta1.NetworkRouteSpec, s conversion.Scope) error {
 	out.To = in.To
 	out.Via = in.Via
@@ -654,7 +646,17 @@ func Convert_v1beta1_NetworkRouteSpec_To_v1alpha3_NetworkRouteSpec(in *v1beta1.N
 }
 
 func autoConvert_v1alpha3_NetworkSpec_To_v1beta1_NetworkSpec(in *NetworkSpec, out *v1beta1.NetworkSpec, s conversion.Scope) error {
-	out.Devices = *(*[]v1beta1.NetworkDeviceSpec)(unsafe.Pointer(&in.Devices))
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]v1beta1.NetworkDeviceSpec, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha3_NetworkDeviceSpec_To_v1beta1_NetworkDeviceSpec(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Devices = nil
+	}
 	out.Routes = *(*[]v1beta1.NetworkRouteSpec)(unsafe.Pointer(&in.Routes))
 	out.PreferredAPIServerCIDR = in.PreferredAPIServerCIDR
 	return nil
@@ -666,7 +668,17 @@ func Convert_v1alpha3_NetworkSpec_To_v1beta1_NetworkSpec(in *NetworkSpec, out *v
 }
 
 func autoConvert_v1beta1_NetworkSpec_To_v1alpha3_NetworkSpec(in *v1beta1.NetworkSpec, out *NetworkSpec, s conversion.Scope) error {
-	out.Devices = *(*[]NetworkDeviceSpec)(unsafe.Pointer(&in.Devices))
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]NetworkDeviceSpec, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Devices = nil
+	}
 	out.Routes = *(*[]NetworkRouteSpec)(unsafe.Pointer(&in.Routes))
 	out.PreferredAPIServerCIDR = in.PreferredAPIServerCIDR
 	return nil
@@ -750,7 +762,15 @@ func Convert_v1beta1_SSHUser_To_v1alpha3_SSHUser(in *v1beta1.SSHUser, out *SSHUs
 func autoConvert_v1alpha3_Topology_To_v1beta1_Topology(in *Topology, out *v1beta1.Topology, s conversion.Scope) error {
 	out.Datacenter = in.Datacenter
 	out.ComputeCluster = (*string)(unsafe.Pointer(in.ComputeCluster))
-	out.Hosts = (*v1beta1.FailureDomainHosts)(unsafe.Pointer(in.Hosts))
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = new(v1beta1.FailureDomainHosts)
+		if err := Convert_v1alpha3_FailureDomainHosts_To_v1beta1_FailureDomainHosts(*in, *out, s); err != nil {
+			return err
+		}
+	} else {
+		out.Hosts = nil
+	}
 	out.Networks = *(*[]string)(unsafe.Pointer(&in.Networks))
 	out.Datastore = in.Datastore
 	return nil
@@ -764,7 +784,15 @@ func Convert_v1alpha3_Topology_To_v1beta1_Topology(in *Topology, out *v1beta1.To
 func autoConvert_v1beta1_Topology_To_v1alpha3_Topology(in *v1beta1.Topology, out *Topology, s conversion.Scope) error {
 	out.Datacenter = in.Datacenter
 	out.ComputeCluster = (*string)(unsafe.Pointer(in.ComputeCluster))
-	out.Hosts = (*FailureDomainHosts)(unsafe.Pointer(in.Hosts))
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = new(FailureDomainHosts)
+		if err := Convert_v1beta1_FailureDomainHosts_To_v1alpha3_FailureDomainHosts(*in, *out, s); err != nil {
+			return err
+		}
+	} else {
+		out.Hosts = nil
+	}
 	out.Networks = *(*[]string)(unsafe.Pointer(&in.Networks))
 	out.Datastore = in.Datastore
 	return nil
@@ -841,7 +869,17 @@ func Convert_v1beta1_VSphereClusterIdentity_To_v1alpha3_VSphereClusterIdentity(i
 
 func autoConvert_v1alpha3_VSphereClusterIdentityList_To_v1beta1_VSphereClusterIdentityList(in *VSphereClusterIdentityList, out *v1beta1.VSphereClusterIdentityList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.VSphereClusterIdentity)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.VSphereClusterIdentity, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha3_VSphereClusterIdentity_To_v1beta1_VSphereClusterIdentity(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -852,7 +890,17 @@ func Convert_v1alpha3_VSphereClusterIdentityList_To_v1beta1_VSphereClusterIdenti
 
 func autoConvert_v1beta1_VSphereClusterIdentityList_To_v1alpha3_VSphereClusterIdentityList(in *v1beta1.VSphereClusterIdentityList, out *VSphereClusterIdentityList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]VSphereClusterIdentity)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereClusterIdentity, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_VSphereClusterIdentity_To_v1alpha3_VSphereClusterIdentity(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -874,15 +922,12 @@ func Convert_v1alpha3_VSphereClusterIdentitySpec_To_v1beta1_VSphereClusterIdenti
 
 func autoConvert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(in *v1beta1.VSphereClusterIdentitySpec, out *VSphereClusterIdentitySpec, s conversion.Scope) error {
 	out.SecretName = in.SecretName
+	// WARNING: in.CredentialsType requires manual conversion: does not exist in peer-type
 	out.AllowedNamespaces = (*AllowedNamespaces)(unsafe.Pointer(in.AllowedNamespaces))
+	// WARNING: in.CABundle requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec is an autogenerated conversion function.
-func Convert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(in *v1beta1.VSphereClusterIdentitySpec, out *VSphereClusterIdentitySpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_VSphereClusterIdentitySpec_To_v1alpha3_VSphereClusterIdentitySpec(in, out, s)
-}
-
 func autoConvert_v1alpha3_VSphereClusterIdentityStatus_To_v1beta1_VSphereClusterIdentityStatus(in *VSphereClusterIdentityStatus, out *v1beta1.VSphereClusterIdentityStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
 	out.Conditions = *(*apiv1beta1.Conditions)(unsafe.Pointer(&in.Conditions))
@@ -963,18 +1008,15 @@ func autoConvert_v1alpha3_VSphereClusterSpec_To_v1beta1_VSphereClusterSpec(in *V
 func autoConvert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(in *v1beta1.VSphereClusterSpec, out *VSphereClusterSpec, s conversion.Scope) error {
 	out.Server = in.Server
 	out.Thumbprint = in.Thumbprint
+	// WARNING: in.CABundle requires manual conversion: does not exist in peer-type
 	if err := Convert_v1beta1_APIEndpoint_To_v1alpha3_APIEndpoint(&in.ControlPlaneEndpoint, &out.ControlPlaneEndpoint, s); err != nil {
 		return err
 	}
 	out.IdentityRef = (*VSphereIdentityReference)(unsafe.Pointer(in.IdentityRef))
+	// WARNING: in.FailureDomainSelection requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec is an autogenerated conversion function.
-func Convert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(in *v1beta1.VSphereClusterSpec, out *VSphereClusterSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_VSphereClusterSpec_To_v1alpha3_VSphereClusterSpec(in, out, s)
-}
-
 func autoConvert_v1alpha3_VSphereClusterStatus_To_v1beta1_VSphereClusterStatus(in *VSphereClusterStatus, out *v1beta1.VSphereClusterStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
 	out.Conditions = *(*apiv1beta1.Conditions)(unsafe.Pointer(&in.Conditions))
@@ -991,14 +1033,10 @@ func autoConvert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(i
 	out.Ready = in.Ready
 	out.Conditions = *(*apiv1alpha3.Conditions)(unsafe.Pointer(&in.Conditions))
 	out.FailureDomains = *(*apiv1alpha3.FailureDomains)(unsafe.Pointer(&in.FailureDomains))
+	// WARNING: in.ClusterModules requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus is an autogenerated conversion function.
-func Convert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(in *v1beta1.VSphereClusterStatus, out *VSphereClusterStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(in, out, s)
-}
-
 func autoConvert_v1alpha3_VSphereDeploymentZone_To_v1beta1_VSphereDeploymentZone(in *VSphereDeploymentZone, out *v1beta1.VSphereDeploymentZone, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha3_VSphereDeploymentZoneSpec_To_v1beta1_VSphereDeploymentZoneSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -1033,7 +1071,17 @@ func Convert_v1beta1_VSphereDeploymentZone_To_v1alpha3_VSphereDeploymentZone(in
 
 func autoConvert_v1alpha3_VSphereDeploymentZoneList_To_v1beta1_VSphereDeploymentZoneList(in *VSphereDeploymentZoneList, out *v1beta1.VSphereDeploymentZoneList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.VSphereDeploymentZone)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.VSphereDeploymentZone, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha3_VSphereDeploymentZone_To_v1beta1_VSphereDeploymentZone(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1044,7 +1092,17 @@ func Convert_v1alpha3_VSphereDeploymentZoneList_To_v1beta1_VSphereDeploymentZone
 
 func autoConvert_v1beta1_VSphereDeploymentZoneList_To_v1alpha3_VSphereDeploymentZoneList(in *v1beta1.VSphereDeploymentZoneList, out *VSphereDeploymentZoneList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]VSphereDeploymentZone)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereDeploymentZone, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_VSphereDeploymentZone_To_v1alpha3_VSphereDeploymentZone(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1075,14 +1133,10 @@ func autoConvert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeployment
 	if err := Convert_v1beta1_PlacementConstraint_To_v1alpha3_PlacementConstraint(&in.PlacementConstraint, &out.PlacementConstraint, s); err != nil {
 		return err
 	}
+	// WARNING: in.CapacityThreshold requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec is an autogenerated conversion function.
-func Convert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec(in *v1beta1.VSphereDeploymentZoneSpec, out *VSphereDeploymentZoneSpec, s conversion.Scope) error {
-	return autoConvert_v1beta1_VSphereDeploymentZoneSpec_To_v1alpha3_VSphereDeploymentZoneSpec(in, out, s)
-}
-
 func autoConvert_v1alpha3_VSphereDeploymentZoneStatus_To_v1beta1_VSphereDeploymentZoneStatus(in *VSphereDeploymentZoneStatus, out *v1beta1.VSphereDeploymentZoneStatus, s conversion.Scope) error {
 	out.Ready = (*bool)(unsafe.Pointer(in.Ready))
 	out.Conditions = *(*apiv1beta1.Conditions)(unsafe.Pointer(&in.Conditions))
@@ -1097,14 +1151,11 @@ func Convert_v1alpha3_VSphereDeploymentZoneStatus_To_v1beta1_VSphereDeploymentZo
 func autoConvert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(in *v1beta1.VSphereDeploymentZoneStatus, out *VSphereDeploymentZoneStatus, s conversion.Scope) error {
 	out.Ready = (*bool)(unsafe.Pointer(in.Ready))
 	out.Conditions = *(*apiv1alpha3.Conditions)(unsafe.Pointer(&in.Conditions))
+	// WARNING: in.Capacity requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostGroupPlacement requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus is an autogenerated conversion function.
-func Convert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(in *v1beta1.VSphereDeploymentZoneStatus, out *VSphereDeploymentZoneStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_VSphereDeploymentZoneStatus_To_v1alpha3_VSphereDeploymentZoneStatus(in, out, s)
-}
-
 func autoConvert_v1alpha3_VSphereFailureDomain_To_v1beta1_VSphereFailureDomain(in *VSphereFailureDomain, out *v1beta1.VSphereFailureDomain, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha3_VSphereFailureDomainSpec_To_v1beta1_VSphereFailureDomainSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -1133,7 +1184,17 @@ func Convert_v1beta1_VSphereFailureDomain_To_v1alpha3_VSphereFailureDomain(in *v
 
 func autoConvert_v1alpha3_VSphereFailureDomainList_To_v1beta1_VSphereFailureDomainList(in *VSphereFailureDomainList, out *v1beta1.VSphereFailureDomainList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]v1beta1.VSphereFailureDomain)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1beta1.VSphereFailureDomain, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha3_VSphereFailureDomain_To_v1beta1_VSphereFailureDomain(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1144,7 +1205,17 @@ func Convert_v1alpha3_VSphereFailureDomainList_To_v1beta1_VSphereFailureDomainLi
 
 func autoConvert_v1beta1_VSphereFailureDomainList_To_v1alpha3_VSphereFailureDomainList(in *v1beta1.VSphereFailureDomainList, out *VSphereFailureDomainList, s conversion.Scope) error {
 	out.ListMeta = in.ListMeta
-	out.Items = *(*[]VSphereFailureDomain)(unsafe.Pointer(&in.Items))
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereFailureDomain, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_VSphereFailureDomain_To_v1alpha3_VSphereFailureDomain(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
 	return nil
 }
 
@@ -1593,18 +1664,18 @@ func autoConvert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(in *v1beta1
 	out.Snapshot = in.Snapshot
 	out.RetryAfter = in.RetryAfter
 	out.TaskRef = in.TaskRef
+	// WARNING: in.TaskRetries requires manual conversion: does not exist in peer-type
 	out.Network = *(*[]NetworkStatus)(unsafe.Pointer(&in.Network))
 	out.FailureReason = (*errors.MachineStatusError)(unsafe.Pointer(in.FailureReason))
 	out.FailureMessage = (*string)(unsafe.Pointer(in.FailureMessage))
+	// WARNING: in.Placement requires manual conversion: does not exist in peer-type
+	// WARNING: in.Hardware requires manual conversion: does not exist in peer-type
+	// WARNING: in.DataDiskUnitNumbers requires manual conversion: does not exist in peer-type
+	// WARNING: in.ClusterModule requires manual conversion: does not exist in peer-type
 	out.Conditions = *(*apiv1alpha3.Conditions)(unsafe.Pointer(&in.Conditions))
 	return nil
 }
 
-// Convert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus is an autogenerated conversion function.
-func Convert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(in *v1beta1.VSphereVMStatus, out *VSphereVMStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_VSphereVMStatus_To_v1alpha3_VSphereVMStatus(in, out, s)
-}
-
 func autoConvert_v1alpha3_VirtualMachine_To_v1beta1_VirtualMachine(in *VirtualMachine, out *v1beta1.VirtualMachine, s conversion.Scope) error {
 	out.Name = in.Name
 	out.BiosUUID = in.BiosUUID
@@ -1667,8 +1738,10 @@ func autoConvert_v1beta1_VirtualMachineCloneSpec_To_v1alpha3_VirtualMachineClone
 	out.Datacenter = in.Datacenter
 	out.Folder = in.Folder
 	out.Datastore = in.Datastore
+	// WARNING: in.DatastoreCluster requires manual conversion: does not exist in peer-type
 	out.StoragePolicyName = in.StoragePolicyName
 	out.ResourcePool = in.ResourcePool
+	// WARNING: in.PlacementPolicy requires manual conversion: does not exist in peer-type
 	if err := Convert_v1beta1_NetworkSpec_To_v1alpha3_NetworkSpec(&in.Network, &out.Network, s); err != nil {
 		return err
 	}
@@ -1677,7 +1750,11 @@ func autoConvert_v1beta1_VirtualMachineCloneSpec_To_v1alpha3_VirtualMachineClone
 	out.MemoryMiB = in.MemoryMiB
 	out.DiskGiB = in.DiskGiB
 	// WARNING: in.AdditionalDisksGiB requires manual conversion: does not exist in peer-type
+	// WARNING: in.DataDisks requires manual conversion: does not exist in peer-type
 	out.CustomVMXKeys = *(*map[string]string)(unsafe.Pointer(&in.CustomVMXKeys))
 	// WARNING: in.TagIDs requires manual conversion: does not exist in peer-type
+	// WARNING: in.PciDevices requires manual conversion: does not exist in peer-type
+	// WARNING: in.ResizePolicy requires manual conversion: does not exist in peer-type
+	// WARNING: in.MetadataTemplate requires manual conversion: does not exist in peer-type
 	return nil
 }
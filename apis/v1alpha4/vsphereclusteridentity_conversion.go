@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha4
 
 import (
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 
 	infrav1beta1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
@@ -25,13 +26,32 @@ import (
 // ConvertTo converts this VSphereClusterIdentity to the Hub version (v1beta1).
 func (src *VSphereClusterIdentity) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*infrav1beta1.VSphereClusterIdentity)
-	return Convert_v1alpha4_VSphereClusterIdentity_To_v1beta1_VSphereClusterIdentity(src, dst, nil)
+	if err := Convert_v1alpha4_VSphereClusterIdentity_To_v1beta1_VSphereClusterIdentity(src, dst, nil); err != nil {
+		return err
+	}
+
+	// Manually restore data.
+	restored := &infrav1beta1.VSphereClusterIdentity{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	dst.Spec.CABundle = restored.Spec.CABundle
+	dst.Spec.CredentialsType = restored.Spec.CredentialsType
+	return nil
 }
 
 // ConvertFrom converts from the Hub version (v1beta1) to this VSphereClusterIdentity.
 func (dst *VSphereClusterIdentity) ConvertFrom(srcRaw conversion.Hub) error { // nolint
 	src := srcRaw.(*infrav1beta1.VSphereClusterIdentity)
-	return Convert_v1beta1_VSphereClusterIdentity_To_v1alpha4_VSphereClusterIdentity(src, dst, nil)
+	if err := Convert_v1beta1_VSphereClusterIdentity_To_v1alpha4_VSphereClusterIdentity(src, dst, nil); err != nil {
+		return err
+	}
+
+	// Preserve Hub data on down-conversion.
+	if err := utilconversion.MarshalData(src, dst); err != nil {
+		return err
+	}
+	return nil
 }
 
 // ConvertTo converts this VSphereClusterIdentityList to the Hub version (v1beta1).
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha4
 
 import (
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 
 	infrav1beta1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
@@ -25,13 +26,30 @@ import (
 // ConvertTo converts this VSphereFailureDomain to the Hub version (v1beta1).
 func (src *VSphereFailureDomain) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*infrav1beta1.VSphereFailureDomain)
-	return Convert_v1alpha4_VSphereFailureDomain_To_v1beta1_VSphereFailureDomain(src, dst, nil)
+	if err := Convert_v1alpha4_VSphereFailureDomain_To_v1beta1_VSphereFailureDomain(src, dst, nil); err != nil {
+		return err
+	}
+
+	// Manually restore data.
+	restored := &infrav1beta1.VSphereFailureDomain{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	if dst.Spec.Topology.Hosts != nil && restored.Spec.Topology.Hosts != nil {
+		dst.Spec.Topology.Hosts.AffinityRulePolicy = restored.Spec.Topology.Hosts.AffinityRulePolicy
+	}
+	return nil
 }
 
 // ConvertFrom converts from the Hub version (v1beta1) to this VSphereFailureDomain.
 func (dst *VSphereFailureDomain) ConvertFrom(srcRaw conversion.Hub) error { // nolint
 	src := srcRaw.(*infrav1beta1.VSphereFailureDomain)
-	return Convert_v1beta1_VSphereFailureDomain_To_v1alpha4_VSphereFailureDomain(src, dst, nil)
+	if err := Convert_v1beta1_VSphereFailureDomain_To_v1alpha4_VSphereFailureDomain(src, dst, nil); err != nil {
+		return err
+	}
+
+	// Preserve Hub data on down-conversion.
+	return utilconversion.MarshalData(src, dst)
 }
 
 // ConvertTo converts this VSphereFailureDomainList to the Hub version (v1beta1).
@@ -37,8 +37,7 @@ func (src *VSphereMachine) ConvertTo(dstRaw conversion.Hub) error {
 		return err
 	}
 
-	dst.Spec.AdditionalDisksGiB = restored.Spec.AdditionalDisksGiB
-	dst.Spec.TagIDs = restored.Spec.TagIDs
+	restoreVirtualMachineCloneSpec(&restored.Spec.VirtualMachineCloneSpec, &dst.Spec.VirtualMachineCloneSpec)
 
 	return nil
 }
@@ -46,7 +45,16 @@ func (src *VSphereMachine) ConvertTo(dstRaw conversion.Hub) error {
 // ConvertFrom converts from the Hub version (v1beta1) to this VSphereMachine.
 func (dst *VSphereMachine) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*infrav1beta1.VSphereMachine)
-	return Convert_v1beta1_VSphereMachine_To_v1alpha4_VSphereMachine(src, dst, nil)
+	if err := Convert_v1beta1_VSphereMachine_To_v1alpha4_VSphereMachine(src, dst, nil); err != nil {
+		return err
+	}
+
+	// Preserve Hub data on down-conversion.
+	if err := utilconversion.MarshalData(src, dst); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // ConvertTo converts this VSphereMachineList to the Hub version (v1beta1).
@@ -39,8 +39,7 @@ func (src *VSphereMachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
 		return err
 	}
-	dst.Spec.Template.Spec.TagIDs = restored.Spec.Template.Spec.TagIDs
-	dst.Spec.Template.Spec.AdditionalDisksGiB = restored.Spec.Template.Spec.AdditionalDisksGiB
+	restoreVirtualMachineCloneSpec(&restored.Spec.Template.Spec.VirtualMachineCloneSpec, &dst.Spec.Template.Spec.VirtualMachineCloneSpec)
 
 	return nil
 }
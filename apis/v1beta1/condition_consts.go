@@ -61,9 +61,19 @@ const (
 	// a static IP address.
 	WaitingForStaticIPAllocationReason = "WaitingForStaticIPAllocation"
 
+	// IPAddressClaimFailedReason (Severity=Warning) documents a VSphereVM controller detecting
+	// an error while claiming an IP address from one of the VSphereIPPools referenced by the
+	// network devices; those kind of errors are usually transient and the claim is automatically
+	// re-tried by the controller.
+	IPAddressClaimFailedReason = "IPAddressClaimFailed"
+
 	// CloningReason documents (Severity=Info) a VSphereMachine/VSphereVM currently executing the clone operation.
 	CloningReason = "Cloning"
 
+	// WaitingForCloneSlotReason (Severity=Info) documents a VSphereVM waiting for other clone, reconfigure
+	// or power-on tasks on its vSphere server or datastore to complete before starting its own.
+	WaitingForCloneSlotReason = "WaitingForCloneSlot"
+
 	// CloningFailedReason (Severity=Warning) documents a VSphereMachine/VSphereVM controller detecting
 	// an error while provisioning; those kind of errors are usually transient and failed provisioning
 	// are automatically re-tried by the controller.
@@ -91,6 +101,25 @@ const (
 	TagsAttachmentFailedReason = "TagsAttachmentFailed"
 )
 
+// Conditions and Reasons related to in-place resizes of a VSphereVM.
+
+const (
+	// VMResizedCondition documents the status of the in-place resize of a VSphereVM with the
+	// InPlace resize policy.
+	VMResizedCondition clusterv1.ConditionType = "VMResized"
+
+	// ResizingReason (Severity=Info) documents a VSphereVM currently applying a change to its hardware.
+	ResizingReason = "Resizing"
+
+	// ResizeFailedReason (Severity=Warning) documents a VSphereVM controller detecting an error while
+	// applying a change to the VM's hardware; the resize is automatically re-tried by the controller.
+	ResizeFailedReason = "ResizeFailed"
+
+	// WaitingForPowerCycleReason (Severity=Warning) documents a VSphereVM with a change to its hardware
+	// that can only be applied when the VM is powered off, while powering off the VM is not allowed.
+	WaitingForPowerCycleReason = "WaitingForPowerCycle"
+)
+
 // Conditions and Reasons related to utilizing a VSphereIdentity to make connections to a VCenter.
 // Can currently be used by VSphereCluster and VSphereVM.
 const (
@@ -162,3 +191,17 @@ const (
 	// associated to the VSphereDeploymentZone is misconfigured.
 	DatastoreNotFoundReason = "DatastoreNotFound"
 )
+
+const (
+	// CapacityAvailableCondition documents whether the deployment zone has
+	// enough free CPU, memory and storage capacity.
+	CapacityAvailableCondition clusterv1.ConditionType = "CapacityAvailable"
+
+	// InsufficientCapacityReason (Severity=Warning) documents that the free CPU, memory or storage
+	// capacity of the VSphereDeploymentZone is below its capacity threshold.
+	InsufficientCapacityReason = "InsufficientCapacity"
+
+	// CapacityUnknownReason (Severity=Warning) documents that the capacity of the
+	// VSphereDeploymentZone could not be collected.
+	CapacityUnknownReason = "CapacityUnknown"
+)
@@ -20,6 +20,7 @@ package v1beta1
 import (
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
@@ -34,6 +35,20 @@ const (
 
 	// ValueReady is the ready value for *Ready annotations.
 	ValueReady = "true"
+
+	// AnnotationAllowResizePowerCycle allows powering off a VM to apply
+	// in-place resizes which cannot be applied to the running VM. The
+	// annotation is propagated from a VSphereMachine to its VSphereVM.
+	AnnotationAllowResizePowerCycle = "vsphere.infrastructure.cluster.x-k8s.io/allow-resize-power-cycle"
+
+	// LabelMetadataTemplate marks a ConfigMap as a cloud-init metadata
+	// template. Labeled ConfigMaps are validated when they are created or
+	// updated.
+	LabelMetadataTemplate = "vsphere.infrastructure.cluster.x-k8s.io/metadata-template"
+
+	// MetadataTemplateKey is the key of a metadata template ConfigMap which
+	// holds the template.
+	MetadataTemplateKey = "template"
 )
 
 // CloneMode is the type of clone operation used to clone a VM from a template.
@@ -52,10 +67,69 @@ const (
 	LinkedClone CloneMode = "linkedClone"
 )
 
+// PlacementPolicy defines how the host and the datastore of a new virtual
+// machine are chosen.
+type PlacementPolicy string
+
+const (
+	// StaticPlacementPolicy means the virtual machine is created in the given
+	// resource pool and datastore. When only a storage policy is given, one of
+	// the compatible datastores is picked at random.
+	StaticPlacementPolicy PlacementPolicy = "Static"
+
+	// RecommendedPlacementPolicy means the host and the datastore of the
+	// virtual machine are chosen by DRS from the hosts of the resource pool's
+	// cluster and the datastores compatible with the storage policy.
+	RecommendedPlacementPolicy PlacementPolicy = "Recommended"
+)
+
+// ResizePolicy defines how changes to the hardware of an existing virtual
+// machine are applied.
+type ResizePolicy string
+
+const (
+	// RolloutResizePolicy means the hardware of an existing virtual machine
+	// cannot be changed, a new machine has to be rolled out instead.
+	RolloutResizePolicy ResizePolicy = "Rollout"
+
+	// InPlaceResizePolicy means changes to the number of CPUs, the memory and
+	// the disk sizes are applied to the existing virtual machine. Disks are
+	// grown online and CPUs and memory are hot-added when the virtual machine
+	// allows it. Any other change requires a power cycle, which is only done
+	// when the AnnotationAllowResizePowerCycle annotation is set.
+	InPlaceResizePolicy ResizePolicy = "InPlace"
+)
+
+// LibraryItemTemplatePrefix is the prefix of the templates which refer to a
+// content library item rather than to a virtual machine in the inventory.
+const LibraryItemTemplatePrefix = "library:"
+
+// CABundleSource is the source of a PEM encoded bundle of certificate
+// authorities. Exactly one of its fields must be set.
+type CABundleSource struct {
+	// Data is the PEM encoded bundle.
+	// +optional
+	Data string `json:"data,omitempty"`
+
+	// SecretRef selects the key of a Secret which contains the PEM encoded
+	// bundle.
+	// +optional
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+
+	// ConfigMapRef selects the key of a ConfigMap which contains the PEM
+	// encoded bundle.
+	// +optional
+	ConfigMapRef *corev1.ConfigMapKeySelector `json:"configMapRef,omitempty"`
+}
+
 // VirtualMachineCloneSpec is information used to clone a virtual machine.
 type VirtualMachineCloneSpec struct {
 	// Template is the name or inventory path of the template used to clone
 	// the virtual machine.
+	// On vCenter, Template may also refer to a content library item, either
+	// as "library:<item ID>" or as "library:<library name>/<item name>". OVF
+	// and VM template items are deployed from the library instead of being
+	// cloned.
 	// +kubebuilder:validation:MinLength=1
 	Template string `json:"template"`
 
@@ -104,6 +178,13 @@ type VirtualMachineCloneSpec struct {
 	// +optional
 	Datastore string `json:"datastore,omitempty"`
 
+	// DatastoreCluster is the name or inventory path of the datastore cluster
+	// in which the virtual machine is created. The datastore is chosen from
+	// the cluster's datastores by Storage DRS.
+	// Mutually exclusive with Datastore.
+	// +optional
+	DatastoreCluster string `json:"datastoreCluster,omitempty"`
+
 	// StoragePolicyName of the storage policy to use with this
 	// Virtual Machine
 	// +optional
@@ -114,6 +195,12 @@ type VirtualMachineCloneSpec struct {
 	// +optional
 	ResourcePool string `json:"resourcePool,omitempty"`
 
+	// PlacementPolicy defines how the host and the datastore of the virtual
+	// machine are chosen. Defaults to Static.
+	// +kubebuilder:validation:Enum=Static;Recommended
+	// +optional
+	PlacementPolicy PlacementPolicy `json:"placementPolicy,omitempty"`
+
 	// Network is the network configuration for this machine's VM.
 	Network NetworkSpec `json:"network"`
 
@@ -141,8 +228,15 @@ type VirtualMachineCloneSpec struct {
 	// AdditionalDisksGiB holds the sizes of additional disks of the virtual machine, in GiB
 	// Defaults to the eponymous property value in the template from which the
 	// virtual machine is cloned.
+	// Entries beyond the disks of the template create new, thin provisioned
+	// disks attached to the controller of the primary disk.
 	// +optional
 	AdditionalDisksGiB []int32 `json:"additionalDisksGiB,omitempty"`
+	// DataDisks is a list of disks which are created in addition to the disks
+	// of the template and the AdditionalDisksGiB, for example to store etcd or
+	// container runtime data on separate disks.
+	// +optional
+	DataDisks []DataDiskSpec `json:"dataDisks,omitempty"`
 	// CustomVMXKeys is a dictionary of advanced VMX options that can be set on VM
 	// Defaults to empty map
 	// +optional
@@ -151,6 +245,103 @@ type VirtualMachineCloneSpec struct {
 	// must use URN-notation instead of display names.
 	// +optional
 	TagIDs []string `json:"tagIDs,omitempty"`
+	// PciDevices is the list of PCI devices, either vGPUs or DirectPath I/O
+	// passthrough devices, used by the virtual machine.
+	// Virtual machines with PCI devices have all of their memory reserved and
+	// are always created as full clones.
+	// +optional
+	PciDevices []PCIDeviceSpec `json:"pciDevices,omitempty"`
+	// ResizePolicy defines how changes to NumCPUs, MemoryMiB, DiskGiB and
+	// AdditionalDisksGiB are applied once the virtual machine exists.
+	// Disks can only grow and disks of linked clones cannot be resized, new
+	// AdditionalDisksGiB entries are created as new disks.
+	// Defaults to Rollout, which does not allow changing these fields.
+	// +kubebuilder:validation:Enum=Rollout;InPlace
+	// +optional
+	ResizePolicy ResizePolicy `json:"resizePolicy,omitempty"`
+
+	// MetadataTemplate is a reference to a ConfigMap in the namespace of the
+	// virtual machine which holds a Go template for the cloud-init metadata
+	// under the MetadataTemplateKey key. The template replaces the built-in
+	// one and is not used for Ignition bootstrap data.
+	// See docs/metadata_templates.md for the data passed to the template.
+	// +optional
+	MetadataTemplate *corev1.LocalObjectReference `json:"metadataTemplate,omitempty"`
+}
+
+// PCIDeviceSpec defines the configuration of a virtual machine's PCI device.
+// A device is either a vGPU selected by VGPUProfile or a DirectPath I/O device
+// selected by VendorID and DeviceID.
+type PCIDeviceSpec struct {
+	// DeviceID is the device ID of a virtual machine's PCI device, in integer.
+	// Required together with VendorID when VGPUProfile is not set.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65534
+	// +optional
+	DeviceID *int32 `json:"deviceId,omitempty"`
+
+	// VendorID is the vendor ID of a virtual machine's PCI device, in integer.
+	// Required together with DeviceID when VGPUProfile is not set.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65534
+	// +optional
+	VendorID *int32 `json:"vendorId,omitempty"`
+
+	// VGPUProfile is the profile name of a virtual machine's vGPU, for
+	// example "grid_t4-4q".
+	// Mutually exclusive with DeviceID and VendorID.
+	// +optional
+	VGPUProfile string `json:"vGPUProfile,omitempty"`
+}
+
+// DiskProvisioningMode is the provisioning type of a virtual disk.
+type DiskProvisioningMode string
+
+const (
+	// ThinProvisioningMode means the disk's space is allocated and zeroed on
+	// demand.
+	ThinProvisioningMode DiskProvisioningMode = "Thin"
+
+	// ThickProvisioningMode means the disk's space is allocated when the disk
+	// is created and zeroed on first write.
+	ThickProvisioningMode DiskProvisioningMode = "Thick"
+
+	// EagerlyZeroedProvisioningMode means the disk's space is allocated and
+	// zeroed when the disk is created.
+	EagerlyZeroedProvisioningMode DiskProvisioningMode = "EagerlyZeroed"
+)
+
+// DataDiskSpec defines a disk which is created for a virtual machine.
+type DataDiskSpec struct {
+	// SizeGiB is the size of the disk, in GiB.
+	// +kubebuilder:validation:Minimum=1
+	SizeGiB int32 `json:"sizeGiB"`
+
+	// Datastore is the name or inventory path of the datastore in which the
+	// disk is created.
+	// Defaults to the datastore of the virtual machine.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// StoragePolicyName is the name of the storage policy applied to the disk.
+	// Defaults to the storage policy of the virtual machine.
+	// +optional
+	StoragePolicyName string `json:"storagePolicyName,omitempty"`
+
+	// ProvisioningMode is the provisioning type of the disk.
+	// Defaults to Thin.
+	// +kubebuilder:validation:Enum=Thin;Thick;EagerlyZeroed
+	// +optional
+	ProvisioningMode DiskProvisioningMode `json:"provisioningMode,omitempty"`
+
+	// ControllerBusNumber is the bus number of the SCSI controller to which
+	// the disk is attached. A paravirtual SCSI controller is created if the
+	// virtual machine has no SCSI controller with this bus number.
+	// Defaults to the controller of the primary disk.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3
+	// +optional
+	ControllerBusNumber *int32 `json:"controllerBusNumber,omitempty"`
 }
 
 // VSphereMachineTemplateResource describes the data needed to create a VSphereMachine from a template
@@ -278,6 +469,13 @@ type NetworkDeviceSpec struct {
 	// addresses with DNS.
 	// +optional
 	SearchDomains []string `json:"searchDomains,omitempty"`
+
+	// AddressesFromPools is a list of references to VSphereIPPool objects in
+	// the same namespace from which an IP address is claimed for this device.
+	// The claimed addresses are added to IPAddrs and the pool's gateway and
+	// nameservers are used when Gateway4, Gateway6 or Nameservers are not set.
+	// +optional
+	AddressesFromPools []corev1.TypedLocalObjectReference `json:"addressesFromPools,omitempty"`
 }
 
 // NetworkRouteSpec defines a static network route.
@@ -290,6 +488,46 @@ type NetworkRouteSpec struct {
 	Metric int32 `json:"metric"`
 }
 
+// VirtualMachinePlacementStatus describes where a virtual machine was placed
+// when it was created.
+type VirtualMachinePlacementStatus struct {
+	// Host is the name of the host chosen for the virtual machine, if the
+	// host was chosen by DRS.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Datastore is the name of the datastore in which the virtual machine
+	// was created.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+}
+
+// VirtualMachineHardwareStatus describes the hardware applied to a virtual
+// machine.
+type VirtualMachineHardwareStatus struct {
+	// NumCPUs is the number of virtual processors of the virtual machine.
+	// +optional
+	NumCPUs int32 `json:"numCPUs,omitempty"`
+
+	// NumCoresPerSocket is the number of cores among which to distribute the
+	// virtual processors of the virtual machine.
+	// +optional
+	NumCoresPerSocket int32 `json:"numCoresPerSocket,omitempty"`
+
+	// MemoryMiB is the size of the virtual machine's memory, in MiB.
+	// +optional
+	MemoryMiB int64 `json:"memoryMiB,omitempty"`
+
+	// DiskGiB is the size of the virtual machine's primary disk, in GiB.
+	// +optional
+	DiskGiB int32 `json:"diskGiB,omitempty"`
+
+	// AdditionalDisksGiB holds the sizes of the virtual machine's additional
+	// disks, in GiB.
+	// +optional
+	AdditionalDisksGiB []int32 `json:"additionalDisksGiB,omitempty"`
+}
+
 // NetworkStatus provides information about one of a VM's networks.
 type NetworkStatus struct {
 	// Connected is a flag that indicates whether this network is currently
@@ -38,6 +38,15 @@ type VSphereClusterSpec struct {
 	// +optional
 	Thumbprint string `json:"thumbprint,omitempty"`
 
+	// CABundle is the bundle of certificate authorities used to verify the
+	// certificate of the vSphere endpoint. Secrets and ConfigMaps are looked
+	// up in the namespace of the VSphereCluster.
+	// Defaults to the CABundle of the VSphereClusterIdentity, if any, or else
+	// to the system's certificate authorities.
+	// Mutually exclusive with Thumbprint.
+	// +optional
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
 	// +optional
 	ControlPlaneEndpoint APIEndpoint `json:"controlPlaneEndpoint"`
@@ -46,6 +55,66 @@ type VSphereClusterSpec struct {
 	// the identity to use when reconciling the cluster.
 	// +optional
 	IdentityRef *VSphereIdentityReference `json:"identityRef,omitempty"`
+
+	// FailureDomainSelection configures how a failure domain is selected for
+	// worker machines which are not assigned one by Cluster API.
+	// If not set, such machines are not placed in a failure domain.
+	// +optional
+	FailureDomainSelection *FailureDomainSelection `json:"failureDomainSelection,omitempty"`
+}
+
+// FailureDomainSelectionPolicy is the policy by which failure domains are
+// selected for worker machines.
+type FailureDomainSelectionPolicy string
+
+const (
+	// SpreadFailureDomainSelectionPolicy selects the failure domain with the
+	// fewest machines of the same MachineDeployment.
+	SpreadFailureDomainSelectionPolicy FailureDomainSelectionPolicy = "Spread"
+
+	// WeightedFailureDomainSelectionPolicy selects failure domains so that the
+	// machines of the same MachineDeployment are spread proportionally to the
+	// weights of the failure domains.
+	WeightedFailureDomainSelectionPolicy FailureDomainSelectionPolicy = "Weighted"
+
+	// CapacityFailureDomainSelectionPolicy selects the failure domain with the
+	// largest share of free CPU and memory capacity.
+	CapacityFailureDomainSelectionPolicy FailureDomainSelectionPolicy = "Capacity"
+)
+
+const (
+	// FailureDomainWeightAttribute is the attribute of the failure domains of
+	// a VSphereCluster with a failure domain selection holding their weight.
+	FailureDomainWeightAttribute = "weight"
+
+	// FailureDomainCapacityAvailableAttribute is the attribute of the failure
+	// domains of a VSphereCluster with a failure domain selection holding
+	// whether their VSphereDeploymentZone reports sufficient capacity.
+	FailureDomainCapacityAvailableAttribute = "capacityAvailable"
+)
+
+// FailureDomainSelection configures how failure domains are selected for
+// worker machines. Failure domains whose VSphereDeploymentZone reports
+// insufficient capacity are only selected if all failure domains do.
+type FailureDomainSelection struct {
+	// Policy is the policy by which failure domains are selected.
+	// +kubebuilder:validation:Enum=Spread;Weighted;Capacity
+	Policy FailureDomainSelectionPolicy `json:"policy"`
+
+	// Weights are the weights of the failure domains, keyed by the name of
+	// their VSphereDeploymentZone, used by the Weighted policy.
+	// Failure domains without a weight have a weight of 1, failure domains
+	// with a weight of 0 are not selected.
+	// +optional
+	Weights map[string]int32 `json:"weights,omitempty"`
+}
+
+// Weight returns the weight of the failure domain with the name.
+func (s *FailureDomainSelection) Weight(name string) int32 {
+	if weight, ok := s.Weights[name]; ok {
+		return weight
+	}
+	return 1
 }
 
 // VSphereClusterStatus defines the observed state of VSphereClusterSpec
@@ -59,6 +128,36 @@ type VSphereClusterStatus struct {
 
 	// FailureDomains is a list of failure domain objects synced from the infrastructure provider.
 	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// ClusterModules lists the vSphere cluster modules, or the DRS VM-VM
+	// anti-affinity rules used instead, which spread the VMs of the control
+	// plane and of each MachineDeployment of the cluster across hosts.
+	// +optional
+	ClusterModules []ClusterModule `json:"clusterModules,omitempty"`
+}
+
+// ClusterModule describes how the VMs of a control plane or MachineDeployment
+// are kept apart from each other on the hosts of a compute cluster.
+type ClusterModule struct {
+	// ControlPlane indicates whether the module is for the control plane.
+	ControlPlane bool `json:"controlPlane"`
+
+	// TargetObjectName is the name of the control plane or MachineDeployment
+	// whose VMs are members of the module.
+	TargetObjectName string `json:"targetObjectName"`
+
+	// ComputeCluster is the inventory path of the compute cluster the VMs
+	// are placed on.
+	ComputeCluster string `json:"computeCluster"`
+
+	// ModuleUUID is the UUID of the vSphere cluster module.
+	// +optional
+	ModuleUUID string `json:"moduleUUID,omitempty"`
+
+	// AntiAffinityRule is the name of the DRS VM-VM anti-affinity rule used
+	// when the vCenter does not support cluster modules.
+	// +optional
+	AntiAffinityRule string `json:"antiAffinityRule,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+//nolint
+func TestVSphereCluster_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+	tests := []struct {
+		name           string
+		vsphereCluster *VSphereCluster
+		wantErr        bool
+	}{
+		{
+			name:           "thumbprint",
+			vsphereCluster: createVSphereCluster("AA:BB", nil),
+			wantErr:        false,
+		},
+		{
+			name:           "CA bundle",
+			vsphereCluster: createVSphereCluster("", &CABundleSource{Data: "bundle"}),
+			wantErr:        false,
+		},
+		{
+			name:           "thumbprint and CA bundle",
+			vsphereCluster: createVSphereCluster("AA:BB", &CABundleSource{Data: "bundle"}),
+			wantErr:        true,
+		},
+		{
+			name: "CA bundle with several sources",
+			vsphereCluster: createVSphereCluster("", &CABundleSource{
+				Data:      "bundle",
+				SecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"},
+			}),
+			wantErr: true,
+		},
+		{
+			name:           "CA bundle without source",
+			vsphereCluster: createVSphereCluster("", &CABundleSource{}),
+			wantErr:        true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.vsphereCluster.ValidateCreate()
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+//nolint
+func TestVSphereClusterIdentity_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+	tests := []struct {
+		name     string
+		caBundle *CABundleSource
+		wantErr  bool
+	}{
+		{
+			name:     "no CA bundle",
+			caBundle: nil,
+			wantErr:  false,
+		},
+		{
+			name:     "CA bundle",
+			caBundle: &CABundleSource{ConfigMapRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"}},
+			wantErr:  false,
+		},
+		{
+			name:     "CA bundle without source",
+			caBundle: &CABundleSource{},
+			wantErr:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			identity := &VSphereClusterIdentity{Spec: VSphereClusterIdentitySpec{SecretName: "creds", CABundle: tc.caBundle}}
+			err := identity.ValidateCreate()
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func createVSphereCluster(thumbprint string, caBundle *CABundleSource) *VSphereCluster {
+	return &VSphereCluster{
+		Spec: VSphereClusterSpec{
+			Server:     "vcenter.example.com",
+			Thumbprint: thumbprint,
+			CABundle:   caBundle,
+		},
+	}
+}
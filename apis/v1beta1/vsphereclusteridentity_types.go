@@ -31,13 +31,46 @@ type VSphereClusterIdentitySpec struct {
 	// +kubebuilder:validation:MinLength=1
 	SecretName string `json:"secretName,omitempty"`
 
+	// CredentialsType is the type of the credentials in the Secret.
+	// Password credentials are read from the "username" and "password" keys.
+	// Certificate credentials are the PEM encoded certificate and private key
+	// of a solution user in the "tls.crt" and "tls.key" keys, which are
+	// exchanged for SAML tokens with the vCenter Security Token Service.
+	// Token credentials are a SAML bearer token in the "token" key.
+	// Defaults to Password.
+	// +kubebuilder:validation:Enum=Password;Certificate;Token
+	// +optional
+	CredentialsType CredentialsType `json:"credentialsType,omitempty"`
+
 	// AllowedNamespaces is used to identify which namespaces are allowed to use this account.
 	// Namespaces can be selected with a label selector.
 	// If this object is nil, no namespaces will be allowed
 	// +optional
 	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+
+	// CABundle is the bundle of certificate authorities used to verify the
+	// certificates of the vSphere endpoints of the clusters using this
+	// identity, unless a cluster sets its own. Secrets and ConfigMaps are
+	// looked up in the controller namespace.
+	// +optional
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
 }
 
+// CredentialsType is the type of the credentials of a VSphereClusterIdentity.
+type CredentialsType string
+
+const (
+	// PasswordCredentials authenticate with a username and password.
+	PasswordCredentials CredentialsType = "Password"
+
+	// CertificateCredentials authenticate with SAML tokens issued for a
+	// solution user certificate.
+	CertificateCredentials CredentialsType = "Certificate"
+
+	// TokenCredentials authenticate with a SAML bearer token.
+	TokenCredentials CredentialsType = "Token"
+)
+
 type VSphereClusterIdentityStatus struct {
 	// +optional
 	Ready bool `json:"ready,omitempty"`
@@ -37,7 +37,8 @@ var _ webhook.Validator = &VSphereClusterTemplate{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (r *VSphereClusterTemplate) ValidateCreate() error {
-	return nil
+	allErrs := validateTrust(r.Spec.Template.Spec, field.NewPath("spec", "template", "spec"))
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
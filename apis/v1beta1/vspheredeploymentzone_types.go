@@ -45,6 +45,15 @@ type VSphereDeploymentZoneSpec struct {
 	// PlacementConstraint encapsulates the placement constraints
 	// used within this deployment zone.
 	PlacementConstraint PlacementConstraint `json:"placementConstraint"`
+
+	// CapacityThreshold is the percentage of free CPU, memory or storage
+	// capacity of this deployment zone below which the CapacityAvailable
+	// condition is set to false.
+	// Defaults to 10.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	CapacityThreshold *int32 `json:"capacityThreshold,omitempty"`
 }
 
 // PlacementConstraint is the context information for VM placements within a failure domain
@@ -82,6 +91,71 @@ type VSphereDeploymentZoneStatus struct {
 	// Conditions defines current service state of the VSphereMachine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// Capacity is the capacity of the hosts and datastores of this
+	// deployment zone. It is collected periodically.
+	// +optional
+	Capacity *DeploymentZoneCapacity `json:"capacity,omitempty"`
+
+	// HostGroupPlacement records the DRS groups and the VM-Host affinity rule
+	// which were created for an automatically configured host group failure
+	// domain. Only these are updated and deleted with the
+	// VSphereDeploymentZone, pre-existing ones are left untouched.
+	// +optional
+	HostGroupPlacement *HostGroupPlacement `json:"hostGroupPlacement,omitempty"`
+}
+
+// HostGroupPlacement names the DRS objects of a compute cluster which were
+// created by the VSphereDeploymentZone controller.
+type HostGroupPlacement struct {
+	// ComputeCluster is the name or inventory path of the compute cluster
+	// the groups and the rule belong to.
+	ComputeCluster string `json:"computeCluster"`
+
+	// HostGroup is the name of the created host group.
+	// +optional
+	HostGroup string `json:"hostGroup,omitempty"`
+
+	// VMGroup is the name of the created VM group.
+	// +optional
+	VMGroup string `json:"vmGroup,omitempty"`
+
+	// AffinityRule is the name of the created VM-Host affinity rule.
+	// +optional
+	AffinityRule string `json:"affinityRule,omitempty"`
+}
+
+// DeploymentZoneCapacity describes the capacity of the hosts and datastores
+// of a deployment zone. Hosts in maintenance mode do not contribute to the
+// CPU and memory capacity.
+type DeploymentZoneCapacity struct {
+	// CPUTotalMHz is the total CPU capacity of the hosts in MHz.
+	CPUTotalMHz int64 `json:"cpuTotalMHz"`
+
+	// CPUFreeMHz is the unused CPU capacity of the hosts in MHz.
+	CPUFreeMHz int64 `json:"cpuFreeMHz"`
+
+	// MemoryTotalMiB is the total memory of the hosts in MiB.
+	MemoryTotalMiB int64 `json:"memoryTotalMiB"`
+
+	// MemoryFreeMiB is the unused memory of the hosts in MiB.
+	MemoryFreeMiB int64 `json:"memoryFreeMiB"`
+
+	// StorageTotalGiB is the total capacity of the datastores in GiB.
+	StorageTotalGiB int64 `json:"storageTotalGiB"`
+
+	// StorageFreeGiB is the free space of the datastores in GiB.
+	StorageFreeGiB int64 `json:"storageFreeGiB"`
+
+	// HostsInMaintenanceMode are the names of the hosts which are in
+	// maintenance mode.
+	// +optional
+	HostsInMaintenanceMode []string `json:"hostsInMaintenanceMode,omitempty"`
+
+	// LastUpdated is the time at which the capacity was collected. It is not
+	// collected again until the refresh interval has passed.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
 }
 
 // +kubebuilder:object:root=true
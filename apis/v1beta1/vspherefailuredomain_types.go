@@ -29,6 +29,18 @@ const (
 	DatacenterFailureDomain     FailureDomainType = "Datacenter"
 )
 
+type VMHostAffinityRulePolicy string
+
+const (
+	// MandatoryVMHostAffinityRule requires the VMs of the VM group to run on
+	// the hosts of the host group.
+	MandatoryVMHostAffinityRule VMHostAffinityRulePolicy = "Mandatory"
+
+	// PreferredVMHostAffinityRule prefers running the VMs of the VM group on
+	// the hosts of the host group.
+	PreferredVMHostAffinityRule VMHostAffinityRulePolicy = "Preferred"
+)
+
 // VSphereFailureDomainSpec defines the desired state of VSphereFailureDomain
 type VSphereFailureDomainSpec struct {
 
@@ -86,6 +98,13 @@ type FailureDomainHosts struct {
 
 	// HostGroupName is the name of the Host group
 	HostGroupName string `json:"hostGroupName"`
+
+	// AffinityRulePolicy is the policy of the VM-Host affinity rule between
+	// the VM group and the Host group which is created when the zone is
+	// configured automatically. Defaults to Mandatory.
+	// +kubebuilder:validation:Enum=Mandatory;Preferred
+	// +optional
+	AffinityRulePolicy VMHostAffinityRulePolicy `json:"affinityRulePolicy,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VSphereIPPoolSpec defines the desired state of VSphereIPPool.
+type VSphereIPPoolSpec struct {
+	// Addresses is a list of IP addresses that can be allocated by the pool.
+	// Each entry is either a single IP address, a range of IP addresses in the
+	// form "10.0.0.10-10.0.0.20" or a CIDR such as "10.0.0.0/28".
+	// All addresses must be of the same IP family as the gateway.
+	// +kubebuilder:validation:MinItems=1
+	Addresses []string `json:"addresses"`
+
+	// Prefix is the network prefix length used for the allocated addresses.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=128
+	Prefix int32 `json:"prefix"`
+
+	// Gateway is the gateway used by the network devices which claim an
+	// address from this pool. It is never allocated.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+
+	// Nameservers is a list of IPv4 and/or IPv6 addresses used as DNS
+	// nameservers by the network devices which claim an address from this pool.
+	// +optional
+	Nameservers []string `json:"nameservers,omitempty"`
+}
+
+// VSphereIPPoolStatus defines the observed state of VSphereIPPool.
+type VSphereIPPoolStatus struct {
+	// Allocations is the list of addresses which are currently claimed by
+	// network devices of VSphereVMs.
+	// +optional
+	Allocations []IPAddressAllocation `json:"allocations,omitempty"`
+}
+
+// IPAddressAllocation describes an address claimed from a VSphereIPPool.
+type IPAddressAllocation struct {
+	// Address is the claimed IP address.
+	Address string `json:"address"`
+
+	// VSphereVM is the name of the VSphereVM which claimed the address.
+	VSphereVM string `json:"vsphereVM"`
+
+	// DeviceIndex is the index of the network device of the VSphereVM which
+	// claimed the address.
+	DeviceIndex int32 `json:"deviceIndex"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:path=vsphereippools,scope=Namespaced,categories=cluster-api
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Prefix",type="integer",JSONPath=".spec.prefix",description="Network prefix length of the allocated addresses"
+// +kubebuilder:printcolumn:name="Gateway",type="string",JSONPath=".spec.gateway",description="Gateway of the allocated addresses"
+
+// VSphereIPPool is the Schema for the vsphereippools API.
+type VSphereIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereIPPoolSpec   `json:"spec,omitempty"`
+	Status VSphereIPPoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereIPPoolList contains a list of VSphereIPPool.
+type VSphereIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereIPPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereIPPool{}, &VSphereIPPoolList{})
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *VSphereIPPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-vsphereippool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vsphereippools,versions=v1beta1,name=validation.vsphereippool.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &VSphereIPPool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *VSphereIPPool) ValidateCreate() error {
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *VSphereIPPool) ValidateUpdate(old runtime.Object) error {
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validateSpec())
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *VSphereIPPool) ValidateDelete() error {
+	return nil
+}
+
+func (r *VSphereIPPool) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if len(r.Spec.Addresses) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("addresses"), "at least one address is required"))
+	}
+
+	var gateway net.IP
+	if r.Spec.Gateway != "" {
+		if gateway = net.ParseIP(r.Spec.Gateway); gateway == nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("gateway"), r.Spec.Gateway, "must be a valid IP address"))
+		}
+	}
+
+	maxPrefix := int32(128)
+	if gateway != nil && gateway.To4() != nil {
+		maxPrefix = 32
+	}
+	if r.Spec.Prefix < 0 || r.Spec.Prefix > maxPrefix {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("prefix"), r.Spec.Prefix, "must be a valid prefix length"))
+	}
+
+	for i, address := range r.Spec.Addresses {
+		ips, ok := parsePoolAddress(address)
+		if !ok {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("addresses").Index(i), address, "must be an IP address, an IP address range or a CIDR"))
+			continue
+		}
+		if gateway != nil && (ips[0].To4() == nil) != (gateway.To4() == nil) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("addresses").Index(i), address, "must be of the same IP family as the gateway"))
+		}
+	}
+
+	for i, nameserver := range r.Spec.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("nameservers").Index(i), nameserver, "must be a valid IP address"))
+		}
+	}
+
+	return allErrs
+}
+
+// parsePoolAddress parses an entry of VSphereIPPoolSpec.Addresses and returns
+// the first and the last IP address of the entry.
+func parsePoolAddress(address string) ([]net.IP, bool) {
+	if strings.Contains(address, "/") {
+		ip, ipNet, err := net.ParseCIDR(address)
+		if err != nil || !ip.Equal(ipNet.IP) {
+			return nil, false
+		}
+		last := make(net.IP, len(ipNet.IP))
+		for i := range ipNet.IP {
+			last[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+		}
+		return []net.IP{ipNet.IP, last}, true
+	}
+
+	if parts := strings.SplitN(address, "-", 2); len(parts) == 2 {
+		first, last := net.ParseIP(strings.TrimSpace(parts[0])), net.ParseIP(strings.TrimSpace(parts[1]))
+		if first == nil || last == nil || (first.To4() == nil) != (last.To4() == nil) || bytes.Compare(first.To16(), last.To16()) > 0 {
+			return nil, false
+		}
+		return []net.IP{first, last}, true
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, false
+	}
+	return []net.IP{ip, ip}, true
+}
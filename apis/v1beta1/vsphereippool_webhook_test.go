@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVSphereIPPool_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		pool    *VSphereIPPool
+		wantErr bool
+	}{
+		{
+			name:    "successful VSphereIPPool creation",
+			pool:    createVSphereIPPool("10.0.0.1", 24, "10.0.0.10", "10.0.0.20-10.0.0.30", "10.0.1.0/28"),
+			wantErr: false,
+		},
+		{
+			name:    "successful IPv6 VSphereIPPool creation",
+			pool:    createVSphereIPPool("fd00::1", 64, "fd00::10-fd00::20"),
+			wantErr: false,
+		},
+		{
+			name:    "no addresses",
+			pool:    createVSphereIPPool("10.0.0.1", 24),
+			wantErr: true,
+		},
+		{
+			name:    "invalid gateway",
+			pool:    createVSphereIPPool("10.0.0.256", 24, "10.0.0.10"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid IPv4 prefix",
+			pool:    createVSphereIPPool("10.0.0.1", 33, "10.0.0.10"),
+			wantErr: true,
+		},
+		{
+			name:    "reversed address range",
+			pool:    createVSphereIPPool("10.0.0.1", 24, "10.0.0.30-10.0.0.20"),
+			wantErr: true,
+		},
+		{
+			name:    "CIDR with host bits",
+			pool:    createVSphereIPPool("10.0.0.1", 24, "10.0.1.1/28"),
+			wantErr: true,
+		},
+		{
+			name:    "address of a different IP family than the gateway",
+			pool:    createVSphereIPPool("10.0.0.1", 24, "fd00::10"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.pool.ValidateCreate()
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func createVSphereIPPool(gateway string, prefix int32, addresses ...string) *VSphereIPPool {
+	return &VSphereIPPool{
+		Spec: VSphereIPPoolSpec{
+			Addresses: addresses,
+			Prefix:    prefix,
+			Gateway:   gateway,
+		},
+	}
+}
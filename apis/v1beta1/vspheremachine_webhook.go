@@ -65,6 +65,11 @@ func (m *VSphereMachine) ValidateCreate() error {
 		}
 	}
 
+	allErrs = append(allErrs, validatePCIDevices(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateAddressesFromPools(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validatePlacement(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateMetadataTemplate(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+
 	return aggregateObjErrors(m.GroupVersionKind().GroupKind(), m.Name, allErrs)
 }
 
@@ -90,6 +95,12 @@ func (m *VSphereMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereMachineSpec, "providerID")
 	delete(newVSphereMachineSpec, "providerID")
 
+	// allow the failure domain to be selected once, see FailureDomainSelection.
+	if old.(*VSphereMachine).Spec.FailureDomain == nil {
+		delete(oldVSphereMachineSpec, "failureDomain")
+		delete(newVSphereMachineSpec, "failureDomain")
+	}
+
 	newVSphereMachineNetwork := newVSphereMachineSpec["network"].(map[string]interface{})
 	oldVSphereMachineNetwork := oldVSphereMachineSpec["network"].(map[string]interface{})
 
@@ -97,6 +108,10 @@ func (m *VSphereMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereMachineNetwork, "devices")
 	delete(newVSphereMachineNetwork, "devices")
 
+	// allow changes to the hardware when resizing in-place.
+	allowInPlaceResize(m.Spec.VirtualMachineCloneSpec, newVSphereMachineSpec, oldVSphereMachineSpec)
+	allErrs = append(allErrs, validateInPlaceResize(m.Spec.VirtualMachineCloneSpec, old.(*VSphereMachine).Spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+
 	// validate that IPAddrs in updaterequest are valid.
 	spec := m.Spec
 	for i, device := range spec.Network.Devices {
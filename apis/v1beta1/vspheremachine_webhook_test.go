@@ -20,6 +20,8 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
 )
 
 var someProviderID = "vsphere://42305f0b-dad7-1d3d-5727-0eaffffffffc"
@@ -64,6 +66,76 @@ func TestVSphereMachine_ValidateCreate(t *testing.T) {
 			vsphereMachine: createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32", "192.168.0.3/32"}),
 			wantErr:        false,
 		},
+		{
+			name:           "successful VSphereMachine creation with PCI device",
+			vsphereMachine: createVSphereMachineWithPCIDevices("", PCIDeviceSpec{DeviceID: pointer.Int32(0x1EB8), VendorID: pointer.Int32(0x10DE)}),
+			wantErr:        false,
+		},
+		{
+			name:           "successful VSphereMachine creation with vGPU device",
+			vsphereMachine: createVSphereMachineWithPCIDevices(FullClone, PCIDeviceSpec{VGPUProfile: "grid_t4-4q"}),
+			wantErr:        false,
+		},
+		{
+			name:           "PCI device without vendor ID",
+			vsphereMachine: createVSphereMachineWithPCIDevices("", PCIDeviceSpec{DeviceID: pointer.Int32(0x1EB8)}),
+			wantErr:        true,
+		},
+		{
+			name:           "PCI device with out of range device ID",
+			vsphereMachine: createVSphereMachineWithPCIDevices("", PCIDeviceSpec{DeviceID: pointer.Int32(0xFFFF), VendorID: pointer.Int32(0x10DE)}),
+			wantErr:        true,
+		},
+		{
+			name:           "vGPU device with device IDs",
+			vsphereMachine: createVSphereMachineWithPCIDevices("", PCIDeviceSpec{VGPUProfile: "grid_t4-4q", DeviceID: pointer.Int32(0x1EB8), VendorID: pointer.Int32(0x10DE)}),
+			wantErr:        true,
+		},
+		{
+			name:           "PCI device with linked clone",
+			vsphereMachine: createVSphereMachineWithPCIDevices(LinkedClone, PCIDeviceSpec{VGPUProfile: "grid_t4-4q"}),
+			wantErr:        true,
+		},
+		{
+			name:           "successful VSphereMachine creation with datastore cluster",
+			vsphereMachine: createVSphereMachineWithPlacement("", "pod", RecommendedPlacementPolicy),
+			wantErr:        false,
+		},
+		{
+			name:           "datastore and datastore cluster",
+			vsphereMachine: createVSphereMachineWithPlacement("ds", "pod", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "content library template with datastore",
+			vsphereMachine: createVSphereMachineWithLibraryTemplate(createVSphereMachineWithPlacement("ds", "", StaticPlacementPolicy)),
+			wantErr:        false,
+		},
+		{
+			name:           "content library template with datastore cluster",
+			vsphereMachine: createVSphereMachineWithLibraryTemplate(createVSphereMachineWithPlacement("", "pod", "")),
+			wantErr:        true,
+		},
+		{
+			name:           "content library template with Recommended placement policy",
+			vsphereMachine: createVSphereMachineWithLibraryTemplate(createVSphereMachineWithPlacement("ds", "", RecommendedPlacementPolicy)),
+			wantErr:        true,
+		},
+		{
+			name:           "successful VSphereMachine creation with metadata template",
+			vsphereMachine: createVSphereMachineWithMetadataTemplate("metadata-template"),
+			wantErr:        false,
+		},
+		{
+			name:           "metadata template without name",
+			vsphereMachine: createVSphereMachineWithMetadataTemplate(""),
+			wantErr:        true,
+		},
+		{
+			name:           "metadata template with invalid name",
+			vsphereMachine: createVSphereMachineWithMetadataTemplate("Metadata_Template"),
+			wantErr:        true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -118,6 +190,48 @@ func TestVSphereMachine_ValidateUpdate(t *testing.T) {
 			vsphereMachine:    createVSphereMachine("bar.com", &someProviderID, "", []string{"192.168.0.1/32", "192.168.0.10/32"}),
 			wantErr:           true,
 		},
+		{
+			name:              "updating hardware cannot be done without the InPlace resize policy",
+			oldVSphereMachine: createVSphereMachineWithHardware("", 2, 20),
+			vsphereMachine:    createVSphereMachineWithHardware("", 4, 20),
+			wantErr:           true,
+		},
+		{
+			name:              "updating hardware can be done with the InPlace resize policy",
+			oldVSphereMachine: createVSphereMachineWithHardware("", 2, 20),
+			vsphereMachine:    createVSphereMachineWithHardware(InPlaceResizePolicy, 4, 40),
+			wantErr:           false,
+		},
+		{
+			name:              "failure domain can be set",
+			oldVSphereMachine: createVSphereMachineWithFailureDomain(nil),
+			vsphereMachine:    createVSphereMachineWithFailureDomain(pointer.String("zone-a")),
+			wantErr:           false,
+		},
+		{
+			name:              "updating failure domain cannot be done",
+			oldVSphereMachine: createVSphereMachineWithFailureDomain(pointer.String("zone-a")),
+			vsphereMachine:    createVSphereMachineWithFailureDomain(pointer.String("zone-b")),
+			wantErr:           true,
+		},
+		{
+			name:              "shrinking disks cannot be done with the InPlace resize policy",
+			oldVSphereMachine: createVSphereMachineWithHardware(InPlaceResizePolicy, 2, 20),
+			vsphereMachine:    createVSphereMachineWithHardware(InPlaceResizePolicy, 2, 10),
+			wantErr:           true,
+		},
+		{
+			name:              "adding disks can be done with the InPlace resize policy",
+			oldVSphereMachine: createVSphereMachineWithDisks([]int32{10}, nil),
+			vsphereMachine:    createVSphereMachineWithDisks([]int32{10, 20}, nil),
+			wantErr:           false,
+		},
+		{
+			name:              "adding disks can be done with the InPlace resize policy when data disks are set",
+			oldVSphereMachine: createVSphereMachineWithDisks([]int32{10}, []DataDiskSpec{{SizeGiB: 5}}),
+			vsphereMachine:    createVSphereMachineWithDisks([]int32{10, 20}, []DataDiskSpec{{SizeGiB: 5}}),
+			wantErr:           false,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -151,3 +265,50 @@ func createVSphereMachine(server string, providerID *string, preferredAPIServerC
 	}
 	return VSphereMachine
 }
+
+func createVSphereMachineWithPCIDevices(cloneMode CloneMode, pciDevices ...PCIDeviceSpec) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", nil)
+	vsphereMachine.Spec.CloneMode = cloneMode
+	vsphereMachine.Spec.PciDevices = pciDevices
+	return vsphereMachine
+}
+
+func createVSphereMachineWithPlacement(datastore, datastoreCluster string, placementPolicy PlacementPolicy) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", nil)
+	vsphereMachine.Spec.Datastore = datastore
+	vsphereMachine.Spec.DatastoreCluster = datastoreCluster
+	vsphereMachine.Spec.PlacementPolicy = placementPolicy
+	return vsphereMachine
+}
+
+func createVSphereMachineWithLibraryTemplate(vsphereMachine *VSphereMachine) *VSphereMachine {
+	vsphereMachine.Spec.Template = LibraryItemTemplatePrefix + "images/ubuntu"
+	return vsphereMachine
+}
+
+func createVSphereMachineWithMetadataTemplate(name string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", nil)
+	vsphereMachine.Spec.MetadataTemplate = &corev1.LocalObjectReference{Name: name}
+	return vsphereMachine
+}
+
+func createVSphereMachineWithHardware(resizePolicy ResizePolicy, numCPUs, diskGiB int32) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", nil)
+	vsphereMachine.Spec.ResizePolicy = resizePolicy
+	vsphereMachine.Spec.NumCPUs = numCPUs
+	vsphereMachine.Spec.DiskGiB = diskGiB
+	return vsphereMachine
+}
+
+func createVSphereMachineWithDisks(additionalDisksGiB []int32, dataDisks []DataDiskSpec) *VSphereMachine {
+	vsphereMachine := createVSphereMachineWithHardware(InPlaceResizePolicy, 2, 20)
+	vsphereMachine.Spec.AdditionalDisksGiB = additionalDisksGiB
+	vsphereMachine.Spec.DataDisks = dataDisks
+	return vsphereMachine
+}
+
+func createVSphereMachineWithFailureDomain(failureDomain *string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", nil)
+	vsphereMachine.Spec.FailureDomain = failureDomain
+	return vsphereMachine
+}
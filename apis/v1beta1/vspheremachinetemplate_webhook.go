@@ -54,6 +54,11 @@ func (r *VSphereMachineTemplate) ValidateCreate() error {
 		}
 	}
 
+	allErrs = append(allErrs, validatePCIDevices(spec.VirtualMachineCloneSpec, field.NewPath("spec", "template", "spec"))...)
+	allErrs = append(allErrs, validateAddressesFromPools(spec.VirtualMachineCloneSpec, field.NewPath("spec", "template", "spec"))...)
+	allErrs = append(allErrs, validatePlacement(spec.VirtualMachineCloneSpec, field.NewPath("spec", "template", "spec"))...)
+	allErrs = append(allErrs, validateMetadataTemplate(spec.VirtualMachineCloneSpec, field.NewPath("spec", "template", "spec"))...)
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
@@ -85,6 +85,12 @@ type VSphereVMStatus struct {
 	// +optional
 	TaskRef string `json:"taskRef,omitempty"`
 
+	// TaskRetries is the number of consecutive tasks related to the machine
+	// which failed with a retryable fault. It determines how long to wait
+	// before the next task is queued.
+	// +optional
+	TaskRetries int32 `json:"taskRetries,omitempty"`
+
 	// Network returns the network status for each of the machine's configured
 	// network interfaces.
 	// +optional
@@ -120,6 +126,26 @@ type VSphereVMStatus struct {
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
 
+	// Placement is the host and the datastore chosen for the VM when it was
+	// created.
+	// +optional
+	Placement *VirtualMachinePlacementStatus `json:"placement,omitempty"`
+
+	// Hardware is the hardware applied to the VM.
+	// +optional
+	Hardware *VirtualMachineHardwareStatus `json:"hardware,omitempty"`
+
+	// DataDiskUnitNumbers are the unit numbers of the DataDisks on their
+	// controllers, in the order of the DataDisks. They tell the data disks
+	// apart from the disks which are resized in-place.
+	// +optional
+	DataDiskUnitNumbers []int32 `json:"dataDiskUnitNumbers,omitempty"`
+
+	// ClusterModule is the UUID of the vSphere cluster module, or the name of
+	// the DRS anti-affinity rule, the VM was added to.
+	// +optional
+	ClusterModule string `json:"clusterModule,omitempty"`
+
 	// Conditions defines current service state of the VSphereVM.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -53,6 +53,11 @@ func (r *VSphereVM) ValidateCreate() error {
 		}
 	}
 
+	allErrs = append(allErrs, validatePCIDevices(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateAddressesFromPools(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validatePlacement(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateMetadataTemplate(spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
@@ -88,6 +93,10 @@ func (r *VSphereVM) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereVMNetwork, "devices")
 	delete(newVSphereVMNetwork, "devices")
 
+	// allow changes to the hardware when resizing in-place.
+	allowInPlaceResize(r.Spec.VirtualMachineCloneSpec, newVSphereVMSpec, oldVSphereVMSpec)
+	allErrs = append(allErrs, validateInPlaceResize(r.Spec.VirtualMachineCloneSpec, old.(*VSphereVM).Spec.VirtualMachineCloneSpec, field.NewPath("spec"))...)
+
 	if !reflect.DeepEqual(oldVSphereVMSpec, newVSphereVMSpec) {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "cannot be modified"))
 	}
@@ -17,8 +17,12 @@ limitations under the License.
 package v1beta1
 
 import (
+	"fmt"
+	"strings"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
@@ -33,3 +37,170 @@ func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorLis
 		allErrs,
 	)
 }
+
+// validatePCIDevices validates the PCI devices of a clone spec. Every device
+// must either be a vGPU or a DirectPath I/O device with valid IDs, and
+// passthrough cannot be combined with linked clones.
+func validatePCIDevices(spec VirtualMachineCloneSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(spec.PciDevices) == 0 {
+		return allErrs
+	}
+
+	if spec.CloneMode == LinkedClone {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("cloneMode"), spec.CloneMode, "linked clones are not supported with PCI devices"))
+	}
+
+	for i, device := range spec.PciDevices {
+		devicePath := fldPath.Child("pciDevices").Index(i)
+		if device.VGPUProfile != "" {
+			if device.DeviceID != nil || device.VendorID != nil {
+				allErrs = append(allErrs, field.Invalid(devicePath, device.VGPUProfile, "vGPUProfile is mutually exclusive with deviceId and vendorId"))
+			}
+			continue
+		}
+		allErrs = append(allErrs, validatePCIID(device.DeviceID, devicePath.Child("deviceId"))...)
+		allErrs = append(allErrs, validatePCIID(device.VendorID, devicePath.Child("vendorId"))...)
+	}
+	return allErrs
+}
+
+func validatePCIID(id *int32, fldPath *field.Path) field.ErrorList {
+	if id == nil {
+		return field.ErrorList{field.Required(fldPath, "must be set when vGPUProfile is not set")}
+	}
+	// 0x0000 and 0xFFFF are not valid PCI vendor or device IDs.
+	if *id <= 0 || *id >= 0xFFFF {
+		return field.ErrorList{field.Invalid(fldPath, *id, "must be between 1 and 65534")}
+	}
+	return nil
+}
+
+// validateAddressesFromPools validates the IP pool references of the network
+// devices of a clone spec. Only VSphereIPPools are supported.
+func validateAddressesFromPools(spec VirtualMachineCloneSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, device := range spec.Network.Devices {
+		for j, pool := range device.AddressesFromPools {
+			poolPath := fldPath.Child("network", "devices").Index(i).Child("addressesFromPools").Index(j)
+			if pool.APIGroup == nil || *pool.APIGroup != GroupVersion.Group {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("apiGroup"), pool.APIGroup, fmt.Sprintf("must be %s", GroupVersion.Group)))
+			}
+			if pool.Kind != "VSphereIPPool" {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("kind"), pool.Kind, "must be VSphereIPPool"))
+			}
+			if pool.Name == "" {
+				allErrs = append(allErrs, field.Required(poolPath.Child("name"), "must be set"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateCABundle validates that exactly one source of a CA bundle is set.
+func validateCABundle(source *CABundleSource, fldPath *field.Path) field.ErrorList {
+	if source == nil {
+		return nil
+	}
+	var set int
+	for _, ok := range []bool{source.Data != "", source.SecretRef != nil, source.ConfigMapRef != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return field.ErrorList{field.Invalid(fldPath, source, "exactly one of data, secretRef and configMapRef must be set")}
+	}
+	return nil
+}
+
+// validateTrust validates the settings the certificate of the vSphere
+// endpoint of a cluster is verified with. A certificate is verified either
+// against a thumbprint or against a CA bundle.
+func validateTrust(spec VSphereClusterSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Thumbprint != "" && spec.CABundle != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("caBundle"), spec.CABundle, "caBundle is mutually exclusive with thumbprint"))
+	}
+	allErrs = append(allErrs, validateCABundle(spec.CABundle, fldPath.Child("caBundle"))...)
+	return allErrs
+}
+
+// validatePlacement validates the placement of a clone spec. A virtual machine
+// can be placed either in a datastore or in a datastore cluster. Virtual
+// machines deployed from a content library item are always placed in the
+// given datastore and resource pool.
+func validatePlacement(spec VirtualMachineCloneSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Datastore != "" && spec.DatastoreCluster != "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("datastoreCluster"), spec.DatastoreCluster, "datastoreCluster is mutually exclusive with datastore"))
+	}
+	if strings.HasPrefix(spec.Template, LibraryItemTemplatePrefix) {
+		if spec.DatastoreCluster != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("datastoreCluster"), spec.DatastoreCluster, "datastoreCluster is not supported with content library templates"))
+		}
+		if spec.PlacementPolicy == RecommendedPlacementPolicy {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("placementPolicy"), spec.PlacementPolicy, "the Recommended placement policy is not supported with content library templates"))
+		}
+	}
+	return allErrs
+}
+
+// validateMetadataTemplate validates that the metadata template reference
+// names a ConfigMap.
+func validateMetadataTemplate(spec VirtualMachineCloneSpec, fldPath *field.Path) field.ErrorList {
+	if spec.MetadataTemplate == nil {
+		return nil
+	}
+	namePath := fldPath.Child("metadataTemplate", "name")
+	if spec.MetadataTemplate.Name == "" {
+		return field.ErrorList{field.Required(namePath, "a ConfigMap name is required")}
+	}
+	var allErrs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(spec.MetadataTemplate.Name) {
+		allErrs = append(allErrs, field.Invalid(namePath, spec.MetadataTemplate.Name, msg))
+	}
+	return allErrs
+}
+
+// inPlaceResizeFields are the fields of a VirtualMachineCloneSpec which can be
+// changed when the InPlace resize policy is used.
+var inPlaceResizeFields = []string{"numCPUs", "memoryMiB", "diskGiB", "additionalDisksGiB"}
+
+// allowInPlaceResize removes the resize policy and, when the new spec uses the
+// InPlace resize policy, the resizable fields from the unstructured specs so
+// they are not considered when comparing the specs.
+func allowInPlaceResize(newSpec VirtualMachineCloneSpec, newUnstructuredSpec, oldUnstructuredSpec map[string]interface{}) {
+	delete(oldUnstructuredSpec, "resizePolicy")
+	delete(newUnstructuredSpec, "resizePolicy")
+
+	if newSpec.ResizePolicy != InPlaceResizePolicy {
+		return
+	}
+	for _, f := range inPlaceResizeFields {
+		delete(oldUnstructuredSpec, f)
+		delete(newUnstructuredSpec, f)
+	}
+}
+
+// validateInPlaceResize validates that disks are not shrunk or removed by an
+// in-place resize.
+func validateInPlaceResize(newSpec, oldSpec VirtualMachineCloneSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if newSpec.ResizePolicy != InPlaceResizePolicy {
+		return allErrs
+	}
+
+	if newSpec.DiskGiB < oldSpec.DiskGiB {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("diskGiB"), newSpec.DiskGiB, "disks cannot be shrunk"))
+	}
+	if len(newSpec.AdditionalDisksGiB) < len(oldSpec.AdditionalDisksGiB) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("additionalDisksGiB"), newSpec.AdditionalDisksGiB, "disks cannot be removed"))
+	}
+	for i := range oldSpec.AdditionalDisksGiB {
+		if i < len(newSpec.AdditionalDisksGiB) && newSpec.AdditionalDisksGiB[i] < oldSpec.AdditionalDisksGiB[i] {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("additionalDisksGiB").Index(i), newSpec.AdditionalDisksGiB[i], "disks cannot be shrunk"))
+		}
+	}
+	return allErrs
+}
@@ -59,6 +59,90 @@ func (in *AllowedNamespaces) DeepCopy() *AllowedNamespaces {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CABundleSource) DeepCopyInto(out *CABundleSource) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundleSource.
+func (in *CABundleSource) DeepCopy() *CABundleSource {
+	if in == nil {
+		return nil
+	}
+	out := new(CABundleSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterModule) DeepCopyInto(out *ClusterModule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterModule.
+func (in *ClusterModule) DeepCopy() *ClusterModule {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterModule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataDiskSpec) DeepCopyInto(out *DataDiskSpec) {
+	*out = *in
+	if in.ControllerBusNumber != nil {
+		in, out := &in.ControllerBusNumber, &out.ControllerBusNumber
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataDiskSpec.
+func (in *DataDiskSpec) DeepCopy() *DataDiskSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DataDiskSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentZoneCapacity) DeepCopyInto(out *DeploymentZoneCapacity) {
+	*out = *in
+	if in.HostsInMaintenanceMode != nil {
+		in, out := &in.HostsInMaintenanceMode, &out.HostsInMaintenanceMode
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentZoneCapacity.
+func (in *DeploymentZoneCapacity) DeepCopy() *DeploymentZoneCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentZoneCapacity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FailureDomain) DeepCopyInto(out *FailureDomain) {
 	*out = *in
@@ -94,6 +178,58 @@ func (in *FailureDomainHosts) DeepCopy() *FailureDomainHosts {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailureDomainSelection) DeepCopyInto(out *FailureDomainSelection) {
+	*out = *in
+	if in.Weights != nil {
+		in, out := &in.Weights, &out.Weights
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomainSelection.
+func (in *FailureDomainSelection) DeepCopy() *FailureDomainSelection {
+	if in == nil {
+		return nil
+	}
+	out := new(FailureDomainSelection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostGroupPlacement) DeepCopyInto(out *HostGroupPlacement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostGroupPlacement.
+func (in *HostGroupPlacement) DeepCopy() *HostGroupPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(HostGroupPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAddressAllocation) DeepCopyInto(out *IPAddressAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAddressAllocation.
+func (in *IPAddressAllocation) DeepCopy() *IPAddressAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAddressAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Network) DeepCopyInto(out *Network) {
 	*out = *in
@@ -147,6 +283,13 @@ func (in *NetworkDeviceSpec) DeepCopyInto(out *NetworkDeviceSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AddressesFromPools != nil {
+		in, out := &in.AddressesFromPools, &out.AddressesFromPools
+		*out = make([]v1.TypedLocalObjectReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDeviceSpec.
@@ -221,6 +364,31 @@ func (in *NetworkStatus) DeepCopy() *NetworkStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PCIDeviceSpec) DeepCopyInto(out *PCIDeviceSpec) {
+	*out = *in
+	if in.DeviceID != nil {
+		in, out := &in.DeviceID, &out.DeviceID
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VendorID != nil {
+		in, out := &in.VendorID, &out.VendorID
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PCIDeviceSpec.
+func (in *PCIDeviceSpec) DeepCopy() *PCIDeviceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PCIDeviceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PlacementConstraint) DeepCopyInto(out *PlacementConstraint) {
 	*out = *in
@@ -380,6 +548,11 @@ func (in *VSphereClusterIdentitySpec) DeepCopyInto(out *VSphereClusterIdentitySp
 		*out = new(AllowedNamespaces)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentitySpec.
@@ -449,12 +622,22 @@ func (in *VSphereClusterList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VSphereClusterSpec) DeepCopyInto(out *VSphereClusterSpec) {
 	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		(*in).DeepCopyInto(*out)
+	}
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
 	if in.IdentityRef != nil {
 		in, out := &in.IdentityRef, &out.IdentityRef
 		*out = new(VSphereIdentityReference)
 		**out = **in
 	}
+	if in.FailureDomainSelection != nil {
+		in, out := &in.FailureDomainSelection, &out.FailureDomainSelection
+		*out = new(FailureDomainSelection)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterSpec.
@@ -484,6 +667,11 @@ func (in *VSphereClusterStatus) DeepCopyInto(out *VSphereClusterStatus) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.ClusterModules != nil {
+		in, out := &in.ClusterModules, &out.ClusterModules
+		*out = make([]ClusterModule, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterStatus.
@@ -654,6 +842,11 @@ func (in *VSphereDeploymentZoneSpec) DeepCopyInto(out *VSphereDeploymentZoneSpec
 		**out = **in
 	}
 	out.PlacementConstraint = in.PlacementConstraint
+	if in.CapacityThreshold != nil {
+		in, out := &in.CapacityThreshold, &out.CapacityThreshold
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneSpec.
@@ -681,6 +874,16 @@ func (in *VSphereDeploymentZoneStatus) DeepCopyInto(out *VSphereDeploymentZoneSt
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = new(DeploymentZoneCapacity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostGroupPlacement != nil {
+		in, out := &in.HostGroupPlacement, &out.HostGroupPlacement
+		*out = new(HostGroupPlacement)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneStatus.
@@ -769,6 +972,110 @@ func (in *VSphereFailureDomainSpec) DeepCopy() *VSphereFailureDomainSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereIPPool) DeepCopyInto(out *VSphereIPPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereIPPool.
+func (in *VSphereIPPool) DeepCopy() *VSphereIPPool {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereIPPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereIPPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereIPPoolList) DeepCopyInto(out *VSphereIPPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereIPPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereIPPoolList.
+func (in *VSphereIPPoolList) DeepCopy() *VSphereIPPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereIPPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereIPPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereIPPoolSpec) DeepCopyInto(out *VSphereIPPoolSpec) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereIPPoolSpec.
+func (in *VSphereIPPoolSpec) DeepCopy() *VSphereIPPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereIPPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereIPPoolStatus) DeepCopyInto(out *VSphereIPPoolStatus) {
+	*out = *in
+	if in.Allocations != nil {
+		in, out := &in.Allocations, &out.Allocations
+		*out = make([]IPAddressAllocation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereIPPoolStatus.
+func (in *VSphereIPPoolStatus) DeepCopy() *VSphereIPPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereIPPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VSphereIdentityReference) DeepCopyInto(out *VSphereIdentityReference) {
 	*out = *in
@@ -1110,6 +1417,21 @@ func (in *VSphereVMStatus) DeepCopyInto(out *VSphereVMStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(VirtualMachinePlacementStatus)
+		**out = **in
+	}
+	if in.Hardware != nil {
+		in, out := &in.Hardware, &out.Hardware
+		*out = new(VirtualMachineHardwareStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DataDiskUnitNumbers != nil {
+		in, out := &in.DataDiskUnitNumbers, &out.DataDiskUnitNumbers
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(apiv1beta1.Conditions, len(*in))
@@ -1160,6 +1482,13 @@ func (in *VirtualMachineCloneSpec) DeepCopyInto(out *VirtualMachineCloneSpec) {
 		*out = make([]int32, len(*in))
 		copy(*out, *in)
 	}
+	if in.DataDisks != nil {
+		in, out := &in.DataDisks, &out.DataDisks
+		*out = make([]DataDiskSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.CustomVMXKeys != nil {
 		in, out := &in.CustomVMXKeys, &out.CustomVMXKeys
 		*out = make(map[string]string, len(*in))
@@ -1172,6 +1501,18 @@ func (in *VirtualMachineCloneSpec) DeepCopyInto(out *VirtualMachineCloneSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PciDevices != nil {
+		in, out := &in.PciDevices, &out.PciDevices
+		*out = make([]PCIDeviceSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MetadataTemplate != nil {
+		in, out := &in.MetadataTemplate, &out.MetadataTemplate
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineCloneSpec.
@@ -1183,3 +1524,38 @@ func (in *VirtualMachineCloneSpec) DeepCopy() *VirtualMachineCloneSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineHardwareStatus) DeepCopyInto(out *VirtualMachineHardwareStatus) {
+	*out = *in
+	if in.AdditionalDisksGiB != nil {
+		in, out := &in.AdditionalDisksGiB, &out.AdditionalDisksGiB
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineHardwareStatus.
+func (in *VirtualMachineHardwareStatus) DeepCopy() *VirtualMachineHardwareStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineHardwareStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachinePlacementStatus) DeepCopyInto(out *VirtualMachinePlacementStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachinePlacementStatus.
+func (in *VirtualMachinePlacementStatus) DeepCopy() *VirtualMachinePlacementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachinePlacementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -35,6 +35,26 @@ type VSphereMachineVolume struct {
 	StorageClass string `json:"storageClass,omitempty"`
 }
 
+// VirtualMachineBootstrapTransport is the transport used to deliver the
+// bootstrap data to the virtual machine.
+// +kubebuilder:validation:Enum=ExtraConfig;VAppConfig;CloudInit
+type VirtualMachineBootstrapTransport string
+
+const (
+	// ExtraConfigBootstrapTransport delivers the bootstrap data in the
+	// guestinfo keys of the VM's ExtraConfig, as consumed by the cloud-init
+	// VMware datasource.
+	ExtraConfigBootstrapTransport VirtualMachineBootstrapTransport = "ExtraConfig"
+
+	// VAppConfigBootstrapTransport delivers the bootstrap data in the OVF
+	// vApp properties of the VM, as consumed by the cloud-init OVF datasource.
+	VAppConfigBootstrapTransport VirtualMachineBootstrapTransport = "VAppConfig"
+
+	// CloudInitBootstrapTransport delivers the bootstrap data to the cloud-init
+	// datasource on a CD-ROM attached to the VM.
+	CloudInitBootstrapTransport VirtualMachineBootstrapTransport = "CloudInit"
+)
+
 // VSphereMachineSpec defines the desired state of VSphereMachine
 type VSphereMachineSpec struct {
 	// ProviderID is the virtual machine's BIOS UUID formated as
@@ -64,6 +84,12 @@ type VSphereMachineSpec struct {
 	// Volumes is the set of PVCs to be created and attached to the VSphereMachine
 	// +optional
 	Volumes []VSphereMachineVolume `json:"volumes,omitempty"`
+
+	// BootstrapTransport is the transport used to deliver the bootstrap data
+	// to the underlying virtual machine. The image must support the transport.
+	// Defaults to ExtraConfig.
+	// +optional
+	BootstrapTransport VirtualMachineBootstrapTransport `json:"bootstrapTransport,omitempty"`
 }
 
 // VSphereMachineStatus defines the observed state of VSphereMachine
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (m *VSphereMachine) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(m).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-vmware-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=vmware.infrastructure.cluster.x-k8s.io,resources=vspheremachines,versions=v1beta1,name=validation.vspheremachine.vmware.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &VSphereMachine{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (m *VSphereMachine) ValidateCreate() error {
+	allErrs := validateBootstrapTransport(m.Spec.BootstrapTransport, field.NewPath("spec", "bootstrapTransport"))
+	return aggregateObjErrors(GroupVersion.WithKind("VSphereMachine").GroupKind(), m.Name, allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+//nolint:forcetypeassert
+func (m *VSphereMachine) ValidateUpdate(old runtime.Object) error {
+	fldPath := field.NewPath("spec", "bootstrapTransport")
+	allErrs := validateBootstrapTransport(m.Spec.BootstrapTransport, fldPath)
+
+	// The bootstrap data is delivered only once, when the VM is created.
+	if oldTransport := old.(*VSphereMachine).Spec.BootstrapTransport; m.Spec.BootstrapTransport != oldTransport {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cannot be modified"))
+	}
+
+	return aggregateObjErrors(GroupVersion.WithKind("VSphereMachine").GroupKind(), m.Name, allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (m *VSphereMachine) ValidateDelete() error {
+	return nil
+}
+
+func validateBootstrapTransport(transport VirtualMachineBootstrapTransport, fldPath *field.Path) field.ErrorList {
+	switch transport {
+	case "", ExtraConfigBootstrapTransport, VAppConfigBootstrapTransport, CloudInitBootstrapTransport:
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(fldPath, transport, []string{
+		string(ExtraConfigBootstrapTransport),
+		string(VAppConfigBootstrapTransport),
+		string(CloudInitBootstrapTransport),
+	})}
+}
+
+func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		gk,
+		name,
+		allErrs,
+	)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVSphereMachine_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport VirtualMachineBootstrapTransport
+		wantErr   bool
+	}{
+		{
+			name: "default bootstrap transport",
+		},
+		{
+			name:      "ExtraConfig bootstrap transport",
+			transport: ExtraConfigBootstrapTransport,
+		},
+		{
+			name:      "VAppConfig bootstrap transport",
+			transport: VAppConfigBootstrapTransport,
+		},
+		{
+			name:      "CloudInit bootstrap transport",
+			transport: CloudInitBootstrapTransport,
+		},
+		{
+			name:      "unsupported bootstrap transport",
+			transport: "Sysprep",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := createVSphereMachine(tc.transport).ValidateCreate()
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestVSphereMachine_ValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name         string
+		oldTransport VirtualMachineBootstrapTransport
+		transport    VirtualMachineBootstrapTransport
+		wantErr      bool
+	}{
+		{
+			name:         "unchanged bootstrap transport",
+			oldTransport: CloudInitBootstrapTransport,
+			transport:    CloudInitBootstrapTransport,
+		},
+		{
+			name:         "modified bootstrap transport",
+			oldTransport: ExtraConfigBootstrapTransport,
+			transport:    VAppConfigBootstrapTransport,
+			wantErr:      true,
+		},
+		{
+			name:      "bootstrap transport set after creation",
+			transport: CloudInitBootstrapTransport,
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := createVSphereMachine(tc.transport).ValidateUpdate(createVSphereMachine(tc.oldTransport))
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func createVSphereMachine(transport VirtualMachineBootstrapTransport) *VSphereMachine {
+	return &VSphereMachine{
+		Spec: VSphereMachineSpec{
+			ImageName:          "image",
+			ClassName:          "class",
+			BootstrapTransport: transport,
+		},
+	}
+}
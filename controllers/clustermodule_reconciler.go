@@ -0,0 +1,321 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
+)
+
+// vsphereMachineTemplateKind is the kind of the infrastructure templates of
+// the control planes and MachineDeployments whose VMs are spread across hosts.
+const vsphereMachineTemplateKind = "VSphereMachineTemplate"
+
+// clusterModuleTarget is a control plane or MachineDeployment whose VMs are
+// kept apart from each other by a cluster module.
+type clusterModuleTarget struct {
+	controlPlane bool
+	name         string
+	templateName string
+}
+
+// reconcileClusterModules ensures that a cluster module, or a DRS VM-VM
+// anti-affinity rule on vCenters without support for cluster modules, exists
+// for the control plane and for each MachineDeployment of the cluster and
+// removes those of targets which no longer exist.
+func (r clusterReconciler) reconcileClusterModules(ctx *context.ClusterContext) error {
+	targets, err := r.clusterModuleTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		modules []infrav1.ClusterModule
+		errs    []error
+	)
+	for _, target := range targets {
+		module, err := r.reconcileClusterModule(ctx, target)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to reconcile cluster module for %s", target.name))
+			// Keep the existing module of the target until it is reconciled.
+			if existing := findClusterModule(ctx.VSphereCluster.Status.ClusterModules, target.controlPlane, target.name); existing != nil {
+				modules = append(modules, *existing)
+			}
+			continue
+		}
+		if module != nil {
+			modules = append(modules, *module)
+		}
+	}
+
+	for _, module := range ctx.VSphereCluster.Status.ClusterModules {
+		if containsClusterModule(modules, module) {
+			continue
+		}
+		if err := r.deleteClusterModule(ctx, module); err != nil {
+			errs = append(errs, err)
+			modules = append(modules, module)
+		}
+	}
+
+	ctx.VSphereCluster.Status.ClusterModules = modules
+	return kerrors.NewAggregate(errs)
+}
+
+// deleteClusterModules deletes the cluster modules and anti-affinity rules of
+// the cluster.
+func (r clusterReconciler) deleteClusterModules(ctx *context.ClusterContext) error {
+	var (
+		modules []infrav1.ClusterModule
+		errs    []error
+	)
+	for _, module := range ctx.VSphereCluster.Status.ClusterModules {
+		if err := r.deleteClusterModule(ctx, module); err != nil {
+			errs = append(errs, err)
+			modules = append(modules, module)
+		}
+	}
+	ctx.VSphereCluster.Status.ClusterModules = modules
+	return kerrors.NewAggregate(errs)
+}
+
+func (r clusterReconciler) reconcileClusterModule(ctx *context.ClusterContext, target clusterModuleTarget) (*infrav1.ClusterModule, error) {
+	template := &infrav1.VSphereMachineTemplate{}
+	templateKey := client.ObjectKey{Namespace: ctx.VSphereCluster.Namespace, Name: target.templateName}
+	if err := r.Client.Get(ctx, templateKey, template); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereMachineTemplate %s", templateKey)
+	}
+
+	spec := template.Spec.Template.Spec
+	if spec.Server != ctx.VSphereCluster.Spec.Server {
+		ctx.Logger.V(4).Info("skipping cluster module for VMs on another server", "target", target.name, "server", spec.Server)
+		return nil, nil
+	}
+
+	authSession, err := r.getVCenterSession(ctx, spec.Datacenter)
+	if err != nil {
+		return nil, err
+	}
+	moduleCtx := &context.ClusterModuleContext{ClusterContext: ctx, Session: authSession}
+
+	computeCluster, err := getComputeCluster(moduleCtx, spec.ResourcePool)
+	if err != nil {
+		return nil, err
+	}
+	if computeCluster == "" {
+		ctx.Logger.V(4).Info("skipping cluster module for VMs outside of a compute cluster", "target", target.name)
+		return nil, nil
+	}
+
+	module := &infrav1.ClusterModule{
+		ControlPlane:     target.controlPlane,
+		TargetObjectName: target.name,
+		ComputeCluster:   computeCluster,
+	}
+
+	supported, err := cluster.IsModuleSupported(moduleCtx)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		module.AntiAffinityRule = antiAffinityRuleName(ctx, target)
+		return module, nil
+	}
+
+	if existing := findClusterModule(ctx.VSphereCluster.Status.ClusterModules, target.controlPlane, target.name); existing != nil &&
+		existing.ComputeCluster == computeCluster && existing.ModuleUUID != "" {
+		exists, err := cluster.ModuleExists(moduleCtx, existing.ModuleUUID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			module.ModuleUUID = existing.ModuleUUID
+			return module, nil
+		}
+	}
+
+	moduleUUID, err := cluster.CreateModule(moduleCtx, computeCluster)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create cluster module in compute cluster %s", computeCluster)
+	}
+	ctx.Logger.Info("created cluster module", "target", target.name, "computeCluster", computeCluster, "moduleUUID", moduleUUID)
+	module.ModuleUUID = moduleUUID
+	return module, nil
+}
+
+func (r clusterReconciler) deleteClusterModule(ctx *context.ClusterContext, module infrav1.ClusterModule) error {
+	authSession, err := r.getVCenterSession(ctx, "")
+	if err != nil {
+		return err
+	}
+	moduleCtx := &context.ClusterModuleContext{ClusterContext: ctx, Session: authSession}
+
+	if module.ModuleUUID != "" {
+		if err := cluster.DeleteModule(moduleCtx, module.ModuleUUID); err != nil {
+			return errors.Wrapf(err, "failed to delete cluster module %s", module.ModuleUUID)
+		}
+		ctx.Logger.Info("deleted cluster module", "target", module.TargetObjectName, "moduleUUID", module.ModuleUUID)
+	}
+
+	if module.AntiAffinityRule != "" {
+		task, err := cluster.DeleteAntiAffinityRule(moduleCtx, module.ComputeCluster, module.AntiAffinityRule)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete anti-affinity rule %s", module.AntiAffinityRule)
+		}
+		if task != nil {
+			if err := task.Wait(ctx); err != nil {
+				return errors.Wrapf(err, "failed to delete anti-affinity rule %s", module.AntiAffinityRule)
+			}
+		}
+		ctx.Logger.Info("deleted anti-affinity rule", "target", module.TargetObjectName, "rule", module.AntiAffinityRule)
+	}
+	return nil
+}
+
+// clusterModuleTargets returns the control plane and the MachineDeployments
+// of the cluster whose VMs are cloned from VSphereMachineTemplates. VMs which
+// are placed in failure domains are spread by those instead.
+func (r clusterReconciler) clusterModuleTargets(ctx *context.ClusterContext) ([]clusterModuleTarget, error) {
+	var targets []clusterModuleTarget
+
+	if ref := ctx.Cluster.Spec.ControlPlaneRef; ref != nil && !hasControlPlaneFailureDomains(ctx.VSphereCluster) {
+		controlPlane, err := external.Get(ctx, r.Client, ref, ctx.Cluster.Namespace)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get control plane %s %s", ref.Kind, ref.Name)
+		}
+		if err == nil {
+			kind, _, _ := unstructured.NestedString(controlPlane.Object, "spec", "machineTemplate", "infrastructureRef", "kind")
+			name, _, _ := unstructured.NestedString(controlPlane.Object, "spec", "machineTemplate", "infrastructureRef", "name")
+			if kind == vsphereMachineTemplateKind && name != "" {
+				targets = append(targets, clusterModuleTarget{controlPlane: true, name: ref.Name, templateName: name})
+			}
+		}
+	}
+
+	machineDeployments := &clusterv1.MachineDeploymentList{}
+	if err := r.Client.List(ctx, machineDeployments,
+		client.InNamespace(ctx.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list MachineDeployments of cluster %s", ctx.Cluster.Name)
+	}
+	for _, md := range machineDeployments.Items {
+		if md.Spec.Template.Spec.FailureDomain != nil {
+			continue
+		}
+		if ref := md.Spec.Template.Spec.InfrastructureRef; ref.Kind == vsphereMachineTemplateKind {
+			targets = append(targets, clusterModuleTarget{name: md.Name, templateName: ref.Name})
+		}
+	}
+	return targets, nil
+}
+
+// getComputeCluster returns the inventory path of the compute cluster owning
+// the resource pool, or an empty string if the resource pool belongs to a
+// standalone host.
+func getComputeCluster(ctx *context.ClusterModuleContext, resourcePool string) (string, error) {
+	pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, resourcePool)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get resource pool %s", resourcePool)
+	}
+	owner, err := pool.Owner(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get owner of resource pool %s", pool.InventoryPath)
+	}
+	if _, ok := owner.(*object.ClusterComputeResource); !ok {
+		return "", nil
+	}
+	ref, err := ctx.Session.Finder.ObjectReference(ctx, owner.Reference())
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get inventory path of compute cluster %s", owner.Reference().Value)
+	}
+	return ref.(*object.ClusterComputeResource).InventoryPath, nil
+}
+
+func antiAffinityRuleName(ctx *context.ClusterContext, target clusterModuleTarget) string {
+	return fmt.Sprintf("capv-%s-%s-%s", ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name, target.name)
+}
+
+func hasControlPlaneFailureDomains(vsphereCluster *infrav1.VSphereCluster) bool {
+	for _, failureDomain := range vsphereCluster.Status.FailureDomains {
+		if failureDomain.ControlPlane {
+			return true
+		}
+	}
+	return false
+}
+
+func findClusterModule(modules []infrav1.ClusterModule, controlPlane bool, name string) *infrav1.ClusterModule {
+	for i := range modules {
+		if modules[i].ControlPlane == controlPlane && modules[i].TargetObjectName == name {
+			return &modules[i]
+		}
+	}
+	return nil
+}
+
+func containsClusterModule(modules []infrav1.ClusterModule, module infrav1.ClusterModule) bool {
+	for _, m := range modules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// machineDeploymentToCluster is a handler.ToRequestsFunc which enqueues
+// requests for the VSphereCluster of a MachineDeployment to reconcile its
+// cluster modules.
+func (r clusterReconciler) machineDeploymentToCluster(o client.Object) []ctrl.Request {
+	md, ok := o.(*clusterv1.MachineDeployment)
+	if !ok {
+		r.Logger.Error(nil, fmt.Sprintf("expected a MachineDeployment but got a %T", o))
+		return nil
+	}
+
+	cluster, err := clusterutilv1.GetClusterByName(r, r.Client, md.Namespace, md.Spec.ClusterName)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Logger.Error(err, "failed to get cluster of MachineDeployment", "namespace", md.Namespace, "name", md.Name)
+		}
+		return nil
+	}
+	if cluster.Spec.InfrastructureRef == nil || cluster.Spec.InfrastructureRef.GroupVersionKind().GroupKind() != infrav1.GroupVersion.WithKind("VSphereCluster").GroupKind() {
+		return nil
+	}
+
+	return []ctrl.Request{{
+		NamespacedName: types.NamespacedName{
+			Namespace: cluster.Namespace,
+			Name:      cluster.Spec.InfrastructureRef.Name,
+		},
+	}}
+}
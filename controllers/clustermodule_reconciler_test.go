@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/cluster/simulator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestReconcileClusterModules(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.ServiceContent.About.ApiVersion = "7.0.1.0"
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Cleanup(simr.Destroy)
+
+	template := &infrav1.VSphereMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "worker"},
+		Spec: infrav1.VSphereMachineTemplateSpec{
+			Template: infrav1.VSphereMachineTemplateResource{
+				Spec: infrav1.VSphereMachineSpec{
+					VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+						Server:       simr.ServerURL().Host,
+						Datacenter:   "DC0",
+						ResourcePool: "DC0_C0/Resources",
+					},
+				},
+			},
+		},
+	}
+	machineDeployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "md-0",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name},
+		},
+		Spec: clusterv1.MachineDeploymentSpec{
+			ClusterName: fake.Clusterv1a2Name,
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					ClusterName: fake.Clusterv1a2Name,
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: infrav1.GroupVersion.String(),
+						Kind:       "VSphereMachineTemplate",
+						Name:       template.Name,
+					},
+				},
+			},
+		},
+	}
+
+	mgmtContext := fake.NewControllerManagerContext(template, machineDeployment)
+	mgmtContext.Username = simr.Username()
+	mgmtContext.Password = simr.Password()
+	mgmtContext.AllowInsecureTLS = true
+	controllerCtx := fake.NewControllerContext(mgmtContext)
+	clusterCtx := fake.NewClusterContext(controllerCtx)
+	clusterCtx.VSphereCluster.Spec.Server = simr.ServerURL().Host
+
+	reconciler := clusterReconciler{ControllerContext: controllerCtx}
+
+	authSession, err := reconciler.getVCenterSession(clusterCtx, "")
+	g.Expect(err).NotTo(HaveOccurred())
+	moduleCtx := &context.ClusterModuleContext{ClusterContext: clusterCtx, Session: authSession}
+
+	t.Run("creates a cluster module for each MachineDeployment", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(reconciler.reconcileClusterModules(clusterCtx)).To(Succeed())
+		modules := clusterCtx.VSphereCluster.Status.ClusterModules
+		g.Expect(modules).To(HaveLen(1))
+		g.Expect(modules[0].ControlPlane).To(BeFalse())
+		g.Expect(modules[0].TargetObjectName).To(Equal("md-0"))
+		g.Expect(modules[0].ComputeCluster).To(Equal("/DC0/host/DC0_C0"))
+		g.Expect(modules[0].ModuleUUID).NotTo(BeEmpty())
+
+		exists, err := cluster.ModuleExists(moduleCtx, modules[0].ModuleUUID)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(exists).To(BeTrue())
+
+		// Existing modules are kept.
+		g.Expect(reconciler.reconcileClusterModules(clusterCtx)).To(Succeed())
+		g.Expect(clusterCtx.VSphereCluster.Status.ClusterModules).To(Equal(modules))
+	})
+
+	t.Run("skips MachineDeployments in failure domains", func(t *testing.T) {
+		g := NewWithT(t)
+		moduleUUID := clusterCtx.VSphereCluster.Status.ClusterModules[0].ModuleUUID
+
+		machineDeployment.Spec.Template.Spec.FailureDomain = pointer.String("zone-a")
+		g.Expect(controllerCtx.Client.Update(controllerCtx, machineDeployment)).To(Succeed())
+
+		g.Expect(reconciler.reconcileClusterModules(clusterCtx)).To(Succeed())
+		g.Expect(clusterCtx.VSphereCluster.Status.ClusterModules).To(BeEmpty())
+
+		exists, err := cluster.ModuleExists(moduleCtx, moduleUUID)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(exists).To(BeFalse())
+
+		machineDeployment.Spec.Template.Spec.FailureDomain = nil
+		g.Expect(controllerCtx.Client.Update(controllerCtx, machineDeployment)).To(Succeed())
+	})
+
+	t.Run("falls back to anti-affinity rules", func(t *testing.T) {
+		g := NewWithT(t)
+
+		dcSession, err := reconciler.getVCenterSession(clusterCtx, "DC0")
+		g.Expect(err).NotTo(HaveOccurred())
+		dcSession.ServiceContent.About.ApiVersion = "7.0.0.0"
+		defer func() { dcSession.ServiceContent.About.ApiVersion = "7.0.1.0" }()
+
+		g.Expect(reconciler.reconcileClusterModules(clusterCtx)).To(Succeed())
+		g.Expect(clusterCtx.VSphereCluster.Status.ClusterModules).To(ConsistOf(infrav1.ClusterModule{
+			TargetObjectName: "md-0",
+			ComputeCluster:   "/DC0/host/DC0_C0",
+			AntiAffinityRule: "capv-" + fake.Namespace + "-" + fake.Clusterv1a2Name + "-md-0",
+		}))
+	})
+
+	t.Run("deletes the cluster modules", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(reconciler.reconcileClusterModules(clusterCtx)).To(Succeed())
+		modules := clusterCtx.VSphereCluster.Status.ClusterModules
+		g.Expect(modules).To(HaveLen(1))
+		g.Expect(modules[0].ModuleUUID).NotTo(BeEmpty())
+
+		g.Expect(reconciler.deleteClusterModules(clusterCtx)).To(Succeed())
+		g.Expect(clusterCtx.VSphereCluster.Status.ClusterModules).To(BeEmpty())
+
+		exists, err := cluster.ModuleExists(moduleCtx, modules[0].ModuleUUID)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(exists).To(BeFalse())
+	})
+}
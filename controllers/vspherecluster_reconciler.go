@@ -20,6 +20,7 @@ package controllers
 import (
 	goctx "context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -42,6 +43,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
@@ -165,6 +167,11 @@ func (r clusterReconciler) reconcileDelete(ctx *context.ClusterContext) (reconci
 		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
+	// Delete the cluster modules once the VMs which are their members are gone.
+	if err := r.deleteClusterModules(ctx); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to delete cluster modules for %s", ctx)
+	}
+
 	// Remove finalizer on Identity Secret
 	if identity.IsSecretIdentity(ctx.VSphereCluster) {
 		secret := &apiv1.Secret{}
@@ -230,6 +237,12 @@ func (r clusterReconciler) reconcileNormal(ctx *context.ClusterContext) (reconci
 	conditions.MarkTrue(ctx.VSphereCluster, infrav1.VCenterAvailableCondition)
 	ctx.VSphereCluster.Status.Ready = true
 
+	if feature.Gates.Enabled(feature.NodeAntiAffinity) {
+		if err := r.reconcileClusterModules(ctx); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile cluster modules for %s", ctx)
+		}
+	}
+
 	// Ensure the VSphereCluster is reconciled when the API server first comes online.
 	// A reconcile event will only be triggered if the Cluster is not marked as
 	// ControlPlaneInitialized.
@@ -292,28 +305,44 @@ func (r clusterReconciler) reconcileIdentitySecret(ctx *context.ClusterContext)
 }
 
 func (r clusterReconciler) reconcileVCenterConnectivity(ctx *context.ClusterContext) error {
+	_, err := r.getVCenterSession(ctx, "")
+	return err
+}
+
+// getVCenterSession returns a session for the vCenter of the VSphereCluster
+// with its finder scoped to the given datacenter.
+func (r clusterReconciler) getVCenterSession(ctx *context.ClusterContext, datacenter string) (*session.Session, error) {
 	params := session.NewParams().
 		WithServer(ctx.VSphereCluster.Spec.Server).
+		WithDatacenter(datacenter).
 		WithThumbprint(ctx.VSphereCluster.Spec.Thumbprint).
+		WithInsecure(r.AllowInsecureTLS).
 		WithFeatures(session.Feature{
 			KeepAliveDuration: r.KeepAliveDuration,
+			IdleTTL:           r.SessionIdleTTL,
 		})
 
+	caBundle, err := identity.GetCABundle(ctx, r.Client, ctx.VSphereCluster, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	params = params.WithCABundle(caBundle)
+
 	if ctx.VSphereCluster.Spec.IdentityRef != nil {
 		creds, err := identity.GetCredentials(ctx, r.Client, ctx.VSphereCluster, r.Namespace)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		params = params.WithUserInfo(creds.Username, creds.Password)
-		_, err = session.GetOrCreate(ctx, params)
-		return err
+		params = params.WithUserInfo(creds.Username, creds.Password).
+			WithCertificate(creds.Certificate).
+			WithToken(creds.Token)
+		return session.GetOrCreate(ctx, params)
 	}
 
 	params = params.WithUserInfo(ctx.Username, ctx.Password)
-	_, err := session.GetOrCreate(ctx,
+	return session.GetOrCreate(ctx,
 		params)
-	return err
 }
 
 func (r clusterReconciler) reconcileDeploymentZones(ctx *context.ClusterContext) (bool, error) {
@@ -329,14 +358,10 @@ func (r clusterReconciler) reconcileDeploymentZones(ctx *context.ClusterContext)
 		if zone.Spec.Server == ctx.VSphereCluster.Spec.Server {
 			if zone.Status.Ready == nil {
 				readyNotReported++
-				failureDomains[zone.Name] = clusterv1.FailureDomainSpec{
-					ControlPlane: *zone.Spec.ControlPlane,
-				}
+				failureDomains[zone.Name] = failureDomainSpec(ctx, zone)
 			} else {
 				if *zone.Status.Ready {
-					failureDomains[zone.Name] = clusterv1.FailureDomainSpec{
-						ControlPlane: *zone.Spec.ControlPlane,
-					}
+					failureDomains[zone.Name] = failureDomainSpec(ctx, zone)
 				} else {
 					notReady++
 				}
@@ -360,6 +385,24 @@ func (r clusterReconciler) reconcileDeploymentZones(ctx *context.ClusterContext)
 	return true, nil
 }
 
+// failureDomainSpec returns the failure domain of the deployment zone. If the
+// VSphereCluster has a failure domain selection, the weight and the capacity
+// of the deployment zone are exposed as attributes.
+func failureDomainSpec(ctx *context.ClusterContext, zone infrav1.VSphereDeploymentZone) clusterv1.FailureDomainSpec {
+	spec := clusterv1.FailureDomainSpec{
+		ControlPlane: *zone.Spec.ControlPlane,
+	}
+	if selection := ctx.VSphereCluster.Spec.FailureDomainSelection; selection != nil {
+		spec.Attributes = map[string]string{
+			infrav1.FailureDomainWeightAttribute: strconv.Itoa(int(selection.Weight(zone.Name))),
+		}
+		if conditions.Has(&zone, infrav1.CapacityAvailableCondition) {
+			spec.Attributes[infrav1.FailureDomainCapacityAvailableAttribute] = strconv.FormatBool(conditions.IsTrue(&zone, infrav1.CapacityAvailableCondition))
+		}
+	}
+	return spec
+}
+
 var (
 	// apiServerTriggers is used to prevent multiple goroutines for a single
 	// Cluster that poll to see if the target API server is online.
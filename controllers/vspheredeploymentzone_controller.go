@@ -195,8 +195,10 @@ func (r vsphereDeploymentZoneReconciler) reconcileNormal(ctx *context.VSphereDep
 		}
 	}
 
+	refreshAfter := r.reconcileCapacity(ctx)
+
 	ctx.VSphereDeploymentZone.Status.Ready = pointer.Bool(true)
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: refreshAfter}, nil
 }
 
 func (r vsphereDeploymentZoneReconciler) reconcilePlacementConstraint(ctx *context.VSphereDeploymentZoneContext) error {
@@ -225,8 +227,10 @@ func (r vsphereDeploymentZoneReconciler) getVCenterSession(ctx *context.VSphereD
 		WithServer(ctx.VSphereDeploymentZone.Spec.Server).
 		WithDatacenter(ctx.VSphereFailureDomain.Spec.Topology.Datacenter).
 		WithUserInfo(r.ControllerContext.Username, r.ControllerContext.Password).
+		WithInsecure(r.AllowInsecureTLS).
 		WithFeatures(session.Feature{
 			KeepAliveDuration: r.KeepAliveDuration,
+			IdleTTL:           r.SessionIdleTTL,
 		})
 
 	clusterList := &infrav1.VSphereClusterList{}
@@ -244,8 +248,16 @@ func (r vsphereDeploymentZoneReconciler) getVCenterSession(ctx *context.VSphereD
 				logger.Error(err, "error retrieving credentials from IdentityRef")
 				continue
 			}
+			caBundle, err := identity.GetCABundle(ctx, r.Client, &clust, r.Namespace)
+			if err != nil {
+				logger.Error(err, "error retrieving CA bundle")
+				continue
+			}
+			params = params.WithCABundle(caBundle)
 			logger.Info("using server credentials to create the authenticated session")
-			params = params.WithUserInfo(creds.Username, creds.Password)
+			params = params.WithUserInfo(creds.Username, creds.Password).
+				WithCertificate(creds.Certificate).
+				WithToken(creds.Token)
 			return session.GetOrCreate(r.Context,
 				params)
 		}
@@ -289,6 +301,10 @@ func (r vsphereDeploymentZoneReconciler) reconcileDelete(ctx *context.VSphereDep
 	}
 
 	if len(ctx.VSphereFailureDomain.OwnerReferences) == 0 {
+		if err := r.deleteHostGroupPlacement(ctx); err != nil {
+			return reconcile.Result{}, err
+		}
+
 		ctx.Logger.Info("deleting vsphereFailureDomain", "name", ctx.VSphereFailureDomain.Name)
 		if err := r.Client.Delete(ctx, ctx.VSphereFailureDomain); err != nil && !apierrors.IsNotFound(err) {
 			ctx.Logger.Error(err, "failed to delete related %s %s", ctx.VSphereFailureDomain.GroupVersionKind(), ctx.VSphereFailureDomain.Name)
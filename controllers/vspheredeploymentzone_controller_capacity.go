@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
+)
+
+const (
+	// defaultCapacityThreshold is the capacity threshold of deployment zones
+	// which do not specify one.
+	defaultCapacityThreshold = 10
+
+	// capacityRefreshInterval is the interval at which the capacity of a
+	// deployment zone is collected.
+	capacityRefreshInterval = 5 * time.Minute
+
+	mib = 1 << 20
+	gib = 1 << 30
+)
+
+// reconcileCapacity collects the capacity of the deployment zone and reports
+// whether its free capacity is above the capacity threshold. The capacity is
+// only collected once per capacityRefreshInterval, as updating it in the
+// status triggers another reconcile. It returns the time until the capacity
+// is to be collected again. Failing to collect the capacity does not affect
+// the readiness of the deployment zone.
+func (r vsphereDeploymentZoneReconciler) reconcileCapacity(ctx *context.VSphereDeploymentZoneContext) time.Duration {
+	capacity := ctx.VSphereDeploymentZone.Status.Capacity
+	var refreshAfter time.Duration
+	if capacity != nil && capacity.LastUpdated != nil {
+		refreshAfter = time.Until(capacity.LastUpdated.Add(capacityRefreshInterval))
+	}
+	if refreshAfter <= 0 {
+		var err error
+		capacity, err = getCapacity(ctx)
+		if err != nil {
+			ctx.Logger.Error(err, "unable to collect capacity")
+			conditions.MarkFalse(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition, infrav1.CapacityUnknownReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return capacityRefreshInterval
+		}
+		now := metav1.Now()
+		capacity.LastUpdated = &now
+		ctx.VSphereDeploymentZone.Status.Capacity = capacity
+		refreshAfter = capacityRefreshInterval
+	}
+
+	threshold := int64(pointer.Int32Deref(ctx.VSphereDeploymentZone.Spec.CapacityThreshold, defaultCapacityThreshold))
+	var insufficient []string
+	if ctx.VSphereFailureDomain.Spec.Topology.ComputeCluster != nil {
+		if belowThreshold(capacity.CPUFreeMHz, capacity.CPUTotalMHz, threshold) {
+			insufficient = append(insufficient, "CPU")
+		}
+		if belowThreshold(capacity.MemoryFreeMiB, capacity.MemoryTotalMiB, threshold) {
+			insufficient = append(insufficient, "memory")
+		}
+	}
+	if capacity.StorageTotalGiB > 0 && belowThreshold(capacity.StorageFreeGiB, capacity.StorageTotalGiB, threshold) {
+		insufficient = append(insufficient, "storage")
+	}
+
+	if len(insufficient) > 0 {
+		conditions.MarkFalse(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition, infrav1.InsufficientCapacityReason, clusterv1.ConditionSeverityWarning,
+			"free %s capacity is below %d%%", strings.Join(insufficient, ", "), threshold)
+		return refreshAfter
+	}
+	conditions.MarkTrue(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition)
+	return refreshAfter
+}
+
+// belowThreshold returns whether free is less than threshold percent of
+// total. Resources without any capacity are below every positive threshold.
+func belowThreshold(free, total, threshold int64) bool {
+	if total == 0 {
+		return threshold > 0
+	}
+	return free*100 < total*threshold
+}
+
+func getCapacity(ctx *context.VSphereDeploymentZoneContext) (*infrav1.DeploymentZoneCapacity, error) {
+	capacity := &infrav1.DeploymentZoneCapacity{}
+	topology := ctx.VSphereFailureDomain.Spec.Topology
+	pc := property.DefaultCollector(ctx.AuthSession.Client.Client)
+
+	var datastores []types.ManagedObjectReference
+	if topology.ComputeCluster != nil {
+		ccr, err := ctx.AuthSession.Finder.ClusterComputeResource(ctx, *topology.ComputeCluster)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find compute cluster %s", *topology.ComputeCluster)
+		}
+
+		var obj mo.ClusterComputeResource
+		if err := ccr.Properties(ctx, ccr.Reference(), []string{"host", "datastore"}, &obj); err != nil {
+			return nil, errors.Wrapf(err, "unable to get properties of compute cluster %s", *topology.ComputeCluster)
+		}
+		hosts := obj.Host
+		datastores = obj.Datastore
+
+		// Only the hosts of the host group are available to host group zones.
+		if ctx.VSphereFailureDomain.Spec.Zone.Type == infrav1.HostGroupFailureDomain && topology.Hosts != nil {
+			refs, err := cluster.ListHostsFromGroup(ctx, ccr, topology.Hosts.HostGroupName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to list hosts of host group %s", topology.Hosts.HostGroupName)
+			}
+			hosts = nil
+			for _, ref := range refs {
+				hosts = append(hosts, ref.Reference())
+			}
+		}
+
+		if len(hosts) > 0 {
+			var hostSystems []mo.HostSystem
+			if err := pc.Retrieve(ctx, hosts, []string{"name", "summary.hardware", "summary.quickStats", "runtime.inMaintenanceMode"}, &hostSystems); err != nil {
+				return nil, errors.Wrap(err, "unable to get properties of hosts")
+			}
+			for _, host := range hostSystems {
+				if host.Runtime.InMaintenanceMode {
+					capacity.HostsInMaintenanceMode = append(capacity.HostsInMaintenanceMode, host.Name)
+					continue
+				}
+				if hardware := host.Summary.Hardware; hardware != nil {
+					cpuTotal := int64(hardware.CpuMhz) * int64(hardware.NumCpuCores)
+					memoryTotal := hardware.MemorySize / mib
+					capacity.CPUTotalMHz += cpuTotal
+					capacity.CPUFreeMHz += cpuTotal - int64(host.Summary.QuickStats.OverallCpuUsage)
+					capacity.MemoryTotalMiB += memoryTotal
+					capacity.MemoryFreeMiB += memoryTotal - int64(host.Summary.QuickStats.OverallMemoryUsage)
+				}
+			}
+		}
+	}
+
+	if topology.Datastore != "" {
+		datastore, err := ctx.AuthSession.Finder.Datastore(ctx, topology.Datastore)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find datastore %s", topology.Datastore)
+		}
+		datastores = []types.ManagedObjectReference{datastore.Reference()}
+	}
+	if len(datastores) > 0 {
+		var dss []mo.Datastore
+		if err := pc.Retrieve(ctx, datastores, []string{"summary"}, &dss); err != nil {
+			return nil, errors.Wrap(err, "unable to get properties of datastores")
+		}
+		for _, ds := range dss {
+			capacity.StorageTotalGiB += ds.Summary.Capacity / gib
+			capacity.StorageFreeGiB += ds.Summary.FreeSpace / gib
+		}
+	}
+	return capacity, nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestVsphereDeploymentZoneReconciler_ReconcileCapacity(t *testing.T) {
+	simr, err := vcsim.NewBuilder().
+		WithOperations("cluster.group.create -cluster DC0_C0 -name group-one -host DC0_C0_H0").
+		Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	t.Cleanup(simr.Destroy)
+
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+	params := session.NewParams().
+		WithInsecure(true).
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("DC0")
+	authSession, err := session.GetOrCreate(controllerCtx, params)
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+
+	reconciler := vsphereDeploymentZoneReconciler{controllerCtx}
+	newDeploymentZoneCtx := func(zone infrav1.FailureDomain, hosts *infrav1.FailureDomainHosts) *context.VSphereDeploymentZoneContext {
+		return &context.VSphereDeploymentZoneContext{
+			ControllerContext:     controllerCtx,
+			VSphereDeploymentZone: &infrav1.VSphereDeploymentZone{},
+			VSphereFailureDomain: &infrav1.VSphereFailureDomain{
+				Spec: infrav1.VSphereFailureDomainSpec{
+					Zone: zone,
+					Topology: infrav1.Topology{
+						Datacenter:     "DC0",
+						ComputeCluster: pointer.String("DC0_C0"),
+						Hosts:          hosts,
+					},
+				},
+			},
+			Logger:      logr.Discard(),
+			AuthSession: authSession,
+		}
+	}
+
+	t.Run("for compute cluster zone", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newDeploymentZoneCtx(infrav1.FailureDomain{Type: infrav1.ComputeClusterFailureDomain}, nil)
+
+		reconciler.reconcileCapacity(ctx)
+		capacity := ctx.VSphereDeploymentZone.Status.Capacity
+		g.Expect(capacity).NotTo(BeNil())
+		g.Expect(capacity.CPUTotalMHz).To(BeNumerically(">", 0))
+		g.Expect(capacity.CPUFreeMHz).To(BeNumerically("<=", capacity.CPUTotalMHz))
+		g.Expect(capacity.MemoryTotalMiB).To(BeNumerically(">", 0))
+		g.Expect(capacity.MemoryFreeMiB).To(BeNumerically("<=", capacity.MemoryTotalMiB))
+		g.Expect(capacity.StorageTotalGiB).To(BeNumerically(">", 0))
+		g.Expect(capacity.StorageFreeGiB).To(BeNumerically("<=", capacity.StorageTotalGiB))
+		g.Expect(capacity.HostsInMaintenanceMode).To(BeEmpty())
+		g.Expect(conditions.IsTrue(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition)).To(BeTrue())
+	})
+
+	t.Run("collects the capacity once per refresh interval", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newDeploymentZoneCtx(infrav1.FailureDomain{Type: infrav1.ComputeClusterFailureDomain}, nil)
+
+		g.Expect(reconciler.reconcileCapacity(ctx)).To(Equal(capacityRefreshInterval))
+		capacity := ctx.VSphereDeploymentZone.Status.Capacity
+		g.Expect(capacity.LastUpdated).NotTo(BeNil())
+
+		// The stored capacity is kept and evaluated against the threshold
+		// until the refresh interval has passed.
+		ctx.VSphereDeploymentZone.Status.Capacity = &infrav1.DeploymentZoneCapacity{
+			CPUTotalMHz:     100,
+			MemoryTotalMiB:  100,
+			MemoryFreeMiB:   100,
+			StorageTotalGiB: 100,
+			StorageFreeGiB:  100,
+			LastUpdated:     &metav1.Time{Time: time.Now().Add(-time.Minute)},
+		}
+		refreshAfter := reconciler.reconcileCapacity(ctx)
+		g.Expect(refreshAfter).To(BeNumerically(">", 0))
+		g.Expect(refreshAfter).To(BeNumerically("<=", capacityRefreshInterval-time.Minute))
+		g.Expect(ctx.VSphereDeploymentZone.Status.Capacity.CPUTotalMHz).To(Equal(int64(100)))
+		g.Expect(conditions.GetMessage(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition)).To(Equal("free CPU capacity is below 10%"))
+
+		ctx.VSphereDeploymentZone.Status.Capacity.LastUpdated = &metav1.Time{Time: time.Now().Add(-capacityRefreshInterval)}
+		g.Expect(reconciler.reconcileCapacity(ctx)).To(Equal(capacityRefreshInterval))
+		g.Expect(ctx.VSphereDeploymentZone.Status.Capacity.CPUTotalMHz).To(Equal(capacity.CPUTotalMHz))
+		g.Expect(conditions.IsTrue(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition)).To(BeTrue())
+	})
+
+	t.Run("for host group zone with all hosts in maintenance mode", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(simr.Run("host.maintenance.enter DC0_C0_H0", gbytes.NewBuffer())).To(Succeed())
+		ctx := newDeploymentZoneCtx(infrav1.FailureDomain{Type: infrav1.HostGroupFailureDomain},
+			&infrav1.FailureDomainHosts{HostGroupName: "group-one", VMGroupName: "vm-group-one"})
+
+		reconciler.reconcileCapacity(ctx)
+		capacity := ctx.VSphereDeploymentZone.Status.Capacity
+		g.Expect(capacity).NotTo(BeNil())
+		g.Expect(capacity.CPUTotalMHz).To(BeZero())
+		g.Expect(capacity.HostsInMaintenanceMode).To(ConsistOf("DC0_C0_H0"))
+		g.Expect(conditions.IsFalse(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition)).To(BeTrue())
+		g.Expect(conditions.GetReason(ctx.VSphereDeploymentZone, infrav1.CapacityAvailableCondition)).To(Equal(infrav1.InsufficientCapacityReason))
+	})
+}
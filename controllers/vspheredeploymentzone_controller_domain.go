@@ -17,7 +17,10 @@ limitations under the License.
 package controllers
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/types"
 	apierrors "k8s.io/apimachinery/pkg/util/errors"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
@@ -171,7 +174,8 @@ func (r vsphereDeploymentZoneReconciler) createAndAttachMetadata(ctx *context.VS
 
 	logger = logger.WithValues("type", failureDomain.Type)
 	objects, err := taggable.GetObjects(ctx, failureDomain.Type)
-	if err != nil {
+	// A missing host group is created from the tagged hosts below.
+	if err != nil && failureDomain.Type != infrav1.HostGroupFailureDomain {
 		logger.V(4).Error(err, "failed to find object")
 		return err
 	}
@@ -185,5 +189,150 @@ func (r vsphereDeploymentZoneReconciler) createAndAttachMetadata(ctx *context.VS
 			errList = append(errList, errors.Wrapf(err, "failed to attach tag"))
 		}
 	}
-	return apierrors.NewAggregate(errList)
+	if len(errList) > 0 {
+		return apierrors.NewAggregate(errList)
+	}
+
+	if failureDomain.Type == infrav1.HostGroupFailureDomain {
+		return r.reconcileHostGroupPlacement(ctx, failureDomain)
+	}
+	return nil
+}
+
+// reconcileHostGroupPlacement ensures that the host group of the topology
+// consists of the hosts of the compute cluster which are tagged with the
+// failure domain's tag, that the VM group of the topology exists and that a
+// VM-Host affinity rule between both groups exists. Hosts are added to the
+// host group by tagging them.
+// Groups and rules which are created are recorded in the status of the
+// VSphereDeploymentZone; pre-existing ones are used as they are and never
+// updated.
+func (r vsphereDeploymentZoneReconciler) reconcileHostGroupPlacement(ctx *context.VSphereDeploymentZoneContext, failureDomain infrav1.FailureDomain) error {
+	topology := ctx.VSphereFailureDomain.Spec.Topology
+	logger := ctrl.LoggerFrom(ctx).WithValues("computeCluster", *topology.ComputeCluster,
+		"hostGroup", topology.Hosts.HostGroupName, "vmGroup", topology.Hosts.VMGroupName)
+
+	placement := ctx.VSphereDeploymentZone.Status.HostGroupPlacement
+	if placement == nil || placement.ComputeCluster != *topology.ComputeCluster {
+		placement = &infrav1.HostGroupPlacement{ComputeCluster: *topology.ComputeCluster}
+	}
+	hosts, err := getTaggedHosts(ctx, *topology.ComputeCluster, failureDomain)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return errors.Errorf("no hosts of compute cluster %s are tagged with %s", *topology.ComputeCluster, failureDomain.Name)
+	}
+
+	logger.V(4).Info("reconciling host group", "hosts", len(hosts))
+	created, err := cluster.ReconcileHostGroup(ctx, *topology.ComputeCluster, topology.Hosts.HostGroupName, hosts,
+		placement.HostGroup == topology.Hosts.HostGroupName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reconcile host group %s", topology.Hosts.HostGroupName)
+	}
+	if created {
+		placement.HostGroup = topology.Hosts.HostGroupName
+		ctx.VSphereDeploymentZone.Status.HostGroupPlacement = placement
+	}
+
+	logger.V(4).Info("reconciling VM group")
+	created, err = cluster.EnsureVMGroup(ctx, *topology.ComputeCluster, topology.Hosts.VMGroupName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reconcile VM group %s", topology.Hosts.VMGroupName)
+	}
+	if created {
+		placement.VMGroup = topology.Hosts.VMGroupName
+		ctx.VSphereDeploymentZone.Status.HostGroupPlacement = placement
+	}
+
+	ruleName := affinityRuleName(topology.Hosts)
+	mandatory := topology.Hosts.AffinityRulePolicy != infrav1.PreferredVMHostAffinityRule
+	logger.V(4).Info("reconciling VM-Host affinity rule", "mandatory", mandatory)
+	created, err = cluster.ReconcileAffinityRule(ctx, *topology.ComputeCluster, ruleName,
+		topology.Hosts.HostGroupName, topology.Hosts.VMGroupName, mandatory, placement.AffinityRule == ruleName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reconcile VM-Host affinity rule")
+	}
+	if created {
+		placement.AffinityRule = ruleName
+		ctx.VSphereDeploymentZone.Status.HostGroupPlacement = placement
+	}
+	return nil
+}
+
+// deleteHostGroupPlacement deletes the VM-Host affinity rule, the VM group and
+// the host group which were created for an automatically configured host group
+// failure domain, as recorded in the status of the VSphereDeploymentZone.
+func (r vsphereDeploymentZoneReconciler) deleteHostGroupPlacement(ctx *context.VSphereDeploymentZoneContext) error {
+	placement := ctx.VSphereDeploymentZone.Status.HostGroupPlacement
+	if placement == nil {
+		return nil
+	}
+
+	authSession, err := r.getVCenterSession(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create auth session")
+	}
+	ctx.AuthSession = authSession
+
+	ctx.Logger.Info("deleting VM-Host affinity rule and groups", "computeCluster", placement.ComputeCluster,
+		"rule", placement.AffinityRule, "hostGroup", placement.HostGroup, "vmGroup", placement.VMGroup)
+	if placement.AffinityRule != "" {
+		if err := cluster.DeleteAffinityRule(ctx, placement.ComputeCluster, placement.AffinityRule); err != nil {
+			return errors.Wrapf(err, "failed to delete VM-Host affinity rule %s", placement.AffinityRule)
+		}
+		placement.AffinityRule = ""
+	}
+	if placement.VMGroup != "" {
+		if err := cluster.DeleteGroup(ctx, placement.ComputeCluster, placement.VMGroup); err != nil {
+			return errors.Wrapf(err, "failed to delete VM group %s", placement.VMGroup)
+		}
+		placement.VMGroup = ""
+	}
+	if placement.HostGroup != "" {
+		if err := cluster.DeleteGroup(ctx, placement.ComputeCluster, placement.HostGroup); err != nil {
+			return errors.Wrapf(err, "failed to delete host group %s", placement.HostGroup)
+		}
+	}
+	ctx.VSphereDeploymentZone.Status.HostGroupPlacement = nil
+	return nil
+}
+
+// getTaggedHosts returns the hosts of the compute cluster which are tagged
+// with the failure domain's tag.
+func getTaggedHosts(ctx *context.VSphereDeploymentZoneContext, computeCluster string, failureDomain infrav1.FailureDomain) ([]types.ManagedObjectReference, error) {
+	ccr, err := ctx.AuthSession.Finder.ClusterComputeResource(ctx, computeCluster)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find compute cluster %s", computeCluster)
+	}
+	hosts, err := ccr.Hosts(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list hosts of compute cluster %s", computeCluster)
+	}
+
+	tag, err := ctx.AuthSession.TagManager.GetTagForCategory(ctx, failureDomain.Name, failureDomain.TagCategory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get tag %s of category %s", failureDomain.Name, failureDomain.TagCategory)
+	}
+	attached, err := ctx.AuthSession.TagManager.ListAttachedObjects(ctx, tag.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list objects tagged with %s", failureDomain.Name)
+	}
+
+	var refs []types.ManagedObjectReference
+	for _, host := range hosts {
+		for _, obj := range attached {
+			if obj.Reference() == host.Reference() {
+				refs = append(refs, host.Reference())
+				break
+			}
+		}
+	}
+	return refs, nil
+}
+
+// affinityRuleName returns the name of the VM-Host affinity rule created
+// between the VM and host groups.
+func affinityRuleName(hosts *infrav1.FailureDomainHosts) string {
+	return fmt.Sprintf("%s-%s", hosts.VMGroupName, hosts.HostGroupName)
 }
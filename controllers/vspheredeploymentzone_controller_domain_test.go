@@ -28,6 +28,7 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
 )
@@ -65,6 +66,7 @@ func ForComputeClusterZone(t *testing.T) {
 	controllerCtx := fake.NewControllerContext(mgmtContext)
 
 	params := session.NewParams().
+		WithInsecure(true).
 		WithServer(simr.ServerURL().Host).
 		WithUserInfo(simr.Username(), simr.Password()).
 		WithDatacenter("*")
@@ -146,6 +148,7 @@ func ForHostGroupZone(t *testing.T) {
 	controllerCtx := fake.NewControllerContext(mgmtContext)
 
 	params := session.NewParams().
+		WithInsecure(true).
 		WithServer(simr.ServerURL().Host).
 		WithUserInfo(simr.Username(), simr.Password()).
 		WithDatacenter("*")
@@ -218,6 +221,7 @@ func TestVsphereDeploymentZoneReconciler_Reconcile_CreateAndAttachMetadata(t *te
 
 	controllerCtx := fake.NewControllerContext(mgmtContext)
 	params := session.NewParams().
+		WithInsecure(true).
 		WithServer(simr.ServerURL().Host).
 		WithUserInfo(simr.Username(), simr.Password()).
 		WithDatacenter("*")
@@ -330,10 +334,11 @@ func TestVsphereDeploymentZoneReconciler_Reconcile_CreateAndAttachMetadata(t *te
 		}
 
 		deploymentZoneCtx := &context.VSphereDeploymentZoneContext{
-			ControllerContext:    controllerCtx,
-			VSphereFailureDomain: vsphereFailureDomain,
-			Logger:               logr.Discard(),
-			AuthSession:          authSession,
+			ControllerContext:     controllerCtx,
+			VSphereDeploymentZone: &infrav1.VSphereDeploymentZone{},
+			VSphereFailureDomain:  vsphereFailureDomain,
+			Logger:                logr.Discard(),
+			AuthSession:           authSession,
 		}
 
 		g.Expect(reconciler.createAndAttachMetadata(deploymentZoneCtx, tests[2].vsphereFailureDomainSpec.Zone)).NotTo(HaveOccurred())
@@ -342,5 +347,115 @@ func TestVsphereDeploymentZoneReconciler_Reconcile_CreateAndAttachMetadata(t *te
 		g.Expect(stdout).To(gbytes.Say("foo"))
 		g.Expect(simr.Run("tags.attached.ls bar", stdout)).To(Succeed())
 		g.Expect(stdout).To(gbytes.Say("HostSystem"))
+
+		rule, err := cluster.VerifyAffinityRule(deploymentZoneCtx, "DC0_C0", "group-one", "vm-group-one")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rule.IsMandatory()).To(BeTrue())
+	})
+
+	t.Run("create host group from tagged hosts", func(t *testing.T) {
+		g := NewWithT(t)
+		vsphereFailureDomain := &infrav1.VSphereFailureDomain{
+			Spec: infrav1.VSphereFailureDomainSpec{
+				Zone: infrav1.FailureDomain{
+					Name:          "baz",
+					Type:          infrav1.HostGroupFailureDomain,
+					TagCategory:   "foo",
+					AutoConfigure: pointer.Bool(true),
+				},
+				Topology: infrav1.Topology{
+					Datacenter:     "DC0",
+					ComputeCluster: pointer.String("DC0_C0"),
+					Hosts: &infrav1.FailureDomainHosts{
+						HostGroupName:      "group-three",
+						VMGroupName:        "vm-group-three",
+						AffinityRulePolicy: infrav1.PreferredVMHostAffinityRule,
+					},
+				},
+			},
+		}
+
+		deploymentZoneCtx := &context.VSphereDeploymentZoneContext{
+			ControllerContext:     controllerCtx,
+			VSphereDeploymentZone: &infrav1.VSphereDeploymentZone{},
+			VSphereFailureDomain:  vsphereFailureDomain,
+			Logger:                logr.Discard(),
+			AuthSession:           authSession,
+		}
+
+		// No hosts are tagged yet.
+		g.Expect(reconciler.createAndAttachMetadata(deploymentZoneCtx, vsphereFailureDomain.Spec.Zone)).To(HaveOccurred())
+
+		g.Expect(simr.Run("tags.attach -c foo baz /DC0/host/DC0_C0/DC0_C0_H2", gbytes.NewBuffer())).To(Succeed())
+		g.Expect(reconciler.createAndAttachMetadata(deploymentZoneCtx, vsphereFailureDomain.Spec.Zone)).To(Succeed())
+
+		ccr, err := authSession.Finder.ClusterComputeResource(deploymentZoneCtx, "DC0_C0")
+		g.Expect(err).NotTo(HaveOccurred())
+		hosts, err := cluster.ListHostsFromGroup(deploymentZoneCtx, ccr, "group-three")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(hosts).To(HaveLen(1))
+
+		rule, err := cluster.VerifyAffinityRule(deploymentZoneCtx, "DC0_C0", "group-three", "vm-group-three")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rule.IsMandatory()).To(BeFalse())
+
+		g.Expect(deploymentZoneCtx.VSphereDeploymentZone.Status.HostGroupPlacement).To(Equal(&infrav1.HostGroupPlacement{
+			ComputeCluster: "DC0_C0",
+			HostGroup:      "group-three",
+			VMGroup:        "vm-group-three",
+			AffinityRule:   "vm-group-three-group-three",
+		}))
+	})
+
+	t.Run("leave pre-existing host group untouched", func(t *testing.T) {
+		g := NewWithT(t)
+		vsphereFailureDomain := &infrav1.VSphereFailureDomain{
+			Spec: infrav1.VSphereFailureDomainSpec{
+				Zone: infrav1.FailureDomain{
+					Name:          "qux",
+					Type:          infrav1.HostGroupFailureDomain,
+					TagCategory:   "foo",
+					AutoConfigure: pointer.Bool(true),
+				},
+				Topology: infrav1.Topology{
+					Datacenter:     "DC0",
+					ComputeCluster: pointer.String("DC0_C0"),
+					Hosts: &infrav1.FailureDomainHosts{
+						HostGroupName: "admin-group",
+						VMGroupName:   "vm-group-four",
+					},
+				},
+			},
+		}
+
+		deploymentZoneCtx := &context.VSphereDeploymentZoneContext{
+			ControllerContext:     controllerCtx,
+			VSphereDeploymentZone: &infrav1.VSphereDeploymentZone{},
+			VSphereFailureDomain:  vsphereFailureDomain,
+			Logger:                logr.Discard(),
+			AuthSession:           authSession,
+		}
+
+		g.Expect(simr.Run("cluster.group.create -cluster DC0_C0 -name admin-group -host DC0_C0_H0", gbytes.NewBuffer())).To(Succeed())
+		g.Expect(reconciler.createAndAttachMetadata(deploymentZoneCtx, vsphereFailureDomain.Spec.Zone)).To(Succeed())
+
+		// Tagging another host does not modify a host group CAPV did not create.
+		g.Expect(simr.Run("tags.attach -c foo qux /DC0/host/DC0_C0/DC0_C0_H1", gbytes.NewBuffer())).To(Succeed())
+		g.Expect(reconciler.createAndAttachMetadata(deploymentZoneCtx, vsphereFailureDomain.Spec.Zone)).To(Succeed())
+
+		ccr, err := authSession.Finder.ClusterComputeResource(deploymentZoneCtx, "DC0_C0")
+		g.Expect(err).NotTo(HaveOccurred())
+		hosts, err := cluster.ListHostsFromGroup(deploymentZoneCtx, ccr, "admin-group")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(hosts).To(HaveLen(1))
+		host, err := authSession.Finder.HostSystem(deploymentZoneCtx, "/DC0/host/DC0_C0/DC0_C0_H0")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(hosts[0].Reference()).To(Equal(host.Reference()))
+
+		g.Expect(deploymentZoneCtx.VSphereDeploymentZone.Status.HostGroupPlacement).To(Equal(&infrav1.HostGroupPlacement{
+			ComputeCluster: "DC0_C0",
+			VMGroup:        "vm-group-four",
+			AffinityRule:   "vm-group-four-admin-group",
+		}))
 	})
 }
@@ -25,6 +25,7 @@ import (
 
 	"github.com/pkg/errors"
 	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -52,6 +53,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/vmoperator"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/tracing"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
@@ -66,6 +68,7 @@ import (
 // +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimages;virtualmachineimages/status,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=nodes;events;configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=configmaps/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // AddMachineControllerToManager adds the machine controller to the provided
 // manager.
@@ -166,6 +169,12 @@ func (r machineReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 	logger := r.Logger.WithName(req.Namespace).WithName(req.Name)
 	logger.V(3).Info("Starting Reconcile VSphereMachine")
 
+	traceCtx, span := tracing.Start(r.ControllerContext, "VSphereMachine.Reconcile",
+		attribute.String("namespace", req.Namespace),
+		attribute.String("name", req.Name))
+	defer func() { tracing.End(span, reterr) }()
+	controllerContext := r.ControllerContext.WithContext(traceCtx)
+
 	// Fetch VSphereMachine object and populate the machine context
 	machineContext, err := r.VMService.FetchVSphereMachine(r.Client, req.NamespacedName)
 	if err != nil {
@@ -197,7 +206,7 @@ func (r machineReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 			machineContext.GetObjectMeta().Name)
 	}
 	machineContext.SetBaseMachineContext(&context.BaseMachineContext{
-		ControllerContext: r.ControllerContext,
+		ControllerContext: controllerContext,
 		Cluster:           cluster,
 		Machine:           machine,
 		Logger:            r.Logger.WithName(req.Namespace).WithName(req.Name),
@@ -231,7 +240,7 @@ func (r machineReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 	}
 
 	// Fetch the VSphereCluster and update the machine context
-	machineContext, err = r.VMService.FetchVSphereCluster(r.Client, cluster, r.ControllerContext, machineContext)
+	machineContext, err = r.VMService.FetchVSphereCluster(r.Client, cluster, controllerContext, machineContext)
 	if err != nil {
 		r.Logger.Info("unable to retrieve VSphereCluster", "error", err)
 		return reconcile.Result{}, nil
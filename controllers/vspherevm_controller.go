@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -43,18 +44,28 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/ipam"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/tracing"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
+// operationSlotRequeueTime is the time after which a VSphereVM which waits for
+// a slot to start a clone, reconfigure or power-on task is reconciled again.
+const operationSlotRequeueTime = time.Minute
+
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevms,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevms/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereippools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereippools/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 
 // AddVMControllerToManager adds the VM controller to the provided manager.
 //nolint:forcetypeassert
@@ -122,6 +133,11 @@ type vmReconciler struct {
 
 // Reconcile ensures the back-end state reflects the Kubernetes resource state intent.
 func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	traceCtx, span := tracing.Start(r.ControllerContext, "VSphereVM.Reconcile",
+		attribute.String("namespace", req.Namespace),
+		attribute.String("name", req.Name))
+	defer func() { tracing.End(span, reterr) }()
+
 	// Get the VSphereVM resource for this request.
 	vsphereVM := &infrav1.VSphereVM{}
 	if err := r.Client.Get(r, req.NamespacedName, vsphereVM); err != nil {
@@ -143,7 +159,7 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 			vsphereVM.Name)
 	}
 
-	authSession, err := r.retrieveVcenterSession(ctx, vsphereVM)
+	authSession, err := r.retrieveVcenterSession(traceCtx, vsphereVM)
 	if err != nil {
 		conditions.MarkFalse(vsphereVM, infrav1.VCenterAvailableCondition, infrav1.VCenterUnreachableReason, clusterv1.ConditionSeverityError, err.Error())
 		return reconcile.Result{}, err
@@ -184,11 +200,22 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 		}
 	}
 
+	// Machines which are not placed in a failure domain are kept apart from
+	// the other machines of their control plane or MachineDeployment.
+	var clusterModule *infrav1.ClusterModule
+	if feature.Gates.Enabled(feature.NodeAntiAffinity) && machine.Spec.FailureDomain == nil && vsphereVM.DeletionTimestamp.IsZero() {
+		clusterModule, err = r.fetchClusterModule(machine)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	// Create the VM context for this request.
 	vmContext := &context.VMContext{
-		ControllerContext:    r.ControllerContext,
+		ControllerContext:    r.ControllerContext.WithContext(traceCtx),
 		VSphereVM:            vsphereVM,
 		VSphereFailureDomain: vsphereFailureDomain,
+		ClusterModule:        clusterModule,
 		Session:              authSession,
 		Logger:               r.Logger.WithName(req.Namespace).WithName(req.Name),
 		PatchHelper:          patchHelper,
@@ -242,6 +269,39 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 	return r.reconcileNormal(vmContext)
 }
 
+// fetchClusterModule returns the cluster module of the control plane or
+// MachineDeployment of the machine as recorded by the VSphereCluster, or nil if
+// there is none yet.
+func (r vmReconciler) fetchClusterModule(machine *clusterv1.Machine) (*infrav1.ClusterModule, error) {
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, machine.ObjectMeta)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get cluster of machine %s/%s", machine.Namespace, machine.Name)
+	}
+	if cluster.Spec.InfrastructureRef == nil || cluster.Spec.InfrastructureRef.Kind != "VSphereCluster" {
+		return nil, nil
+	}
+
+	vsphereCluster := &infrav1.VSphereCluster{}
+	vsphereClusterKey := apitypes.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Client.Get(r, vsphereClusterKey, vsphereCluster); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereCluster %s", vsphereClusterKey)
+	}
+
+	controlPlane := clusterutilv1.IsControlPlaneMachine(machine)
+	var targetName string
+	switch {
+	case controlPlane && cluster.Spec.ControlPlaneRef != nil:
+		targetName = cluster.Spec.ControlPlaneRef.Name
+	case !controlPlane:
+		targetName = machine.Labels[clusterv1.MachineDeploymentLabelName]
+	}
+	if targetName == "" {
+		return nil, nil
+	}
+
+	return findClusterModule(vsphereCluster.Status.ClusterModules, controlPlane, targetName), nil
+}
+
 func (r vmReconciler) reconcileDelete(ctx *context.VMContext) (reconcile.Result, error) {
 	ctx.Logger.Info("Handling deleted VSphereVM")
 
@@ -261,6 +321,12 @@ func (r vmReconciler) reconcileDelete(ctx *context.VMContext) (reconcile.Result,
 		return reconcile.Result{}, nil
 	}
 
+	// The VM is deleted so release its IP addresses.
+	var ipAddressService services.IPAddressService = &ipam.PoolService{}
+	if err := ipAddressService.ReleaseIPAddresses(ctx); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to release IP addresses")
+	}
+
 	// The VM is deleted so remove the finalizer.
 	ctrlutil.RemoveFinalizer(ctx.VSphereVM, infrav1.VMFinalizer)
 
@@ -277,6 +343,13 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 
 	// TODO(akutz) Implement selection of VM service based on vSphere version
 	var vmService services.VirtualMachineService = &govmomi.VMService{}
+	var ipAddressService services.IPAddressService = &ipam.PoolService{}
+
+	// Claim the IP addresses of the devices which reference IP pools.
+	if err := ipAddressService.ReconcileIPAddresses(ctx); err != nil {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.IPAddressClaimFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to claim IP addresses")
+	}
 
 	if r.isWaitingForStaticIPAllocation(ctx) {
 		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.WaitingForStaticIPAllocationReason, clusterv1.ConditionSeverityInfo, "")
@@ -290,12 +363,17 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 		return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile VM")
 	}
 
-	// Do not proceed until the backend VM is marked ready.
+	// Do not proceed until the backend VM is marked ready. A VM which waits
+	// for an operation slot is woken once one is released, and is requeued
+	// in case the wake-up is missed.
 	if vm.State != infrav1.VirtualMachineStateReady {
 		ctx.Logger.Info(
 			"VM state is not reconciled",
 			"expected-vm-state", infrav1.VirtualMachineStateReady,
 			"actual-vm-state", vm.State)
+		if govmomi.WaitingForOperationSlot(ctx) {
+			return reconcile.Result{RequeueAfter: operationSlotRequeueTime}, nil
+		}
 		return reconcile.Result{}, nil
 	}
 
@@ -318,6 +396,9 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 	}
 
 	// Once the network is online the VM is considered ready.
+	if !ctx.VSphereVM.Status.Ready {
+		govmomi.ObserveVSphereVMReady(ctx)
+	}
 	ctx.VSphereVM.Status.Ready = true
 	conditions.MarkTrue(ctx.VSphereVM, infrav1.VMProvisionedCondition)
 	ctx.Logger.Info("VSphereVM is ready")
@@ -381,8 +462,10 @@ func (r *vmReconciler) retrieveVcenterSession(ctx goctx.Context, vsphereVM *infr
 		WithDatacenter(vsphereVM.Spec.Datacenter).
 		WithUserInfo(r.ControllerContext.Username, r.ControllerContext.Password).
 		WithThumbprint(vsphereVM.Spec.Thumbprint).
+		WithInsecure(r.AllowInsecureTLS).
 		WithFeatures(session.Feature{
 			KeepAliveDuration: r.KeepAliveDuration,
+			IdleTTL:           r.SessionIdleTTL,
 		})
 	cluster, err := clusterutilv1.GetClusterFromMetadata(r.ControllerContext, r.Client, vsphereVM.ObjectMeta)
 	if err != nil {
@@ -403,12 +486,20 @@ func (r *vmReconciler) retrieveVcenterSession(ctx goctx.Context, vsphereVM *infr
 			params)
 	}
 
+	caBundle, err := identity.GetCABundle(ctx, r.Client, vsphereCluster, r.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve CA bundle")
+	}
+	params = params.WithCABundle(caBundle)
+
 	if vsphereCluster.Spec.IdentityRef != nil {
 		creds, err := identity.GetCredentials(ctx, r.Client, vsphereCluster, r.Namespace)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to retrieve credentials from IdentityRef")
 		}
-		params = params.WithUserInfo(creds.Username, creds.Password)
+		params = params.WithUserInfo(creds.Username, creds.Password).
+			WithCertificate(creds.Certificate).
+			WithToken(creds.Token)
 		return session.GetOrCreate(r.Context,
 			params)
 	}
@@ -122,6 +122,7 @@ func TestReconcileNormal_WaitingForIPAddrAllocation(t *testing.T) {
 	password, _ := simr.ServerURL().User.Password()
 	controllerMgrContext.Password = password
 	controllerMgrContext.Username = simr.ServerURL().User.Username()
+	controllerMgrContext.AllowInsecureTLS = true
 
 	controllerContext := &context.ControllerContext{
 		ControllerManagerContext: controllerMgrContext,
@@ -301,6 +302,7 @@ func TestRetrievingVCenterCredentialsFromCluster(t *testing.T) {
 	}
 
 	controllerMgrContext := fake.NewControllerManagerContext(secret, vSphereVM, vsphereMachine, machine, cluster, vsphereCluster)
+	controllerMgrContext.AllowInsecureTLS = true
 
 	controllerContext := &context.ControllerContext{
 		ControllerManagerContext: controllerMgrContext,
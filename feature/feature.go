@@ -22,12 +22,17 @@ import (
 )
 
 const (
-// Every capv-specific feature gate should add method here following this template:
-//
-// // owner: @username
-// // alpha: v1.X
-// MyFeature featuregate.Feature = "MyFeature".
-
+	// Every capv-specific feature gate should add method here following this template:
+	//
+	// // owner: @username
+	// // alpha: v1.X
+	// MyFeature featuregate.Feature = "MyFeature".
+
+	// NodeAntiAffinity spreads the VMs of control planes and MachineDeployments
+	// across the hosts of their compute cluster.
+	//
+	// alpha: v1.3
+	NodeAntiAffinity featuregate.Feature = "NodeAntiAffinity"
 )
 
 func init() {
@@ -38,5 +43,5 @@ func init() {
 // To add a new feature, define a key for it above and add it here.
 var defaultCAPVFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
 	// Every feature should be initiated here:
-
+	NodeAntiAffinity: {Default: false, PreRelease: featuregate.Alpha},
 }
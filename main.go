@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	goctx "context"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -24,6 +25,7 @@ import (
 	"net/http/pprof"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"gopkg.in/fsnotify.v1"
@@ -41,7 +43,9 @@ import (
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/constants"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/tracing"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/version"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks"
 )
 
 var (
@@ -57,6 +61,7 @@ var (
 	defaultWebhookPort       = manager.DefaultWebhookServiceContainerPort
 	defaultEnableKeepAlive   = constants.DefaultEnableKeepAlive
 	defaultKeepAliveDuration = constants.DefaultKeepAliveDuration
+	defaultSessionIdleTTL    = constants.DefaultSessionIdleTTL
 )
 
 func main() {
@@ -136,12 +141,59 @@ func main() {
 		defaultKeepAliveDuration,
 		"idle time interval(minutes) in between send() requests in keepalive handler")
 
+	flag.DurationVar(
+		&managerOpts.SessionIdleTTL,
+		"session-idle-ttl",
+		defaultSessionIdleTTL,
+		"duration after which cached vSphere sessions which have not been used are logged out, 0 disables the eviction of idle sessions")
+
+	flag.BoolVar(
+		&managerOpts.AllowInsecureTLS,
+		"allow-insecure-tls",
+		false,
+		"allow connecting to vSphere endpoints without verifying their certificates if neither a thumbprint nor a CA bundle is configured")
+
+	flag.IntVar(
+		&managerOpts.OperationLimits.Server,
+		"max-concurrent-vcenter-operations",
+		0,
+		"maximum number of concurrent clone, reconfigure and power-on tasks per vSphere server, 0 disables the limit")
+
+	flag.IntVar(
+		&managerOpts.OperationLimits.Datastore,
+		"max-concurrent-datastore-operations",
+		0,
+		"maximum number of concurrent clone, reconfigure and power-on tasks per datastore, 0 disables the limit")
+
+	flag.StringVar(
+		&managerOpts.Tracing.Endpoint,
+		"tracing-otlp-endpoint",
+		"",
+		"host:port of the OTLP gRPC endpoint traces of reconciles and vCenter calls are exported to, tracing is disabled if empty")
+
+	flag.BoolVar(
+		&managerOpts.Tracing.Insecure,
+		"tracing-otlp-insecure",
+		false,
+		"export traces to the OTLP endpoint without TLS")
+
+	flag.Float64Var(
+		&managerOpts.Tracing.SamplingRatio,
+		"tracing-sampling-ratio",
+		1,
+		"ratio of the reconciles which are traced, between 0 and 1")
+
 	flag.StringVar(
 		&managerOpts.NetworkProvider,
 		"network-provider",
 		"",
 		"network provider to be used by Supervisor based clusters.")
 
+	flag.Func(
+		"feature-gates",
+		"A set of key=value pairs that describe feature gates for alpha/experimental features. Options are:\n"+strings.Join(feature.MutableGates.KnownFeatures(), "\n"),
+		feature.MutableGates.Set)
+
 	flag.Parse()
 
 	if managerOpts.Namespace != "" {
@@ -160,9 +212,22 @@ func main() {
 
 	managerOpts.SyncPeriod = &syncPeriod
 
+	shutdownTracing, err := tracing.Setup(goctx.Background(), managerOpts.Tracing)
+	if err != nil {
+		setupLog.Error(err, "problem setting up tracing")
+		os.Exit(1)
+	}
+
 	// Create a function that adds all of the controllers and webhooks to the
 	// manager.
 	addToManager := func(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+		// The metadata template webhook validates ConfigMaps, which exist in
+		// both modes, and is deployed by both the default and the supervisor
+		// manifests.
+		if err := (&webhooks.MetadataTemplateValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+
 		cluster := &v1beta1.VSphereCluster{}
 		gvr := v1beta1.GroupVersion.WithResource(reflect.TypeOf(cluster).Elem().Name())
 		_, err := mgr.GetRESTMapper().KindFor(gvr)
@@ -212,6 +277,9 @@ func main() {
 		setupLog.Error(err, "problem running controller manager")
 		os.Exit(1)
 	}
+	if err := shutdownTracing(goctx.Background()); err != nil {
+		setupLog.Error(err, "problem flushing traces")
+	}
 
 	// initialize notifier for capv-manager-bootstrap-credentials
 	watch, err := manager.InitializeWatch(mgr.GetContext(), &managerOpts)
@@ -225,6 +293,13 @@ func main() {
 }
 
 func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+	if err := (&v1beta1.VSphereCluster{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&v1beta1.VSphereClusterIdentity{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := (&v1beta1.VSphereClusterTemplate{}).SetupWebhookWithManager(mgr); err != nil {
 		return err
 	}
@@ -264,6 +339,10 @@ func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Man
 		return err
 	}
 
+	if err := (&v1beta1.VSphereIPPool{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := controllers.AddClusterControllerToManager(ctx, mgr, &v1beta1.VSphereCluster{}); err != nil {
 		return err
 	}
@@ -283,6 +362,10 @@ func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Man
 }
 
 func setupSupervisorControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+	if err := (&vmwarev1b1.VSphereMachine{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := controllers.AddClusterControllerToManager(ctx, mgr, &vmwarev1b1.VSphereCluster{}); err != nil {
 		return err
 	}
@@ -56,4 +56,8 @@ const (
 
 	// KeepaliveDuration unit minutes.
 	DefaultKeepAliveDuration = time.Minute * 5
+
+	// DefaultSessionIdleTTL is the duration after which cached vSphere
+	// sessions which have not been used are logged out.
+	DefaultSessionIdleTTL = time.Minute * 30
 )
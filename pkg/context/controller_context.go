@@ -17,7 +17,9 @@ limitations under the License.
 package context
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 
@@ -36,6 +38,45 @@ type ControllerContext struct {
 
 	// Recorder is used to record events.
 	Recorder record.Recorder
+
+	// ctx overrides the Go context of the controller manager, such as with
+	// a context which carries the span of a reconcile.
+	ctx context.Context
+}
+
+// WithContext returns a copy of the controller context whose Go context is
+// the given one, such as a context which carries the span of a reconcile.
+func (c *ControllerContext) WithContext(ctx context.Context) *ControllerContext {
+	controllerContext := *c
+	controllerContext.ctx = ctx
+	return &controllerContext
+}
+
+func (c *ControllerContext) goContext() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return c.ControllerManagerContext.Context
+}
+
+// Deadline implements context.Context.
+func (c *ControllerContext) Deadline() (time.Time, bool) {
+	return c.goContext().Deadline()
+}
+
+// Done implements context.Context.
+func (c *ControllerContext) Done() <-chan struct{} {
+	return c.goContext().Done()
+}
+
+// Err implements context.Context.
+func (c *ControllerContext) Err() error {
+	return c.goContext().Err()
+}
+
+// Value implements context.Context.
+func (c *ControllerContext) Value(key interface{}) interface{} {
+	return c.goContext().Value(key)
 }
 
 // String returns ControllerManagerName/ControllerName.
@@ -28,6 +28,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 )
 
 // ControllerManagerContext is the context of the controller that owns the
@@ -86,9 +87,23 @@ type ControllerManagerContext struct {
 	// in keepalive handler
 	KeepAliveDuration time.Duration
 
+	// SessionIdleTTL is the duration after which cached vSphere sessions
+	// which have not been used are logged out.
+	SessionIdleTTL time.Duration
+
+	// AllowInsecureTLS allows connecting to vSphere endpoints without
+	// verifying their certificates if neither a thumbprint nor a CA bundle
+	// is configured.
+	AllowInsecureTLS bool
+
 	// NetworkProvider is the network provider used by Supervisor based clusters
 	NetworkProvider string
 
+	// OperationThrottle limits the number of concurrent clone, reconfigure
+	// and power-on tasks per vSphere server and datastore. Operations are
+	// not limited if nil.
+	OperationThrottle *throttle.Throttle
+
 	genericEventCache sync.Map
 }
 
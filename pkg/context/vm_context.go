@@ -34,6 +34,7 @@ type VMContext struct {
 	Logger               logr.Logger
 	Session              *session.Session
 	VSphereFailureDomain *infrav1.VSphereFailureDomain
+	ClusterModule        *infrav1.ClusterModule
 }
 
 // String returns VSphereVMGroupVersionKind VSphereVMNamespace/VSphereVMName.
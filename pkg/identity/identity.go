@@ -18,6 +18,7 @@ package identity
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"strings"
@@ -33,11 +34,29 @@ import (
 const (
 	UsernameKey = "username"
 	PasswordKey = "password"
+
+	// CertificateKey and PrivateKeyKey are the keys of the PEM encoded
+	// solution user certificate and private key of Certificate credentials.
+	CertificateKey = apiv1.TLSCertKey
+	PrivateKeyKey  = apiv1.TLSPrivateKeyKey
+
+	// TokenKey is the key of the SAML bearer token of Token credentials.
+	TokenKey = "token"
 )
 
+// Credentials are the credentials used to authenticate with a vSphere
+// endpoint. Either the username and password, the certificate or the token
+// is set.
 type Credentials struct {
 	Username string
 	Password string
+
+	// Certificate is the solution user certificate for which SAML tokens
+	// are issued.
+	Certificate *tls.Certificate
+
+	// Token is a SAML bearer token.
+	Token string
 }
 
 func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphereCluster, controllerNamespace string) (*Credentials, error) {
@@ -48,6 +67,7 @@ func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphe
 	ref := cluster.Spec.IdentityRef
 	secret := &apiv1.Secret{}
 	var secretKey client.ObjectKey
+	credentialsType := infrav1.PasswordCredentials
 
 	switch ref.Kind {
 	case infrav1.SecretKind:
@@ -92,6 +112,9 @@ func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphe
 			Name:      identity.Spec.SecretName,
 			Namespace: controllerNamespace,
 		}
+		if identity.Spec.CredentialsType != "" {
+			credentialsType = identity.Spec.CredentialsType
+		}
 	default:
 		return nil, fmt.Errorf("unknown type %s used for Identity", ref.Kind)
 	}
@@ -100,6 +123,21 @@ func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphe
 		return nil, err
 	}
 
+	switch credentialsType {
+	case infrav1.CertificateCredentials:
+		certificate, err := tls.X509KeyPair(secret.Data[CertificateKey], secret.Data[PrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in secret %s: %w", secretKey, err)
+		}
+		return &Credentials{Certificate: &certificate}, nil
+	case infrav1.TokenCredentials:
+		token := getData(secret, TokenKey)
+		if token == "" {
+			return nil, fmt.Errorf("key %s not found in secret %s", TokenKey, secretKey)
+		}
+		return &Credentials{Token: token}, nil
+	}
+
 	credentials := &Credentials{
 		Username: getData(secret, UsernameKey),
 		Password: getData(secret, PasswordKey),
@@ -108,6 +146,81 @@ func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphe
 	return credentials, nil
 }
 
+// GetCABundle returns the PEM encoded bundle of certificate authorities used
+// to verify the certificate of the cluster's vSphere endpoint. The cluster's
+// own bundle takes precedence over the bundle of its VSphereClusterIdentity.
+// No bundle is returned if neither is set.
+//
+// The bundle is read on every call so that rotating the referenced Secret or
+// ConfigMap takes effect without changes to the clusters.
+func GetCABundle(ctx context.Context, c client.Client, cluster *infrav1.VSphereCluster, controllerNamespace string) ([]byte, error) {
+	if c == nil {
+		return nil, errors.New("kubernetes client is required")
+	}
+	if cluster == nil {
+		return nil, errors.New("vsphere cluster is required")
+	}
+
+	if cluster.Spec.CABundle != nil {
+		if cluster.Spec.Thumbprint != "" {
+			return nil, errors.New("caBundle and thumbprint are mutually exclusive")
+		}
+		return getCABundle(ctx, c, cluster.Spec.CABundle, cluster.Namespace)
+	}
+
+	ref := cluster.Spec.IdentityRef
+	if cluster.Spec.Thumbprint != "" || ref == nil || ref.Kind != infrav1.VSphereClusterIdentityKind {
+		return nil, nil
+	}
+	identity := &infrav1.VSphereClusterIdentity{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, identity); err != nil {
+		return nil, err
+	}
+	if identity.Spec.CABundle == nil {
+		return nil, nil
+	}
+	return getCABundle(ctx, c, identity.Spec.CABundle, controllerNamespace)
+}
+
+func getCABundle(ctx context.Context, c client.Client, source *infrav1.CABundleSource, namespace string) ([]byte, error) {
+	var set int
+	for _, ok := range []bool{source.Data != "", source.SecretRef != nil, source.ConfigMapRef != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of data, secretRef and configMapRef must be set for caBundle")
+	}
+
+	switch {
+	case source.SecretRef != nil:
+		secret := &apiv1.Secret{}
+		key := client.ObjectKey{Namespace: namespace, Name: source.SecretRef.Name}
+		if err := c.Get(ctx, key, secret); err != nil {
+			return nil, err
+		}
+		data, ok := secret.Data[source.SecretRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s", source.SecretRef.Key, key)
+		}
+		return data, nil
+	case source.ConfigMapRef != nil:
+		configMap := &apiv1.ConfigMap{}
+		key := client.ObjectKey{Namespace: namespace, Name: source.ConfigMapRef.Name}
+		if err := c.Get(ctx, key, configMap); err != nil {
+			return nil, err
+		}
+		data, ok := configMap.Data[source.ConfigMapRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in configmap %s", source.ConfigMapRef.Key, key)
+		}
+		return []byte(data), nil
+	default:
+		return []byte(source.Data), nil
+	}
+}
+
 func validateInputs(c client.Client, cluster *infrav1.VSphereCluster) error {
 	if c == nil {
 		return errors.New("kubernetes client is required")
@@ -17,12 +17,23 @@ limitations under the License.
 package identity
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
@@ -445,3 +456,192 @@ func TestIsOwnedByIdentityOrCluster(t *testing.T) {
 		})
 	}
 }
+
+func TestGetCABundle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+
+	objects := []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-ns", Name: "ca"},
+			Data:       map[string][]byte{"ca.crt": []byte("secret-ca")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: manager.DefaultPodNamespace, Name: "ca"},
+			Data:       map[string]string{"ca.crt": "configmap-ca"},
+		},
+		&infrav1.VSphereClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "identity"},
+			Spec: infrav1.VSphereClusterIdentitySpec{
+				CABundle: &infrav1.CABundleSource{
+					ConfigMapRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "ca"},
+						Key:                  "ca.crt",
+					},
+				},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	identityRef := &infrav1.VSphereIdentityReference{Kind: infrav1.VSphereClusterIdentityKind, Name: "identity"}
+	secretRef := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"}
+
+	tests := []struct {
+		name    string
+		spec    infrav1.VSphereClusterSpec
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no CA bundle",
+			spec: infrav1.VSphereClusterSpec{},
+		},
+		{
+			name: "inline CA bundle",
+			spec: infrav1.VSphereClusterSpec{CABundle: &infrav1.CABundleSource{Data: "inline-ca"}},
+			want: "inline-ca",
+		},
+		{
+			name: "CA bundle from a secret in the cluster namespace",
+			spec: infrav1.VSphereClusterSpec{CABundle: &infrav1.CABundleSource{SecretRef: secretRef}},
+			want: "secret-ca",
+		},
+		{
+			name: "CA bundle of the identity",
+			spec: infrav1.VSphereClusterSpec{IdentityRef: identityRef},
+			want: "configmap-ca",
+		},
+		{
+			name: "cluster CA bundle overrides the identity",
+			spec: infrav1.VSphereClusterSpec{IdentityRef: identityRef, CABundle: &infrav1.CABundleSource{Data: "inline-ca"}},
+			want: "inline-ca",
+		},
+		{
+			name: "thumbprint overrides the identity",
+			spec: infrav1.VSphereClusterSpec{IdentityRef: identityRef, Thumbprint: "thumbprint"},
+		},
+		{
+			name:    "CA bundle and thumbprint",
+			spec:    infrav1.VSphereClusterSpec{Thumbprint: "thumbprint", CABundle: &infrav1.CABundleSource{Data: "inline-ca"}},
+			wantErr: true,
+		},
+		{
+			name:    "multiple sources",
+			spec:    infrav1.VSphereClusterSpec{CABundle: &infrav1.CABundleSource{Data: "inline-ca", SecretRef: secretRef}},
+			wantErr: true,
+		},
+		{
+			name: "missing key",
+			spec: infrav1.VSphereClusterSpec{CABundle: &infrav1.CABundleSource{SecretRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "ca"},
+				Key:                  "missing",
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &infrav1.VSphereCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-ns", Name: "cluster"},
+				Spec:       tt.spec,
+			}
+			caBundle, err := GetCABundle(context.Background(), c, cluster, manager.DefaultPodNamespace)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(string(caBundle)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestGetCredentialsOfType(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "capv"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	newIdentity := func(name string, credentialsType infrav1.CredentialsType, data map[string][]byte) []client.Object {
+		return []client.Object{
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: manager.DefaultPodNamespace, Name: name},
+				Data:       data,
+			},
+			&infrav1.VSphereClusterIdentity{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: infrav1.VSphereClusterIdentitySpec{
+					SecretName:        name,
+					CredentialsType:   credentialsType,
+					AllowedNamespaces: &infrav1.AllowedNamespaces{},
+				},
+				Status: infrav1.VSphereClusterIdentityStatus{Ready: true},
+			},
+		}
+	}
+	objects := []client.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster-ns"}}}
+	objects = append(objects, newIdentity("password", "", map[string][]byte{UsernameKey: []byte("user"), PasswordKey: []byte("pass")})...)
+	objects = append(objects, newIdentity("certificate", infrav1.CertificateCredentials, map[string][]byte{CertificateKey: certPEM, PrivateKeyKey: keyPEM})...)
+	objects = append(objects, newIdentity("invalid-certificate", infrav1.CertificateCredentials, map[string][]byte{CertificateKey: certPEM})...)
+	objects = append(objects, newIdentity("token", infrav1.TokenCredentials, map[string][]byte{TokenKey: []byte("<saml2:Assertion/>")})...)
+	objects = append(objects, newIdentity("missing-token", infrav1.TokenCredentials, map[string][]byte{})...)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	getCredentials := func(name string) (*Credentials, error) {
+		cluster := &infrav1.VSphereCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cluster-ns", Name: "cluster"},
+			Spec: infrav1.VSphereClusterSpec{
+				IdentityRef: &infrav1.VSphereIdentityReference{Kind: infrav1.VSphereClusterIdentityKind, Name: name},
+			},
+		}
+		return GetCredentials(context.Background(), c, cluster, manager.DefaultPodNamespace)
+	}
+
+	t.Run("password", func(t *testing.T) {
+		g := NewWithT(t)
+		creds, err := getCredentials("password")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(creds).To(Equal(&Credentials{Username: "user", Password: "pass"}))
+	})
+
+	t.Run("certificate", func(t *testing.T) {
+		g := NewWithT(t)
+		creds, err := getCredentials("certificate")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(creds.Certificate).NotTo(BeNil())
+		g.Expect(creds.Certificate.Certificate).To(Equal([][]byte{der}))
+		g.Expect(creds.Username).To(BeEmpty())
+
+		_, err = getCredentials("invalid-certificate")
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("token", func(t *testing.T) {
+		g := NewWithT(t)
+		creds, err := getCredentials("token")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(creds).To(Equal(&Credentials{Token: "<saml2:Assertion/>"}))
+
+		_, err = getCredentials("missing-token")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
@@ -38,6 +38,7 @@ import (
 	vmwarev1b1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 )
 
 // Manager is a CAPV controller manager.
@@ -94,7 +95,10 @@ func New(opts Options) (Manager, error) {
 		Password:                opts.Password,
 		EnableKeepAlive:         opts.EnableKeepAlive,
 		KeepAliveDuration:       opts.KeepAliveDuration,
+		SessionIdleTTL:          opts.SessionIdleTTL,
+		AllowInsecureTLS:        opts.AllowInsecureTLS,
 		NetworkProvider:         opts.NetworkProvider,
+		OperationThrottle:       throttle.New(opts.OperationLimits),
 	}
 
 	// Add the requested items to the manager.
@@ -29,6 +29,8 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/tracing"
 )
 
 // AddToManagerFunc is a function that can be optionally specified with
@@ -73,6 +75,23 @@ type Options struct {
 	// in keepalive handler
 	KeepAliveDuration time.Duration
 
+	// SessionIdleTTL is the duration after which cached vSphere sessions
+	// which have not been used are logged out.
+	SessionIdleTTL time.Duration
+
+	// AllowInsecureTLS allows connecting to vSphere endpoints without
+	// verifying their certificates if neither a thumbprint nor a CA bundle
+	// is configured.
+	AllowInsecureTLS bool
+
+	// OperationLimits are the maximum numbers of concurrent clone,
+	// reconfigure and power-on tasks per vSphere server and datastore.
+	OperationLimits throttle.Limits
+
+	// Tracing configures the export of traces of reconciles and vCenter
+	// calls. Traces are not exported by default.
+	Tracing tracing.Options
+
 	// CredentialsFile is the file that contains credentials of CAPV
 	CredentialsFile string
 
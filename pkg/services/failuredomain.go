@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// failureDomainReservationTTL is how long a selected failure domain is
+// counted for a machine whose VSphereMachine is not yet seen with it.
+const failureDomainReservationTTL = 5 * time.Minute
+
+// failureDomainReservation is a failure domain selected for a machine.
+type failureDomainReservation struct {
+	cluster       string
+	deployment    string
+	failureDomain string
+	expires       time.Time
+}
+
+// reservations holds the failure domains which were selected for machines
+// but may not yet be visible in the cached VSphereMachines. They are counted
+// in addition to the cached machines, so that machines which are reconciled
+// concurrently or in quick succession are spread as well.
+var reservations = struct {
+	sync.Mutex
+	machines map[apitypes.NamespacedName]failureDomainReservation
+}{machines: map[apitypes.NamespacedName]failureDomainReservation{}}
+
+// selectFailureDomain selects a failure domain for the machine according to
+// the failure domain selection of the VSphereCluster and reserves it for the
+// machine. It returns nil if the VSphereCluster has no failure domain
+// selection or no failure domain can be selected.
+func selectFailureDomain(ctx *context.VIMMachineContext) (*string, error) {
+	selection := ctx.VSphereCluster.Spec.FailureDomainSelection
+	if selection == nil {
+		return nil, nil
+	}
+
+	reservations.Lock()
+	defer reservations.Unlock()
+
+	var zones, degradedZones []*infrav1.VSphereDeploymentZone
+	for name := range ctx.VSphereCluster.Status.FailureDomains {
+		if selection.Policy == infrav1.WeightedFailureDomainSelectionPolicy && selection.Weight(name) <= 0 {
+			continue
+		}
+		zone := &infrav1.VSphereDeploymentZone{}
+		if err := ctx.Client.Get(ctx, client.ObjectKey{Name: name}, zone); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if conditions.IsFalse(zone, infrav1.CapacityAvailableCondition) {
+			degradedZones = append(degradedZones, zone)
+			continue
+		}
+		zones = append(zones, zone)
+	}
+	// Failure domains with insufficient capacity are only selected if there
+	// are no others.
+	if len(zones) == 0 {
+		zones = degradedZones
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+
+	machines, err := countMachinesPerFailureDomain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// less reports whether zone a is preferred over zone b.
+	var less func(a, b *infrav1.VSphereDeploymentZone) bool
+	switch selection.Policy {
+	case infrav1.WeightedFailureDomainSelectionPolicy:
+		less = func(a, b *infrav1.VSphereDeploymentZone) bool {
+			// Compares (machines+1)/weight without dividing.
+			return int64(machines[a.Name]+1)*int64(selection.Weight(b.Name)) < int64(machines[b.Name]+1)*int64(selection.Weight(a.Name))
+		}
+	case infrav1.CapacityFailureDomainSelectionPolicy:
+		less = func(a, b *infrav1.VSphereDeploymentZone) bool {
+			freeA, freeB := freeCapacity(a), freeCapacity(b)
+			if freeA != freeB {
+				return freeA > freeB
+			}
+			return machines[a.Name] < machines[b.Name]
+		}
+	default:
+		less = func(a, b *infrav1.VSphereDeploymentZone) bool {
+			return machines[a.Name] < machines[b.Name]
+		}
+	}
+
+	selected := zones[0]
+	for _, zone := range zones[1:] {
+		if less(zone, selected) {
+			selected = zone
+		}
+	}
+
+	reservations.machines[apitypes.NamespacedName{Namespace: ctx.VSphereMachine.Namespace, Name: ctx.VSphereMachine.Name}] = failureDomainReservation{
+		cluster:       ctx.Cluster.Name,
+		deployment:    ctx.Machine.Labels[clusterv1.MachineDeploymentLabelName],
+		failureDomain: selected.Name,
+		expires:       time.Now().Add(failureDomainReservationTTL),
+	}
+	return &selected.Name, nil
+}
+
+// countMachinesPerFailureDomain returns the number of VSphereMachines of the
+// same MachineDeployment, or of the same cluster for machines which do not
+// belong to a MachineDeployment, in each failure domain. Machines whose
+// selected failure domain is not yet cached are counted by their reservation.
+// The caller must hold the reservations lock.
+func countMachinesPerFailureDomain(ctx *context.VIMMachineContext) (map[string]int, error) {
+	selector := client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}
+	deployment, ok := ctx.Machine.Labels[clusterv1.MachineDeploymentLabelName]
+	if ok {
+		selector[clusterv1.MachineDeploymentLabelName] = deployment
+	}
+
+	var machines infrav1.VSphereMachineList
+	if err := ctx.Client.List(ctx, &machines, client.InNamespace(ctx.VSphereMachine.Namespace), selector); err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	deleting := map[string]bool{}
+	for _, machine := range machines.Items {
+		if !machine.DeletionTimestamp.IsZero() {
+			deleting[machine.Name] = true
+		}
+		if machine.Name == ctx.VSphereMachine.Name || !machine.DeletionTimestamp.IsZero() || machine.Spec.FailureDomain == nil {
+			continue
+		}
+		counts[*machine.Spec.FailureDomain]++
+		// The selected failure domain is cached, the reservation is no longer needed.
+		delete(reservations.machines, apitypes.NamespacedName{Namespace: machine.Namespace, Name: machine.Name})
+	}
+
+	now := time.Now()
+	for key, reservation := range reservations.machines {
+		if now.After(reservation.expires) {
+			delete(reservations.machines, key)
+			continue
+		}
+		if key.Namespace != ctx.VSphereMachine.Namespace || key.Name == ctx.VSphereMachine.Name ||
+			reservation.cluster != ctx.Cluster.Name || (ok && reservation.deployment != deployment) {
+			continue
+		}
+		// A machine which is not listed may be missing from the cache yet.
+		if deleting[key.Name] {
+			delete(reservations.machines, key)
+			continue
+		}
+		counts[reservation.failureDomain]++
+	}
+	return counts, nil
+}
+
+// freeCapacity returns the smaller of the shares of free CPU and memory
+// capacity of the deployment zone, or -1 if its capacity is unknown.
+func freeCapacity(zone *infrav1.VSphereDeploymentZone) float64 {
+	capacity := zone.Status.Capacity
+	if capacity == nil || capacity.CPUTotalMHz == 0 || capacity.MemoryTotalMiB == 0 {
+		return -1
+	}
+	cpu := float64(capacity.CPUFreeMHz) / float64(capacity.CPUTotalMHz)
+	memory := float64(capacity.MemoryFreeMiB) / float64(capacity.MemoryTotalMiB)
+	if cpu < memory {
+		return cpu
+	}
+	return memory
+}
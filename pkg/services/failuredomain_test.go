@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+var _ = Describe("VimMachineService_SelectFailureDomain", func() {
+	deplZone := func(name string, cpuFreeMHz int64, capacityAvailable bool) *infrav1.VSphereDeploymentZone {
+		zone := &infrav1.VSphereDeploymentZone{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: infrav1.VSphereDeploymentZoneSpec{
+				ControlPlane: pointer.Bool(false),
+			},
+			Status: infrav1.VSphereDeploymentZoneStatus{
+				Capacity: &infrav1.DeploymentZoneCapacity{
+					CPUTotalMHz:    1000,
+					CPUFreeMHz:     cpuFreeMHz,
+					MemoryTotalMiB: 1000,
+					MemoryFreeMiB:  1000,
+				},
+			},
+		}
+		if capacityAvailable {
+			conditions.MarkTrue(zone, infrav1.CapacityAvailableCondition)
+		} else {
+			conditions.MarkFalse(zone, infrav1.CapacityAvailableCondition, infrav1.InsufficientCapacityReason, clusterv1.ConditionSeverityWarning, "")
+		}
+		return zone
+	}
+
+	vsphereMachine := func(name string, failureDomain string) *infrav1.VSphereMachine {
+		return &infrav1.VSphereMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: fake.Namespace,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name},
+			},
+			Spec: infrav1.VSphereMachineSpec{FailureDomain: pointer.String(failureDomain)},
+		}
+	}
+
+	var (
+		machineCtx *context.VIMMachineContext
+		selection  *infrav1.FailureDomainSelection
+	)
+
+	BeforeEach(func() {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(
+			deplZone("zone-a", 100, true), deplZone("zone-b", 800, true), deplZone("zone-c", 900, false),
+			vsphereMachine("machine-1", "zone-a"), vsphereMachine("machine-2", "zone-a"), vsphereMachine("machine-3", "zone-b")))
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		machineCtx.VSphereCluster.Status.FailureDomains = clusterv1.FailureDomains{}
+		for _, name := range []string{"zone-a", "zone-b", "zone-c"} {
+			machineCtx.VSphereCluster.Status.FailureDomains[name] = clusterv1.FailureDomainSpec{}
+		}
+		selection = &infrav1.FailureDomainSelection{}
+		machineCtx.VSphereCluster.Spec.FailureDomainSelection = selection
+
+		reservations.Lock()
+		reservations.machines = map[apitypes.NamespacedName]failureDomainReservation{}
+		reservations.Unlock()
+	})
+
+	selectedFailureDomain := func() string {
+		failureDomain, err := selectFailureDomain(machineCtx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failureDomain).NotTo(BeNil())
+		return *failureDomain
+	}
+
+	It("does not select a failure domain without a selection", func() {
+		machineCtx.VSphereCluster.Spec.FailureDomainSelection = nil
+		failureDomain, err := selectFailureDomain(machineCtx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failureDomain).To(BeNil())
+	})
+
+	It("selects the failure domain with the fewest machines when spreading", func() {
+		selection.Policy = infrav1.SpreadFailureDomainSelectionPolicy
+		Expect(selectedFailureDomain()).To(Equal("zone-b"))
+	})
+
+	It("counts failure domains selected for machines which are not cached yet", func() {
+		selection.Policy = infrav1.SpreadFailureDomainSelectionPolicy
+		Expect(selectedFailureDomain()).To(Equal("zone-b"))
+		// Reselecting for the same machine replaces its reservation.
+		Expect(selectedFailureDomain()).To(Equal("zone-b"))
+
+		machineCtx.VSphereMachine.Name = "machine-4"
+		Expect(selectedFailureDomain()).To(Equal("zone-a"))
+	})
+
+	It("selects failure domains proportionally to their weights", func() {
+		selection.Policy = infrav1.WeightedFailureDomainSelectionPolicy
+		Expect(selectedFailureDomain()).To(Equal("zone-b"))
+
+		selection.Weights = map[string]int32{"zone-a": 4}
+		Expect(selectedFailureDomain()).To(Equal("zone-a"))
+
+		selection.Weights = map[string]int32{"zone-a": 0, "zone-b": 0}
+		Expect(selectedFailureDomain()).To(Equal("zone-c"))
+	})
+
+	It("selects the failure domain with the most free capacity", func() {
+		selection.Policy = infrav1.CapacityFailureDomainSelectionPolicy
+		Expect(selectedFailureDomain()).To(Equal("zone-b"))
+	})
+
+	It("selects failure domains with insufficient capacity if there are no others", func() {
+		selection.Policy = infrav1.SpreadFailureDomainSelectionPolicy
+		machineCtx.VSphereCluster.Status.FailureDomains = clusterv1.FailureDomains{"zone-c": clusterv1.FailureDomainSpec{}}
+		Expect(selectedFailureDomain()).To(Equal("zone-c"))
+	})
+})
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/nocloud"
+)
+
+// cloudInitISOName is the name of the NoCloud ISO in the directory of a VM.
+const cloudInitISOName = "cidata.iso"
+
+// getCloudInitISOChecksum returns the checksum of the NoCloud ISO of the VM,
+// or an empty string if its cloud-init user data and metadata are in the
+// guestinfo.
+func (vms *VMService) getCloudInitISOChecksum(ctx *virtualMachineContext) (string, error) {
+	extraConfig, err := ctx.Session.Properties.ExtraConfig(ctx, ctx.Ref)
+	if err != nil {
+		return "", err
+	}
+	checksum := ""
+	for _, ec := range extraConfig {
+		if optVal := ec.GetOptionValue(); optVal != nil && optVal.Key == extra.CloudInitISOKey {
+			checksum, _ = optVal.Value.(string)
+		}
+	}
+	return checksum, nil
+}
+
+// reconcileCloudInitISO delivers the cloud-init user data and the metadata
+// of the VM on a NoCloud ISO in the directory of the VM, which replaces the
+// user data and metadata in the guestinfo. The ISO is not replaced while the
+// VM is powered on, as cloud-init only reads it on the first boot.
+func (vms *VMService) reconcileCloudInitISO(ctx *virtualMachineContext, metadata []byte, checksum string) (bool, error) {
+	userData, _, err := vms.getBootstrapData(&ctx.VMContext)
+	if err != nil {
+		return false, err
+	}
+	image, err := nocloud.ISO(userData, metadata)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to create NoCloud ISO for vm %s", ctx)
+	}
+	newChecksum := fmt.Sprintf("%x", sha256.Sum256(image))
+	if newChecksum == checksum {
+		return true, nil
+	}
+
+	if checksum != "" && checksum != extra.CloudInitISOPending {
+		powerState, err := vms.getPowerState(ctx)
+		if err != nil {
+			return false, err
+		}
+		if powerState == infrav1.VirtualMachinePowerStatePoweredOn {
+			return true, nil
+		}
+	}
+
+	if !acquireOperationSlot(&ctx.VMContext, infrav1.VMProvisionedCondition, vmDatastore(ctx)) {
+		return false, nil
+	}
+	ctx.Logger.Info("updating NoCloud ISO")
+	taskRef, err := vms.setCloudInitISO(ctx, image, newChecksum)
+	if err != nil {
+		releaseOperationSlot(&ctx.VMContext)
+		return false, err
+	}
+
+	ctx.VSphereVM.Status.TaskRef = taskRef
+	ctx.Logger.Info("wait for VM NoCloud ISO to be updated")
+	return false, nil
+}
+
+// setCloudInitISO uploads the NoCloud ISO to the directory of the VM and
+// reconfigures the VM to boot with the ISO attached to its CD-ROM drive.
+func (vms *VMService) setCloudInitISO(ctx *virtualMachineContext, image []byte, checksum string) (string, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.files.vmPathName", "config.hardware.device"}, &obj); err != nil {
+		return "", errors.Wrapf(err, "unable to get properties of vm %s", ctx)
+	}
+	var vmxPath object.DatastorePath
+	if !vmxPath.FromString(obj.Config.Files.VmPathName) {
+		return "", errors.Errorf("unable to parse path %q of vm %s", obj.Config.Files.VmPathName, ctx)
+	}
+	isoPath := object.DatastorePath{
+		Datastore: vmxPath.Datastore,
+		Path:      path.Join(path.Dir(vmxPath.Path), cloudInitISOName),
+	}
+
+	datastore, err := ctx.Session.Finder.Datastore(ctx, isoPath.Datastore)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to find datastore %s for vm %s", isoPath.Datastore, ctx)
+	}
+	upload := soap.DefaultUpload
+	upload.ContentLength = int64(len(image))
+	if err := datastore.Upload(ctx, bytes.NewReader(image), isoPath.Path, &upload); err != nil {
+		return "", errors.Wrapf(err, "unable to upload NoCloud ISO %s for vm %s", isoPath.String(), ctx)
+	}
+
+	spec := types.VirtualMachineConfigSpec{
+		ExtraConfig: []types.BaseOptionValue{
+			&types.OptionValue{Key: guestInfoKeyUserdata, Value: ""},
+			&types.OptionValue{Key: guestInfoKeyUserdataEnc, Value: ""},
+			&types.OptionValue{Key: guestInfoKeyMetadata, Value: ""},
+			&types.OptionValue{Key: guestInfoKeyMetadataEnc, Value: ""},
+			&types.OptionValue{Key: extra.CloudInitISOKey, Value: checksum},
+		},
+	}
+
+	devices := object.VirtualDeviceList(obj.Config.Hardware.Device)
+	if findCloudInitCdrom(devices) == nil {
+		operation := types.VirtualDeviceConfigSpecOperationEdit
+		cdrom, err := devices.FindCdrom("")
+		if err != nil {
+			controller, err := devices.FindIDEController("")
+			if err != nil {
+				return "", errors.Wrapf(err, "unable to attach NoCloud ISO to vm %s", ctx)
+			}
+			if cdrom, err = devices.CreateCdrom(controller); err != nil {
+				return "", errors.Wrapf(err, "unable to attach NoCloud ISO to vm %s", ctx)
+			}
+			operation = types.VirtualDeviceConfigSpecOperationAdd
+		}
+		cdrom = devices.InsertIso(cdrom, isoPath.String())
+		cdrom.Connectable = &types.VirtualDeviceConnectInfo{
+			AllowGuestControl: true,
+			Connected:         true,
+			StartConnected:    true,
+		}
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: operation,
+			Device:    cdrom,
+		})
+	}
+
+	task, err := ctx.Obj.Reconfigure(ctx, spec)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to attach NoCloud ISO to vm %s", ctx)
+	}
+	return task.Reference().Value, nil
+}
+
+// deleteCloudInitISO deletes the NoCloud ISO of the VM, which is not deleted
+// together with the VM as it is not one of the files of the VM.
+func (vms *VMService) deleteCloudInitISO(ctx *virtualMachineContext) error {
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
+	if err != nil {
+		return err
+	}
+	cdrom := findCloudInitCdrom(object.VirtualDeviceList(obj.Config.Hardware.Device))
+	if cdrom == nil {
+		return nil
+	}
+	fileName := cdrom.Backing.(*types.VirtualCdromIsoBackingInfo).FileName //nolint:forcetypeassert
+
+	datacenter, err := ctx.Session.Finder.DatacenterOrDefault(ctx, ctx.VSphereVM.Spec.Datacenter)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datacenter for %q", ctx)
+	}
+	ctx.Logger.Info("deleting NoCloud ISO", "iso", fileName)
+	task, err := object.NewFileManager(ctx.Session.Client.Client).DeleteDatastoreFile(ctx, fileName, datacenter)
+	if err != nil {
+		return errors.Wrapf(err, "unable to delete NoCloud ISO %s of vm %s", fileName, ctx)
+	}
+	if err := task.Wait(ctx); err != nil && !isFileNotFound(err) {
+		return errors.Wrapf(err, "unable to delete NoCloud ISO %s of vm %s", fileName, ctx)
+	}
+	return nil
+}
+
+// findCloudInitCdrom returns the CD-ROM drive the NoCloud ISO is attached to.
+func findCloudInitCdrom(devices object.VirtualDeviceList) *types.VirtualCdrom {
+	for _, device := range devices.SelectByType((*types.VirtualCdrom)(nil)) {
+		cdrom := device.(*types.VirtualCdrom) //nolint:forcetypeassert
+		if backing, ok := cdrom.Backing.(*types.VirtualCdromIsoBackingInfo); ok && path.Base(backing.FileName) == cloudInitISOName {
+			return cdrom
+		}
+	}
+	return nil
+}
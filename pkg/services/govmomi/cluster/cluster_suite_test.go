@@ -34,9 +34,13 @@ func TestCluster(t *testing.T) {
 
 type testComputeClusterCtx struct {
 	context.Context
-	finder *find.Finder
+	finder  *find.Finder
+	session *session.Session
 }
 
 func (t testComputeClusterCtx) GetSession() *session.Session {
+	if t.session != nil {
+		return t.session
+	}
 	return &session.Session{Finder: t.finder}
 }
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ReconcileHostGroup creates the host group with the given name and hosts in
+// the compute cluster if it does not exist. The hosts of an existing group are
+// only updated if update is set, such as for a group which was created by
+// ReconcileHostGroup before. It returns whether the group was created.
+func ReconcileHostGroup(ctx computeClusterContext, clusterName, hostGroupName string, hosts []types.ManagedObjectReference, update bool) (bool, error) {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return false, err
+	}
+
+	group, err := findGroup(ctx, ccr, hostGroupName)
+	if err != nil {
+		return false, err
+	}
+
+	operation := types.ArrayUpdateOperationAdd
+	if group != nil {
+		hostGroup, ok := group.(*types.ClusterHostGroup)
+		if !ok {
+			return false, errors.Errorf("group %s of compute cluster %s is not a host group", hostGroupName, clusterName)
+		}
+		if !update || sameRefs(hostGroup.Host, hosts) {
+			return false, nil
+		}
+		operation = types.ArrayUpdateOperationEdit
+	}
+
+	err = reconfigureGroup(ctx, ccr, operation, &types.ClusterHostGroup{
+		ClusterGroupInfo: types.ClusterGroupInfo{Name: hostGroupName},
+		Host:             hosts,
+	})
+	return group == nil && err == nil, err
+}
+
+// EnsureVMGroup ensures that the VM group with the given name exists in the
+// compute cluster. VM groups are created without VMs, which are added once
+// they are created. It returns whether the group was created.
+func EnsureVMGroup(ctx computeClusterContext, clusterName, vmGroupName string) (bool, error) {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return false, err
+	}
+
+	group, err := findGroup(ctx, ccr, vmGroupName)
+	if err != nil {
+		return false, err
+	}
+	if group != nil {
+		if _, ok := group.(*types.ClusterVmGroup); !ok {
+			return false, errors.Errorf("group %s of compute cluster %s is not a VM group", vmGroupName, clusterName)
+		}
+		return false, nil
+	}
+
+	err = reconfigureGroup(ctx, ccr, types.ArrayUpdateOperationAdd, &types.ClusterVmGroup{
+		ClusterGroupInfo: types.ClusterGroupInfo{Name: vmGroupName},
+	})
+	return err == nil, err
+}
+
+// DeleteGroup deletes the VM or host group with the given name from the
+// compute cluster if it exists.
+func DeleteGroup(ctx computeClusterContext, clusterName, groupName string) error {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+
+	group, err := findGroup(ctx, ccr, groupName)
+	if err != nil || group == nil {
+		return err
+	}
+
+	spec := &types.ClusterConfigSpecEx{
+		GroupSpec: []types.ClusterGroupSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: types.ArrayUpdateOperationRemove,
+					RemoveKey: groupName,
+				},
+			},
+		},
+	}
+	return reconfigure(ctx, ccr, spec)
+}
+
+func findGroup(ctx context.Context, ccr *object.ClusterComputeResource, groupName string) (types.BaseClusterGroupInfo, error) {
+	clusterConfigInfoEx, err := ccr.Configuration(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list groups of compute cluster %s", ccr.InventoryPath)
+	}
+	for _, group := range clusterConfigInfoEx.Group {
+		if group.GetClusterGroupInfo().Name == groupName {
+			return group, nil
+		}
+	}
+	return nil, nil
+}
+
+func reconfigureGroup(ctx context.Context, ccr *object.ClusterComputeResource, operation types.ArrayUpdateOperation, group types.BaseClusterGroupInfo) error {
+	spec := &types.ClusterConfigSpecEx{
+		GroupSpec: []types.ClusterGroupSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: operation,
+				},
+				Info: group,
+			},
+		},
+	}
+	return reconfigure(ctx, ccr, spec)
+}
+
+func reconfigure(ctx context.Context, ccr *object.ClusterComputeResource, spec *types.ClusterConfigSpecEx) error {
+	task, err := ccr.Reconfigure(ctx, spec, true)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+func sameRefs(a, b []types.ManagedObjectReference) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ref := range b {
+		if !containsRef(a, ref) {
+			return false
+		}
+	}
+	return true
+}
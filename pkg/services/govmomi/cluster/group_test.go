@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestHostGroupPlacement(t *testing.T) {
+	g := NewWithT(t)
+	sim, err := vcsim.NewBuilder().Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer sim.Destroy()
+
+	ctx := context.Background()
+	client, _ := govmomi.NewClient(ctx, sim.ServerURL(), true)
+	finder := find.NewFinder(client.Client, false)
+
+	dc, _ := finder.DatacenterOrDefault(ctx, "DC0")
+	finder.SetDatacenter(dc)
+
+	computeClusterCtx := testComputeClusterCtx{
+		Context: context.Background(),
+		finder:  finder,
+	}
+
+	ccr, err := finder.ClusterComputeResource(ctx, "DC0_C0")
+	g.Expect(err).NotTo(HaveOccurred())
+	hosts, err := ccr.Hosts(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(hosts)).To(BeNumerically(">=", 2))
+	refs := []types.ManagedObjectReference{hosts[0].Reference(), hosts[1].Reference()}
+
+	created, err := ReconcileHostGroup(computeClusterCtx, "DC0_C0", "blah-host-group", refs[:1], false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeTrue())
+	group, err := findGroup(ctx, ccr, "blah-host-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(group.(*types.ClusterHostGroup).Host).To(ConsistOf(refs[0]))
+
+	// The hosts of an existing group are only updated if requested.
+	created, err = ReconcileHostGroup(computeClusterCtx, "DC0_C0", "blah-host-group", refs, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeFalse())
+	group, err = findGroup(ctx, ccr, "blah-host-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(group.(*types.ClusterHostGroup).Host).To(ConsistOf(refs[0]))
+
+	created, err = ReconcileHostGroup(computeClusterCtx, "DC0_C0", "blah-host-group", refs, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeFalse())
+	group, err = findGroup(ctx, ccr, "blah-host-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(group.(*types.ClusterHostGroup).Host).To(ConsistOf(refs))
+
+	created, err = EnsureVMGroup(computeClusterCtx, "DC0_C0", "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeTrue())
+	created, err = EnsureVMGroup(computeClusterCtx, "DC0_C0", "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeFalse())
+	group, err = findGroup(ctx, ccr, "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(group).To(BeAssignableToTypeOf(&types.ClusterVmGroup{}))
+
+	// A host group cannot be used as VM group.
+	_, err = EnsureVMGroup(computeClusterCtx, "DC0_C0", "blah-host-group")
+	g.Expect(err).To(HaveOccurred())
+
+	created, err = ReconcileAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule", "blah-host-group", "blah-vm-group", false, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeTrue())
+	rule, err := VerifyAffinityRule(computeClusterCtx, "DC0_C0", "blah-host-group", "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rule.IsMandatory()).To(BeFalse())
+	g.Expect(rule.Disabled()).To(BeFalse())
+
+	// An existing rule is only updated if requested.
+	created, err = ReconcileAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule", "blah-host-group", "blah-vm-group", true, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeFalse())
+	rule, err = VerifyAffinityRule(computeClusterCtx, "DC0_C0", "blah-host-group", "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rule.IsMandatory()).To(BeFalse())
+
+	created, err = ReconcileAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule", "blah-host-group", "blah-vm-group", true, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created).To(BeFalse())
+	rule, err = VerifyAffinityRule(computeClusterCtx, "DC0_C0", "blah-host-group", "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rule.IsMandatory()).To(BeTrue())
+
+	// Only the rule with the given name is deleted.
+	g.Expect(DeleteAffinityRule(computeClusterCtx, "DC0_C0", "other-rule")).To(Succeed())
+	_, err = VerifyAffinityRule(computeClusterCtx, "DC0_C0", "blah-host-group", "blah-vm-group")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(DeleteAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule")).To(Succeed())
+	g.Expect(DeleteAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule")).To(Succeed())
+	_, err = VerifyAffinityRule(computeClusterCtx, "DC0_C0", "blah-host-group", "blah-vm-group")
+	g.Expect(err).To(HaveOccurred())
+
+	for _, name := range []string{"blah-vm-group", "blah-host-group"} {
+		g.Expect(DeleteGroup(computeClusterCtx, "DC0_C0", name)).To(Succeed())
+		g.Expect(DeleteGroup(computeClusterCtx, "DC0_C0", name)).To(Succeed())
+		group, err = findGroup(ctx, ccr, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(group).To(BeNil())
+	}
+}
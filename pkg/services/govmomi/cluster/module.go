@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+	vapicluster "github.com/vmware/govmomi/vapi/cluster"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// minModuleAPIVersion is the first vSphere API version supporting cluster
+// modules, vSphere 7.0 Update 1.
+var minModuleAPIVersion = version.Must(version.NewVersion("7.0.1"))
+
+// IsModuleSupported returns whether the vCenter supports cluster modules.
+func IsModuleSupported(ctx computeClusterContext) (bool, error) {
+	s := ctx.GetSession()
+	if s.TagManager == nil {
+		return false, nil
+	}
+	apiVersion, err := version.NewVersion(s.ServiceContent.About.ApiVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to parse vSphere API version %q", s.ServiceContent.About.ApiVersion)
+	}
+	return apiVersion.GreaterThanOrEqual(minModuleAPIVersion), nil
+}
+
+// CreateModule creates a cluster module in the compute cluster and returns
+// its UUID.
+func CreateModule(ctx computeClusterContext, clusterName string) (string, error) {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return "", err
+	}
+	return moduleManager(ctx).CreateModule(ctx, ccr)
+}
+
+// ModuleExists returns whether the cluster module with the UUID exists.
+func ModuleExists(ctx computeClusterContext, moduleUUID string) (bool, error) {
+	modules, err := moduleManager(ctx).ListModules(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to list cluster modules")
+	}
+	for _, module := range modules {
+		if module.Module == moduleUUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteModule deletes the cluster module with the UUID if it exists.
+func DeleteModule(ctx computeClusterContext, moduleUUID string) error {
+	exists, err := ModuleExists(ctx, moduleUUID)
+	if err != nil || !exists {
+		return err
+	}
+	return moduleManager(ctx).DeleteModule(ctx, moduleUUID)
+}
+
+// AddModuleMember adds the VM to the cluster module with the UUID. VMs which
+// already are members of the module are ignored.
+func AddModuleMember(ctx computeClusterContext, moduleUUID string, vm types.ManagedObjectReference) error {
+	members, err := moduleManager(ctx).ListModuleMembers(ctx, moduleUUID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to list members of cluster module %s", moduleUUID)
+	}
+	for _, member := range members {
+		if member == vm {
+			return nil
+		}
+	}
+	if _, err := moduleManager(ctx).AddModuleMembers(ctx, moduleUUID, vm); err != nil {
+		return errors.Wrapf(err, "unable to add VM %s to cluster module %s", vm.Value, moduleUUID)
+	}
+	return nil
+}
+
+func moduleManager(ctx computeClusterContext) *vapicluster.Manager {
+	return vapicluster.NewManager(ctx.GetSession().TagManager.Client)
+}
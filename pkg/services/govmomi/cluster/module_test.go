@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/cluster/simulator"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestModule(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.ServiceContent.About.ApiVersion = "7.0.1.0"
+	sim, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer sim.Destroy()
+
+	s, err := session.GetOrCreate(context.Background(),
+		session.NewParams().
+			WithInsecure(true).
+			WithServer(sim.ServerURL().Host).
+			WithUserInfo(sim.Username(), sim.Password()).
+			WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	computeClusterCtx := testComputeClusterCtx{
+		Context: context.Background(),
+		session: s,
+	}
+
+	supported, err := IsModuleSupported(computeClusterCtx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(supported).To(BeTrue())
+
+	moduleUUID, err := CreateModule(computeClusterCtx, "DC0_C0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(moduleUUID).NotTo(BeEmpty())
+
+	exists, err := ModuleExists(computeClusterCtx, moduleUUID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exists).To(BeTrue())
+
+	vm, err := s.Finder.VirtualMachine(computeClusterCtx, "DC0_C0_RP0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(AddModuleMember(computeClusterCtx, moduleUUID, vm.Reference())).To(Succeed())
+	g.Expect(AddModuleMember(computeClusterCtx, moduleUUID, vm.Reference())).To(Succeed())
+	members, err := moduleManager(computeClusterCtx).ListModuleMembers(computeClusterCtx, moduleUUID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(members).To(ConsistOf(vm.Reference()))
+
+	g.Expect(DeleteModule(computeClusterCtx, moduleUUID)).To(Succeed())
+	exists, err = ModuleExists(computeClusterCtx, moduleUUID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exists).To(BeFalse())
+	g.Expect(DeleteModule(computeClusterCtx, moduleUUID)).To(Succeed())
+
+	s.ServiceContent.About.ApiVersion = "7.0.0.0"
+	supported, err = IsModuleSupported(computeClusterCtx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(supported).To(BeFalse())
+}
@@ -18,6 +18,7 @@ package cluster
 
 import (
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/types"
 	"k8s.io/utils/pointer"
 )
@@ -64,6 +65,200 @@ func VerifyAffinityRule(ctx computeClusterContext, clusterName, hostGroupName, v
 	return nil, errors.New("no matching affinity rule found/exists")
 }
 
+// ReconcileAffinityRule ensures that a VM-Host affinity rule between the VM and
+// host groups of the compute cluster exists. A missing rule is created with the
+// given name, enabled and mandatory or preferred as requested. An existing rule
+// is only updated if update is set, such as for a rule which was created by
+// ReconcileAffinityRule before. It returns whether the rule was created.
+func ReconcileAffinityRule(ctx computeClusterContext, clusterName, ruleName, hostGroupName, vmGroupName string, mandatory, update bool) (bool, error) {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return false, err
+	}
+
+	rule, err := findAffinityRule(ctx, ccr, hostGroupName, vmGroupName)
+	if err != nil {
+		return false, err
+	}
+
+	operation := types.ArrayUpdateOperationEdit
+	if rule == nil {
+		operation = types.ArrayUpdateOperationAdd
+		rule = &types.ClusterVmHostRuleInfo{
+			ClusterRuleInfo: types.ClusterRuleInfo{
+				Name: ruleName,
+			},
+			VmGroupName:         vmGroupName,
+			AffineHostGroupName: hostGroupName,
+		}
+	} else if !update || (pointer.BoolDeref(rule.Enabled, false) && pointer.BoolDeref(rule.Mandatory, false) == mandatory) {
+		return false, nil
+	}
+	rule.Enabled = pointer.Bool(true)
+	rule.Mandatory = pointer.Bool(mandatory)
+
+	spec := &types.ClusterConfigSpecEx{
+		RulesSpec: []types.ClusterRuleSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: operation,
+				},
+				Info: rule,
+			},
+		},
+	}
+	err = reconfigure(ctx, ccr, spec)
+	return operation == types.ArrayUpdateOperationAdd && err == nil, err
+}
+
+// DeleteAffinityRule deletes the VM-Host affinity rule with the given name
+// from the compute cluster if it exists.
+func DeleteAffinityRule(ctx computeClusterContext, clusterName, ruleName string) error {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return err
+	}
+
+	clusterConfigInfoEx, err := ccr.Configuration(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to list rules for compute cluster %s", ccr.InventoryPath)
+	}
+	for _, rule := range clusterConfigInfoEx.Rule {
+		vmHostRuleInfo, ok := rule.(*types.ClusterVmHostRuleInfo)
+		if !ok || vmHostRuleInfo.Name != ruleName {
+			continue
+		}
+		spec := &types.ClusterConfigSpecEx{
+			RulesSpec: []types.ClusterRuleSpec{
+				{
+					ArrayUpdateSpec: types.ArrayUpdateSpec{
+						Operation: types.ArrayUpdateOperationRemove,
+						RemoveKey: vmHostRuleInfo.Key,
+					},
+				},
+			},
+		}
+		return reconfigure(ctx, ccr, spec)
+	}
+	return nil
+}
+
+func findAffinityRule(ctx computeClusterContext, ccr *object.ClusterComputeResource, hostGroupName, vmGroupName string) (*types.ClusterVmHostRuleInfo, error) {
+	clusterConfigInfoEx, err := ccr.Configuration(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list rules for compute cluster %s", ccr.InventoryPath)
+	}
+
+	for _, rule := range clusterConfigInfoEx.Rule {
+		if vmHostRuleInfo, ok := rule.(*types.ClusterVmHostRuleInfo); ok &&
+			vmHostRuleInfo.AffineHostGroupName == hostGroupName && vmHostRuleInfo.VmGroupName == vmGroupName {
+			return vmHostRuleInfo, nil
+		}
+	}
+	return nil, nil
+}
+
+// AddToAntiAffinityRule adds the VMs to the DRS VM-VM anti-affinity rule of
+// the compute cluster with the given name, creating the rule if it does not
+// exist. A nil task is returned if all VMs already are members of the rule.
+// DRS requires the rule to have at least two members, so no rule is created
+// for a single VM.
+func AddToAntiAffinityRule(ctx computeClusterContext, clusterName, ruleName string, vms ...types.ManagedObjectReference) (*object.Task, error) {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := findAntiAffinityRule(ctx, ccr, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	operation := types.ArrayUpdateOperationEdit
+	if rule == nil {
+		operation = types.ArrayUpdateOperationAdd
+		rule = &types.ClusterAntiAffinityRuleSpec{
+			ClusterRuleInfo: types.ClusterRuleInfo{
+				Name:    ruleName,
+				Enabled: pointer.Bool(true),
+			},
+		}
+	}
+
+	updated := false
+	for _, vm := range vms {
+		if !containsRef(rule.Vm, vm) {
+			rule.Vm = append(rule.Vm, vm)
+			updated = true
+		}
+	}
+	if !updated || len(rule.Vm) < 2 {
+		return nil, nil
+	}
+
+	spec := &types.ClusterConfigSpecEx{
+		RulesSpec: []types.ClusterRuleSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: operation,
+				},
+				Info: rule,
+			},
+		},
+	}
+	return ccr.Reconfigure(ctx, spec, true)
+}
+
+// DeleteAntiAffinityRule deletes the DRS VM-VM anti-affinity rule of the
+// compute cluster with the given name. A nil task is returned if the rule
+// does not exist.
+func DeleteAntiAffinityRule(ctx computeClusterContext, clusterName, ruleName string) (*object.Task, error) {
+	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := findAntiAffinityRule(ctx, ccr, ruleName)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	spec := &types.ClusterConfigSpecEx{
+		RulesSpec: []types.ClusterRuleSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: types.ArrayUpdateOperationRemove,
+					RemoveKey: rule.Key,
+				},
+			},
+		},
+	}
+	return ccr.Reconfigure(ctx, spec, true)
+}
+
+func findAntiAffinityRule(ctx computeClusterContext, ccr *object.ClusterComputeResource, ruleName string) (*types.ClusterAntiAffinityRuleSpec, error) {
+	clusterConfigInfoEx, err := ccr.Configuration(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list rules for compute cluster %s", ccr.InventoryPath)
+	}
+
+	for _, rule := range clusterConfigInfoEx.Rule {
+		if antiAffinityRule, ok := rule.(*types.ClusterAntiAffinityRuleSpec); ok && antiAffinityRule.Name == ruleName {
+			return antiAffinityRule, nil
+		}
+	}
+	return nil, nil
+}
+
+func containsRef(refs []types.ManagedObjectReference, ref types.ManagedObjectReference) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
 func listRules(ctx computeClusterContext, clusterName string) ([]types.BaseClusterRuleInfo, error) {
 	ccr, err := ctx.GetSession().Finder.ClusterComputeResource(ctx, clusterName)
 	if err != nil {
@@ -23,6 +23,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/types"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
 )
@@ -56,3 +57,64 @@ func TestVerifyAffinityRule(t *testing.T) {
 	g.Expect(rule.IsMandatory()).To(BeTrue())
 	g.Expect(rule.Disabled()).To(BeFalse())
 }
+
+func TestAntiAffinityRule(t *testing.T) {
+	g := NewWithT(t)
+	sim, err := vcsim.NewBuilder().Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer sim.Destroy()
+
+	ctx := context.Background()
+	client, _ := govmomi.NewClient(ctx, sim.ServerURL(), true)
+	finder := find.NewFinder(client.Client, false)
+
+	dc, _ := finder.DatacenterOrDefault(ctx, "DC0")
+	finder.SetDatacenter(dc)
+
+	computeClusterCtx := testComputeClusterCtx{
+		Context: context.Background(),
+		finder:  finder,
+	}
+
+	var refs []types.ManagedObjectReference
+	for _, name := range []string{"DC0_C0_RP0_VM0", "DC0_C0_RP0_VM1"} {
+		vm, err := finder.VirtualMachine(ctx, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		refs = append(refs, vm.Reference())
+	}
+
+	ccr, err := finder.ClusterComputeResource(ctx, "DC0_C0")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// A rule is not created for a single VM.
+	task, err := AddToAntiAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule", refs[0])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task).To(BeNil())
+
+	task, err = AddToAntiAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule", refs...)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task).NotTo(BeNil())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+
+	rule, err := findAntiAffinityRule(computeClusterCtx, ccr, "blah-rule")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rule).NotTo(BeNil())
+	g.Expect(rule.Vm).To(ConsistOf(refs))
+
+	task, err = AddToAntiAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule", refs[1])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task).To(BeNil())
+
+	task, err = DeleteAntiAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task).NotTo(BeNil())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+
+	rule, err = findAntiAffinityRule(computeClusterCtx, ccr, "blah-rule")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rule).To(BeNil())
+
+	task, err = DeleteAntiAffinityRule(computeClusterCtx, "DC0_C0", "blah-rule")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task).To(BeNil())
+}
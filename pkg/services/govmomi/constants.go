@@ -26,4 +26,6 @@ const (
 	guestInfoKeyMetadataEnc = "guestinfo.metadata.encoding"
 	guestInfoKeyUserdata    = "guestinfo.userdata"
 	guestInfoKeyUserdataEnc = "guestinfo.userdata.encoding"
+	guestInfoKeyIgnition    = "guestinfo.ignition.config.data"
+	guestInfoKeyIgnitionEnc = "guestinfo.ignition.config.data.encoding"
 )
@@ -17,14 +17,32 @@ limitations under the License.
 package govmomi
 
 import (
+	"github.com/vmware/govmomi/vim25/types"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/esxi"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
 )
 
-func createVM(ctx *context.VMContext, bootstrapData []byte) error {
+// createVM creates the VM once the number of concurrent clones on the server
+// and on the datastore the VM is placed on allows it.
+func createVM(ctx *context.VMContext, bootstrapData []byte, format bootstrapv1.Format) error {
+	acquire := func(datastore types.ManagedObjectReference) bool {
+		return acquireOperationSlot(ctx, infrav1.VMProvisionedCondition, datastore.Value)
+	}
+	var err error
 	if ctx.Session.IsVC() {
-		return vcenter.Clone(ctx, bootstrapData)
+		err = vcenter.Clone(ctx, bootstrapData, format, acquire)
+	} else {
+		err = esxi.Clone(ctx, bootstrapData, format, acquire)
+	}
+	if err != nil {
+		// The template, folder, resource pool, datastores and networks are
+		// cached by the session. They are found again on the next attempt, in
+		// case the VM could not be created because one of them was replaced.
+		ctx.Session.Inventory.Reset()
 	}
-	return esxi.Clone(ctx, bootstrapData)
+	return err
 }
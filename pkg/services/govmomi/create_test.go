@@ -45,6 +45,7 @@ func TestCreate(t *testing.T) {
 	authSession, err := session.GetOrCreate(
 		vmContext.Context,
 		session.NewParams().
+			WithInsecure(true).
 			WithServer(vmContext.VSphereVM.Spec.Server).
 			WithUserInfo(simr.Username(), simr.Password()).
 			WithDatacenter("*"))
@@ -59,11 +60,17 @@ func TestCreate(t *testing.T) {
 	disk := object.VirtualDeviceList(vm.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
 	disk.CapacityInKB = int64(vmContext.VSphereVM.Spec.DiskGiB) * 1024 * 1024
 
-	if err := createVM(vmContext, []byte("")); err != nil {
+	if err := createVM(vmContext, []byte(""), ""); err != nil {
 		t.Fatal(err)
 	}
 
 	if model.Machine+1 != model.Count().Machine {
 		t.Error("failed to clone vm")
 	}
+
+	// Wait for the clone task so it does not outlive the simulator.
+	task := object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+	if err := task.Wait(vmContext); err != nil {
+		t.Fatal(err)
+	}
 }
@@ -20,6 +20,8 @@ import (
 	"fmt"
 
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 // errNotFound is returned by the findVM function when a VM is not found.
@@ -73,3 +75,13 @@ func wasNotFoundByBIOSUUID(err error) bool {
 		return false
 	}
 }
+
+func isFileNotFound(err error) bool {
+	switch err := err.(type) {
+	case task.Error:
+		_, ok := err.Fault().(*types.FileNotFound)
+		return ok
+	default:
+		return false
+	}
+}
@@ -17,12 +17,387 @@ limitations under the License.
 package esxi
 
 import (
+	"fmt"
+	"path"
+	"strings"
+
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
 )
 
 // Clone kicks off a clone operation on ESXi to create a new virtual machine.
-func Clone(ctx *context.VMContext, bootstrapData []byte) error {
-	return errors.New("temporarily disabled esxi support")
+//
+// ESXi does not support the CloneVM_Task API, so the template's disks are
+// copied into a new directory on the target datastore and a new VM is
+// created from the copied disks. The rest of the VM's hardware, network and
+// guestinfo configuration is applied the same way as on vCenter.
+//
+// Every call starts a single task, such as copying a disk or creating the VM,
+// and records it in the VSphereVM's status. Clone is called again to take the
+// next step once the task completed. No task is started unless acquire
+// returns true for the target datastore.
+// nolint:gocognit
+func Clone(ctx *context.VMContext, bootstrapData []byte, format bootstrapv1.Format, acquire func(datastore types.ManagedObjectReference) bool) error {
+	ctx = &context.VMContext{
+		ControllerContext: ctx.ControllerContext,
+		VSphereVM:         ctx.VSphereVM,
+		Session:           ctx.Session,
+		Logger:            ctx.Logger.WithName("esxi"),
+		PatchHelper:       ctx.PatchHelper,
+	}
+	ctx.Logger.Info("starting clone process")
+
+	extraConfig, err := vcenter.GetExtraConfig(ctx, bootstrapData, format)
+	if err != nil {
+		return err
+	}
+
+	tpl, err := template.FindTemplate(ctx, ctx.VSphereVM.Spec.Template)
+	if err != nil {
+		return err
+	}
+
+	var tplObj mo.VirtualMachine
+	if err := tpl.Properties(ctx, tpl.Reference(), []string{"config"}, &tplObj); err != nil {
+		return errors.Wrapf(err, "error getting config for template %s", ctx.VSphereVM.Spec.Template)
+	}
+	if tplObj.Config == nil {
+		return errors.Errorf("template %s has no config", ctx.VSphereVM.Spec.Template)
+	}
+
+	// ESXi has no notion of linked clones, so the disks are always fully
+	// copied.
+	if ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone {
+		ctx.Logger.Info("linked clone is not supported on ESXi, falling back to full clone")
+	}
+	ctx.VSphereVM.Status.CloneMode = infrav1.FullClone
+
+	if ctx.VSphereVM.Spec.StoragePolicyName != "" {
+		ctx.Logger.Info("storage policies are not supported on ESXi, ignoring", "storagePolicyName", ctx.VSphereVM.Spec.StoragePolicyName)
+	}
+	if ctx.VSphereVM.Spec.DatastoreCluster != "" || ctx.VSphereVM.Spec.PlacementPolicy == infrav1.RecommendedPlacementPolicy {
+		ctx.Logger.Info("DRS placement is not supported on ESXi, using the default datastore", "datastoreCluster", ctx.VSphereVM.Spec.DatastoreCluster)
+	}
+
+	datacenter, err := ctx.Session.Finder.DatacenterOrDefault(ctx, ctx.VSphereVM.Spec.Datacenter)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datacenter for %q", ctx)
+	}
+
+	folder, err := ctx.Session.Inventory.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get folder for %q", ctx)
+	}
+
+	pool, err := ctx.Session.Inventory.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get resource pool for %q", ctx)
+	}
+
+	datastore, err := ctx.Session.Inventory.DatastoreOrDefault(ctx, ctx.VSphereVM.Spec.Datastore)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datastore for %q", ctx)
+	}
+	if !acquire(datastore.Reference()) {
+		return nil
+	}
+	ctx.VSphereVM.Status.Placement = &infrav1.VirtualMachinePlacementStatus{Datastore: datastore.Name()}
+
+	devices := object.VirtualDeviceList(tplObj.Config.Hardware.Device)
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+
+	// Record the template's disk sizes before GetDiskSpec updates the
+	// capacity of the disks to the requested sizes.
+	tplCapacitiesKB := make([]int64, 0, len(disks))
+	for _, disk := range disks {
+		tplCapacitiesKB = append(tplCapacitiesKB, disk.(*types.VirtualDisk).CapacityInKB) //nolint:forcetypeassert
+	}
+	if _, err := vcenter.GetDiskSpec(ctx, devices); err != nil {
+		return errors.Wrapf(err, "error getting disk spec for %q", ctx)
+	}
+
+	if err := makeVMDirectory(ctx, datacenter, datastore); err != nil {
+		return err
+	}
+
+	// Create a new list of device specs for creating the VM.
+	var deviceSpecs []types.BaseVirtualDeviceConfigSpec
+
+	// Controllers and disks are added with temporary device keys so that
+	// unique keys are generated when the devices are created.
+	key := int32(-200)
+	controllerKeys := map[int32]int32{}
+	for i, d := range disks {
+		disk := d.(*types.VirtualDisk) //nolint:forcetypeassert
+		backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		if !ok {
+			return errors.Errorf("unsupported backing %T for disk %d of template %s", disk.Backing, i, ctx.VSphereVM.Spec.Template)
+		}
+
+		diskPath := datastore.Path(path.Join(ctx.VSphereVM.Name, diskFileName(ctx.VSphereVM.Name, i)))
+		if copied, err := reconcileDisk(ctx, datacenter, datastore, backing, diskPath, tplCapacitiesKB[i], disk.CapacityInKB); err != nil || !copied {
+			return err
+		}
+
+		newControllerKey, ok := controllerKeys[disk.ControllerKey]
+		if !ok {
+			newControllerKey = disk.ControllerKey
+			controller, isController := devices.FindByKey(disk.ControllerKey).(types.BaseVirtualController)
+			// IDE controllers are part of the default devices of every VM,
+			// any other controller needs to be created.
+			if _, isIDE := controller.(*types.VirtualIDEController); isController && !isIDE {
+				newControllerKey = key
+				key--
+				controller.GetVirtualController().Key = newControllerKey
+				controller.GetVirtualController().Device = nil
+				deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+					Operation: types.VirtualDeviceConfigSpecOperationAdd,
+					Device:    controller.(types.BaseVirtualDevice),
+				})
+			}
+			controllerKeys[disk.ControllerKey] = newControllerKey
+		}
+
+		dsRef := datastore.Reference()
+		deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device: &types.VirtualDisk{
+				VirtualDevice: types.VirtualDevice{
+					Key:           key,
+					ControllerKey: newControllerKey,
+					UnitNumber:    disk.UnitNumber,
+					Backing: &types.VirtualDiskFlatVer2BackingInfo{
+						VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{
+							FileName:  diskPath,
+							Datastore: &dsRef,
+						},
+						DiskMode:        backing.DiskMode,
+						ThinProvisioned: backing.ThinProvisioned,
+						EagerlyScrub:    backing.EagerlyScrub,
+					},
+				},
+				CapacityInKB: disk.CapacityInKB,
+			},
+		})
+		key--
+	}
+
+	// Data disks are attached to the controllers of the new VM.
+	var newDevices object.VirtualDeviceList
+	for _, deviceSpec := range deviceSpecs {
+		newDevices = append(newDevices, deviceSpec.GetVirtualDeviceConfigSpec().Device)
+	}
+	dataDiskSpecs, err := vcenter.GetDataDiskSpecs(ctx, newDevices)
+	if err != nil {
+		return errors.Wrapf(err, "error getting data disk specs for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, dataDiskSpecs...)
+
+	// The template's NICs are not copied, only the network devices of the
+	// VSphereVM are added.
+	networkSpecs, err := vcenter.GetNetworkSpecs(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "error getting network specs for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, networkSpecs...)
+	deviceSpecs = append(deviceSpecs, vcenter.GetPCIDeviceSpecs(ctx)...)
+
+	spec := vcenter.NewConfigSpec(ctx, deviceSpecs, extraConfig)
+	spec.Name = ctx.VSphereVM.Name
+	spec.GuestId = tplObj.Config.GuestId
+	spec.Version = tplObj.Config.Version
+	spec.Firmware = tplObj.Config.Firmware
+	spec.Files = &types.VirtualMachineFileInfo{
+		VmPathName: datastore.Path(ctx.VSphereVM.Name),
+	}
+
+	ctx.Logger.Info("creating machine", "namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name, "cloneType", ctx.VSphereVM.Status.CloneMode)
+	task, err := folder.CreateVM(ctx, *spec, pool, nil)
+	if err != nil {
+		return errors.Wrapf(err, "error trigging create op for machine %s", ctx)
+	}
+
+	setTaskRef(ctx, task)
+	return nil
+}
+
+// setTaskRef records the task in the VSphereVM's status.
+func setTaskRef(ctx *context.VMContext, task *object.Task) {
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+
+	// patch the vsphereVM early to ensure that the task is
+	// reflected in the status right away, this avoid situations
+	// of concurrent clones
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+}
+
+// diskFileName returns the name of the VM's disk file at the given index,
+// following the naming convention used by ESXi.
+func diskFileName(vmName string, index int) string {
+	if index == 0 {
+		return vmName + ".vmdk"
+	}
+	return fmt.Sprintf("%s_%d.vmdk", vmName, index)
+}
+
+// makeVMDirectory creates the directory holding the VM's files unless it
+// already exists from a previous attempt.
+func makeVMDirectory(ctx *context.VMContext, datacenter *object.Datacenter, datastore *object.Datastore) error {
+	if _, err := datastore.Stat(ctx, ctx.VSphereVM.Name); err == nil {
+		return nil
+	} else if !isNoSuchFile(err) {
+		return errors.Wrapf(err, "unable to stat directory for %q", ctx)
+	}
+
+	fm := object.NewFileManager(ctx.Session.Client.Client)
+	if err := fm.MakeDirectory(ctx, datastore.Path(ctx.VSphereVM.Name), datacenter, true); err != nil {
+		return errors.Wrapf(err, "unable to create directory for %q", ctx)
+	}
+	return nil
+}
+
+// stagingDiskPath returns the path the disk at diskPath is copied and grown
+// at, before it is renamed to diskPath.
+func stagingDiskPath(diskPath string) string {
+	return strings.TrimSuffix(diskPath, ".vmdk") + "-copy.vmdk"
+}
+
+// reconcileDisk takes the next step of copying the template's disk to
+// diskPath and growing it to capacityKB, and returns whether the disk is
+// complete. The disk is copied and grown at its staging path and only renamed
+// to diskPath once it is complete, so that a disk whose copy failed halfway
+// is never mistaken for a complete one. The staged disk is deleted and copied
+// again if the last task failed.
+func reconcileDisk(ctx *context.VMContext, datacenter *object.Datacenter, datastore *object.Datastore, backing *types.VirtualDiskFlatVer2BackingInfo, diskPath string, tplCapacityKB, capacityKB int64) (bool, error) {
+	if exists, err := diskExists(ctx, datastore, diskPath); err != nil || exists {
+		return exists, err
+	}
+	stagingPath := stagingDiskPath(diskPath)
+	staged, err := diskExists(ctx, datastore, stagingPath)
+	if err != nil {
+		return false, err
+	}
+
+	vdm := object.NewVirtualDiskManager(ctx.Session.Client.Client)
+	var task *object.Task
+	switch {
+	case !staged:
+		diskType := types.VirtualDiskTypePreallocated
+		switch {
+		case backing.ThinProvisioned != nil && *backing.ThinProvisioned:
+			diskType = types.VirtualDiskTypeThin
+		case backing.EagerlyScrub != nil && *backing.EagerlyScrub:
+			diskType = types.VirtualDiskTypeEagerZeroedThick
+		}
+
+		ctx.Logger.Info("copying disk", "source", backing.FileName, "destination", stagingPath, "diskType", diskType)
+		task, err = vdm.CopyVirtualDisk(ctx, backing.FileName, datacenter, stagingPath, datacenter, &types.VirtualDiskSpec{
+			DiskType:    string(diskType),
+			AdapterType: string(types.VirtualDiskAdapterTypeLsiLogic),
+		}, false)
+		if err != nil {
+			return false, errors.Wrapf(err, "error trigging copy op for disk %s", backing.FileName)
+		}
+	case ctx.VSphereVM.Status.TaskRetries > 0:
+		ctx.Logger.Info("deleting incomplete disk", "disk", stagingPath)
+		task, err = vdm.DeleteVirtualDisk(ctx, stagingPath, datacenter)
+		if err != nil {
+			return false, errors.Wrapf(err, "error trigging delete op for disk %s", stagingPath)
+		}
+	default:
+		grow := false
+		if capacityKB > tplCapacityKB {
+			stagedCapacityKB, err := diskCapacityKB(ctx, datastore, stagingPath)
+			if err != nil {
+				return false, err
+			}
+			grow = stagedCapacityKB < capacityKB
+		}
+		if grow {
+			ctx.Logger.Info("extending disk", "disk", stagingPath, "capacityInKB", capacityKB)
+			dcRef := datacenter.Reference()
+			res, err := methods.ExtendVirtualDisk_Task(ctx, ctx.Session.Client.Client, &types.ExtendVirtualDisk_Task{
+				This:          *ctx.Session.Client.Client.ServiceContent.VirtualDiskManager,
+				Name:          stagingPath,
+				Datacenter:    &dcRef,
+				NewCapacityKb: capacityKB,
+			})
+			if err != nil {
+				return false, errors.Wrapf(err, "error trigging extend op for disk %s", stagingPath)
+			}
+			task = object.NewTask(ctx.Session.Client.Client, res.Returnval)
+		} else {
+			ctx.Logger.Info("renaming disk", "source", stagingPath, "destination", diskPath)
+			task, err = vdm.MoveVirtualDisk(ctx, stagingPath, datacenter, diskPath, datacenter, false)
+			if err != nil {
+				return false, errors.Wrapf(err, "error trigging move op for disk %s", stagingPath)
+			}
+		}
+	}
+
+	setTaskRef(ctx, task)
+	return false, nil
+}
+
+// diskExists returns whether the disk at the given datastore path exists.
+func diskExists(ctx *context.VMContext, datastore *object.Datastore, diskPath string) (bool, error) {
+	var dsPath object.DatastorePath
+	dsPath.FromString(diskPath)
+	if _, err := datastore.Stat(ctx, dsPath.Path); err == nil {
+		return true, nil
+	} else if !isNoSuchFile(err) {
+		return false, errors.Wrapf(err, "unable to stat disk %s for %q", diskPath, ctx)
+	}
+	return false, nil
+}
+
+// diskCapacityKB returns the capacity of the disk at the given datastore path.
+func diskCapacityKB(ctx *context.VMContext, datastore *object.Datastore, diskPath string) (int64, error) {
+	var dsPath object.DatastorePath
+	dsPath.FromString(diskPath)
+	browser, err := datastore.Browser(ctx)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to get browser of datastore %s", datastore.Name())
+	}
+	task, err := browser.SearchDatastore(ctx, datastore.Path(path.Dir(dsPath.Path)), &types.HostDatastoreBrowserSearchSpec{
+		Query: []types.BaseFileQuery{&types.VmDiskFileQuery{
+			Details: &types.VmDiskFileQueryFlags{CapacityKb: true},
+		}},
+		MatchPattern: []string{path.Base(dsPath.Path)},
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to search for disk %s", diskPath)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to search for disk %s", diskPath)
+	}
+	if res, ok := info.Result.(types.HostDatastoreBrowserSearchResults); ok {
+		for _, file := range res.File {
+			if disk, ok := file.(*types.VmDiskFileInfo); ok {
+				return disk.CapacityKb, nil
+			}
+		}
+	}
+	return 0, errors.Errorf("unable to get capacity of disk %s", diskPath)
+}
+
+func isNoSuchFile(err error) bool {
+	switch err.(type) {
+	case object.DatastoreNoSuchFileError, object.DatastoreNoSuchDirectoryError:
+		return true
+	default:
+		return false
+	}
 }
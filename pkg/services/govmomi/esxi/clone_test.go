@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package esxi
+
+import (
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+//nolint:forcetypeassert
+func TestClone(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := vcsim.NewBuilder().WithModel(simulator.ESX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+	vmContext.VSphereVM.Spec.Datacenter = "*"
+
+	authSession, err := session.GetOrCreate(
+		vmContext.Context,
+		session.NewParams().
+			WithInsecure(true).
+			WithServer(vmContext.VSphereVM.Spec.Server).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter(vmContext.VSphereVM.Spec.Datacenter))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(authSession.IsVC()).To(BeFalse())
+	vmContext.Session = authSession
+
+	tpl := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	vmContext.VSphereVM.Spec.Template = tpl.Name
+	tplDisk := object.VirtualDeviceList(tpl.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
+	vmContext.VSphereVM.Spec.DiskGiB = int32(tplDisk.CapacityInKB / 1024 / 1024)
+
+	acquire := func(types.ManagedObjectReference) bool { return true }
+
+	t.Run("creates a VM from the template's disks", func(t *testing.T) {
+		g := NewWithT(t)
+
+		tasks, vm := cloneVM(g, vmContext, acquire)
+		g.Expect(tasks).To(Equal([]string{
+			"VirtualDiskManager.copyVirtualDisk",
+			"VirtualDiskManager.moveVirtualDisk",
+			"Folder.createVm",
+		}))
+		g.Expect(vm.Config.Name).To(Equal(vmContext.VSphereVM.Name))
+		g.Expect(vm.Config.InstanceUuid).To(Equal(string(vmContext.VSphereVM.UID)))
+		g.Expect(vm.Config.GuestId).To(Equal(tpl.Config.GuestId))
+		g.Expect(vm.Config.Hardware.NumCPU).To(Equal(vmContext.VSphereVM.Spec.NumCPUs))
+		g.Expect(int64(vm.Config.Hardware.MemoryMB)).To(Equal(vmContext.VSphereVM.Spec.MemoryMiB))
+
+		devices := object.VirtualDeviceList(vm.Config.Hardware.Device)
+		disks := devices.SelectByType((*types.VirtualDisk)(nil))
+		g.Expect(disks).To(HaveLen(1))
+		backing := disks[0].GetVirtualDevice().Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		g.Expect(backing.FileName).To(Equal("[LocalDS_0] fake-vm/fake-vm.vmdk"))
+		g.Expect(devices.SelectByType((*types.VirtualEthernetCard)(nil))).To(HaveLen(len(vmContext.VSphereVM.Spec.Network.Devices)))
+
+		extraConfig := map[string]interface{}{}
+		for _, ec := range vm.Config.ExtraConfig {
+			extraConfig[ec.GetOptionValue().Key] = ec.GetOptionValue().Value
+		}
+		g.Expect(extraConfig).To(HaveKeyWithValue("guestinfo.userdata.encoding", "gzip+base64"))
+		g.Expect(extraConfig).To(HaveKey("guestinfo.userdata"))
+	})
+
+	t.Run("copies a disk again if the last task failed", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ctx := *vmContext
+		ctx.VSphereVM = vmContext.VSphereVM.DeepCopy()
+		ctx.VSphereVM.Name = "failed-vm"
+		ctx.VSphereVM.UID = "failed-vm"
+		ctx.VSphereVM.Status = infrav1.VSphereVMStatus{}
+		stageDisk(g, &ctx, tplDisk)
+		ctx.VSphereVM.Status.TaskRetries = 1
+
+		tasks, _ := cloneVM(g, &ctx, acquire)
+		g.Expect(tasks).To(Equal([]string{
+			"VirtualDiskManager.deleteVirtualDisk",
+			"VirtualDiskManager.copyVirtualDisk",
+			"VirtualDiskManager.moveVirtualDisk",
+			"Folder.createVm",
+		}))
+	})
+
+	t.Run("reuses a disk copied by a previous attempt", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ctx := *vmContext
+		ctx.VSphereVM = vmContext.VSphereVM.DeepCopy()
+		ctx.VSphereVM.Name = "copied-vm"
+		ctx.VSphereVM.UID = "copied-vm"
+		ctx.VSphereVM.Status = infrav1.VSphereVMStatus{}
+		stageDisk(g, &ctx, tplDisk)
+
+		tasks, _ := cloneVM(g, &ctx, acquire)
+		g.Expect(tasks).To(Equal([]string{
+			"VirtualDiskManager.moveVirtualDisk",
+			"Folder.createVm",
+		}))
+	})
+
+	t.Run("fails to resize the template disk down", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ctx := *vmContext
+		ctx.VSphereVM = vmContext.VSphereVM.DeepCopy()
+		ctx.VSphereVM.Name = "small-vm"
+		ctx.VSphereVM.Spec.DiskGiB = 0
+		g.Expect(Clone(&ctx, nil, "", acquire)).To(MatchError(ContainSubstring("can't resize template disk down")))
+	})
+}
+
+// cloneVM calls Clone and waits for the task it started until the VM is
+// created, and returns the description IDs of the tasks and the VM.
+func cloneVM(g *WithT, ctx *context.VMContext, acquire func(types.ManagedObjectReference) bool) ([]string, mo.VirtualMachine) {
+	var tasks []string
+	for len(tasks) < 10 {
+		g.Expect(Clone(ctx, []byte("bootstrap"), "", acquire)).To(Succeed())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{
+			Type:  "Task",
+			Value: ctx.VSphereVM.Status.TaskRef,
+		})
+		info, err := task.WaitForResult(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		ctx.VSphereVM.Status.TaskRef = ""
+		ctx.VSphereVM.Status.TaskRetries = 0
+		tasks = append(tasks, info.DescriptionId)
+
+		if vmRef, ok := info.Result.(types.ManagedObjectReference); ok {
+			var vm mo.VirtualMachine
+			g.Expect(ctx.Session.RetrieveOne(ctx, vmRef, []string{"config"}, &vm)).To(Succeed())
+			return tasks, vm
+		}
+	}
+	g.Expect(len(tasks)).To(BeNumerically("<", 10), "the VM was not created by the tasks %v", tasks)
+	return tasks, mo.VirtualMachine{}
+}
+
+// stageDisk copies the template's disk to the staging path of the VM's disk,
+// as if a previous attempt copied it.
+func stageDisk(g *WithT, ctx *context.VMContext, tplDisk *types.VirtualDisk) {
+	datacenter, err := ctx.Session.Finder.DefaultDatacenter(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	datastore, err := ctx.Session.Finder.DefaultDatastore(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(object.NewFileManager(ctx.Session.Client.Client).MakeDirectory(ctx, datastore.Path(ctx.VSphereVM.Name), datacenter, true)).To(Succeed())
+
+	diskPath := datastore.Path(path.Join(ctx.VSphereVM.Name, diskFileName(ctx.VSphereVM.Name, 0)))
+	backing := tplDisk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+	task, err := object.NewVirtualDiskManager(ctx.Session.Client.Client).CopyVirtualDisk(ctx, backing.FileName, datacenter, stagingDiskPath(diskPath), datacenter, nil, false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+}
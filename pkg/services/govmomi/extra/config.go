@@ -17,11 +17,42 @@ limitations under the License.
 package extra
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/base64"
+	"io"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
+const (
+	encodingBase64     = "base64"
+	encodingGzipBase64 = "gzip+base64"
+)
+
+// MaxGuestInfoSize is the maximum total size of the guestinfo keys and values
+// of a VM. vSphere limits it to 1MiB by default. Cloud-init user data and
+// metadata above the limit are delivered on a NoCloud ISO instead.
+const MaxGuestInfoSize = 1 << 20
+
+const guestInfoPrefix = "guestinfo."
+
+// CloudInitISOKey is the key holding the SHA-256 checksum of the NoCloud ISO
+// of a VM whose cloud-init user data and metadata are delivered on an ISO
+// instead of the guestinfo. It is CloudInitISOPending until the ISO is
+// attached.
+const CloudInitISOKey = "capv.cidata.sha256"
+
+// CloudInitISOPending is the value of CloudInitISOKey for a VM whose NoCloud
+// ISO is not attached yet.
+const CloudInitISOPending = "pending"
+
+// ErrGuestInfoTooLarge is returned when the guestinfo of a VM would exceed
+// MaxGuestInfoSize.
+var ErrGuestInfoTooLarge = errors.New("guestinfo exceeds the size limit")
+
 // Config is data used with a VM's guestInfo RPC interface.
 type Config []types.BaseOptionValue
 
@@ -38,44 +69,96 @@ func (e *Config) SetCustomVMXKeys(customKeys map[string]string) error {
 }
 
 // SetCloudInitUserData sets the cloud init user data at the key
-// "guestinfo.userdata" as a base64-encoded string.
+// "guestinfo.userdata" as a gzip-compressed, base64-encoded string.
 func (e *Config) SetCloudInitUserData(data []byte) error {
-	*e = append(*e,
-		&types.OptionValue{
-			Key:   "guestinfo.userdata",
-			Value: e.encode(data),
-		},
-		&types.OptionValue{
-			Key:   "guestinfo.userdata.encoding",
-			Value: "base64",
-		},
-	)
-	return nil
+	return e.setEncoded("guestinfo.userdata", data)
+}
+
+// SetIgnitionUserData sets the Ignition config at the key
+// "guestinfo.ignition.config.data" as a gzip-compressed, base64-encoded
+// string.
+func (e *Config) SetIgnitionUserData(data []byte) error {
+	return e.setEncoded("guestinfo.ignition.config.data", data)
+}
+
+// SetCloudInitISO sets the checksum of the NoCloud ISO delivering the
+// cloud-init user data and metadata.
+func (e *Config) SetCloudInitISO(checksum string) {
+	*e = append(*e, &types.OptionValue{
+		Key:   CloudInitISOKey,
+		Value: checksum,
+	})
 }
 
 // SetCloudInitMetadata sets the cloud init user data at the key
-// "guestinfo.metadata" as a base64-encoded string.
+// "guestinfo.metadata" as a gzip-compressed, base64-encoded string.
 func (e *Config) SetCloudInitMetadata(data []byte) error {
+	return e.setEncoded("guestinfo.metadata", data)
+}
+
+// setEncoded sets the data at the key as a gzip-compressed, base64-encoded
+// string and its encoding at the key suffixed with ".encoding".
+func (e *Config) setEncoded(key string, data []byte) error {
+	value, err := e.encode(data)
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode %s", key)
+	}
 	*e = append(*e,
 		&types.OptionValue{
-			Key:   "guestinfo.metadata",
-			Value: e.encode(data),
+			Key:   key,
+			Value: value,
 		},
 		&types.OptionValue{
-			Key:   "guestinfo.metadata.encoding",
-			Value: "base64",
+			Key:   key + ".encoding",
+			Value: encodingGzipBase64,
 		},
 	)
+	return nil
+}
+
+// GuestInfoSize returns the total size of the guestinfo keys and values of
+// the config.
+func (e Config) GuestInfoSize() int {
+	size := 0
+	for _, v := range e {
+		if opt := v.GetOptionValue(); opt != nil && strings.HasPrefix(opt.Key, guestInfoPrefix) {
+			value, _ := opt.Value.(string)
+			size += len(opt.Key) + len(value)
+		}
+	}
+	return size
+}
 
+// ValidateGuestInfoSize returns ErrGuestInfoTooLarge if the guestinfo of the
+// config, together with the guestinfo of the existing config which it does
+// not replace, exceeds MaxGuestInfoSize.
+func (e Config) ValidateGuestInfoSize(existing Config) error {
+	keys := map[string]bool{}
+	for _, v := range e {
+		if opt := v.GetOptionValue(); opt != nil {
+			keys[opt.Key] = true
+		}
+	}
+	var kept Config
+	for _, v := range existing {
+		if opt := v.GetOptionValue(); opt != nil && !keys[opt.Key] {
+			kept = append(kept, v)
+		}
+	}
+
+	size := e.GuestInfoSize() + kept.GuestInfoSize()
+	if size > MaxGuestInfoSize {
+		return errors.Wrapf(ErrGuestInfoTooLarge, "guestinfo is %d bytes, the limit is %d bytes", size, MaxGuestInfoSize)
+	}
 	return nil
 }
 
 // encode first attempts to decode the data as many times as necessary
-// to ensure it is plain-text before returning the result as a base64
-// encoded string.
-func (e *Config) encode(data []byte) string {
+// to ensure it is plain-text before returning the result as a gzip
+// compressed, base64 encoded string.
+func (e *Config) encode(data []byte) (string, error) {
 	if len(data) == 0 {
-		return ""
+		return "", nil
 	}
 	for {
 		decoded, err := base64.StdEncoding.DecodeString(string(data))
@@ -84,5 +167,38 @@ func (e *Config) encode(data []byte) string {
 		}
 		data = decoded
 	}
-	return base64.StdEncoding.EncodeToString(data)
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Decode returns the data of a guestinfo value with the given encoding.
+// Values without an encoding are returned as is.
+func Decode(value, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(value), nil
+	case encodingBase64, "b64":
+		return base64.StdEncoding.DecodeString(value)
+	case encodingGzipBase64, "gz+b64":
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, errors.Errorf("unsupported encoding %q", encoding)
+	}
 }
@@ -17,6 +17,9 @@ limitations under the License.
 package extra
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"testing"
@@ -67,6 +70,17 @@ var _ = Describe("Config_SetCloudInitUserData", func() {
 	)
 })
 
+var _ = Describe("Config_SetIgnitionUserData", func() {
+	ConfigInitFnTester(
+		func(config *Config, s string) error {
+			return config.SetIgnitionUserData([]byte(s))
+		},
+		"SetIgnitionUserData",
+		"guestinfo.ignition.config.data",
+		"guestinfo.ignition.config.data.encoding",
+	)
+})
+
 var _ = Describe("Config_SetCloudInitMetadata", func() {
 	ConfigInitFnTester(func(config *Config, s string) error {
 		return config.SetCloudInitMetadata([]byte(s))
@@ -81,10 +95,18 @@ func base64Encode(s string) string {
 	return base64.StdEncoding.EncodeToString([]byte(s))
 }
 
-// ConfigInitFnTester is a common testing method for config.SetCloudInitUserData and config.SetCloudInitMetadata.
+func gzipBase64Encode(s string) string {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// ConfigInitFnTester is a common testing method for config.SetCloudInitUserData, config.SetIgnitionUserData and config.SetCloudInitMetadata.
 func ConfigInitFnTester(method ConfigInitFn, methodName string, dataKey string, encodingKey string) {
 	const sampleData = "some sample data, "
-	var expectedData = base64Encode(sampleData)
+	var expectedData = gzipBase64Encode(sampleData)
 
 	Context(fmt.Sprintf("we call %q with some non-encoded sample data", methodName), func() {
 		var config Config
@@ -95,19 +117,26 @@ func ConfigInitFnTester(method ConfigInitFn, methodName string, dataKey string,
 			Expect(len(config)).To(Equal(2))
 		})
 
-		It(fmt.Sprintf("must set data as a base64 encoded string with the key %q", dataKey), func() {
+		It(fmt.Sprintf("must set data as a gzip compressed, base64 encoded string with the key %q", dataKey), func() {
 			Expect(config).To(ContainElement(&types.OptionValue{
 				Key:   dataKey,
 				Value: expectedData,
 			}))
 		})
 
-		It("must set a key to indicate base64 encoding of the data", func() {
+		It("must set a key to indicate gzip+base64 encoding of the data", func() {
 			Expect(config).To(ContainElement(&types.OptionValue{
 				Key:   encodingKey,
-				Value: "base64",
+				Value: "gzip+base64",
 			}))
 		})
+
+		It("must decode to the sample data", func() {
+			value := config[0].GetOptionValue().Value.(string)
+			data, err := Decode(value, config[1].GetOptionValue().Value.(string))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(sampleData))
+		})
 	})
 
 	Context(fmt.Sprintf("We call %q with some pre-encoded data (single pass)", methodName), func() {
@@ -115,11 +144,11 @@ func ConfigInitFnTester(method ConfigInitFn, methodName string, dataKey string,
 		preEncodedData := base64Encode(sampleData)
 		err := method(&config, preEncodedData)
 
-		It("does not encode the data further on storing", func() {
+		It("compresses and encodes the decoded data on storing", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(config).To(ContainElement(&types.OptionValue{
 				Key:   dataKey,
-				Value: preEncodedData,
+				Value: expectedData,
 			}))
 		})
 	})
@@ -142,4 +171,74 @@ func ConfigInitFnTester(method ConfigInitFn, methodName string, dataKey string,
 			}))
 		})
 	})
+
 }
+
+var _ = Describe("Config_ValidateGuestInfoSize", func() {
+	randomData := func(size int) []byte {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+		return data
+	}
+
+	It("accepts guestinfo within the size limit", func() {
+		var config Config
+		Expect(config.SetCloudInitUserData(randomData(MaxGuestInfoSize / 2))).To(Succeed())
+		Expect(config.ValidateGuestInfoSize(nil)).To(Succeed())
+	})
+
+	It("rejects a single value exceeding the size limit", func() {
+		var config Config
+		Expect(config.SetCloudInitUserData(randomData(MaxGuestInfoSize))).To(Succeed())
+		Expect(config.ValidateGuestInfoSize(nil)).To(MatchError(ErrGuestInfoTooLarge))
+	})
+
+	It("rejects the combined user data and metadata exceeding the size limit", func() {
+		// Encoding random data grows it by a third.
+		var existing Config
+		Expect(existing.SetCloudInitUserData(randomData(MaxGuestInfoSize / 2))).To(Succeed())
+		Expect(existing.SetCustomVMXKeys(map[string]string{"svga.present": string(randomData(MaxGuestInfoSize / 2))})).To(Succeed())
+		Expect(existing.ValidateGuestInfoSize(nil)).To(Succeed())
+
+		var config Config
+		Expect(config.SetCloudInitMetadata(randomData(MaxGuestInfoSize / 2))).To(Succeed())
+		Expect(config.ValidateGuestInfoSize(existing)).To(MatchError(ErrGuestInfoTooLarge))
+	})
+
+	It("does not count replaced values towards the size limit", func() {
+		var existing Config
+		Expect(existing.SetCloudInitUserData(randomData(MaxGuestInfoSize / 8))).To(Succeed())
+		Expect(existing.SetCloudInitMetadata(randomData(MaxGuestInfoSize / 2))).To(Succeed())
+
+		var config Config
+		Expect(config.SetCloudInitMetadata(randomData(MaxGuestInfoSize / 2))).To(Succeed())
+		Expect(config.ValidateGuestInfoSize(existing)).To(Succeed())
+	})
+})
+
+var _ = Describe("Decode", func() {
+	const sampleData = "some sample data, "
+
+	It("decodes gzip+base64 encoded data", func() {
+		data, err := Decode(gzipBase64Encode(sampleData), "gzip+base64")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal(sampleData))
+	})
+
+	It("decodes base64 encoded data", func() {
+		data, err := Decode(base64Encode(sampleData), "base64")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal(sampleData))
+	})
+
+	It("returns data without an encoding as is", func() {
+		data, err := Decode(sampleData, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal(sampleData))
+	})
+
+	It("returns an error for unsupported encodings", func() {
+		_, err := Decode(sampleData, "zstd")
+		Expect(err).To(HaveOccurred())
+	})
+})
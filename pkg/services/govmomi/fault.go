@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// taskRetryBaseDelay is the duration to wait before a task is queued
+	// again after it failed with a retryable fault. It doubles with every
+	// consecutive failure, up to taskRetryMaxDelay.
+	taskRetryBaseDelay = time.Minute
+	taskRetryMaxDelay  = 30 * time.Minute
+)
+
+// isTerminalFault returns whether a task which failed with the fault fails
+// again when it is retried, such as when the resources or licenses it
+// requires are missing or the datastore path it refers to is invalid.
+func isTerminalFault(fault types.BaseMethodFault) bool {
+	switch fault.(type) {
+	case types.BaseInsufficientResourcesFault, types.BaseNotEnoughLicenses:
+		return true
+	case *types.NoDiskSpace, *types.InvalidDatastorePath, *types.InvalidLicense, *types.LicenseEntityNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// taskRetryDelay returns the duration to wait before a task is queued again
+// after the given number of consecutive tasks failed with retryable faults.
+func taskRetryDelay(retries int32) time.Duration {
+	delay := taskRetryBaseDelay
+	for i := int32(1); i < retries && delay < taskRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > taskRetryMaxDelay {
+		delay = taskRetryMaxDelay
+	}
+	return delay
+}
+
+// faultName returns the type name of the fault of a failed task, such as
+// NoDiskSpace.
+func faultName(task *mo.Task) string {
+	if task.Info.Error == nil || task.Info.Error.Fault == nil {
+		return "Unknown"
+	}
+	return reflect.Indirect(reflect.ValueOf(task.Info.Error.Fault)).Type().Name()
+}
+
+// faultMessage returns the message of the fault of a failed task, or the
+// description of the task if the fault has no message.
+func faultMessage(task *mo.Task) string {
+	if task.Info.Error != nil && task.Info.Error.LocalizedMessage != "" {
+		return task.Info.Error.LocalizedMessage
+	}
+	if task.Info.Description != nil {
+		return task.Info.Description.Message
+	}
+	return ""
+}
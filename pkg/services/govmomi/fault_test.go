@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func Test_IsTerminalFault(t *testing.T) {
+	tests := []struct {
+		fault    types.BaseMethodFault
+		terminal bool
+	}{
+		{&types.InsufficientResourcesFault{}, true},
+		{&types.InsufficientMemoryResourcesFault{}, true},
+		{&types.NoDiskSpace{}, true},
+		{&types.InvalidDatastorePath{}, true},
+		{&types.LicenseExpired{}, true},
+		{&types.InvalidLicense{}, true},
+		{&types.FileNotFound{}, false},
+		{&types.TaskInProgress{}, false},
+		{&types.HostCommunication{}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.fault), func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(isTerminalFault(tt.fault)).To(Equal(tt.terminal))
+		})
+	}
+}
+
+func Test_TaskRetryDelay(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(taskRetryDelay(1)).To(Equal(time.Minute))
+	g.Expect(taskRetryDelay(2)).To(Equal(2 * time.Minute))
+	g.Expect(taskRetryDelay(4)).To(Equal(8 * time.Minute))
+	g.Expect(taskRetryDelay(10)).To(Equal(taskRetryMaxDelay))
+}
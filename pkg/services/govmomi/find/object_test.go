@@ -139,6 +139,7 @@ func setupSimulatorAndSession(model *simulator.Model) (*vcsim.Simulator, *sessio
 
 	authSession, err := session.GetOrCreate(context.Background(),
 		session.NewParams().
+			WithInsecure(true).
 			WithServer(sim.ServerURL().Host).
 			WithUserInfo(sim.Username(), sim.Password()).
 			WithDatacenter(datacenterName),
@@ -0,0 +1,240 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
+)
+
+// reconcileHardware reports the hardware of the VM in the VSphereVM's status
+// and, if the VSphereVM uses the InPlace resize policy, applies changes to the
+// number of CPUs, the memory and the disk sizes to the VM.
+//
+// Disks are grown online and CPUs and memory are hot-added when the VM allows
+// it. Other changes are applied while the VM is powered off, which requires the
+// AnnotationAllowResizePowerCycle annotation when the VM is powered on. The VM
+// is powered on again by reconcilePowerState.
+func (vms *VMService) reconcileHardware(ctx *virtualMachineContext) (bool, error) {
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
+	if err != nil {
+		return false, err
+	}
+	if obj.Config == nil {
+		return false, errors.Errorf("vm %s has no config", ctx)
+	}
+
+	hardware := obj.Config.Hardware
+	devices := object.VirtualDeviceList(hardware.Device)
+	resizable, disksKnown := withoutDataDisks(ctx, devices)
+	ctx.VSphereVM.Status.Hardware = getHardwareStatus(hardware, resizable)
+
+	if ctx.VSphereVM.Spec.ResizePolicy != infrav1.InPlaceResizePolicy {
+		return true, nil
+	}
+
+	configSpec := types.VirtualMachineConfigSpec{}
+	needsPowerOff := false
+
+	// Apply the same defaults as when the VM was cloned.
+	desired := vcenter.NewConfigSpec(&ctx.VMContext, nil, nil)
+	desiredCoresPerSocket := desired.NumCoresPerSocket
+	if ctx.VSphereVM.Spec.NumCoresPerSocket == 0 && hardware.NumCoresPerSocket > 0 && desired.NumCPUs%hardware.NumCoresPerSocket == 0 {
+		// Keep the current topology when it fits the new number of CPUs.
+		desiredCoresPerSocket = hardware.NumCoresPerSocket
+	}
+	if desired.NumCPUs != hardware.NumCPU || desiredCoresPerSocket != hardware.NumCoresPerSocket {
+		configSpec.NumCPUs = desired.NumCPUs
+		configSpec.NumCoresPerSocket = desiredCoresPerSocket
+		switch {
+		case desiredCoresPerSocket != hardware.NumCoresPerSocket:
+			needsPowerOff = true
+		case desired.NumCPUs > hardware.NumCPU:
+			needsPowerOff = !isEnabled(obj.Config.CpuHotAddEnabled)
+		default:
+			needsPowerOff = !isEnabled(obj.Config.CpuHotRemoveEnabled)
+		}
+	}
+	if desired.MemoryMB != int64(hardware.MemoryMB) {
+		configSpec.MemoryMB = desired.MemoryMB
+		if desired.MemoryMB < int64(hardware.MemoryMB) || !isEnabled(obj.Config.MemoryHotAddEnabled) {
+			needsPowerOff = true
+		}
+	}
+
+	diskSpecs, err := getDiskResizeSpecs(ctx, devices)
+	if err != nil {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, err
+	}
+	configSpec.DeviceChange = diskSpecs
+
+	if configSpec.NumCPUs == 0 && configSpec.MemoryMB == 0 && len(configSpec.DeviceChange) == 0 {
+		if !disksKnown {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityWarning,
+				"the disks cannot be resized, the unit numbers of the data disks are not known")
+			return true, nil
+		}
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VMResizedCondition)
+		return true, nil
+	}
+
+	if needsPowerOff && obj.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
+		if _, ok := ctx.VSphereVM.Annotations[infrav1.AnnotationAllowResizePowerCycle]; !ok {
+			// Apply the changes which do not require a power cycle.
+			if len(diskSpecs) == 0 {
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.WaitingForPowerCycleReason, clusterv1.ConditionSeverityWarning,
+					"the resize requires powering off the VM, which is allowed by the %s annotation", infrav1.AnnotationAllowResizePowerCycle)
+				return true, nil
+			}
+			configSpec = types.VirtualMachineConfigSpec{DeviceChange: diskSpecs}
+		} else {
+			ctx.Logger.Info("powering off to resize")
+			task, err := ctx.Obj.PowerOff(ctx)
+			if err != nil {
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+				return false, errors.Wrapf(err, "failed to trigger power off op for vm %s", ctx)
+			}
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizingReason, clusterv1.ConditionSeverityInfo, "powering off")
+			ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+			ctx.Logger.Info("wait for VM to be powered off")
+			return false, nil
+		}
+	}
+
+	if !acquireOperationSlot(&ctx.VMContext, infrav1.VMResizedCondition, vmDatastore(ctx)) {
+		return false, nil
+	}
+	ctx.Logger.Info("resizing", "numCPUs", configSpec.NumCPUs, "memoryMiB", configSpec.MemoryMB, "disks", len(configSpec.DeviceChange))
+	task, err := ctx.Obj.Reconfigure(ctx, configSpec)
+	if err != nil {
+		releaseOperationSlot(&ctx.VMContext)
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, errors.Wrapf(err, "unable to resize vm %s", ctx)
+	}
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizingReason, clusterv1.ConditionSeverityInfo, "")
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	ctx.Logger.Info("wait for VM to be resized")
+	return false, nil
+}
+
+// getDiskResizeSpecs returns the device changes that grow the VM's disks to
+// the sizes requested by the VSphereVM.
+func getDiskResizeSpecs(ctx *virtualMachineContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	// The data disks are neither resized nor counted as additional disks, so
+	// the disks cannot be told apart until their unit numbers are known.
+	resizable, ok := withoutDataDisks(ctx, devices)
+	if !ok {
+		ctx.Logger.Info("unit numbers of the data disks are unknown, skipping disk resize")
+		return nil, nil
+	}
+
+	var changes []types.BaseVirtualDeviceConfigSpec
+	if ctx.VSphereVM.Status.CloneMode == infrav1.LinkedClone {
+		ctx.Logger.V(4).Info("disks of linked clones cannot be resized, skipping disk resize")
+	} else {
+		// The devices are cached by the session and must not be modified,
+		// the disks are copied before they are resized.
+		resizable = copyDisks(resizable)
+		disks := resizable.SelectByType((*types.VirtualDisk)(nil))
+		capacitiesKB := make([]int64, 0, len(disks))
+		for _, disk := range disks {
+			capacitiesKB = append(capacitiesKB, disk.(*types.VirtualDisk).CapacityInKB) //nolint:forcetypeassert
+		}
+
+		// GetDiskSpec updates the capacity of the disks to the requested sizes.
+		diskSpecs, err := vcenter.GetDiskSpec(&ctx.VMContext, resizable)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resize disks of vm %s", ctx)
+		}
+		for i, diskSpec := range diskSpecs {
+			if diskSpec.GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk).CapacityInKB == capacitiesKB[i] { //nolint:forcetypeassert
+				continue
+			}
+			changes = append(changes, diskSpec)
+		}
+	}
+
+	// The additional disks added to the spec are created next to the
+	// existing disks, including the data disks.
+	existing := len(resizable.SelectByType((*types.VirtualDisk)(nil)))
+	addSpecs, err := vcenter.GetAdditionalDiskSpecs(&ctx.VMContext, devices, existing)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to add disks to vm %s", ctx)
+	}
+	return append(changes, addSpecs...), nil
+}
+
+// withoutDataDisks returns the devices without the VM's data disks, which are
+// identified by their controller and unit number. It returns false if the unit
+// numbers of the data disks are not known.
+func withoutDataDisks(ctx *virtualMachineContext, devices object.VirtualDeviceList) (object.VirtualDeviceList, bool) {
+	dataDisks, ok := vcenter.SelectDataDisks(&ctx.VMContext, devices)
+	if !ok {
+		return devices, false
+	}
+	if len(dataDisks) == 0 {
+		return devices, true
+	}
+	return devices.Select(func(device types.BaseVirtualDevice) bool {
+		for _, dataDisk := range dataDisks {
+			if device == dataDisk {
+				return false
+			}
+		}
+		return true
+	}), true
+}
+
+func copyDisks(devices object.VirtualDeviceList) object.VirtualDeviceList {
+	copied := make(object.VirtualDeviceList, 0, len(devices))
+	for _, device := range devices {
+		if disk, ok := device.(*types.VirtualDisk); ok {
+			diskCopy := *disk
+			device = &diskCopy
+		}
+		copied = append(copied, device)
+	}
+	return copied
+}
+
+func getHardwareStatus(hardware types.VirtualHardware, devices object.VirtualDeviceList) *infrav1.VirtualMachineHardwareStatus {
+	status := &infrav1.VirtualMachineHardwareStatus{
+		NumCPUs:           hardware.NumCPU,
+		NumCoresPerSocket: hardware.NumCoresPerSocket,
+		MemoryMiB:         int64(hardware.MemoryMB),
+	}
+	for i, disk := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		sizeGiB := int32(disk.(*types.VirtualDisk).CapacityInKB / 1024 / 1024) //nolint:forcetypeassert
+		if i == 0 {
+			status.DiskGiB = sizeGiB
+		} else {
+			status.AdditionalDisksGiB = append(status.AdditionalDisksGiB, sizeGiB)
+		}
+	}
+	return status
+}
+
+func isEnabled(b *bool) bool {
+	return b != nil && *b
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+//nolint:forcetypeassert
+func TestReconcileHardware(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	newVMContext := func(g *WithT) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+		vmContext.VSphereVM.Spec.ResizePolicy = infrav1.InPlaceResizePolicy
+
+		authSession, err := session.GetOrCreate(
+			vmContext.Context,
+			session.NewParams().
+				WithInsecure(true).
+				WithServer(vmContext.VSphereVM.Spec.Server).
+				WithUserInfo(simr.Username(), simr.Password()).
+				WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		simVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+		disk := object.VirtualDeviceList(simVM.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
+		disk.CapacityInKB = int64(vmContext.VSphereVM.Spec.DiskGiB) * 1024 * 1024
+		simVM.Config.Hardware.NumCPU = vmContext.VSphereVM.Spec.NumCPUs
+		simVM.Config.Hardware.NumCoresPerSocket = vmContext.VSphereVM.Spec.NumCPUs
+		simVM.Config.Hardware.MemoryMB = int32(vmContext.VSphereVM.Spec.MemoryMiB)
+		authSession.Properties.Invalidate(simVM.Reference())
+
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       object.NewVirtualMachine(authSession.Client.Client, simVM.Reference()),
+			Ref:       simVM.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+
+	waitForTask := func(g *WithT, ctx *virtualMachineContext) {
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		// The completed task is cleared from the status and the cached
+		// properties of the VM are invalidated.
+		inFlight, err := reconcileInFlightTask(&ctx.VMContext)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(inFlight).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	}
+
+	getVM := func(g *WithT, ctx *virtualMachineContext) mo.VirtualMachine {
+		var vm mo.VirtualMachine
+		g.Expect(ctx.Session.RetrieveOne(ctx, ctx.Ref, []string{"config.hardware", "runtime.powerState"}, &vm)).To(Succeed())
+		return vm
+	}
+
+	t.Run("reports the hardware without resizing by default", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g)
+		ctx.VSphereVM.Spec.ResizePolicy = ""
+		ctx.VSphereVM.Spec.NumCPUs = 4
+
+		ok, err := (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(ctx.VSphereVM.Status.Hardware).To(Equal(&infrav1.VirtualMachineHardwareStatus{
+			NumCPUs:           2,
+			NumCoresPerSocket: 2,
+			MemoryMiB:         2048,
+			DiskGiB:           20,
+		}))
+	})
+
+	t.Run("waits for the power cycle annotation", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g)
+		ctx.VSphereVM.Spec.MemoryMiB = 4096
+
+		ok, err := (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMResizedCondition)).To(Equal(infrav1.WaitingForPowerCycleReason))
+	})
+
+	t.Run("power cycles the VM to resize", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g)
+		ctx.VSphereVM.Annotations = map[string]string{infrav1.AnnotationAllowResizePowerCycle: ""}
+		ctx.VSphereVM.Spec.NumCPUs = 4
+		ctx.VSphereVM.Spec.MemoryMiB = 4096
+
+		// The VM is powered off first.
+		ok, err := (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		waitForTask(g, ctx)
+		g.Expect(getVM(g, ctx).Runtime.PowerState).To(Equal(types.VirtualMachinePowerStatePoweredOff))
+
+		// Then it is resized.
+		ok, err = (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		waitForTask(g, ctx)
+		vm := getVM(g, ctx)
+		g.Expect(vm.Config.Hardware.NumCPU).To(Equal(int32(4)))
+		g.Expect(vm.Config.Hardware.NumCoresPerSocket).To(Equal(int32(2)))
+		g.Expect(vm.Config.Hardware.MemoryMB).To(Equal(int32(4096)))
+
+		ok, err = (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMResizedCondition)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Hardware.NumCPUs).To(Equal(int32(4)))
+		g.Expect(ctx.VSphereVM.Status.Hardware.MemoryMiB).To(Equal(int64(4096)))
+	})
+
+	t.Run("fails to shrink disks", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g)
+		ctx.VSphereVM.Spec.DiskGiB = 10
+
+		_, err := (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring("can't resize template disk down")))
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMResizedCondition)).To(Equal(infrav1.ResizeFailedReason))
+	})
+
+	t.Run("creates added disks and leaves data disks alone", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g)
+		ctx.VSphereVM.Spec.AdditionalDisksGiB = []int32{5}
+
+		ok, err := (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		waitForTask(g, ctx)
+		disks := object.VirtualDeviceList(getVM(g, ctx).Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))
+		g.Expect(disks).To(HaveLen(2))
+
+		ok, err = (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMResizedCondition)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Hardware.AdditionalDisksGiB).To(Equal([]int32{5}))
+
+		// The same disk is not resized as an additional disk once it is
+		// known to be a data disk.
+		ctx.VSphereVM.Spec.AdditionalDisksGiB = nil
+		ctx.VSphereVM.Spec.DataDisks = []infrav1.DataDiskSpec{{SizeGiB: 5}}
+		ok, err = (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMResizedCondition)).To(Equal(infrav1.ResizeFailedReason))
+
+		ctx.VSphereVM.Status.DataDiskUnitNumbers = []int32{*disks[1].GetVirtualDevice().UnitNumber}
+		ctx.VSphereVM.Spec.DiskGiB = 30
+		ok, err = (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		waitForTask(g, ctx)
+		devices := object.VirtualDeviceList(getVM(g, ctx).Config.Hardware.Device)
+		g.Expect(devices.SelectByType((*types.VirtualDisk)(nil))).To(HaveLen(2))
+		g.Expect(devices.FindByKey(disks[0].GetVirtualDevice().Key).(*types.VirtualDisk).CapacityInKB).To(Equal(int64(30 * 1024 * 1024)))
+		g.Expect(devices.FindByKey(disks[1].GetVirtualDevice().Key).(*types.VirtualDisk).CapacityInKB).To(Equal(int64(5 * 1024 * 1024)))
+
+		ok, err = (&VMService{}).reconcileHardware(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMResizedCondition)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Hardware.DiskGiB).To(Equal(int32(30)))
+		g.Expect(ctx.VSphereVM.Status.Hardware.AdditionalDisksGiB).To(BeEmpty())
+	})
+}
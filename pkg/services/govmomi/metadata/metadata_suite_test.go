@@ -146,6 +146,7 @@ func configureSimulatorAndContext() (err error) {
 	authSession, err := session.GetOrCreate(
 		ctx.Context,
 		session.NewParams().
+			WithInsecure(true).
 			WithServer(ctx.VSphereVM.Spec.Server).
 			WithUserInfo(sim.Username(), sim.Password()).
 			WithDatacenter("*"))
@@ -0,0 +1,309 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+//nolint:forcetypeassert
+func TestReconcileMetadata(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	const ignitionConfig = `{"ignition":{"version":"3.1.0"}}`
+
+	newVMContext := func(g *WithT, extraConfig []types.BaseOptionValue) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+		vmContext.VSphereVM.Spec.Datacenter = "*"
+
+		authSession, err := session.GetOrCreate(
+			vmContext.Context,
+			session.NewParams().
+				WithInsecure(true).
+				WithServer(vmContext.VSphereVM.Spec.Server).
+				WithUserInfo(simr.Username(), simr.Password()).
+				WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: vmContext.VSphereVM.Namespace,
+				Name:      vmContext.VSphereVM.Name + "-bootstrap",
+			},
+			Data: map[string][]byte{
+				"value":  []byte(ignitionConfig),
+				"format": []byte("ignition"),
+			},
+		}
+		g.Expect(vmContext.Client.Create(vmContext, secret)).To(Succeed())
+		vmContext.VSphereVM.Spec.BootstrapRef = &corev1.ObjectReference{
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+		}
+
+		simVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+		simVM.Config.ExtraConfig = extraConfig
+		authSession.Properties.Invalidate(simVM.Reference())
+
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       object.NewVirtualMachine(authSession.Client.Client, simVM.Reference()),
+			Ref:       simVM.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+
+	reconcileMetadata := func(g *WithT, ctx *virtualMachineContext) {
+		ok, err := (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		inFlight, err := reconcileInFlightTask(&ctx.VMContext)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(inFlight).To(BeFalse())
+
+		ok, err = (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	}
+
+	t.Run("sets the cloud-init metadata", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, nil)
+
+		reconcileMetadata(g, ctx)
+
+		expected, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM)
+		g.Expect(err).NotTo(HaveOccurred())
+		metadata, ignition, err := (&VMService{}).getMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ignition).To(BeFalse())
+		g.Expect(metadata).To(Equal(string(expected)))
+	})
+
+	t.Run("compresses the cloud-init metadata", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, nil)
+
+		reconcileMetadata(g, ctx)
+
+		var obj mo.VirtualMachine
+		g.Expect(ctx.Session.RetrieveOne(ctx, ctx.Ref, []string{"config.extraConfig"}, &obj)).To(Succeed())
+		g.Expect(obj.Config.ExtraConfig).To(ContainElement(&types.OptionValue{Key: guestInfoKeyMetadataEnc, Value: "gzip+base64"}))
+	})
+
+	t.Run("keeps base64 encoded metadata of earlier versions", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, nil)
+		metadata, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM)
+		g.Expect(err).NotTo(HaveOccurred())
+		simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Config.ExtraConfig = []types.BaseOptionValue{
+			&types.OptionValue{Key: guestInfoKeyMetadata, Value: base64.StdEncoding.EncodeToString(metadata)},
+			&types.OptionValue{Key: guestInfoKeyMetadataEnc, Value: "base64"},
+		}
+
+		ok, err := (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	})
+
+	t.Run("renders the referenced metadata template", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, nil)
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ctx.VSphereVM.Namespace,
+				Name:      "metadata-template",
+			},
+			Data: map[string]string{
+				infrav1.MetadataTemplateKey: "local-hostname: {{ .Hostname }}.example.com\n",
+			},
+		}
+		g.Expect(ctx.Client.Create(ctx, configMap)).To(Succeed())
+		ctx.VSphereVM.Spec.MetadataTemplate = &corev1.LocalObjectReference{Name: configMap.Name}
+
+		reconcileMetadata(g, ctx)
+
+		metadata, _, err := (&VMService{}).getMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(metadata).To(Equal("local-hostname: " + ctx.VSphereVM.Name + ".example.com\n"))
+	})
+
+	t.Run("fails if the metadata template does not exist", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, nil)
+		ctx.VSphereVM.Spec.MetadataTemplate = &corev1.LocalObjectReference{Name: "missing"}
+
+		_, err := (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring("failed to retrieve metadata template configmap")))
+	})
+
+	t.Run("adds the network configuration to the Ignition config", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, []types.BaseOptionValue{
+			&types.OptionValue{Key: guestInfoKeyIgnition, Value: base64.StdEncoding.EncodeToString([]byte(ignitionConfig))},
+		})
+
+		reconcileMetadata(g, ctx)
+
+		expected, err := util.GetMachineIgnitionConfig([]byte(ignitionConfig), ctx.VSphereVM.Name, *ctx.VSphereVM)
+		g.Expect(err).NotTo(HaveOccurred())
+		metadata, ignition, err := (&VMService{}).getMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ignition).To(BeTrue())
+		g.Expect(metadata).To(Equal(string(expected)))
+	})
+
+	t.Run("fails if the Ignition config exceeds the guestinfo size limit", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, []types.BaseOptionValue{
+			&types.OptionValue{Key: guestInfoKeyIgnition, Value: base64.StdEncoding.EncodeToString([]byte(ignitionConfig))},
+			&types.OptionValue{Key: "guestinfo.other", Value: strings.Repeat("a", extra.MaxGuestInfoSize)},
+		})
+
+		_, err := (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).To(MatchError(extra.ErrGuestInfoTooLarge))
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition)).To(Equal(infrav1.CloningFailedReason))
+		g.Expect(conditions.GetMessage(ctx.VSphereVM, infrav1.VMProvisionedCondition)).To(ContainSubstring("exceeds the size limit"))
+	})
+
+	extraConfigValues := func(g *WithT, ctx *virtualMachineContext) map[string]string {
+		var obj mo.VirtualMachine
+		g.Expect(ctx.Session.RetrieveOne(ctx, ctx.Ref, []string{"config.extraConfig"}, &obj)).To(Succeed())
+		values := map[string]string{}
+		for _, ec := range obj.Config.ExtraConfig {
+			optVal := ec.GetOptionValue()
+			values[optVal.Key], _ = optVal.Value.(string)
+		}
+		return values
+	}
+
+	cloudInitISO := func(g *WithT, ctx *virtualMachineContext) string {
+		devices, err := ctx.Obj.Device(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		cdrom := findCloudInitCdrom(devices)
+		g.Expect(cdrom).NotTo(BeNil())
+		g.Expect(cdrom.Connectable.StartConnected).To(BeTrue())
+		return cdrom.Backing.(*types.VirtualCdromIsoBackingInfo).FileName
+	}
+
+	cloudInitISOExists := func(g *WithT, ctx *virtualMachineContext, fileName string) bool {
+		var isoPath object.DatastorePath
+		g.Expect(isoPath.FromString(fileName)).To(BeTrue())
+		datastore, err := ctx.Session.Finder.Datastore(ctx, isoPath.Datastore)
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = datastore.Stat(ctx, isoPath.Path)
+		return err == nil
+	}
+
+	t.Run("delivers the user data and metadata on a NoCloud ISO if they exceed the guestinfo size limit", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, []types.BaseOptionValue{
+			&types.OptionValue{Key: guestInfoKeyUserdata, Value: strings.Repeat("a", extra.MaxGuestInfoSize-len(guestInfoKeyUserdata))},
+			&types.OptionValue{Key: guestInfoKeyUserdataEnc, Value: "gzip+base64"},
+		})
+
+		reconcileMetadata(g, ctx)
+
+		values := extraConfigValues(g, ctx)
+		g.Expect(values[guestInfoKeyUserdata]).To(BeEmpty())
+		g.Expect(values[guestInfoKeyMetadata]).To(BeEmpty())
+		g.Expect(values[extra.CloudInitISOKey]).To(HaveLen(64))
+
+		fileName := cloudInitISO(g, ctx)
+		g.Expect(fileName).To(HaveSuffix("/cidata.iso"))
+		g.Expect(cloudInitISOExists(g, ctx, fileName)).To(BeTrue())
+
+		g.Expect((&VMService{}).deleteCloudInitISO(ctx)).To(Succeed())
+		g.Expect(cloudInitISOExists(g, ctx, fileName)).To(BeFalse())
+		g.Expect((&VMService{}).deleteCloudInitISO(ctx)).To(Succeed())
+	})
+
+	t.Run("creates the NoCloud ISO of a VM cloned without user data", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, []types.BaseOptionValue{
+			&types.OptionValue{Key: extra.CloudInitISOKey, Value: extra.CloudInitISOPending},
+		})
+
+		reconcileMetadata(g, ctx)
+
+		values := extraConfigValues(g, ctx)
+		g.Expect(values[guestInfoKeyMetadata]).To(BeEmpty())
+		g.Expect(values[extra.CloudInitISOKey]).To(HaveLen(64))
+		g.Expect(cloudInitISOExists(g, ctx, cloudInitISO(g, ctx))).To(BeTrue())
+	})
+
+	t.Run("does not replace the NoCloud ISO of a powered on VM", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, []types.BaseOptionValue{
+			&types.OptionValue{Key: extra.CloudInitISOKey, Value: strings.Repeat("0", 64)},
+		})
+		g.Expect(simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Runtime.PowerState).To(Equal(types.VirtualMachinePowerStatePoweredOn))
+
+		ok, err := (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+
+	t.Run("waits for a slot to update the metadata", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, nil)
+		ctx.OperationThrottle = throttle.New(throttle.Limits{Server: 1})
+		ctx.OperationThrottle.Hold(throttle.Operation{Key: "other", Server: ctx.VSphereVM.Spec.Server})
+
+		ok, err := (&VMService{}).reconcileMetadata(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition)).To(Equal(infrav1.WaitingForCloneSlotReason))
+
+		ctx.OperationThrottle.Release("other")
+		reconcileMetadata(g, ctx)
+	})
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/vim25/mo"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+const (
+	metricsNamespace = "capv"
+	metricsSubsystem = "vm"
+)
+
+var vmLabels = []string{"server", "datacenter", "failure_domain"}
+
+var (
+	cloneDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "clone_duration_seconds",
+		Help:      "Duration of the successful clone tasks of VSphereVMs.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 10),
+	}, vmLabels)
+
+	powerOnToIPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "power_on_to_ip_seconds",
+		Help:      "Duration from powering on the VMs of VSphereVMs until they report their first IP addresses.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 8),
+	}, vmLabels)
+
+	creationToReadyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "creation_to_ready_seconds",
+		Help:      "Duration from the creation of VSphereVMs until they are ready.",
+		Buckets:   prometheus.ExponentialBuckets(15, 2, 10),
+	}, vmLabels)
+
+	taskFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "task_failures_total",
+		Help:      "Total number of failed vCenter tasks of VSphereVMs per fault type.",
+	}, append(vmLabels, "fault"))
+
+	provisioningFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "provisioning_failures_total",
+		Help:      "Total number of TaskFailure and CloningFailed reasons reported on the VMProvisioned condition of VSphereVMs.",
+	}, append(vmLabels, "reason"))
+)
+
+func init() {
+	metrics.Registry.MustRegister(cloneDuration, powerOnToIPDuration, creationToReadyDuration, taskFailures, provisioningFailures)
+}
+
+// metricLabels returns the values of the vmLabels of the VSphereVM.
+func metricLabels(ctx *context.VMContext, extra ...string) []string {
+	var failureDomain string
+	if ctx.VSphereFailureDomain != nil {
+		failureDomain = ctx.VSphereFailureDomain.Name
+	}
+	return append([]string{ctx.VSphereVM.Spec.Server, ctx.VSphereVM.Spec.Datacenter, failureDomain}, extra...)
+}
+
+// ObserveVSphereVMReady records the duration from the creation of the
+// VSphereVM until it is ready.
+func ObserveVSphereVMReady(ctx *context.VMContext) {
+	creationToReadyDuration.WithLabelValues(metricLabels(ctx)...).Observe(time.Since(ctx.VSphereVM.CreationTimestamp.Time).Seconds())
+}
+
+// observeTaskCompletion records the duration of a successful clone task, or
+// the fault of a failed task.
+func observeTaskCompletion(ctx *context.VMContext, task *mo.Task) {
+	switch {
+	case task.Info.Error != nil:
+		taskFailures.WithLabelValues(metricLabels(ctx, faultName(task))...).Inc()
+	case task.Info.DescriptionId == "VirtualMachine.clone" && task.Info.StartTime != nil && task.Info.CompleteTime != nil:
+		cloneDuration.WithLabelValues(metricLabels(ctx)...).Observe(task.Info.CompleteTime.Sub(*task.Info.StartTime).Seconds())
+	}
+}
+
+// markProvisioningFailure marks the VMProvisioned condition as false with the
+// reason of a failed provisioning step, such as CloningFailed. The failure is
+// only recorded when the reason of the condition changes, so that a failure
+// reported by every reconcile until it is resolved is counted once.
+func markProvisioningFailure(ctx *context.VMContext, reason string, severity clusterv1.ConditionSeverity, message string) {
+	if conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition) != reason {
+		provisioningFailures.WithLabelValues(metricLabels(ctx, reason)...).Inc()
+	}
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, reason, severity, "%s", message)
+}
+
+// observePowerOnToIP records the duration from powering on the VM until it
+// reported its first IP addresses, unless the VSphereVM already has
+// addresses.
+func observePowerOnToIP(ctx *virtualMachineContext) {
+	if len(ctx.VSphereVM.Status.Addresses) > 0 {
+		return
+	}
+	var hasAddresses bool
+	for _, netStatus := range ctx.State.Network {
+		hasAddresses = hasAddresses || len(netStatus.IPAddrs) > 0
+	}
+	if !hasAddresses {
+		return
+	}
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
+	if err != nil || obj.Runtime.BootTime == nil {
+		return
+	}
+	powerOnToIPDuration.WithLabelValues(metricLabels(&ctx.VMContext)...).Observe(time.Since(*obj.Runtime.BootTime).Seconds())
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func Test_ObserveTaskCompletion(t *testing.T) {
+	vmCtx := &context.VMContext{
+		ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+		Logger:            logr.Discard(),
+		VSphereVM: &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+				Server:     "metrics.vcenter",
+				Datacenter: "dc0",
+			},
+		}},
+		VSphereFailureDomain: &infrav1.VSphereFailureDomain{},
+	}
+	vmCtx.VSphereFailureDomain.Name = "fd0"
+
+	t.Run("records the fault of a failed task once", func(t *testing.T) {
+		g := NewWithT(t)
+		failures := taskFailures.WithLabelValues("metrics.vcenter", "dc0", "fd0", "FileNotFound")
+		conditionFailures := provisioningFailures.WithLabelValues("metrics.vcenter", "dc0", "fd0", infrav1.TaskFailure)
+		before := testutil.ToFloat64(failures)
+		beforeConditions := testutil.ToFloat64(conditionFailures)
+
+		task := baseTask(types.TaskInfoStateError, "file not found")
+		task.Info.Error = &types.LocalizedMethodFault{Fault: &types.FileNotFound{}}
+		vmCtx.VSphereVM.Status.TaskRef = "task-123"
+		_, err := checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(testutil.ToFloat64(failures)).To(Equal(before + 1))
+		g.Expect(testutil.ToFloat64(conditionFailures)).To(Equal(beforeConditions + 1))
+
+		// The task is not recorded again once it is retried.
+		vmCtx.VSphereVM.Status.RetryAfter.Time = time.Now().Add(-time.Second)
+		_, err = checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(testutil.ToFloat64(failures)).To(Equal(before + 1))
+		g.Expect(vmCtx.VSphereVM.Status.RetryAfter.IsZero()).To(BeTrue())
+	})
+
+	t.Run("records a provisioning failure when the reason of the condition changes", func(t *testing.T) {
+		g := NewWithT(t)
+		failures := provisioningFailures.WithLabelValues("metrics.vcenter", "dc0", "fd0", infrav1.CloningFailedReason)
+		before := testutil.ToFloat64(failures)
+
+		markProvisioningFailure(vmCtx, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, "unable to clone")
+		markProvisioningFailure(vmCtx, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, "unable to clone")
+		g.Expect(testutil.ToFloat64(failures)).To(Equal(before + 1))
+		g.Expect(conditions.GetReason(vmCtx.VSphereVM, infrav1.VMProvisionedCondition)).To(Equal(infrav1.CloningFailedReason))
+
+		conditions.MarkFalse(vmCtx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningReason, clusterv1.ConditionSeverityInfo, "")
+		markProvisioningFailure(vmCtx, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, "unable to clone")
+		g.Expect(testutil.ToFloat64(failures)).To(Equal(before + 2))
+	})
+
+	t.Run("records the duration of a successful clone task", func(t *testing.T) {
+		g := NewWithT(t)
+		before := testutil.CollectAndCount(cloneDuration)
+
+		start := time.Now().Add(-time.Minute)
+		complete := time.Now()
+		task := baseTask(types.TaskInfoStateSuccess, "")
+		task.Info.DescriptionId = "VirtualMachine.clone"
+		task.Info.StartTime = &start
+		task.Info.CompleteTime = &complete
+		vmCtx.VSphereVM.Status.TaskRef = "task-456"
+		_, err := checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(testutil.CollectAndCount(cloneDuration)).To(Equal(before + 1))
+	})
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nocloud
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+const (
+	sectorSize = 2048
+
+	// The first 16 sectors of an ISO 9660 image are the system area, which is
+	// followed by the volume descriptors.
+	sectorPrimaryVolumeDescriptor = 16
+	sectorTerminator              = 17
+	sectorLPathTable              = 18
+	sectorMPathTable              = 19
+	sectorRootDirectory           = 20
+	sectorFirstFile               = 21
+
+	pathTableSize = 10
+
+	flagDirectory = 0x02
+)
+
+// newISO9660 returns an ISO 9660 image with the given volume label and files
+// in its root directory. The file names are stored in upper case followed by
+// ".;1", which Linux presents in lower case without the suffix.
+func newISO9660(label string, files map[string][]byte) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type extent struct {
+		identifier string
+		sector     int
+		data       []byte
+	}
+	extents := make([]extent, 0, len(names))
+	sector := sectorFirstFile
+	for _, name := range names {
+		data := files[name]
+		extents = append(extents, extent{
+			identifier: strings.ToUpper(name) + ".;1",
+			sector:     sector,
+			data:       data,
+		})
+		sector += (len(data) + sectorSize - 1) / sectorSize
+	}
+
+	image := make([]byte, sector*sectorSize)
+
+	root := directoryRecord([]byte{0x00}, sectorRootDirectory, sectorSize, flagDirectory)
+	records := [][]byte{root, directoryRecord([]byte{0x01}, sectorRootDirectory, sectorSize, flagDirectory)}
+	for _, e := range extents {
+		records = append(records, directoryRecord([]byte(e.identifier), e.sector, len(e.data), 0))
+		copy(image[e.sector*sectorSize:], e.data)
+	}
+	offset := sectorRootDirectory * sectorSize
+	for _, record := range records {
+		offset += copy(image[offset:], record)
+	}
+
+	pvd := image[sectorPrimaryVolumeDescriptor*sectorSize:]
+	pvd[0] = 1
+	copy(pvd[1:6], "CD001")
+	pvd[6] = 1
+	copy(pvd[8:40], padded("", 32))
+	copy(pvd[40:72], padded(label, 32))
+	putBothEndian32(pvd[80:88], sector)
+	putBothEndian16(pvd[120:124], 1)
+	putBothEndian16(pvd[124:128], 1)
+	putBothEndian16(pvd[128:132], sectorSize)
+	putBothEndian32(pvd[132:140], pathTableSize)
+	binary.LittleEndian.PutUint32(pvd[140:144], sectorLPathTable)
+	binary.BigEndian.PutUint32(pvd[148:152], sectorMPathTable)
+	copy(pvd[156:190], root)
+	copy(pvd[190:813], padded("", 623))
+	for _, date := range [][]byte{pvd[813:830], pvd[830:847], pvd[847:864], pvd[864:881]} {
+		copy(date, strings.Repeat("0", 16))
+	}
+	pvd[881] = 1
+
+	terminator := image[sectorTerminator*sectorSize:]
+	terminator[0] = 255
+	copy(terminator[1:6], "CD001")
+	terminator[6] = 1
+
+	// The path tables only hold the root directory.
+	for _, table := range []struct {
+		sector int
+		order  binary.ByteOrder
+	}{
+		{sectorLPathTable, binary.LittleEndian},
+		{sectorMPathTable, binary.BigEndian},
+	} {
+		entry := image[table.sector*sectorSize:]
+		entry[0] = 1
+		table.order.PutUint32(entry[2:6], sectorRootDirectory)
+		table.order.PutUint16(entry[6:8], 1)
+	}
+
+	return image
+}
+
+// directoryRecord returns the directory record of a file or directory with
+// the given identifier whose data is at the given sector.
+func directoryRecord(identifier []byte, sector, size int, flags byte) []byte {
+	length := 33 + len(identifier)
+	if length%2 != 0 {
+		length++
+	}
+	record := make([]byte, length)
+	record[0] = byte(length)
+	putBothEndian32(record[2:10], sector)
+	putBothEndian32(record[10:18], size)
+	record[25] = flags
+	putBothEndian16(record[28:32], 1)
+	record[32] = byte(len(identifier))
+	copy(record[33:], identifier)
+	return record
+}
+
+func padded(s string, length int) string {
+	return s + strings.Repeat(" ", length-len(s))
+}
+
+func putBothEndian16(b []byte, v int) {
+	binary.LittleEndian.PutUint16(b[0:2], uint16(v))
+	binary.BigEndian.PutUint16(b[2:4], uint16(v))
+}
+
+func putBothEndian32(b []byte, v int) {
+	binary.LittleEndian.PutUint32(b[0:4], uint32(v))
+	binary.BigEndian.PutUint32(b[4:8], uint32(v))
+}
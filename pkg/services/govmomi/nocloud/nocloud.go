@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nocloud builds the ISO images read by the NoCloud datasource of
+// cloud-init, which deliver user data and metadata which are too large for
+// the guestinfo of a VM.
+package nocloud
+
+import (
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// VolumeLabel is the label by which cloud-init finds the NoCloud ISO.
+const VolumeLabel = "CIDATA"
+
+const (
+	fileUserData      = "user-data"
+	fileMetaData      = "meta-data"
+	fileNetworkConfig = "network-config"
+)
+
+// ISO returns a NoCloud ISO image with the given user data and metadata.
+// The metadata is in the format of the VMware datasource, its network
+// configuration is written to the network-config file of the image.
+func ISO(userData, metadata []byte) ([]byte, error) {
+	files := map[string][]byte{
+		fileUserData: userData,
+		fileMetaData: metadata,
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(metadata, &values); err != nil {
+		return nil, errors.Wrap(err, "unable to parse metadata")
+	}
+	if network, ok := values["network"]; ok {
+		networkConfig, err := yaml.Marshal(network)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal network configuration")
+		}
+		files[fileNetworkConfig] = networkConfig
+	}
+
+	return newISO9660(VolumeLabel, files), nil
+}
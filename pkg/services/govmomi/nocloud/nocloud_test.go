@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nocloud
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// readISO9660 returns the volume label of an ISO 9660 image and the files
+// in its root directory by the names Linux presents them with.
+func readISO9660(g *WithT, image []byte) (string, map[string]string) {
+	pvd := image[sectorPrimaryVolumeDescriptor*sectorSize:]
+	g.Expect(pvd[0]).To(Equal(byte(1)))
+	g.Expect(string(pvd[1:6])).To(Equal("CD001"))
+	g.Expect(int(binary.LittleEndian.Uint32(pvd[80:84])) * sectorSize).To(Equal(len(image)))
+	label := strings.TrimRight(string(pvd[40:72]), " ")
+
+	root := pvd[156:190]
+	dir := image[int(binary.LittleEndian.Uint32(root[2:6]))*sectorSize:][:binary.LittleEndian.Uint32(root[10:14])]
+
+	files := map[string]string{}
+	for len(dir) > 0 && dir[0] > 0 {
+		record := dir[:dir[0]]
+		dir = dir[dir[0]:]
+		if record[25]&flagDirectory != 0 {
+			continue
+		}
+		name := string(record[33 : 33+record[32]])
+		g.Expect(name).To(HaveSuffix(".;1"))
+		start := int(binary.LittleEndian.Uint32(record[2:6])) * sectorSize
+		size := int(binary.LittleEndian.Uint32(record[10:14]))
+		files[strings.ToLower(strings.TrimSuffix(name, ".;1"))] = string(image[start : start+size])
+	}
+	return label, files
+}
+
+func TestISO(t *testing.T) {
+	metadata := `instance-id: "vm"
+local-hostname: "vm"
+network:
+  version: 2
+  ethernets:
+    id0:
+      dhcp4: true
+`
+
+	t.Run("contains the user data, metadata and network configuration", func(t *testing.T) {
+		g := NewWithT(t)
+		userData := strings.Repeat("#cloud-config\n", 1<<17)
+
+		image, err := ISO([]byte(userData), []byte(metadata))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		label, files := readISO9660(g, image)
+		g.Expect(label).To(Equal("CIDATA"))
+		g.Expect(files).To(HaveLen(3))
+		g.Expect(files).To(HaveKeyWithValue("user-data", userData))
+		g.Expect(files).To(HaveKeyWithValue("meta-data", metadata))
+		g.Expect(files).To(HaveKeyWithValue("network-config", "ethernets:\n  id0:\n    dhcp4: true\nversion: 2\n"))
+	})
+
+	t.Run("omits the network configuration if the metadata has none", func(t *testing.T) {
+		g := NewWithT(t)
+
+		image, err := ISO([]byte("#cloud-config\n"), []byte("local-hostname: vm\n"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, files := readISO9660(g, image)
+		g.Expect(files).To(HaveLen(2))
+		g.Expect(files).NotTo(HaveKey("network-config"))
+	})
+
+	t.Run("is the same for the same data", func(t *testing.T) {
+		g := NewWithT(t)
+
+		first, err := ISO([]byte("#cloud-config\n"), []byte(metadata))
+		g.Expect(err).NotTo(HaveOccurred())
+		second, err := ISO([]byte("#cloud-config\n"), []byte(metadata))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(bytes.Equal(first, second)).To(BeTrue())
+	})
+
+	t.Run("fails if the metadata is not YAML", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := ISO([]byte("#cloud-config\n"), []byte("{"))
+		g.Expect(err).To(MatchError(ContainSubstring("unable to parse metadata")))
+	})
+}
@@ -17,22 +17,24 @@ limitations under the License.
 package govmomi
 
 import (
-	"encoding/base64"
 	"fmt"
+	"text/template"
 
 	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/pbm"
 	pbmTypes "github.com/vmware/govmomi/pbm/types"
-	"github.com/vmware/govmomi/property"
-	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
@@ -67,7 +69,7 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 	// VSphereVM resource once its associated task completes. If
 	// there is no task for the VSphereVM resource then no reconcile
 	// event is triggered.
-	defer reconcileVSphereVMOnTaskCompletion(ctx)
+	defer watchTask(ctx)
 
 	// Before going further, we need the VM's managed object reference.
 	vmRef, err := findVM(ctx)
@@ -92,16 +94,16 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		}
 
 		// Get the bootstrap data.
-		bootstrapData, err := vms.getBootstrapData(ctx)
+		bootstrapData, format, err := vms.getBootstrapData(ctx)
 		if err != nil {
-			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			markProvisioningFailure(ctx, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 			return vm, err
 		}
 
-		// Create the VM.
-		err = createVM(ctx, bootstrapData)
+		err = createVM(ctx, bootstrapData, format)
 		if err != nil {
-			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			markProvisioningFailure(ctx, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			releaseOperationSlot(ctx)
 		}
 		return vm, nil
 	}
@@ -118,6 +120,11 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		State:     &vm,
 	}
 
+	// Trigger a reconcile whenever the power state, the IP addresses or the
+	// configuration of the VM change, such as once the VM reports the IP
+	// addresses after it was powered on.
+	watchVM(vmCtx)
+
 	vms.reconcileUUID(vmCtx)
 
 	if err := vms.reconcileNetworkStatus(vmCtx); err != nil {
@@ -128,7 +135,7 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		return vm, err
 	}
 
-	if err := vms.reconcileStoragePolicy(vmCtx); err != nil {
+	if ok, err := vms.reconcileStoragePolicy(vmCtx); err != nil || !ok {
 		return vm, err
 	}
 
@@ -136,6 +143,14 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		return vm, err
 	}
 
+	if ok, err := vms.reconcileClusterModule(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
+	if ok, err := vms.reconcileHardware(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
 	if ok, err := vms.reconcilePowerState(vmCtx); err != nil || !ok {
 		return vm, err
 	}
@@ -145,6 +160,8 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		return vm, err
 	}
 
+	observePowerOnToIP(vmCtx)
+
 	vm.State = infrav1.VirtualMachineStateReady
 	return vm, nil
 }
@@ -156,6 +173,9 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 		State: infrav1.VirtualMachineStatePending,
 	}
 
+	// The VM no longer needs a slot for the tasks it waited for.
+	forgetOperation(ctx)
+
 	// If there is an in-flight task associated with this VM then do not
 	// reconcile the VM until the task is completed.
 	if inFlight, err := reconcileInFlightTask(ctx); err != nil || inFlight {
@@ -166,7 +186,7 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 	// VSphereVM resource once its associated task completes. If
 	// there is no task for the VSphereVM resource then no reconcile
 	// event is triggered.
-	defer reconcileVSphereVMOnTaskCompletion(ctx)
+	defer watchTask(ctx)
 
 	// Before going further, we need the VM's managed object reference.
 	vmRef, err := findVM(ctx)
@@ -211,6 +231,10 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 		return vm, nil
 	}
 
+	if err := vms.deleteCloudInitISO(vmCtx); err != nil {
+		return vm, err
+	}
+
 	// At this point the VM is not powered on and can be destroyed. Store the
 	// destroy task's reference and return a requeue error.
 	ctx.Logger.Info("destroying vm")
@@ -233,14 +257,42 @@ func (vms *VMService) reconcileNetworkStatus(ctx *virtualMachineContext) error {
 }
 
 func (vms *VMService) reconcileMetadata(ctx *virtualMachineContext) (bool, error) {
-	existingMetadata, err := vms.getMetadata(ctx)
+	existingMetadata, ignition, err := vms.getMetadata(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	newMetadata, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM, ctx.State.Network...)
-	if err != nil {
-		return false, err
+	var newMetadata []byte
+	if ignition {
+		// Ignition has no metadata, the hostname and network configuration
+		// are added to the Ignition config instead.
+		bootstrapData, _, err := vms.getBootstrapData(&ctx.VMContext)
+		if err != nil {
+			return false, err
+		}
+		newMetadata, err = util.GetMachineIgnitionConfig(bootstrapData, ctx.VSphereVM.Name, *ctx.VSphereVM, ctx.State.Network...)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		tpl, err := vms.getMetadataTemplate(&ctx.VMContext)
+		if err != nil {
+			return false, err
+		}
+		newMetadata, err = util.GetMachineMetadataWithTemplate(tpl, ctx.VSphereVM.Name, *ctx.VSphereVM, ctx.State.Network...)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if !ignition {
+		checksum, err := vms.getCloudInitISOChecksum(ctx)
+		if err != nil {
+			return false, err
+		}
+		if checksum != "" {
+			return vms.reconcileCloudInitISO(ctx, newMetadata, checksum)
+		}
 	}
 
 	// If the metadata is the same then return early.
@@ -248,9 +300,23 @@ func (vms *VMService) reconcileMetadata(ctx *virtualMachineContext) (bool, error
 		return true, nil
 	}
 
+	extraConfig, err := vms.getMetadataExtraConfig(ctx, newMetadata, ignition)
+	if !ignition && errors.Is(err, extra.ErrGuestInfoTooLarge) {
+		ctx.Logger.Info("user data and metadata exceed the guestinfo size limit, delivering them on a NoCloud ISO")
+		return vms.reconcileCloudInitISO(ctx, newMetadata, "")
+	}
+	if err != nil {
+		markProvisioningFailure(&ctx.VMContext, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, err
+	}
+
+	if !acquireOperationSlot(&ctx.VMContext, infrav1.VMProvisionedCondition, vmDatastore(ctx)) {
+		return false, nil
+	}
 	ctx.Logger.Info("updating metadata")
-	taskRef, err := vms.setMetadata(ctx, newMetadata)
+	taskRef, err := vms.setMetadata(ctx, extraConfig)
 	if err != nil {
+		releaseOperationSlot(&ctx.VMContext)
 		return false, errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
 	}
 
@@ -266,9 +332,13 @@ func (vms *VMService) reconcilePowerState(ctx *virtualMachineContext) (bool, err
 	}
 	switch powerState {
 	case infrav1.VirtualMachinePowerStatePoweredOff:
+		if !acquireOperationSlot(&ctx.VMContext, infrav1.VMProvisionedCondition, vmDatastore(ctx)) {
+			return false, nil
+		}
 		ctx.Logger.Info("powering on")
 		task, err := ctx.Obj.PowerOn(ctx)
 		if err != nil {
+			releaseOperationSlot(&ctx.VMContext)
 			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.PoweringOnFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 			return false, errors.Wrapf(err, "failed to trigger power on op for vm %s", ctx)
 		}
@@ -281,10 +351,6 @@ func (vms *VMService) reconcilePowerState(ctx *virtualMachineContext) (bool, err
 			return false, err
 		}
 
-		// Once the VM is successfully powered on, a reconcile request should be
-		// triggered once the VM reports IP addresses are available.
-		reconcileVSphereVMWhenNetworkIsReady(ctx, task)
-
 		ctx.Logger.Info("wait for VM to be powered on")
 		return false, nil
 	case infrav1.VirtualMachinePowerStatePoweredOn:
@@ -295,40 +361,64 @@ func (vms *VMService) reconcilePowerState(ctx *virtualMachineContext) (bool, err
 	}
 }
 
-func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) error {
+func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) (bool, error) {
 	if ctx.VSphereVM.Spec.StoragePolicyName == "" {
 		ctx.Logger.Info("storage policy not defined. skipping reconcile storage policy")
-		return nil
+		return true, nil
+	}
+	if !ctx.Session.IsVC() {
+		ctx.Logger.Info("storage policies are not supported on ESXi. skipping reconcile storage policy")
+		return true, nil
 	}
 
 	// return early if the VM is already powered on
 	powerState, err := vms.getPowerState(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if powerState == infrav1.VirtualMachinePowerStatePoweredOn {
 		ctx.Logger.Info("VM powered on. skipping reconcile storage policy")
-		return nil
+		return true, nil
 	}
 
 	pbmClient, err := pbm.NewClient(ctx, ctx.Session.Client.Client)
 	if err != nil {
-		return errors.Wrap(err, "unable to create pbm client")
+		return false, errors.Wrap(err, "unable to create pbm client")
 	}
 	storageProfileID, err := pbmClient.ProfileIDByName(ctx, ctx.VSphereVM.Spec.StoragePolicyName)
 	if err != nil {
-		return errors.Wrap(err, "unable to retrieve storage profile ID")
+		return false, errors.Wrap(err, "unable to retrieve storage profile ID")
 	}
 	entities, err := pbmClient.QueryAssociatedEntity(ctx, pbmTypes.PbmProfileId{UniqueId: storageProfileID}, "virtualDiskId")
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	// Data disks with their own storage policy keep it.
+	for _, dataDisk := range ctx.VSphereVM.Spec.DataDisks {
+		if dataDisk.StoragePolicyName == "" {
+			continue
+		}
+		dataDiskProfileID, err := pbmClient.ProfileIDByName(ctx, dataDisk.StoragePolicyName)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to retrieve data disk storage profile ID")
+		}
+		dataDiskEntities, err := pbmClient.QueryAssociatedEntity(ctx, pbmTypes.PbmProfileId{UniqueId: dataDiskProfileID}, "virtualDiskId")
+		if err != nil {
+			return false, err
+		}
+		entities = append(entities, dataDiskEntities...)
 	}
 
 	var changes []types.BaseVirtualDeviceConfigSpec
-	devices, err := ctx.Obj.Device(ctx)
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
 	if err != nil {
-		return err
+		return false, err
 	}
+	if obj.Config == nil {
+		return false, errors.Errorf("vm %s has no config", ctx)
+	}
+	devices := object.VirtualDeviceList(obj.Config.Hardware.Device)
 
 	disks := devices.SelectByType((*types.VirtualDisk)(nil))
 	for _, d := range disks {
@@ -357,6 +447,9 @@ func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) error {
 	}
 
 	if len(changes) > 0 {
+		if !acquireOperationSlot(&ctx.VMContext, infrav1.VMProvisionedCondition, vmDatastore(ctx)) {
+			return false, nil
+		}
 		task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
 			VmProfile: []types.BaseVirtualMachineProfileSpec{
 				&types.VirtualMachineDefinedProfileSpec{ProfileId: storageProfileID},
@@ -364,24 +457,30 @@ func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) error {
 			DeviceChange: changes,
 		})
 		if err != nil {
-			return errors.Wrapf(err, "unable to set storagePolicy on vm %s", ctx)
+			releaseOperationSlot(&ctx.VMContext)
+			return false, errors.Wrapf(err, "unable to set storagePolicy on vm %s", ctx)
 		}
 		ctx.VSphereVM.Status.TaskRef = task.Reference().Value
 	}
-	return nil
+	return true, nil
 }
 
 func (vms *VMService) reconcileUUID(ctx *virtualMachineContext) {
-	ctx.State.BiosUUID = ctx.Obj.UUID(ctx)
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
+	if err != nil || obj.Config == nil {
+		ctx.State.BiosUUID = ""
+		return
+	}
+	ctx.State.BiosUUID = obj.Config.Uuid
 }
 
 func (vms *VMService) getPowerState(ctx *virtualMachineContext) (infrav1.VirtualMachinePowerState, error) {
-	powerState, err := ctx.Obj.PowerState(ctx)
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
 	if err != nil {
 		return "", err
 	}
 
-	switch powerState {
+	switch powerState := obj.Runtime.PowerState; powerState {
 	case types.VirtualMachinePowerStatePoweredOn:
 		return infrav1.VirtualMachinePowerStatePoweredOn, nil
 	case types.VirtualMachinePowerStatePoweredOff:
@@ -393,56 +492,71 @@ func (vms *VMService) getPowerState(ctx *virtualMachineContext) (infrav1.Virtual
 	}
 }
 
-func (vms *VMService) getMetadata(ctx *virtualMachineContext) (string, error) {
-	var (
-		obj mo.VirtualMachine
-
-		pc    = property.DefaultCollector(ctx.Session.Client.Client)
-		props = []string{"config.extraConfig"}
-	)
-
-	if err := pc.RetrieveOne(ctx, ctx.Ref, props, &obj); err != nil {
-		return "", errors.Wrapf(err, "unable to fetch props %v for vm %s", props, ctx)
-	}
-	if obj.Config == nil {
-		return "", nil
+// getMetadata returns the metadata of the VM, or its Ignition config if it
+// was created with one, in which case it also returns true.
+func (vms *VMService) getMetadata(ctx *virtualMachineContext) (string, bool, error) {
+	extraConfig, err := ctx.Session.Properties.ExtraConfig(ctx, ctx.Ref)
+	if err != nil {
+		return "", false, err
 	}
 
-	var metadataBase64 string
-	for _, ec := range obj.Config.ExtraConfig {
+	values := map[string]string{}
+	for _, ec := range extraConfig {
 		if optVal := ec.GetOptionValue(); optVal != nil {
-			// TODO(akutz) Using a switch instead of if in case we ever
-			//             want to check the metadata encoding as well.
-			//             Since the image stamped images always use
-			//             base64, it should be okay to not check.
-			//nolint:gocritic
-			switch optVal.Key {
-			case guestInfoKeyMetadata:
-				if v, ok := optVal.Value.(string); ok {
-					metadataBase64 = v
-				}
+			if v, ok := optVal.Value.(string); ok {
+				values[optVal.Key] = v
 			}
 		}
 	}
 
-	if metadataBase64 == "" {
-		return "", nil
+	key, encodingKey := guestInfoKeyMetadata, guestInfoKeyMetadataEnc
+	_, ignition := values[guestInfoKeyIgnition]
+	if ignition {
+		key, encodingKey = guestInfoKeyIgnition, guestInfoKeyIgnitionEnc
+	}
+	if values[key] == "" {
+		return "", ignition, nil
 	}
 
-	metadataBuf, err := base64.StdEncoding.DecodeString(metadataBase64)
+	// Metadata without an encoding was set by earlier versions, which always
+	// encoded it as base64.
+	encoding := values[encodingKey]
+	if encoding == "" {
+		encoding = "base64"
+	}
+	metadataBuf, err := extra.Decode(values[key], encoding)
 	if err != nil {
-		return "", errors.Wrapf(err, "unable to decode metadata for %s", ctx)
+		return "", false, errors.Wrapf(err, "unable to decode metadata for %s", ctx)
 	}
 
-	return string(metadataBuf), nil
+	return string(metadataBuf), ignition, nil
 }
 
-func (vms *VMService) setMetadata(ctx *virtualMachineContext, metadata []byte) (string, error) {
+// getMetadataExtraConfig returns the extra config setting the metadata of the
+// VM, or its Ignition config. It returns extra.ErrGuestInfoTooLarge if the
+// guestinfo of the VM would exceed the size limit, together with the bootstrap
+// data set when the VM was created.
+func (vms *VMService) getMetadataExtraConfig(ctx *virtualMachineContext, metadata []byte, ignition bool) (extra.Config, error) {
 	var extraConfig extra.Config
-	if err := extraConfig.SetCloudInitMetadata(metadata); err != nil {
-		return "", errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+	if ignition {
+		if err := extraConfig.SetIgnitionUserData(metadata); err != nil {
+			return nil, errors.Wrapf(err, "unable to set Ignition config on vm %s", ctx)
+		}
+	} else if err := extraConfig.SetCloudInitMetadata(metadata); err != nil {
+		return nil, errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+	}
+
+	existing, err := ctx.Session.Properties.ExtraConfig(ctx, ctx.Ref)
+	if err != nil {
+		return nil, err
 	}
+	if err := extraConfig.ValidateGuestInfoSize(existing); err != nil {
+		return nil, errors.Wrapf(err, "metadata of vm %s is too large", ctx)
+	}
+	return extraConfig, nil
+}
 
+func (vms *VMService) setMetadata(ctx *virtualMachineContext, extraConfig extra.Config) (string, error) {
 	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
 		ExtraConfig: extraConfig,
 	})
@@ -454,7 +568,11 @@ func (vms *VMService) setMetadata(ctx *virtualMachineContext, metadata []byte) (
 }
 
 func (vms *VMService) getNetworkStatus(ctx *virtualMachineContext) ([]infrav1.NetworkStatus, error) {
-	allNetStatus, err := net.GetNetworkStatus(ctx, ctx.Session.Client.Client, ctx.Ref)
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
+	if err != nil {
+		return nil, err
+	}
+	allNetStatus, err := net.GetNetworkStatusOf(obj)
 	if err != nil {
 		return nil, err
 	}
@@ -471,10 +589,12 @@ func (vms *VMService) getNetworkStatus(ctx *virtualMachineContext) ([]infrav1.Ne
 	return apiNetStatus, nil
 }
 
-func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, error) {
+// getBootstrapData returns the bootstrap data of the VM and its format, which
+// defaults to cloud-config.
+func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, bootstrapv1.Format, error) {
 	if ctx.VSphereVM.Spec.BootstrapRef == nil {
 		ctx.Logger.Info("VM has no bootstrap data")
-		return nil, nil
+		return nil, "", nil
 	}
 
 	secret := &corev1.Secret{}
@@ -483,15 +603,46 @@ func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, error) {
 		Name:      ctx.VSphereVM.Spec.BootstrapRef.Name,
 	}
 	if err := ctx.Client.Get(ctx, secretKey, secret); err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve bootstrap data secret for %s", ctx)
+		return nil, "", errors.Wrapf(err, "failed to retrieve bootstrap data secret for %s", ctx)
 	}
 
 	value, ok := secret.Data["value"]
 	if !ok {
-		return nil, errors.New("error retrieving bootstrap data: secret value key is missing")
+		return nil, "", errors.New("error retrieving bootstrap data: secret value key is missing")
 	}
 
-	return value, nil
+	format := bootstrapv1.Format(secret.Data["format"])
+	if format == "" {
+		format = bootstrapv1.CloudConfig
+	}
+	return value, format, nil
+}
+
+// getMetadataTemplate returns the cloud-init metadata template referenced by
+// the VSphereVM, or nil if it does not reference one.
+func (vms *VMService) getMetadataTemplate(ctx *context.VMContext) (*template.Template, error) {
+	if ctx.VSphereVM.Spec.MetadataTemplate == nil {
+		return nil, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	configMapKey := apitypes.NamespacedName{
+		Namespace: ctx.VSphereVM.Namespace,
+		Name:      ctx.VSphereVM.Spec.MetadataTemplate.Name,
+	}
+	if err := ctx.Client.Get(ctx, configMapKey, configMap); err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve metadata template configmap for %s", ctx)
+	}
+
+	text, ok := configMap.Data[infrav1.MetadataTemplateKey]
+	if !ok {
+		return nil, errors.Errorf("error retrieving metadata template: configmap %s key is missing", infrav1.MetadataTemplateKey)
+	}
+	tpl, err := util.ParseMetadataTemplate(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse metadata template configmap for %s", ctx)
+	}
+	return tpl, nil
 }
 
 func (vms *VMService) reconcileVMGroupInfo(ctx *virtualMachineContext) (bool, error) {
@@ -523,11 +674,99 @@ func (vms *VMService) reconcileVMGroupInfo(ctx *virtualMachineContext) (bool, er
 	return true, nil
 }
 
+// reconcileClusterModule adds the VM to the cluster module of its control
+// plane or MachineDeployment, or to the DRS VM-VM anti-affinity rule used
+// instead, together with the VMs of the other VSphereVMs of the control plane
+// or MachineDeployment.
+func (vms *VMService) reconcileClusterModule(ctx *virtualMachineContext) (bool, error) {
+	module := ctx.ClusterModule
+	if module == nil {
+		return true, nil
+	}
+
+	// The VM is only added once, the membership is not checked again.
+	if module.ModuleUUID != "" {
+		if ctx.VSphereVM.Status.ClusterModule == module.ModuleUUID {
+			return true, nil
+		}
+		if err := cluster.AddModuleMember(ctx, module.ModuleUUID, ctx.Ref); err != nil {
+			return false, errors.Wrapf(err, "failed to add VM %s to cluster module", ctx.VSphereVM.Name)
+		}
+		ctx.VSphereVM.Status.ClusterModule = module.ModuleUUID
+		return true, nil
+	}
+
+	if ctx.VSphereVM.Status.ClusterModule == module.AntiAffinityRule {
+		return true, nil
+	}
+	peers, err := getClusterModulePeers(ctx)
+	if err != nil {
+		return false, err
+	}
+	task, err := cluster.AddToAntiAffinityRule(ctx, module.ComputeCluster, module.AntiAffinityRule, append([]types.ManagedObjectReference{ctx.Ref}, peers...)...)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to add VM %s to anti-affinity rule %s", ctx.VSphereVM.Name, module.AntiAffinityRule)
+	}
+	if task != nil {
+		ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+		ctx.Logger.Info("wait for VM to be added to anti-affinity rule")
+		return false, nil
+	}
+	// A rule is only created once it has at least two VMs, so the VM is only
+	// a member if it has peers. Later VMs add themselves to the rule.
+	if len(peers) > 0 {
+		ctx.VSphereVM.Status.ClusterModule = module.AntiAffinityRule
+	}
+	return true, nil
+}
+
+// getClusterModulePeers returns the VMs of the other VSphereVMs of the
+// control plane or MachineDeployment of the VM.
+func getClusterModulePeers(ctx *virtualMachineContext) ([]types.ManagedObjectReference, error) {
+	selector := labels.SelectorFromSet(labels.Set{clusterv1.ClusterLabelName: ctx.VSphereVM.Labels[clusterv1.ClusterLabelName]})
+	if ctx.ClusterModule.ControlPlane {
+		requirement, err := labels.NewRequirement(clusterv1.MachineControlPlaneLabelName, selection.Exists, nil)
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*requirement)
+	} else {
+		requirement, err := labels.NewRequirement(clusterv1.MachineDeploymentLabelName, selection.Equals, []string{ctx.ClusterModule.TargetObjectName})
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*requirement)
+	}
+
+	vsphereVMs := &infrav1.VSphereVMList{}
+	if err := ctx.Client.List(ctx, vsphereVMs, client.InNamespace(ctx.VSphereVM.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list VSphereVMs of %s", ctx.ClusterModule.TargetObjectName)
+	}
+
+	var refs []types.ManagedObjectReference
+	for _, vsphereVM := range vsphereVMs.Items {
+		if vsphereVM.Name == ctx.VSphereVM.Name || vsphereVM.Spec.BiosUUID == "" || !vsphereVM.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ref, err := ctx.Session.FindByBIOSUUID(ctx, vsphereVM.Spec.BiosUUID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find VM of VSphereVM %s", vsphereVM.Name)
+		}
+		if ref != nil {
+			refs = append(refs, ref.Reference())
+		}
+	}
+	return refs, nil
+}
+
 func (vms *VMService) reconcileTags(ctx *virtualMachineContext) error {
 	if len(ctx.VSphereVM.Spec.TagIDs) == 0 {
 		ctx.Logger.Info("no tags defined. skipping tags reconciliation")
 		return nil
 	}
+	if ctx.Session.TagManager == nil {
+		return errors.Errorf("failed to attach tags %v to VM %s: tagging is not supported on ESXi", ctx.VSphereVM.Spec.TagIDs, ctx.VSphereVM.Name)
+	}
 
 	err := ctx.Session.TagManager.AttachMultipleTagsToObject(ctx, ctx.VSphereVM.Spec.TagIDs, ctx.Ref)
 	if err != nil {
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/cluster/simulator"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestReconcileClusterModule(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.ServiceContent.About.ApiVersion = "7.0.1.0"
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+	authSession, err := session.GetOrCreate(
+		vmContext.Context,
+		session.NewParams().
+			WithInsecure(true).
+			WithServer(vmContext.VSphereVM.Spec.Server).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+
+	vm, err := authSession.Finder.VirtualMachine(vmContext, "DC0_C0_RP0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       vm,
+		Ref:       vm.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	moduleUUID, err := cluster.CreateModule(ctx, "DC0_C0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx.ClusterModule = &infrav1.ClusterModule{ComputeCluster: "DC0_C0", ModuleUUID: moduleUUID}
+
+	ok, err := (&VMService{}).reconcileClusterModule(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.ClusterModule).To(Equal(moduleUUID))
+
+	// The members of the module are not listed again once the VM was added,
+	// which would fail for a deleted module.
+	g.Expect(cluster.DeleteModule(ctx, moduleUUID)).To(Succeed())
+	ok, err = (&VMService{}).reconcileClusterModule(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+
+	// The VM is added to a module which replaces the previous one.
+	moduleUUID, err = cluster.CreateModule(ctx, "DC0_C0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx.ClusterModule.ModuleUUID = moduleUUID
+	ok, err = (&VMService{}).reconcileClusterModule(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.ClusterModule).To(Equal(moduleUUID))
+}
+
+func TestDestroyVM(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := vcsim.NewBuilder().Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+	authSession, err := session.GetOrCreate(
+		vmContext.Context,
+		session.NewParams().
+			WithInsecure(true).
+			WithServer(vmContext.VSphereVM.Spec.Server).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+
+	t.Run("forgets the operation of a VM which waits for a slot", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken bool
+		vmContext.OperationThrottle = throttle.New(throttle.Limits{Server: 1})
+		vmContext.OperationThrottle.Hold(throttle.Operation{Key: "other", Server: vmContext.VSphereVM.Spec.Server})
+		g.Expect(acquireOperationSlot(vmContext, infrav1.VMProvisionedCondition, "")).To(BeFalse())
+		g.Expect(WaitingForOperationSlot(vmContext)).To(BeTrue())
+		g.Expect(vmContext.OperationThrottle.Acquire(throttle.Operation{
+			Key:    "next",
+			Server: vmContext.VSphereVM.Spec.Server,
+			Wake:   func() { woken = true },
+		})).To(BeFalse())
+
+		vm, err := (&VMService{}).DestroyVM(vmContext)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vm.State).To(Equal(infrav1.VirtualMachineStateNotFound))
+		g.Expect(WaitingForOperationSlot(vmContext)).To(BeFalse())
+
+		// The next VM gets the slot released by the other one.
+		vmContext.OperationThrottle.Release("other")
+		g.Expect(woken).To(BeTrue())
+	})
+}
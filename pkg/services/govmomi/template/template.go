@@ -18,12 +18,16 @@ package template
 
 import (
 	"context"
+	"net/http"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
@@ -62,13 +66,93 @@ func findTemplateByInstanceUUID(ctx tplContext, templateID string) (*object.Virt
 
 func findTemplateByName(ctx tplContext, templateID string) (*object.VirtualMachine, error) {
 	ctx.GetLogger().V(6).Info("find template by name", "name", templateID)
-	tpl, err := ctx.GetSession().Finder.VirtualMachine(ctx, templateID)
+	tpl, err := ctx.GetSession().Inventory.VirtualMachine(ctx, templateID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to find template by name %q", templateID)
 	}
 	return tpl, nil
 }
 
+// FindLibraryItem finds the content library item a template refers to with the
+// "library:" prefix, based either on the item's ID or on the name of its
+// library and its own name, separated by a slash, e.g. "library:images/ubuntu".
+// Nil is returned if the template does not have the prefix, in which case it
+// refers to a VM in the inventory.
+//
+// Resolved items, as well as items which were not found, are cached by the
+// session for a limited time.
+func FindLibraryItem(ctx tplContext, templateID string) (*library.Item, error) {
+	if !strings.HasPrefix(templateID, infrav1.LibraryItemTemplatePrefix) {
+		return nil, nil
+	}
+	ref := strings.TrimPrefix(templateID, infrav1.LibraryItemTemplatePrefix)
+
+	// Content libraries are only available on vCenter.
+	if !ctx.GetSession().IsVC() {
+		return nil, errors.Errorf("content library item %q cannot be used without vCenter", ref)
+	}
+
+	item, ok := ctx.GetSession().LibraryItem(ref)
+	if !ok {
+		var err error
+		if isValidUUID(ref) {
+			item, err = findLibraryItemByID(ctx, ref)
+		} else if parts := strings.Split(ref, "/"); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			item, err = findLibraryItemByName(ctx, parts[0], parts[1])
+		} else {
+			return nil, errors.Errorf("invalid content library item %q, expected an item ID or <library name>/<item name>", ref)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ctx.GetSession().CacheLibraryItem(ref, item)
+	}
+	if item == nil {
+		return nil, errors.Errorf("unable to find content library item %q", ref)
+	}
+	return item, nil
+}
+
+func findLibraryItemByID(ctx tplContext, itemID string) (*library.Item, error) {
+	ctx.GetLogger().V(6).Info("find library item by id", "id", itemID)
+	item, err := library.NewManager(ctx.GetSession().TagManager.Client).GetLibraryItem(ctx, itemID)
+	if err != nil {
+		// The vAPI client does not export the status of failed requests.
+		if strings.HasSuffix(err.Error(), http.StatusText(http.StatusNotFound)) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "error getting library item %q", itemID)
+	}
+	return item, nil
+}
+
+func findLibraryItemByName(ctx tplContext, libraryName, itemName string) (*library.Item, error) {
+	ctx.GetLogger().V(6).Info("find library item by name", "library", libraryName, "name", itemName)
+	manager := library.NewManager(ctx.GetSession().TagManager.Client)
+	libraryIDs, err := manager.FindLibrary(ctx, library.Find{Name: libraryName})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding content library %q", libraryName)
+	}
+	for _, libraryID := range libraryIDs {
+		itemIDs, err := manager.FindLibraryItems(ctx, library.FindItem{LibraryID: libraryID, Name: itemName})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error finding item %q of content library %q", itemName, libraryName)
+		}
+		if len(itemIDs) > 0 {
+			return getLibraryItem(ctx, manager, itemIDs[0])
+		}
+	}
+	return nil, nil
+}
+
+func getLibraryItem(ctx tplContext, manager *library.Manager, itemID string) (*library.Item, error) {
+	item, err := manager.GetLibraryItem(ctx, itemID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting library item %q", itemID)
+	}
+	return item, nil
+}
+
 func isValidUUID(str string) bool {
 	_, err := uuid.Parse(str)
 	return err == nil
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// throttledTasks are the description IDs of the tasks which require an
+// operation slot.
+var throttledTasks = map[string]struct{}{
+	"VirtualMachine.clone":                 {},
+	"VirtualMachine.reconfigure":           {},
+	"VirtualMachine.powerOn":               {},
+	"VirtualDiskManager.copyVirtualDisk":   {},
+	"VirtualDiskManager.extendVirtualDisk": {},
+}
+
+func operationThrottle(ctx *context.VMContext) *throttle.Throttle {
+	if ctx.ControllerContext == nil || ctx.ControllerManagerContext == nil {
+		return nil
+	}
+	return ctx.OperationThrottle
+}
+
+// vmOperation returns the operation of a clone, reconfigure or power-on task
+// of the VSphereVM on the datastore with the given MoRef value, if known. The
+// tasks of control plane VMs are prioritized.
+func vmOperation(ctx *context.VMContext, datastore string) throttle.Operation {
+	priority := throttle.PriorityDefault
+	if util.IsControlPlaneMachine(ctx.VSphereVM) {
+		priority = throttle.PriorityControlPlane
+	}
+	enqueue := enqueueVSphereVMFunc(ctx)
+	return throttle.Operation{
+		Key:       operationKey(ctx),
+		Server:    ctx.VSphereVM.Spec.Server,
+		Datastore: datastore,
+		Priority:  priority,
+		Wake:      func() { enqueue("reason", "operation-slot-released") },
+	}
+}
+
+func operationKey(ctx *context.VMContext) string {
+	return ctx.VSphereVM.Namespace + "/" + ctx.VSphereVM.Name
+}
+
+// vmDatastore returns the MoRef value of the datastore of the VM's first
+// disk, which is where the VM was placed when it was cloned, or an empty
+// string if it is not known.
+func vmDatastore(ctx *virtualMachineContext) string {
+	obj, err := ctx.Session.Properties.VirtualMachine(ctx, ctx.Ref)
+	if err != nil || obj.Config == nil {
+		return ""
+	}
+	for _, device := range obj.Config.Hardware.Device {
+		disk, ok := device.(*types.VirtualDisk)
+		if !ok {
+			continue
+		}
+		if backing, ok := disk.Backing.(types.BaseVirtualDeviceFileBackingInfo); ok {
+			if datastore := backing.GetVirtualDeviceFileBackingInfo().Datastore; datastore != nil {
+				return datastore.Value
+			}
+		}
+	}
+	return ""
+}
+
+// placementDatastore returns the MoRef value of the datastore recorded in the
+// placement of the VSphereVM, or an empty string if it is not known.
+func placementDatastore(ctx *context.VMContext) string {
+	placement := ctx.VSphereVM.Status.Placement
+	if placement == nil || placement.Datastore == "" {
+		return ""
+	}
+	datastore, err := ctx.Session.Inventory.DatastoreOrDefault(ctx, placement.Datastore)
+	if err != nil {
+		return ""
+	}
+	return datastore.Reference().Value
+}
+
+// acquireOperationSlot returns whether the VSphereVM may start a clone,
+// reconfigure or power-on task on the datastore with the given MoRef value.
+// Otherwise the condition is marked as waiting for a slot, and the VSphereVM
+// is reconciled again once a slot is released. The slot is released once the
+// task completes.
+func acquireOperationSlot(ctx *context.VMContext, condition clusterv1.ConditionType, datastore string) bool {
+	if operationThrottle(ctx).Acquire(vmOperation(ctx, datastore)) {
+		return true
+	}
+	ctx.Logger.Info("waiting for a slot to start a task", "server", ctx.VSphereVM.Spec.Server, "datastore", datastore)
+	// A provisioned VM which waits for a slot, such as to update its
+	// metadata, is not reported as being provisioned again.
+	if condition != infrav1.VMProvisionedCondition || !conditions.IsTrue(ctx.VSphereVM, condition) {
+		conditions.MarkFalse(ctx.VSphereVM, condition, infrav1.WaitingForCloneSlotReason, clusterv1.ConditionSeverityInfo,
+			"waiting for other clone, reconfigure or power-on tasks on %s to complete", ctx.VSphereVM.Spec.Server)
+	}
+	return false
+}
+
+// releaseOperationSlot releases the slot of the VSphereVM, if it holds one.
+func releaseOperationSlot(ctx *context.VMContext) {
+	operationThrottle(ctx).Release(operationKey(ctx))
+}
+
+// forgetOperation forgets the operation of the VSphereVM if it waits for a
+// slot, so that a deleted VSphereVM does not hold up the operations of other
+// VSphereVMs.
+func forgetOperation(ctx *context.VMContext) {
+	operationThrottle(ctx).Forget(operationKey(ctx))
+}
+
+// WaitingForOperationSlot returns whether the VSphereVM waits for a slot to
+// start a clone, reconfigure or power-on task.
+func WaitingForOperationSlot(ctx *context.VMContext) bool {
+	return operationThrottle(ctx).Waits(operationKey(ctx))
+}
+
+// reconcileOperationSlot makes the VSphereVM hold a slot while its clone,
+// reconfigure or power-on task is queued or running, including tasks which
+// were started before the controller restarted, or while it is deployed from
+// a content library, and releases it otherwise. A slot which is not held yet
+// is taken on the datastore recorded in the placement of the VSphereVM.
+func reconcileOperationSlot(ctx *context.VMContext, task *mo.Task) {
+	hold := vcenter.Deploying(ctx)
+	if task != nil {
+		_, throttled := throttledTasks[task.Info.DescriptionId]
+		hold = hold || (throttled && (task.Info.State == types.TaskInfoStateQueued || task.Info.State == types.TaskInfoStateRunning))
+	}
+	if hold {
+		if t := operationThrottle(ctx); t != nil && !t.Holds(operationKey(ctx)) {
+			t.Hold(vmOperation(ctx, placementDatastore(ctx)))
+		}
+		return
+	}
+	releaseOperationSlot(ctx)
+}
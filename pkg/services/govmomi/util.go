@@ -17,17 +17,17 @@ limitations under the License.
 package govmomi
 
 import (
-	gonet "net"
+	"fmt"
 	"path"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/vmware/govmomi/object"
-	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
@@ -78,7 +78,7 @@ func findVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
 	}
 	if objRef == nil {
 		// fallback to use inventory paths
-		folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+		folder, err := ctx.Session.Inventory.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
 		if err != nil {
 			return types.ManagedObjectReference{}, err
 		}
@@ -118,6 +118,7 @@ func getTask(ctx *context.VMContext) *mo.Task {
 func reconcileInFlightTask(ctx *context.VMContext) (bool, error) {
 	// Check to see if there is an in-flight task.
 	task := getTask(ctx)
+	reconcileOperationSlot(ctx, task)
 	return checkAndRetryTask(ctx, task)
 }
 
@@ -149,10 +150,14 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 		return true, nil
 	case types.TaskInfoStateSuccess:
 		logger.Info("task is a success", "description-id", task.Info.DescriptionId)
+		observeTaskCompletion(ctx, task)
+		invalidateCachedObject(ctx, task.Info.Entity)
 		ctx.VSphereVM.Status.TaskRef = ""
+		ctx.VSphereVM.Status.TaskRetries = 0
 		return false, nil
 	case types.TaskInfoStateError:
-		logger.Info("task failed", "description-id", task.Info.DescriptionId)
+		logger.Info("task failed", "description-id", task.Info.DescriptionId, "fault", faultName(task))
+		invalidateCachedObject(ctx, task.Info.Entity)
 
 		// NOTE: When a task fails there is not simple way to understand which operation is failing (e.g. cloning or powering on)
 		// so we are reporting failures using a dedicated reason until we find a better solution.
@@ -161,421 +166,210 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 		if task.Info.Description != nil {
 			description = task.Info.Description.Message
 		}
-		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TaskFailure, clusterv1.ConditionSeverityInfo, description)
+		markProvisioningFailure(ctx, infrav1.TaskFailure, clusterv1.ConditionSeverityInfo, description)
 
 		// Instead of directly requeuing the failed task, wait for the RetryAfter duration to pass
 		// before resetting the taskRef from the VSphereVM status.
-		if ctx.VSphereVM.Status.RetryAfter.IsZero() {
-			ctx.VSphereVM.Status.RetryAfter = metav1.Time{Time: time.Now().Add(1 * time.Minute)}
-		} else {
+		if !ctx.VSphereVM.Status.RetryAfter.IsZero() {
 			ctx.VSphereVM.Status.TaskRef = ""
 			ctx.VSphereVM.Status.RetryAfter = metav1.Time{}
+			return true, nil
+		}
+
+		// The failed task is only handled when it is seen first.
+		observeTaskCompletion(ctx, task)
+		message := faultMessage(task)
+
+		// Tasks of a VSphereVM which was never ready which failed with a
+		// terminal fault are not retried, the VSphereVM is failed instead.
+		// A ready VSphereVM is not failed by a failed update, such as a
+		// resize, the condition of the update reports the fault instead and
+		// the task is retried.
+		if task.Info.Error != nil && isTerminalFault(task.Info.Error.Fault) {
+			failureMessage := fmt.Sprintf("%s task failed with %s: %s", task.Info.DescriptionId, faultName(task), message)
+			if !ctx.VSphereVM.Status.Ready {
+				ctx.VSphereVM.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)
+				ctx.VSphereVM.Status.FailureMessage = pointer.StringPtr(failureMessage)
+				ctx.VSphereVM.Status.TaskRef = ""
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TaskFailure, clusterv1.ConditionSeverityError, message)
+				ctx.Recorder.Warnf(ctx.VSphereVM, "TaskFailed", "%s task failed with %s and will not be retried: %s", task.Info.DescriptionId, faultName(task), message)
+				return true, nil
+			}
+			if conditions.GetReason(ctx.VSphereVM, infrav1.VMResizedCondition) == infrav1.ResizingReason {
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityError, "%s", failureMessage)
+			} else {
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TaskFailure, clusterv1.ConditionSeverityError, "%s", failureMessage)
+			}
 		}
+
+		ctx.VSphereVM.Status.TaskRetries++
+		delay := taskRetryDelay(ctx.VSphereVM.Status.TaskRetries)
+		ctx.VSphereVM.Status.RetryAfter = metav1.Time{Time: time.Now().Add(delay)}
+		ctx.Recorder.Warnf(ctx.VSphereVM, "TaskFailed", "%s task failed with %s, retrying after %s: %s", task.Info.DescriptionId, faultName(task), delay, message)
 		return true, nil
 	default:
 		return false, errors.Errorf("unknown task state %q for %q", task.Info.State, ctx)
 	}
 }
 
-func reconcileVSphereVMWhenNetworkIsReady(ctx *virtualMachineContext, powerOnTask *object.Task) {
-	reconcileVSphereVMOnChannel(
-		&ctx.VMContext,
-		func() (<-chan []interface{}, <-chan error, error) {
-			// Wait for the VM to be powered on.
-			powerOnTaskInfo, err := powerOnTask.WaitForResult(ctx)
-			if err != nil && powerOnTaskInfo == nil {
-				return nil, nil, errors.Wrapf(err, "failed to wait for power on op for vm %s", ctx)
-			}
-			powerState, err := ctx.Obj.PowerState(ctx)
-			if err != nil {
-				return nil, nil, errors.Wrapf(err, "failed to get power state for vm %s", ctx)
-			}
-			if powerState != types.VirtualMachinePowerStatePoweredOn {
-				return nil, nil, errors.Errorf(
-					"unexpected power state %v for vm %s",
-					powerState, ctx)
-			}
+// vmWatchedProperties are the properties of a VM whose changes trigger a
+// reconcile of its VSphereVM.
+var vmWatchedProperties = []string{"runtime.powerState", "guest.net", "config.changeVersion"}
 
-			// Wait for all NICs to have valid MAC addresses.
-			if err := waitForMacAddresses(ctx); err != nil {
-				return nil, nil, errors.Wrapf(err, "failed to wait for mac addresses for vm %s", ctx)
-			}
+// watchVM triggers a reconcile of the VSphereVM whenever the power state, the
+// IP addresses or the configuration of its VM change, or the VM is deleted.
+// The VM is watched by the watcher of the session, it is not watched anymore
+// once it is deleted.
+func watchVM(ctx *virtualMachineContext) {
+	watcher, err := ctx.Session.Watcher(ctx)
+	if err != nil {
+		ctx.Logger.Error(err, "unable to watch vm")
+		return
+	}
 
-			// Get all the MAC addresses. This is done separately from waiting
-			// for all NICs to have MAC addresses in order to ensure the order
-			// of the retrieved MAC addresses matches the order of the device
-			// specs, and not the propery change order.
-			_, macToDeviceIndex, deviceToMacIndex, err := getMacAddresses(ctx)
-			if err != nil {
-				return nil, nil, errors.Wrapf(err, "failed to get mac addresses for vm %s", ctx)
+	addresses := ctx.VSphereVM.Status.Addresses
+	enqueue := enqueueVSphereVMFunc(&ctx.VMContext)
+	if err := watcher.Watch(ctx, ctx.Ref, vmWatchedProperties, func(update types.ObjectUpdate) bool {
+		switch update.Kind {
+		case types.ObjectUpdateKindEnter:
+			// The initial update reports the state the VM is reconciled with.
+			return false
+		case types.ObjectUpdateKindLeave:
+			invalidateCachedObject(&ctx.VMContext, &ctx.Ref)
+			enqueue("reason", "vm-deleted")
+			return true
+		}
+		invalidateCachedObject(&ctx.VMContext, &ctx.Ref)
+		for _, change := range update.ChangeSet {
+			if change.Name == "guest.net" && !ipAddressesChanged(ctx, change, addresses) {
+				continue
 			}
+			enqueue("reason", "vm", "property", change.Name)
+			break
+		}
+		return false
+	}); err != nil {
+		ctx.Logger.Error(err, "unable to watch vm")
+	}
+}
 
-			// Wait for the IP addresses to show up for the VM.
-			chanIPAddresses, chanErrs := waitForIPAddresses(ctx, macToDeviceIndex, deviceToMacIndex)
-
-			// Trigger a reconcile every time a new IP is discovered.
-			chanOfLoggerKeysAndValues := make(chan []interface{})
-			go func() {
-				for ip := range chanIPAddresses {
-					chanOfLoggerKeysAndValues <- []interface{}{
-						"reason", "network",
-						"ipAddress", ip,
-					}
-				}
-			}()
-			return chanOfLoggerKeysAndValues, chanErrs, nil
-		})
+// ipAddressesChanged returns whether the guest.net property change reports
+// other IP addresses than the given addresses of the VSphereVM. Link-local
+// addresses and the addresses of NICs which are not backed by a virtual
+// device, such as the interfaces of containers, are ignored.
+func ipAddressesChanged(ctx *virtualMachineContext, change types.PropertyChange, addresses []string) bool {
+	nics, ok := change.Val.(types.ArrayOfGuestNicInfo)
+	if !ok {
+		return change.Op != types.PropertyChangeOpAssign
+	}
+	known := map[string]struct{}{}
+	for _, addr := range addresses {
+		known[addr] = struct{}{}
+	}
+	var discovered int
+	for _, nic := range nics.GuestNicInfo {
+		if nic.DeviceConfigId < 0 {
+			continue
+		}
+		for _, addr := range sanitizeIPAddrs(&ctx.VMContext, nic.IpAddress) {
+			if _, ok := known[addr]; !ok {
+				return true
+			}
+			discovered++
+		}
+	}
+	return discovered != len(known)
 }
 
-func reconcileVSphereVMOnTaskCompletion(ctx *context.VMContext) {
-	task := getTask(ctx)
-	if task == nil {
+// watchTask triggers a reconcile of the VSphereVM once the task in its status
+// succeeds. No reconcile is triggered if the task fails, as the task should not
+// be retried right away. The task is watched by the watcher of the session.
+func watchTask(ctx *context.VMContext) {
+	if ctx.VSphereVM.Status.TaskRef == "" {
 		ctx.Logger.V(4).Info(
 			"skipping reconcile VSphereVM on task completion",
 			"reason", "no-task")
 		return
 	}
-	taskRef := task.Reference()
-	taskHelper := object.NewTask(ctx.Session.Client.Client, taskRef)
-
-	ctx.Logger.Info(
-		"enqueuing reconcile request on task completion",
-		"task-ref", taskRef,
-		"task-name", task.Info.Name,
-		"task-entity-name", task.Info.EntityName,
-		"task-description-id", task.Info.DescriptionId)
-
-	reconcileVSphereVMOnFuncCompletion(ctx, func() ([]interface{}, error) {
-		taskInfo, err := taskHelper.WaitForResult(ctx)
-
-		// An error is only returned if the process of waiting for the result
-		// failed, *not* if the task itself failed.
-		if err != nil && taskInfo == nil {
-			return nil, err
-		}
-		// do not queue in the event channel when task fails as we don't
-		// want to retry right away
-		if taskInfo.State == types.TaskInfoStateError {
-			ctx.Logger.Info("async task wait failed")
-			return nil, errors.Errorf("task failed")
-		}
-
-		return []interface{}{
-			"reason", "task",
-			"task-ref", taskRef,
-			"task-name", taskInfo.Name,
-			"task-entity-name", taskInfo.EntityName,
-			"task-state", taskInfo.State,
-			"task-description-id", taskInfo.DescriptionId,
-		}, nil
-	})
-}
-
-func reconcileVSphereVMOnFuncCompletion(ctx *context.VMContext, waitFn func() (loggerKeysAndValues []interface{}, _ error)) {
-	obj := ctx.VSphereVM.DeepCopy()
-	gvk := obj.GetObjectKind().GroupVersionKind()
+	taskRef := types.ManagedObjectReference{Type: morefTypeTask, Value: ctx.VSphereVM.Status.TaskRef}
 
-	// Wait on the function to complete in a background goroutine.
-	go func() {
-		loggerKeysAndValues, err := waitFn()
-		if err != nil {
-			ctx.Logger.Error(err, "failed to wait on func")
-			return
-		}
+	watcher, err := ctx.Session.Watcher(ctx)
+	if err != nil {
+		ctx.Logger.Error(err, "unable to watch task", "task-ref", taskRef)
+		return
+	}
 
-		// Once the task has completed (successfully or otherwise), trigger
-		// a reconcile event for the associated resource by sending a
-		// GenericEvent into the event channel for the resource type.
-		ctx.Logger.Info("triggering GenericEvent", loggerKeysAndValues...)
-		eventChannel := ctx.GetGenericEventChannelFor(gvk)
-		eventChannel <- event.GenericEvent{
-			Object: obj,
+	// The entity of the task is only reported with the initial values, its
+	// cached properties are invalidated once the task completes.
+	var entity *types.ManagedObjectReference
+	enqueue := enqueueVSphereVMFunc(ctx)
+	if err := watcher.Watch(ctx, taskRef, []string{"info.state", "info.entity"}, func(update types.ObjectUpdate) bool {
+		if update.Kind == types.ObjectUpdateKindLeave {
+			enqueue("reason", "task-deleted", "task-ref", taskRef)
+			return true
 		}
-	}()
-}
-
-func reconcileVSphereVMOnChannel(ctx *context.VMContext, waitFn func() (<-chan []interface{}, <-chan error, error)) {
-	obj := ctx.VSphereVM.DeepCopy()
-	gvk := obj.GetObjectKind().GroupVersionKind()
-
-	// Send a generic event for every set of logger keys/values received
-	// on the channel.
-	go func() {
-		chanOfLoggerKeysAndValues, chanErrs, err := waitFn()
-		if err != nil {
-			ctx.Logger.Error(err, "failed to wait on func")
-			return
+		for _, change := range update.ChangeSet {
+			if ref, ok := change.Val.(types.ManagedObjectReference); ok && change.Name == "info.entity" {
+				entity = &ref
+			}
 		}
-		for {
-			select {
-			case loggerKeysAndValues := <-chanOfLoggerKeysAndValues:
-				if loggerKeysAndValues == nil {
-					return
-				}
-				go func() {
-					// Trigger a reconcile event for the associated resource by
-					// sending a GenericEvent into the event channel for the resource
-					// type.
-					ctx.Logger.Info("triggering GenericEvent", loggerKeysAndValues...)
-					eventChannel := ctx.GetGenericEventChannelFor(gvk)
-					eventChannel <- event.GenericEvent{
-						Object: obj,
-					}
-				}()
-			case err := <-chanErrs:
-				if err != nil {
-					ctx.Logger.Error(err, "error occurred while waiting to trigger a generic event")
-				}
-				return
-			case <-ctx.Done():
-				return
+		for _, change := range update.ChangeSet {
+			if change.Name != "info.state" {
+				continue
+			}
+			switch change.Val {
+			case types.TaskInfoStateSuccess:
+				invalidateCachedObject(ctx, entity)
+				releaseOperationSlot(ctx)
+				enqueue("reason", "task", "task-ref", taskRef, "task-state", change.Val)
+				return true
+			case types.TaskInfoStateError:
+				invalidateCachedObject(ctx, entity)
+				releaseOperationSlot(ctx)
+				ctx.Logger.Info("async task wait failed", "task-ref", taskRef)
+				return true
 			}
 		}
-	}()
-}
+		return false
+	}); err != nil {
+		ctx.Logger.Error(err, "unable to watch task", "task-ref", taskRef)
+		return
+	}
 
-// waitForMacAddresses waits for all configured network devices to have
-// valid MAC addresses.
-func waitForMacAddresses(ctx *virtualMachineContext) error {
-	return property.Wait(
-		ctx, property.DefaultCollector(ctx.Session.Client.Client),
-		ctx.Obj.Reference(), []string{"config.hardware.device"},
-		func(propertyChanges []types.PropertyChange) bool {
-			for _, propChange := range propertyChanges {
-				if propChange.Op != types.PropertyChangeOpAssign {
-					continue
-				}
-				deviceList := object.VirtualDeviceList(propChange.Val.(types.ArrayOfVirtualDevice).VirtualDevice)
-				for _, dev := range deviceList {
-					if nic, ok := dev.(types.BaseVirtualEthernetCard); ok {
-						mac := nic.GetVirtualEthernetCard().MacAddress
-						if mac == "" {
-							return false
-						}
-					}
-				}
-			}
-			return true
-		})
+	ctx.Logger.V(4).Info("enqueuing reconcile request on task completion", "task-ref", taskRef)
 }
 
-// getMacAddresses gets the MAC addresses for all network devices.
-// This happens separately from waitForMacAddresses to ensure returned order of
-// devices matches the spec and not order in which the property changes were
-// noticed.
-func getMacAddresses(ctx *virtualMachineContext) ([]string, map[string]int, map[int]string, error) {
-	var (
-		vm                   mo.VirtualMachine
-		macAddresses         []string
-		macToDeviceSpecIndex = map[string]int{}
-		deviceSpecIndexToMac = map[int]string{}
-	)
-	if err := ctx.Obj.Properties(ctx, ctx.Obj.Reference(), []string{"config.hardware.device"}, &vm); err != nil {
-		return nil, nil, nil, err
+// invalidateCachedObject removes the object from the property and inventory
+// caches of the session, such as once a task changed it.
+func invalidateCachedObject(ctx *context.VMContext, ref *types.ManagedObjectReference) {
+	if ref == nil || ctx.Session == nil {
+		return
 	}
-	i := 0
-	for _, device := range vm.Config.Hardware.Device {
-		if nic, ok := device.(types.BaseVirtualEthernetCard); ok {
-			mac := nic.GetVirtualEthernetCard().MacAddress
-			macAddresses = append(macAddresses, mac)
-			macToDeviceSpecIndex[mac] = i
-			deviceSpecIndexToMac[i] = mac
-			i++
-		}
+	if ctx.Session.Properties != nil {
+		ctx.Session.Properties.Invalidate(*ref)
 	}
-	return macAddresses, macToDeviceSpecIndex, deviceSpecIndexToMac, nil
-}
-
-// waitForIPAddresses waits for all network devices that should be getting an
-// IP address to have an IP address. This is any network device that specifies a
-// network name and DHCP for v4 or v6 or one or more static IP addresses.
-// The gocyclo detector is disabled for this function as it is difficult to
-// rewrite much simpler due to the maps used to track state and the lambdas
-// that use the maps.
-// nolint:gocyclo,gocognit
-func waitForIPAddresses(
-	ctx *virtualMachineContext,
-	macToDeviceIndex map[string]int,
-	deviceToMacIndex map[int]string) (<-chan string, <-chan error) {
-	var (
-		chanErrs          = make(chan error)
-		chanIPAddresses   = make(chan string)
-		macToHasIPv4Lease = map[string]struct{}{}
-		macToHasIPv6Lease = map[string]struct{}{}
-		macToSkipped      = map[string]map[string]struct{}{}
-		macToHasStaticIP  = map[string]map[string]struct{}{}
-		propCollector     = property.DefaultCollector(ctx.Session.Client.Client)
-	)
-
-	// Initialize the nested maps early.
-	for mac := range macToDeviceIndex {
-		macToSkipped[mac] = map[string]struct{}{}
-		macToHasStaticIP[mac] = map[string]struct{}{}
+	if ctx.Session.Inventory != nil {
+		ctx.Session.Inventory.Invalidate(*ref)
 	}
+}
 
-	onPropertyChange := func(propertyChanges []types.PropertyChange) bool {
-		for _, propChange := range propertyChanges {
-			if propChange.Op != types.PropertyChangeOpAssign {
-				continue
-			}
-			nics := propChange.Val.(types.ArrayOfGuestNicInfo).GuestNicInfo
-			for _, nic := range nics {
-				mac := nic.MacAddress
-				if mac == "" || nic.IpConfig == nil {
-					continue
-				}
-				// Ignore any that don't correspond to a network
-				// device spec.
-				deviceSpecIndex, ok := macToDeviceIndex[mac]
-				if !ok {
-					chanErrs <- errors.Errorf("unknown device spec index for mac %s while waiting for ip addresses for vm %s", mac, ctx)
-					// Return true to stop the property collector from waiting
-					// on any more changes.
-					return true
-				}
-				if deviceSpecIndex < 0 || deviceSpecIndex >= len(ctx.VSphereVM.Spec.Network.Devices) {
-					chanErrs <- errors.Errorf("invalid device spec index %d for mac %s while waiting for ip addresses for vm %s", deviceSpecIndex, mac, ctx)
-					// Return true to stop the property collector from waiting
-					// on any more changes.
-					return true
-				}
-
-				// Get the network device spec that corresponds to the MAC.
-				deviceSpec := ctx.VSphereVM.Spec.Network.Devices[deviceSpecIndex]
-
-				// Look at each IP and determine whether a reconcile has
-				// been triggered for the IP.
-				for _, discoveredIPInfo := range nic.IpConfig.IpAddress {
-					discoveredIP := discoveredIPInfo.IpAddress
-
-					// Ignore link-local addresses.
-					if err := net.ErrOnLocalOnlyIPAddr(discoveredIP); err != nil {
-						if _, ok := macToSkipped[mac][discoveredIP]; !ok {
-							ctx.Logger.Info("ignoring IP address", "reason", err.Error())
-							macToSkipped[mac][discoveredIP] = struct{}{}
-						}
-						continue
-					}
-
-					// Check to see if the IP is in the list of the device
-					// spec's static IP addresses.
-					isStatic := false
-					for _, specIP := range deviceSpec.IPAddrs {
-						// The static IP assigned to the VM is required in the CIDR format
-						ip, _, _ := gonet.ParseCIDR(specIP)
-						if discoveredIP == ip.String() {
-							isStatic = true
-							break
-						}
-					}
-
-					// If it's a static IP then check to see if the IP has
-					// triggered a reconcile yet.
-					switch {
-					case isStatic:
-						if _, ok := macToHasStaticIP[mac][discoveredIP]; !ok {
-							// No reconcile yet. Record the IP send it to the
-							// channel.
-							ctx.Logger.Info(
-								"discovered IP address",
-								"addressType", "static",
-								"addressValue", discoveredIP)
-							macToHasStaticIP[mac][discoveredIP] = struct{}{}
-							chanIPAddresses <- discoveredIP
-						}
-					case gonet.ParseIP(discoveredIP).To4() != nil:
-						// An IPv4 address...
-						if deviceSpec.DHCP4 {
-							// Has an IPv4 lease been discovered yet?
-							if _, ok := macToHasIPv4Lease[mac]; !ok {
-								ctx.Logger.Info(
-									"discovered IP address",
-									"addressType", "dhcp4",
-									"addressValue", discoveredIP)
-								macToHasIPv4Lease[mac] = struct{}{}
-								chanIPAddresses <- discoveredIP
-							}
-						}
-					default:
-						// An IPv6 address..
-						if deviceSpec.DHCP6 {
-							// Has an IPv6 lease been discovered yet?
-							if _, ok := macToHasIPv6Lease[mac]; !ok {
-								ctx.Logger.Info(
-									"discovered IP address",
-									"addressType", "dhcp6",
-									"addressValue", discoveredIP)
-								macToHasIPv6Lease[mac] = struct{}{}
-								chanIPAddresses <- discoveredIP
-							}
-						}
-					}
-				}
-			}
-		}
-
-		// Determine whether or not the wait operation is over by whether
-		// or not the VM has all of the requested IP addresses.
-		for i, deviceSpec := range ctx.VSphereVM.Spec.Network.Devices {
-			mac, ok := deviceToMacIndex[i]
-			if !ok {
-				chanErrs <- errors.Errorf("invalid mac index %d waiting for ip addresses for vm %s", i, ctx)
+// enqueueVSphereVMFunc returns a function which triggers a reconcile of the
+// VSphereVM by sending a GenericEvent into the event channel for the
+// resource type. The event is sent in the background, as the watcher's
+// handlers must not block.
+func enqueueVSphereVMFunc(ctx *context.VMContext) func(loggerKeysAndValues ...interface{}) {
+	obj := ctx.VSphereVM.DeepCopy()
+	eventChannel := ctx.GetGenericEventChannelFor(obj.GetObjectKind().GroupVersionKind())
+	logger := ctx.Logger
+	done := ctx.Done()
 
-				// Return true to stop the property collector from waiting
-				// on any more changes.
-				return true
-			}
-			// If the device spec requires DHCP4 then the Wait is not
-			// over if there is no IPv4 lease.
-			if deviceSpec.DHCP4 {
-				if _, ok := macToHasIPv4Lease[mac]; !ok {
-					ctx.Logger.Info(
-						"the VM is missing the requested IP address",
-						"addressType", "dhcp4")
-					return false
-				}
-			}
-			// If the device spec requires DHCP6 then the Wait is not
-			// over if there is no IPv4 lease.
-			if deviceSpec.DHCP6 {
-				if _, ok := macToHasIPv6Lease[mac]; !ok {
-					ctx.Logger.Info(
-						"the VM is missing the requested IP address",
-						"addressType", "dhcp6")
-					return false
-				}
-			}
-			// If the device spec requires static IP addresses, the wait
-			// is not over if the device lacks one of those addresses.
-			for _, specIP := range deviceSpec.IPAddrs {
-				ip, _, _ := gonet.ParseCIDR(specIP)
-				if _, ok := macToHasStaticIP[mac][ip.String()]; !ok {
-					ctx.Logger.Info(
-						"the VM is missing the requested IP address",
-						"addressType", "static",
-						"addressValue", specIP)
-					return false
-				}
+	return func(loggerKeysAndValues ...interface{}) {
+		logger.Info("triggering GenericEvent", loggerKeysAndValues...)
+		go func() {
+			select {
+			case eventChannel <- event.GenericEvent{Object: obj}:
+			case <-done:
 			}
-		}
-
-		ctx.Logger.Info("the VM has all of the requested IP addresses")
-		return true
+		}()
 	}
-
-	// The wait function will not return true until all the VM's
-	// network devices have IP assignments that match the requested
-	// network device specs. However, every time a new IP is discovered,
-	// a reconcile request will be triggered for the VSphereVM.
-	go func() {
-		if err := property.Wait(
-			ctx, propCollector, ctx.Obj.Reference(),
-			[]string{"guest.net"}, onPropertyChange); err != nil {
-			chanErrs <- errors.Wrapf(err, "failed to wait for ip addresses for vm %s", ctx)
-		}
-		close(chanIPAddresses)
-		close(chanErrs)
-	}()
-
-	return chanIPAddresses, chanErrs
 }
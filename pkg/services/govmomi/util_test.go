@@ -23,13 +23,21 @@ import (
 
 	"github.com/go-logr/logr"
 	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
 )
 
 func Test_ShouldRetryTask(t *testing.T) {
@@ -65,7 +73,8 @@ func Test_ShouldRetryTask(t *testing.T) {
 		g := NewWithT(t)
 
 		vmCtx := &context.VMContext{
-			Logger: logr.Discard(),
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			Logger:            logr.Discard(),
 			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
 				TaskRef:    "task-123",
 				RetryAfter: metav1.Time{Time: time.Now().Add(-1 * time.Minute)},
@@ -112,7 +121,8 @@ func Test_ShouldRetryTask(t *testing.T) {
 	t.Run("when failed task was previously not checked", func(t *testing.T) {
 		g := NewWithT(t)
 		vmCtx := &context.VMContext{
-			Logger: logr.Discard(),
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			Logger:            logr.Discard(),
 			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
 				// RetryAfter is not set since this is the first reconcile
 				TaskRef: "task-123",
@@ -125,6 +135,89 @@ func Test_ShouldRetryTask(t *testing.T) {
 		g.Expect(reconciled).To(BeTrue())
 		g.Expect(conditions.IsFalse(vmCtx.VSphereVM, infrav1.VMProvisionedCondition))
 		g.Expect(vmCtx.VSphereVM.Status.RetryAfter.Unix()).To(BeNumerically("<=", metav1.Now().Add(1*time.Minute).Unix()))
+		g.Expect(vmCtx.VSphereVM.Status.TaskRetries).To(Equal(int32(1)))
+		g.Expect(vmCtx.VSphereVM.Status.FailureReason).To(BeNil())
+	})
+
+	t.Run("when failed task was previously retried", func(t *testing.T) {
+		g := NewWithT(t)
+		vmCtx := &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			Logger:            logr.Discard(),
+			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
+				TaskRef:     "task-123",
+				TaskRetries: 2,
+			}},
+		}
+		task := baseTask(types.TaskInfoStateError, "task is stuck")
+
+		reconciled, err := checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(reconciled).To(BeTrue())
+		g.Expect(vmCtx.VSphereVM.Status.TaskRetries).To(Equal(int32(3)))
+		g.Expect(vmCtx.VSphereVM.Status.RetryAfter.Time).To(BeTemporally("~", time.Now().Add(4*time.Minute), time.Minute))
+
+		task = baseTask(types.TaskInfoStateSuccess, "")
+		vmCtx.VSphereVM.Status.RetryAfter = metav1.Time{}
+		_, err = checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vmCtx.VSphereVM.Status.TaskRetries).To(BeZero())
+	})
+
+	t.Run("when task failed with a terminal fault", func(t *testing.T) {
+		g := NewWithT(t)
+		vmCtx := &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			Logger:            logr.Discard(),
+			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
+				TaskRef: "task-123",
+			}},
+		}
+		task := baseTask(types.TaskInfoStateError, "task failed")
+		task.Info.DescriptionId = "VirtualMachine.clone"
+		task.Info.Error = &types.LocalizedMethodFault{
+			Fault:            &types.NoDiskSpace{Datastore: "ds0"},
+			LocalizedMessage: "There is not enough space on the file system",
+		}
+
+		reconciled, err := checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(reconciled).To(BeTrue())
+		g.Expect(vmCtx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(vmCtx.VSphereVM.Status.RetryAfter.IsZero()).To(BeTrue())
+		g.Expect(*vmCtx.VSphereVM.Status.FailureReason).To(Equal(capierrors.CreateMachineError))
+		g.Expect(*vmCtx.VSphereVM.Status.FailureMessage).To(Equal("VirtualMachine.clone task failed with NoDiskSpace: There is not enough space on the file system"))
+		g.Expect(*conditions.GetSeverity(vmCtx.VSphereVM, infrav1.VMProvisionedCondition)).To(Equal(clusterv1.ConditionSeverityError))
+	})
+
+	t.Run("when task of a ready VSphereVM failed with a terminal fault", func(t *testing.T) {
+		g := NewWithT(t)
+		vmCtx := &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			Logger:            logr.Discard(),
+			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
+				Ready:   true,
+				TaskRef: "task-123",
+			}},
+		}
+		conditions.MarkFalse(vmCtx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizingReason, clusterv1.ConditionSeverityInfo, "")
+		task := baseTask(types.TaskInfoStateError, "task failed")
+		task.Info.DescriptionId = "VirtualMachine.reconfigure"
+		task.Info.Error = &types.LocalizedMethodFault{
+			Fault:            &types.NoDiskSpace{Datastore: "ds0"},
+			LocalizedMessage: "There is not enough space on the file system",
+		}
+
+		reconciled, err := checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(reconciled).To(BeTrue())
+		g.Expect(vmCtx.VSphereVM.Status.FailureReason).To(BeNil())
+		g.Expect(vmCtx.VSphereVM.Status.FailureMessage).To(BeNil())
+		g.Expect(vmCtx.VSphereVM.Status.TaskRetries).To(Equal(int32(1)))
+		g.Expect(vmCtx.VSphereVM.Status.RetryAfter.IsZero()).To(BeFalse())
+		g.Expect(conditions.GetReason(vmCtx.VSphereVM, infrav1.VMResizedCondition)).To(Equal(infrav1.ResizeFailedReason))
+		g.Expect(*conditions.GetSeverity(vmCtx.VSphereVM, infrav1.VMResizedCondition)).To(Equal(clusterv1.ConditionSeverityError))
+		g.Expect(conditions.GetMessage(vmCtx.VSphereVM, infrav1.VMResizedCondition)).To(Equal("VirtualMachine.reconfigure task failed with NoDiskSpace: There is not enough space on the file system"))
 	})
 }
 
@@ -148,3 +241,67 @@ func baseTask(state types.TaskInfoState, errorDescription string) mo.Task {
 	}
 	return t
 }
+
+//nolint:forcetypeassert
+func Test_WatchVSphereVM(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+	authSession, err := session.GetOrCreate(
+		vmContext.Context,
+		session.NewParams().
+			WithInsecure(true).
+			WithServer(vmContext.VSphereVM.Spec.Server).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	// The watcher blocks the simulator from shutting down while it waits for
+	// updates.
+	defer authSession.StopWatcher()
+	vmContext.Session = authSession
+
+	simVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       object.NewVirtualMachine(authSession.Client.Client, simVM.Reference()),
+		Ref:       simVM.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+	events := ctx.GetGenericEventChannelFor(ctx.VSphereVM.GetObjectKind().GroupVersionKind())
+
+	t.Run("triggers a reconcile once the task succeeds", func(t *testing.T) {
+		g := NewWithT(t)
+		task, err := ctx.Obj.PowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+
+		watchTask(&ctx.VMContext)
+
+		var e event.GenericEvent
+		g.Eventually(events).Should(Receive(&e))
+		g.Expect(e.Object.GetName()).To(Equal(ctx.VSphereVM.Name))
+	})
+
+	t.Run("triggers a reconcile when the power state changes", func(t *testing.T) {
+		g := NewWithT(t)
+		watchVM(ctx)
+		g.Consistently(events).ShouldNot(Receive())
+
+		task, err := ctx.Obj.PowerOn(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		var e event.GenericEvent
+		g.Eventually(events).Should(Receive(&e))
+		g.Expect(e.Object.GetName()).To(Equal(ctx.VSphereVM.Name))
+	})
+}
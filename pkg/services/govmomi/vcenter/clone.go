@@ -27,6 +27,8 @@ import (
 	pbmTypes "github.com/vmware/govmomi/pbm/types"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
@@ -40,8 +42,10 @@ const (
 )
 
 // Clone kicks off a clone operation on vCenter to create a new virtual machine.
+// Once the datastore of the VM is resolved, acquire is called with it, and
+// the VM is only cloned if it returns true.
 // nolint:gocognit,gocyclo
-func Clone(ctx *context.VMContext, bootstrapData []byte) error {
+func Clone(ctx *context.VMContext, bootstrapData []byte, format bootstrapv1.Format, acquire func(datastore types.ManagedObjectReference) bool) error {
 	ctx = &context.VMContext{
 		ControllerContext: ctx.ControllerContext,
 		VSphereVM:         ctx.VSphereVM,
@@ -51,18 +55,17 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	}
 	ctx.Logger.Info("starting clone process")
 
-	var extraConfig extra.Config
-	if len(bootstrapData) > 0 {
-		ctx.Logger.Info("applied bootstrap data to VM clone spec")
-		if err := extraConfig.SetCloudInitUserData(bootstrapData); err != nil {
-			return err
-		}
+	extraConfig, err := GetExtraConfig(ctx, bootstrapData, format)
+	if err != nil {
+		return err
 	}
-	if ctx.VSphereVM.Spec.CustomVMXKeys != nil {
-		ctx.Logger.Info("applied custom vmx keys o VM clone spec")
-		if err := extraConfig.SetCustomVMXKeys(ctx.VSphereVM.Spec.CustomVMXKeys); err != nil {
-			return err
-		}
+
+	item, err := template.FindLibraryItem(ctx, ctx.VSphereVM.Spec.Template)
+	if err != nil {
+		return err
+	}
+	if item != nil {
+		return Deploy(ctx, item, extraConfig, acquire)
 	}
 
 	tpl, err := template.FindTemplate(ctx, ctx.VSphereVM.Spec.Template)
@@ -71,10 +74,11 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	}
 
 	// If a linked clone is requested then a MoRef for a snapshot must be
-	// found with which to perform the linked clone.
+	// found with which to perform the linked clone. VMs with PCI devices are
+	// always full clones.
 	var snapshotRef *types.ManagedObjectReference
 	//nolint:nestif
-	if ctx.VSphereVM.Spec.CloneMode == "" || ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone {
+	if (ctx.VSphereVM.Spec.CloneMode == "" || ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone) && len(ctx.VSphereVM.Spec.PciDevices) == 0 {
 		ctx.Logger.Info("linked clone requested")
 		// If the name of a snapshot was not provided then find the template's
 		// current snapshot.
@@ -109,12 +113,12 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		diskMoveType = linkCloneDiskMoveType
 	}
 
-	folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	folder, err := ctx.Session.Inventory.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
 	if err != nil {
 		return errors.Wrapf(err, "unable to get folder for %q", ctx)
 	}
 
-	pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	pool, err := ctx.Session.Inventory.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
 	if err != nil {
 		return errors.Wrapf(err, "unable to get resource pool for %q", ctx)
 	}
@@ -129,45 +133,28 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 
 	// Only non-linked clones may expand the size of the template's disk.
 	if snapshotRef == nil {
-		diskSpecs, err := getDiskSpec(ctx, devices)
+		diskSpecs, err := GetDiskSpec(ctx, devices)
 		if err != nil {
 			return errors.Wrapf(err, "error getting disk spec for %q", ctx)
 		}
 		deviceSpecs = append(deviceSpecs, diskSpecs...)
 	}
 
-	networkSpecs, err := getNetworkSpecs(ctx, devices)
+	networkSpecs, err := GetNetworkSpecs(ctx, devices)
 	if err != nil {
 		return errors.Wrapf(err, "error getting network specs for %q", ctx)
 	}
 	deviceSpecs = append(deviceSpecs, networkSpecs...)
+	deviceSpecs = append(deviceSpecs, GetPCIDeviceSpecs(ctx)...)
 
-	numCPUs := ctx.VSphereVM.Spec.NumCPUs
-	if numCPUs < 2 {
-		numCPUs = 2
-	}
-	numCoresPerSocket := ctx.VSphereVM.Spec.NumCoresPerSocket
-	if numCoresPerSocket == 0 {
-		numCoresPerSocket = numCPUs
-	}
-	memMiB := ctx.VSphereVM.Spec.MemoryMiB
-	if memMiB == 0 {
-		memMiB = 2048
+	dataDiskSpecs, err := GetDataDiskSpecs(ctx, devices)
+	if err != nil {
+		return errors.Wrapf(err, "error getting data disk specs for %q", ctx)
 	}
+	deviceSpecs = append(deviceSpecs, dataDiskSpecs...)
 
 	spec := types.VirtualMachineCloneSpec{
-		Config: &types.VirtualMachineConfigSpec{
-			// Assign the clone's InstanceUUID the value of the Kubernetes Machine
-			// object's UID. This allows lookup of the cloned VM prior to knowing
-			// the VM's UUID.
-			InstanceUuid:      string(ctx.VSphereVM.UID),
-			Flags:             newVMFlagInfo(),
-			DeviceChange:      deviceSpecs,
-			ExtraConfig:       extraConfig,
-			NumCPUs:           numCPUs,
-			NumCoresPerSocket: numCoresPerSocket,
-			MemoryMB:          memMiB,
-		},
+		Config: NewConfigSpec(ctx, deviceSpecs, extraConfig),
 		Location: types.VirtualMachineRelocateSpec{
 			DiskMoveType: string(diskMoveType),
 			Folder:       types.NewReference(folder.Reference()),
@@ -183,7 +170,7 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 
 	var datastoreRef *types.ManagedObjectReference
 	if ctx.VSphereVM.Spec.Datastore != "" {
-		datastore, err := ctx.Session.Finder.Datastore(ctx, ctx.VSphereVM.Spec.Datastore)
+		datastore, err := ctx.Session.Inventory.DatastoreOrDefault(ctx, ctx.VSphereVM.Spec.Datastore)
 		if err != nil {
 			return errors.Wrapf(err, "unable to get datastore %s for %q", ctx.VSphereVM.Spec.Datastore, ctx)
 		}
@@ -192,6 +179,7 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	}
 
 	var storageProfileID string
+	var compatibleDatastores []types.ManagedObjectReference
 	//nolint:nestif
 	if ctx.VSphereVM.Spec.StoragePolicyName != "" {
 		pbmClient, err := pbm.NewClient(ctx, ctx.Session.Client.Client)
@@ -215,11 +203,14 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 			return fmt.Errorf("no compatible datastores found for storage policy: %s", ctx.VSphereVM.Spec.StoragePolicyName)
 		}
 
+		for _, ds := range result.CompatibleDatastores() {
+			compatibleDatastores = append(compatibleDatastores, types.ManagedObjectReference{Type: ds.HubType, Value: ds.HubId})
+		}
+
 		if datastoreRef != nil {
 			ctx.Logger.Info("datastore and storagepolicy defined; searching for datastore in storage policy compatible datastores")
 			found := false
-			for _, ds := range result.CompatibleDatastores() {
-				compatibleRef := types.ManagedObjectReference{Type: ds.HubType, Value: ds.HubId}
+			for _, compatibleRef := range compatibleDatastores {
 				if compatibleRef.String() == datastoreRef.String() {
 					found = true
 				}
@@ -227,22 +218,59 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 			if !found {
 				return fmt.Errorf("couldn't find specified datastore: %s in compatible list of datastores for storage policy", ctx.VSphereVM.Spec.Datastore)
 			}
-		} else {
-			rand.Seed(time.Now().UnixNano())
-			ds := result.CompatibleDatastores()[rand.Intn(len(result.CompatibleDatastores()))] //nolint:gosec
-			datastoreRef = &types.ManagedObjectReference{Type: ds.HubType, Value: ds.HubId}
+		}
+	}
+
+	recommendedPlacement := ctx.VSphereVM.Spec.PlacementPolicy == infrav1.RecommendedPlacementPolicy
+	switch {
+	case datastoreRef != nil:
+	case ctx.VSphereVM.Spec.DatastoreCluster != "":
+		datastoreRef, err = recommendDatastore(ctx, tpl, folder, &spec)
+		if err != nil {
+			return err
+		}
+		spec.Location.Datastore = datastoreRef
+	case len(compatibleDatastores) > 0 && !recommendedPlacement:
+		rand.Seed(time.Now().UnixNano())
+		ds := compatibleDatastores[rand.Intn(len(compatibleDatastores))] //nolint:gosec
+		datastoreRef = &ds
+	}
+
+	var hostRef *types.ManagedObjectReference
+	if recommendedPlacement {
+		// The datastore is only chosen by DRS if it was not chosen yet.
+		candidates := compatibleDatastores
+		if datastoreRef != nil {
+			candidates = []types.ManagedObjectReference{*datastoreRef}
+		}
+		var recommendedDatastoreRef *types.ManagedObjectReference
+		hostRef, recommendedDatastoreRef, err = recommendPlacement(ctx, tpl, pool, &spec, candidates)
+		if err != nil {
+			return err
+		}
+		spec.Location.Host = hostRef
+		if datastoreRef == nil && recommendedDatastoreRef != nil {
+			datastoreRef = recommendedDatastoreRef
+			spec.Location.Datastore = datastoreRef
 		}
 	}
 
 	if datastoreRef == nil {
 		// if no datastore defined through VM spec or storage policy, use default
-		datastore, err := ctx.Session.Finder.DefaultDatastore(ctx)
+		datastore, err := ctx.Session.Inventory.DatastoreOrDefault(ctx, "")
 		if err != nil {
 			return errors.Wrapf(err, "unable to get default datastore for %q", ctx)
 		}
 		datastoreRef = types.NewReference(datastore.Reference())
 	}
 
+	if !acquire(*datastoreRef) {
+		return nil
+	}
+	if err := setPlacementStatus(ctx, hostRef, datastoreRef); err != nil {
+		return err
+	}
+
 	disks := devices.SelectByType((*types.VirtualDisk)(nil))
 	spec.Location.Disk = getDiskLocators(disks, *datastoreRef)
 
@@ -263,6 +291,85 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	return nil
 }
 
+// GetExtraConfig returns the guestinfo extra config for a new VM, including
+// the bootstrap data in the given format and any custom VMX keys. Cloud-init
+// user data which would exceed the guestinfo size limit is left out, it is
+// delivered on a NoCloud ISO together with the metadata once the VM exists.
+func GetExtraConfig(ctx *context.VMContext, bootstrapData []byte, format bootstrapv1.Format) (extra.Config, error) {
+	var extraConfig extra.Config
+	if len(bootstrapData) > 0 {
+		ctx.Logger.Info("applied bootstrap data to VM clone spec", "format", format)
+		switch format {
+		case bootstrapv1.Ignition:
+			if err := extraConfig.SetIgnitionUserData(bootstrapData); err != nil {
+				return nil, err
+			}
+		default:
+			if err := extraConfig.SetCloudInitUserData(bootstrapData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ctx.VSphereVM.Spec.CustomVMXKeys != nil {
+		ctx.Logger.Info("applied custom vmx keys o VM clone spec")
+		if err := extraConfig.SetCustomVMXKeys(ctx.VSphereVM.Spec.CustomVMXKeys); err != nil {
+			return nil, err
+		}
+	}
+	err := extraConfig.ValidateGuestInfoSize(nil)
+	if errors.Is(err, extra.ErrGuestInfoTooLarge) && len(bootstrapData) > 0 && format != bootstrapv1.Ignition {
+		ctx.Logger.Info("bootstrap data exceeds the guestinfo size limit, delivering it on a NoCloud ISO")
+		extraConfig = nil
+		extraConfig.SetCloudInitISO(extra.CloudInitISOPending)
+		if err := extraConfig.SetCustomVMXKeys(ctx.VSphereVM.Spec.CustomVMXKeys); err != nil {
+			return nil, err
+		}
+		err = extraConfig.ValidateGuestInfoSize(nil)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "bootstrap data is too large")
+	}
+	return extraConfig, nil
+}
+
+// NewConfigSpec returns the config spec for a new VM with the CPU and memory
+// settings of the VSphereVM, the given device changes and extra config.
+func NewConfigSpec(ctx *context.VMContext, deviceSpecs []types.BaseVirtualDeviceConfigSpec, extraConfig extra.Config) *types.VirtualMachineConfigSpec {
+	numCPUs := ctx.VSphereVM.Spec.NumCPUs
+	if numCPUs < 2 {
+		numCPUs = 2
+	}
+	numCoresPerSocket := ctx.VSphereVM.Spec.NumCoresPerSocket
+	if numCoresPerSocket == 0 {
+		numCoresPerSocket = numCPUs
+	}
+	memMiB := ctx.VSphereVM.Spec.MemoryMiB
+	if memMiB == 0 {
+		memMiB = 2048
+	}
+
+	spec := &types.VirtualMachineConfigSpec{
+		// Assign the clone's InstanceUUID the value of the Kubernetes Machine
+		// object's UID. This allows lookup of the cloned VM prior to knowing
+		// the VM's UUID.
+		InstanceUuid:      string(ctx.VSphereVM.UID),
+		Flags:             newVMFlagInfo(),
+		DeviceChange:      deviceSpecs,
+		ExtraConfig:       extraConfig,
+		NumCPUs:           numCPUs,
+		NumCoresPerSocket: numCoresPerSocket,
+		MemoryMB:          memMiB,
+	}
+
+	// PCI passthrough devices, including vGPUs, can only be powered on when
+	// all of the VM's memory is reserved.
+	if len(ctx.VSphereVM.Spec.PciDevices) > 0 {
+		spec.MemoryReservationLockedToMax = pointer.Bool(true)
+	}
+
+	return spec
+}
+
 func newVMFlagInfo() *types.VirtualMachineFlagInfo {
 	diskUUIDEnabled := true
 	return &types.VirtualMachineFlagInfo{
@@ -288,7 +395,9 @@ func getDiskLocators(disks object.VirtualDeviceList, datastoreRef types.ManagedO
 	return diskLocators
 }
 
-func getDiskSpec(ctx *context.VMContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
+// GetDiskSpec returns the device changes that resize the template's disks to
+// the sizes requested by the VSphereVM.
+func GetDiskSpec(ctx *context.VMContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
 	disks := devices.SelectByType((*types.VirtualDisk)(nil))
 	if len(disks) == 0 {
 		return nil, errors.Errorf("Invalid disk count: %d", len(disks))
@@ -305,7 +414,8 @@ func getDiskSpec(ctx *context.VMContext, devices object.VirtualDeviceList) ([]ty
 	diskSpecs = append(diskSpecs, primaryDiskConfigSpec)
 
 	// Check for additional disks
-	// CAPV will not spin up additional extra disks provided in the conf but not available in the template
+	// Additional disks provided in the conf but not available in the template
+	// are created by GetDataDiskSpecs.
 	if len(disks) > 1 {
 		// Disk range starts from 1 to avoid primary disk
 		for i, disk := range disks[1:] {
@@ -340,9 +450,238 @@ func getDiskConfigSpec(disk *types.VirtualDisk, diskCloneCapacityKB int64) (type
 	}, nil
 }
 
+// GetDataDiskSpecs returns the device changes that create the disks requested
+// by the VSphereVM which do not exist in the given devices: the entries of
+// AdditionalDisksGiB beyond the existing disks and the DataDisks. The unit
+// numbers of the DataDisks are recorded in the VSphereVM's status.
+func GetDataDiskSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	deviceSpecs, unitNumbers, err := getNewDiskSpecs(ctx, devices, len(disks), ctx.VSphereVM.Spec.DataDisks)
+	if err != nil {
+		return nil, err
+	}
+	ctx.VSphereVM.Status.DataDiskUnitNumbers = unitNumbers
+	return deviceSpecs, nil
+}
+
+// GetAdditionalDiskSpecs returns the device changes that create the entries
+// of AdditionalDisksGiB beyond the given number of existing disks, which are
+// the disks of the VM without its data disks. The devices are all the devices
+// of the VM.
+func GetAdditionalDiskSpecs(ctx *context.VMContext, devices object.VirtualDeviceList, existing int) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	deviceSpecs, _, err := getNewDiskSpecs(ctx, devices, existing, nil)
+	return deviceSpecs, err
+}
+
+// getNewDiskSpecs returns the device changes that create the entries of
+// AdditionalDisksGiB beyond the given number of existing disks, followed by
+// the given data disks, and the unit numbers of the data disks.
+func getNewDiskSpecs(ctx *context.VMContext, devices object.VirtualDeviceList, existing int, dataDiskSpecs []infrav1.DataDiskSpec) ([]types.BaseVirtualDeviceConfigSpec, []int32, error) {
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+
+	var dataDisks []infrav1.DataDiskSpec
+	if existing > 0 && len(ctx.VSphereVM.Spec.AdditionalDisksGiB) > existing-1 {
+		for _, sizeGiB := range ctx.VSphereVM.Spec.AdditionalDisksGiB[existing-1:] {
+			dataDisks = append(dataDisks, infrav1.DataDiskSpec{SizeGiB: sizeGiB})
+		}
+	}
+	additional := len(dataDisks)
+	dataDisks = append(dataDisks, dataDiskSpecs...)
+	if len(dataDisks) == 0 {
+		return nil, nil, nil
+	}
+
+	var primaryControllerKey *int32
+	if len(disks) > 0 {
+		primaryControllerKey = &disks[0].GetVirtualDevice().ControllerKey
+	}
+
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+	var unitNumbers []int32
+	// New devices may be appended to the list, so copy it to not modify the
+	// caller's list.
+	devices = append(object.VirtualDeviceList{}, devices...)
+	key := int32(-400)
+	for i, dataDisk := range dataDisks {
+		var controllerKey int32
+		switch {
+		case dataDisk.ControllerBusNumber == nil && primaryControllerKey == nil:
+			return nil, nil, errors.Errorf("unable to attach data disk %d, there is no primary disk", i)
+		case dataDisk.ControllerBusNumber == nil:
+			controllerKey = *primaryControllerKey
+		default:
+			controller := findSCSIController(devices, *dataDisk.ControllerBusNumber)
+			if controller == nil {
+				controller = &types.ParaVirtualSCSIController{
+					VirtualSCSIController: types.VirtualSCSIController{
+						VirtualController: types.VirtualController{
+							VirtualDevice: types.VirtualDevice{Key: key},
+							BusNumber:     *dataDisk.ControllerBusNumber,
+						},
+						SharedBus: types.VirtualSCSISharingNoSharing,
+					},
+				}
+				key--
+				devices = append(devices, controller)
+				deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+					Operation: types.VirtualDeviceConfigSpecOperationAdd,
+					Device:    controller,
+				})
+			}
+			controllerKey = controller.GetVirtualDevice().Key
+		}
+
+		unitNumber, err := freeUnitNumber(devices, controllerKey)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to attach data disk %d", i)
+		}
+
+		backing := &types.VirtualDiskFlatVer2BackingInfo{
+			DiskMode: string(types.VirtualDiskModePersistent),
+		}
+		switch dataDisk.ProvisioningMode {
+		case infrav1.ThickProvisioningMode:
+			backing.ThinProvisioned = types.NewBool(false)
+		case infrav1.EagerlyZeroedProvisioningMode:
+			backing.ThinProvisioned = types.NewBool(false)
+			backing.EagerlyScrub = types.NewBool(true)
+		default:
+			backing.ThinProvisioned = types.NewBool(true)
+		}
+		if dataDisk.Datastore != "" {
+			datastore, err := ctx.Session.Inventory.DatastoreOrDefault(ctx, dataDisk.Datastore)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to get datastore %s for data disk %d", dataDisk.Datastore, i)
+			}
+			backing.FileName = fmt.Sprintf("[%s]", datastore.Name())
+			backing.Datastore = types.NewReference(datastore.Reference())
+		}
+
+		disk := &types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{
+				Key:           key,
+				ControllerKey: controllerKey,
+				UnitNumber:    &unitNumber,
+				Backing:       backing,
+			},
+			CapacityInKB: int64(dataDisk.SizeGiB) * 1024 * 1024,
+		}
+		key--
+		devices = append(devices, disk)
+
+		diskSpec := &types.VirtualDeviceConfigSpec{
+			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+			Device:        disk,
+		}
+		if dataDisk.StoragePolicyName != "" && !ctx.Session.IsVC() {
+			ctx.Logger.Info("storage policies are not supported on ESXi, ignoring", "storagePolicyName", dataDisk.StoragePolicyName)
+		} else if dataDisk.StoragePolicyName != "" {
+			storageProfileID, err := getStorageProfileID(ctx, dataDisk.StoragePolicyName)
+			if err != nil {
+				return nil, nil, err
+			}
+			diskSpec.Profile = []types.BaseVirtualMachineProfileSpec{
+				&types.VirtualMachineDefinedProfileSpec{ProfileId: storageProfileID},
+			}
+		}
+		deviceSpecs = append(deviceSpecs, diskSpec)
+		if i >= additional {
+			unitNumbers = append(unitNumbers, unitNumber)
+		}
+		ctx.Logger.V(4).Info("created data disk", "data-disk-spec", dataDisk)
+	}
+
+	return deviceSpecs, unitNumbers, nil
+}
+
+// SelectDataDisks returns the data disks among the devices of the VM, which
+// are found by the unit numbers recorded in the VSphereVM's status. It returns
+// false if they are not known.
+func SelectDataDisks(ctx *context.VMContext, devices object.VirtualDeviceList) (object.VirtualDeviceList, bool) {
+	dataDisks := ctx.VSphereVM.Spec.DataDisks
+	if len(dataDisks) == 0 {
+		return nil, true
+	}
+	unitNumbers := ctx.VSphereVM.Status.DataDiskUnitNumbers
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(unitNumbers) != len(dataDisks) || len(disks) == 0 {
+		return nil, false
+	}
+
+	var selected object.VirtualDeviceList
+	for i, dataDisk := range dataDisks {
+		// The data disks without a controller bus number are attached to the
+		// controller of the primary disk.
+		controllerKey := disks[0].GetVirtualDevice().ControllerKey
+		if dataDisk.ControllerBusNumber != nil {
+			controller := findSCSIController(devices, *dataDisk.ControllerBusNumber)
+			if controller == nil {
+				return nil, false
+			}
+			controllerKey = controller.GetVirtualDevice().Key
+		}
+		for _, disk := range disks {
+			d := disk.GetVirtualDevice()
+			if d.ControllerKey == controllerKey && d.UnitNumber != nil && *d.UnitNumber == unitNumbers[i] {
+				selected = append(selected, disk)
+			}
+		}
+	}
+	return selected, true
+}
+
+func findSCSIController(devices object.VirtualDeviceList, busNumber int32) types.BaseVirtualDevice {
+	for _, device := range devices {
+		if controller, ok := device.(types.BaseVirtualSCSIController); ok && controller.GetVirtualSCSIController().BusNumber == busNumber {
+			return device
+		}
+	}
+	return nil
+}
+
+// freeUnitNumber returns the lowest unit number of the controller which is
+// not used by any of the devices.
+func freeUnitNumber(devices object.VirtualDeviceList, controllerKey int32) (int32, error) {
+	used := map[int32]bool{}
+	for _, device := range devices {
+		d := device.GetVirtualDevice()
+		if d.ControllerKey == controllerKey && d.UnitNumber != nil {
+			used[*d.UnitNumber] = true
+		}
+	}
+
+	maxUnitNumber := int32(2)
+	if _, ok := devices.FindByKey(controllerKey).(types.BaseVirtualSCSIController); ok {
+		// The SCSI controller itself uses unit number 7.
+		used[7] = true
+		maxUnitNumber = 16
+	}
+	for unitNumber := int32(0); unitNumber < maxUnitNumber; unitNumber++ {
+		if !used[unitNumber] {
+			return unitNumber, nil
+		}
+	}
+	return 0, errors.Errorf("no free unit number on controller %d", controllerKey)
+}
+
+func getStorageProfileID(ctx *context.VMContext, storagePolicyName string) (string, error) {
+	pbmClient, err := pbm.NewClient(ctx, ctx.Session.Client.Client)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create pbm client for %q", ctx)
+	}
+	storageProfileID, err := pbmClient.ProfileIDByName(ctx, storagePolicyName)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get storageProfileID from name %s for %q", storagePolicyName, ctx)
+	}
+	return storageProfileID, nil
+}
+
 const ethCardType = "vmxnet3"
 
-func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
+// GetNetworkSpecs returns the device changes that replace the template's NICs
+// with the network devices requested by the VSphereVM.
+func GetNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
 	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
 
 	// Remove any existing NICs
@@ -357,7 +696,7 @@ func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) (
 	key := int32(-100)
 	for i := range ctx.VSphereVM.Spec.Network.Devices {
 		netSpec := &ctx.VSphereVM.Spec.Network.Devices[i]
-		ref, err := ctx.Session.Finder.Network(ctx, netSpec.NetworkName)
+		ref, err := ctx.Session.Inventory.Network(ctx, netSpec.NetworkName)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to find network %q", netSpec.NetworkName)
 		}
@@ -397,3 +736,42 @@ func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) (
 
 	return deviceSpecs, nil
 }
+
+// GetPCIDeviceSpecs returns the device changes that add the PCI devices
+// requested by the VSphereVM.
+func GetPCIDeviceSpecs(ctx *context.VMContext) []types.BaseVirtualDeviceConfigSpec {
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+
+	key := int32(-300)
+	for _, pciDevice := range ctx.VSphereVM.Spec.PciDevices {
+		var backing types.BaseVirtualDeviceBackingInfo
+		if pciDevice.VGPUProfile != "" {
+			backing = &types.VirtualPCIPassthroughVmiopBackingInfo{
+				Vgpu: pciDevice.VGPUProfile,
+			}
+		} else {
+			backing = &types.VirtualPCIPassthroughDynamicBackingInfo{
+				AllowedDevice: []types.VirtualPCIPassthroughAllowedDevice{
+					{
+						VendorId: *pciDevice.VendorID,
+						DeviceId: *pciDevice.DeviceID,
+					},
+				},
+			}
+		}
+
+		deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+			Device: &types.VirtualPCIPassthrough{
+				VirtualDevice: types.VirtualDevice{
+					Key:     key,
+					Backing: backing,
+				},
+			},
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+		})
+		ctx.Logger.V(4).Info("created pci device", "pci-device-spec", pciDevice)
+		key--
+	}
+
+	return deviceSpecs
+}
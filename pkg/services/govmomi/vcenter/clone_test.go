@@ -18,18 +18,25 @@ package vcenter
 
 import (
 	ctx "context"
+	"crypto/rand"
 	"crypto/tls"
+	"reflect"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
 
 	// run init func to register the tagging API endpoints.
 	_ "github.com/vmware/govmomi/vapi/simulator"
 	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
@@ -117,14 +124,14 @@ func TestGetDiskSpec(t *testing.T) {
 				},
 			}
 			vmContext := &context.VMContext{VSphereVM: vsphereVM}
-			devices, err := getDiskSpec(vmContext, tc.disks)
+			devices, err := GetDiskSpec(vmContext, tc.disks)
 			switch {
 			case tc.err != "" && err == nil:
 				fallthrough
 			case tc.err == "" && err != nil:
 				fallthrough
 			case err != nil && tc.err != err.Error():
-				t.Fatalf("Expected to get '%v' error from getDiskSpec, got: '%v'", tc.err, err)
+				t.Fatalf("Expected to get '%v' error from GetDiskSpec, got: '%v'", tc.err, err)
 			}
 			if deviceFound := len(devices) != 0; tc.expectDevice != deviceFound {
 				t.Fatalf("Expected to get a device: %v, but got: '%#v'", tc.expectDevice, devices)
@@ -154,6 +161,181 @@ func validateDiskSpec(t *testing.T, device types.BaseVirtualDeviceConfigSpec, cl
 	}
 }
 
+func TestGetPCIDeviceSpecs(t *testing.T) {
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.PciDevices = []v1beta1.PCIDeviceSpec{
+		{DeviceID: pointer.Int32(0x1EB8), VendorID: pointer.Int32(0x10DE)},
+		{VGPUProfile: "grid_t4-4q"},
+	}
+
+	devices := GetPCIDeviceSpecs(vmContext)
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 PCI devices, got: %d", len(devices))
+	}
+	for _, device := range devices {
+		if device.GetVirtualDeviceConfigSpec().Operation != types.VirtualDeviceConfigSpecOperationAdd {
+			t.Errorf("PCI device operation does not match '%s', got: %s",
+				types.VirtualDeviceConfigSpecOperationAdd, device.GetVirtualDeviceConfigSpec().Operation)
+		}
+	}
+
+	pci := devices[0].GetVirtualDeviceConfigSpec().Device.(*types.VirtualPCIPassthrough) //nolint:forcetypeassert
+	backing, ok := pci.Backing.(*types.VirtualPCIPassthroughDynamicBackingInfo)
+	if !ok {
+		t.Fatalf("Expected dynamic backing for PCI device, got: %T", pci.Backing)
+	}
+	if allowed := backing.AllowedDevice[0]; allowed.DeviceId != 0x1EB8 || allowed.VendorId != 0x10DE {
+		t.Errorf("PCI device IDs do not match, got: %#v", allowed)
+	}
+
+	vgpu := devices[1].GetVirtualDeviceConfigSpec().Device.(*types.VirtualPCIPassthrough) //nolint:forcetypeassert
+	vgpuBacking, ok := vgpu.Backing.(*types.VirtualPCIPassthroughVmiopBackingInfo)
+	if !ok {
+		t.Fatalf("Expected vmiop backing for vGPU device, got: %T", vgpu.Backing)
+	}
+	if vgpuBacking.Vgpu != "grid_t4-4q" {
+		t.Errorf("vGPU profile does not match: expected grid_t4-4q, got %s", vgpuBacking.Vgpu)
+	}
+
+	spec := NewConfigSpec(vmContext, devices, nil)
+	if spec.MemoryReservationLockedToMax == nil || !*spec.MemoryReservationLockedToMax {
+		t.Errorf("Expected memory reservation to be locked to max for VMs with PCI devices")
+	}
+}
+
+func TestGetDataDiskSpecs(t *testing.T) {
+	scsiController := &types.ParaVirtualSCSIController{
+		VirtualSCSIController: types.VirtualSCSIController{
+			VirtualController: types.VirtualController{
+				VirtualDevice: types.VirtualDevice{Key: 1000},
+				BusNumber:     0,
+			},
+		},
+	}
+	primaryDisk := &types.VirtualDisk{
+		VirtualDevice: types.VirtualDevice{
+			Key:           2000,
+			ControllerKey: 1000,
+			UnitNumber:    pointer.Int32(0),
+		},
+		CapacityInKB: 20 * 1024 * 1024,
+	}
+	devices := object.VirtualDeviceList{scsiController, primaryDisk}
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.AdditionalDisksGiB = []int32{10}
+	vmContext.VSphereVM.Spec.DataDisks = []v1beta1.DataDiskSpec{
+		{SizeGiB: 30, ProvisioningMode: v1beta1.EagerlyZeroedProvisioningMode},
+		{SizeGiB: 40, ControllerBusNumber: pointer.Int32(1)},
+	}
+
+	deviceSpecs, err := GetDataDiskSpecs(vmContext, devices)
+	if err != nil {
+		t.Fatalf("Failed to get data disk specs: %v", err)
+	}
+	// Three disks and a new controller for the disk on bus 1.
+	if len(deviceSpecs) != 4 {
+		t.Fatalf("Expected 4 device specs, got: %d", len(deviceSpecs))
+	}
+	if len(devices) != 2 {
+		t.Errorf("Expected the devices to not be modified, got: %d devices", len(devices))
+	}
+
+	testCases := []struct {
+		spec          types.BaseVirtualDeviceConfigSpec
+		sizeGiB       int64
+		controllerKey int32
+		unitNumber    int32
+		thin          bool
+		eagerlyScrub  bool
+	}{
+		{spec: deviceSpecs[0], sizeGiB: 10, controllerKey: 1000, unitNumber: 1, thin: true},
+		{spec: deviceSpecs[1], sizeGiB: 30, controllerKey: 1000, unitNumber: 2, eagerlyScrub: true},
+		{spec: deviceSpecs[3], sizeGiB: 40, controllerKey: deviceSpecs[2].GetVirtualDeviceConfigSpec().Device.GetVirtualDevice().Key, unitNumber: 0, thin: true},
+	}
+	for i, tc := range testCases {
+		spec := tc.spec.GetVirtualDeviceConfigSpec()
+		if spec.Operation != types.VirtualDeviceConfigSpecOperationAdd || spec.FileOperation != types.VirtualDeviceConfigSpecFileOperationCreate {
+			t.Errorf("Data disk %d operations do not match, got: %s %s", i, spec.Operation, spec.FileOperation)
+		}
+		disk := spec.Device.(*types.VirtualDisk) //nolint:forcetypeassert
+		if disk.CapacityInKB != tc.sizeGiB*1024*1024 {
+			t.Errorf("Data disk %d size does not match: expected %d, got %d", i, tc.sizeGiB*1024*1024, disk.CapacityInKB)
+		}
+		if disk.ControllerKey != tc.controllerKey || *disk.UnitNumber != tc.unitNumber {
+			t.Errorf("Data disk %d placement does not match: expected %d:%d, got %d:%d", i, tc.controllerKey, tc.unitNumber, disk.ControllerKey, *disk.UnitNumber)
+		}
+		backing := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo) //nolint:forcetypeassert
+		if *backing.ThinProvisioned != tc.thin || (backing.EagerlyScrub != nil && *backing.EagerlyScrub) != tc.eagerlyScrub {
+			t.Errorf("Data disk %d provisioning does not match, got: thin %v, eagerly scrub %v", i, *backing.ThinProvisioned, backing.EagerlyScrub)
+		}
+	}
+
+	controller, ok := deviceSpecs[2].GetVirtualDeviceConfigSpec().Device.(*types.ParaVirtualSCSIController)
+	if !ok || controller.BusNumber != 1 {
+		t.Errorf("Expected a new SCSI controller on bus 1, got: %#v", deviceSpecs[2].GetVirtualDeviceConfigSpec().Device)
+	}
+	if unitNumbers := vmContext.VSphereVM.Status.DataDiskUnitNumbers; !reflect.DeepEqual(unitNumbers, []int32{2, 0}) {
+		t.Errorf("Expected the unit numbers of the data disks to be recorded, got: %v", unitNumbers)
+	}
+}
+
+func TestSelectDataDisks(t *testing.T) {
+	disk := func(key, controllerKey, unitNumber int32) *types.VirtualDisk {
+		return &types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{
+				Key:           key,
+				ControllerKey: controllerKey,
+				UnitNumber:    pointer.Int32(unitNumber),
+			},
+		}
+	}
+	controller := func(key, busNumber int32) *types.ParaVirtualSCSIController {
+		return &types.ParaVirtualSCSIController{
+			VirtualSCSIController: types.VirtualSCSIController{
+				VirtualController: types.VirtualController{
+					VirtualDevice: types.VirtualDevice{Key: key},
+					BusNumber:     busNumber,
+				},
+			},
+		}
+	}
+	// The data disk on bus 1 is listed before the additional disk, which is
+	// attached after a gap in the unit numbers of the primary controller.
+	devices := object.VirtualDeviceList{
+		controller(1000, 0),
+		controller(1001, 1),
+		disk(2000, 1000, 0),
+		disk(2001, 1001, 0),
+		disk(2002, 1000, 1),
+		disk(2003, 1000, 3),
+	}
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.AdditionalDisksGiB = []int32{10}
+	vmContext.VSphereVM.Spec.DataDisks = []v1beta1.DataDiskSpec{
+		{SizeGiB: 30},
+		{SizeGiB: 40, ControllerBusNumber: pointer.Int32(1)},
+	}
+
+	if _, ok := SelectDataDisks(vmContext, devices); ok {
+		t.Errorf("Expected the data disks to not be known without their unit numbers")
+	}
+
+	vmContext.VSphereVM.Status.DataDiskUnitNumbers = []int32{1, 0}
+	dataDisks, ok := SelectDataDisks(vmContext, devices)
+	if !ok {
+		t.Fatalf("Expected the data disks to be known")
+	}
+	var keys []int32
+	for _, dataDisk := range dataDisks {
+		keys = append(keys, dataDisk.GetVirtualDevice().Key)
+	}
+	if !reflect.DeepEqual(keys, []int32{2002, 2001}) {
+		t.Errorf("Expected the data disks 2002 and 2001, got: %v", keys)
+	}
+}
+
 func initSimulator(t *testing.T) (*simulator.Model, *session.Session, *simulator.Server) {
 	t.Helper()
 
@@ -171,6 +353,7 @@ func initSimulator(t *testing.T) (*simulator.Model, *session.Session, *simulator
 	authSession, err := session.GetOrCreate(
 		ctx.TODO(),
 		session.NewParams().
+			WithInsecure(true).
 			WithServer(server.URL.Host).
 			WithUserInfo(server.URL.User.Username(), pass).
 			WithDatacenter("*"))
@@ -180,3 +363,41 @@ func initSimulator(t *testing.T) (*simulator.Model, *session.Session, *simulator
 
 	return model, authSession, server
 }
+
+func TestGetExtraConfig(t *testing.T) {
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.CustomVMXKeys = map[string]string{"svga.present": "TRUE"}
+	values := func(extraConfig extra.Config) map[string]string {
+		values := map[string]string{}
+		for _, ec := range extraConfig {
+			optVal := ec.GetOptionValue()
+			values[optVal.Key], _ = optVal.Value.(string)
+		}
+		return values
+	}
+
+	extraConfig, err := GetExtraConfig(vmContext, []byte("#cloud-config\n"), bootstrapv1.CloudConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values(extraConfig); v["guestinfo.userdata"] == "" || v[extra.CloudInitISOKey] != "" || v["svga.present"] != "TRUE" {
+		t.Errorf("Expected the user data in the guestinfo, got: %v", v)
+	}
+
+	// Random data does not compress, its encoding exceeds the size limit.
+	bootstrapData := make([]byte, extra.MaxGuestInfoSize)
+	if _, err := rand.Read(bootstrapData); err != nil {
+		t.Fatal(err)
+	}
+	extraConfig, err = GetExtraConfig(vmContext, bootstrapData, bootstrapv1.CloudConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values(extraConfig); v["guestinfo.userdata"] != "" || v[extra.CloudInitISOKey] != extra.CloudInitISOPending || v["svga.present"] != "TRUE" {
+		t.Errorf("Expected the user data to be left for the NoCloud ISO, got: %v", v)
+	}
+
+	if _, err := GetExtraConfig(vmContext, bootstrapData, bootstrapv1.Ignition); !errors.Is(err, extra.ErrGuestInfoTooLarge) {
+		t.Errorf("Expected the Ignition config to exceed the guestinfo size limit, got: %v", err)
+	}
+}
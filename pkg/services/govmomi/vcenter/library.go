@@ -0,0 +1,287 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	goctx "context"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	vapivcenter "github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+)
+
+// Deploy deploys a new virtual machine from a content library item and kicks
+// off a reconfigure operation which applies the VSphereVM's hardware, network
+// and bootstrap data to it.
+//
+// Both OVF and VM template items are supported. Deployed virtual machines are
+// always full clones. The item is deployed in the background, the VM is
+// reconfigured by the first call after the deployment completed. Each call
+// only proceeds if acquire returns true for the datastore of the VM.
+func Deploy(ctx *context.VMContext, item *library.Item, extraConfig extra.Config, acquire func(datastore types.ManagedObjectReference) bool) error {
+	ctx.Logger.Info("deploying from content library", "libraryItem", item.Name, "libraryItemID", item.ID, "type", item.Type)
+
+	if ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone {
+		ctx.Logger.Info("linked clone is not supported for content library items, falling back to full clone")
+	}
+	ctx.VSphereVM.Status.CloneMode = infrav1.FullClone
+
+	folder, err := ctx.Session.Inventory.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get folder for %q", ctx)
+	}
+
+	pool, err := ctx.Session.Inventory.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get resource pool for %q", ctx)
+	}
+
+	datastore, err := ctx.Session.Inventory.DatastoreOrDefault(ctx, ctx.VSphereVM.Spec.Datastore)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datastore for %q", ctx)
+	}
+	if !acquire(datastore.Reference()) {
+		return nil
+	}
+
+	var storageProfileID string
+	if ctx.VSphereVM.Spec.StoragePolicyName != "" {
+		storageProfileID, err = getStorageProfileID(ctx, ctx.VSphereVM.Spec.StoragePolicyName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The deployed VM is only identified by its instance UUID once it is
+	// reconfigured, so a VM deployed by a previous, interrupted attempt is
+	// recognized by its annotation.
+	annotation := fmt.Sprintf("Deployed from content library item %s for VSphereVM %s/%s (%s)",
+		item.ID, ctx.VSphereVM.Namespace, ctx.VSphereVM.Name, ctx.VSphereVM.UID)
+
+	vmRef, deploying, err := deploymentResult(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "error deploying content library item %s for %q", item.Name, ctx)
+	}
+	if deploying {
+		ctx.Logger.Info("waiting for the deployment from content library", "libraryItem", item.Name)
+		return nil
+	}
+	if vmRef == nil {
+		vmRef, err = findDeployedVM(ctx, folder, annotation)
+		if err != nil {
+			return err
+		}
+	}
+
+	if vmRef == nil {
+		var deploy func(goctx.Context) (*types.ManagedObjectReference, error)
+		manager := vapivcenter.NewManager(ctx.Session.TagManager.Client)
+		switch item.Type {
+		case library.ItemTypeOVF:
+			spec := vapivcenter.Deploy{
+				DeploymentSpec: vapivcenter.DeploymentSpec{
+					Name:               ctx.VSphereVM.Name,
+					Annotation:         annotation,
+					AcceptAllEULA:      true,
+					DefaultDatastoreID: datastore.Reference().Value,
+					StorageProfileID:   storageProfileID,
+				},
+				Target: vapivcenter.Target{
+					ResourcePoolID: pool.Reference().Value,
+					FolderID:       folder.Reference().Value,
+				},
+			}
+			deploy = func(deployCtx goctx.Context) (*types.ManagedObjectReference, error) {
+				return manager.DeployLibraryItem(deployCtx, item.ID, spec)
+			}
+		case library.ItemTypeVMTX:
+			storage := &vapivcenter.DiskStorage{Datastore: datastore.Reference().Value}
+			if storageProfileID != "" {
+				storage.StoragePolicy = &vapivcenter.StoragePolicy{Policy: storageProfileID, Type: "USE_SPECIFIED_POLICY"}
+			}
+			spec := vapivcenter.DeployTemplate{
+				Name:        ctx.VSphereVM.Name,
+				Description: annotation,
+				Placement: &vapivcenter.Placement{
+					ResourcePool: pool.Reference().Value,
+					Folder:       folder.Reference().Value,
+				},
+				DiskStorage:   storage,
+				VMHomeStorage: storage,
+			}
+			deploy = func(deployCtx goctx.Context) (*types.ManagedObjectReference, error) {
+				return manager.DeployTemplateLibraryItem(deployCtx, item.ID, spec)
+			}
+		default:
+			return errors.Errorf("unsupported type %q of content library item %s for %q", item.Type, item.Name, ctx)
+		}
+		startDeployment(ctx, deploy)
+		return nil
+	}
+
+	vm := object.NewVirtualMachine(ctx.Session.Client.Client, *vmRef)
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "error getting devices for %q", ctx)
+	}
+
+	var deviceSpecs []types.BaseVirtualDeviceConfigSpec
+	diskSpecs, err := GetDiskSpec(ctx, devices)
+	if err != nil {
+		return errors.Wrapf(err, "error getting disk spec for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, diskSpecs...)
+
+	networkSpecs, err := GetNetworkSpecs(ctx, devices)
+	if err != nil {
+		return errors.Wrapf(err, "error getting network specs for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, networkSpecs...)
+	deviceSpecs = append(deviceSpecs, GetPCIDeviceSpecs(ctx)...)
+
+	dataDiskSpecs, err := GetDataDiskSpecs(ctx, devices)
+	if err != nil {
+		return errors.Wrapf(err, "error getting data disk specs for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, dataDiskSpecs...)
+
+	datastoreRef := datastore.Reference()
+	if err := setPlacementStatus(ctx, nil, &datastoreRef); err != nil {
+		return err
+	}
+
+	ctx.Logger.Info("reconfiguring deployed machine", "namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name)
+	task, err := vm.Reconfigure(ctx, *NewConfigSpec(ctx, deviceSpecs, extraConfig))
+	if err != nil {
+		return errors.Wrapf(err, "error trigging reconfigure op for machine %s", ctx)
+	}
+
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+
+	// patch the vsphereVM early to ensure that the task is
+	// reflected in the status right away, this avoid situations
+	// of concurrent clones
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+	return nil
+}
+
+// deployments are the deployments from content libraries in progress, by the
+// UID of their VSphereVM. The vAPI deploy calls only return once the VM is
+// deployed, which takes as long as copying the item's disks, so they are made
+// in the background instead of blocking a reconcile, just like a clone task.
+var deployments sync.Map
+
+type deployment struct {
+	done  chan struct{}
+	vmRef *types.ManagedObjectReference
+	err   error
+}
+
+// Deploying returns whether a VM is being deployed from a content library for
+// the VSphereVM.
+func Deploying(ctx *context.VMContext) bool {
+	value, ok := deployments.Load(ctx.VSphereVM.UID)
+	if !ok {
+		return false
+	}
+	select {
+	case <-value.(*deployment).done: //nolint:forcetypeassert
+		return false
+	default:
+		return true
+	}
+}
+
+// startDeployment makes the given deploy call in the background. The
+// VSphereVM is reconciled again once the call returns.
+func startDeployment(ctx *context.VMContext, deploy func(goctx.Context) (*types.ManagedObjectReference, error)) {
+	d := &deployment{done: make(chan struct{})}
+	deployments.Store(ctx.VSphereVM.UID, d)
+
+	obj := ctx.VSphereVM.DeepCopy()
+	eventChannel := ctx.GetGenericEventChannelFor(obj.GetObjectKind().GroupVersionKind())
+	// The deployment outlives the reconcile, so it is only canceled when the
+	// controller manager stops.
+	managerCtx := ctx.ControllerManagerContext
+	logger := ctx.Logger
+
+	go func() {
+		d.vmRef, d.err = deploy(managerCtx)
+		close(d.done)
+		logger.Info("deployment from content library completed", "error", d.err)
+		select {
+		case eventChannel <- event.GenericEvent{Object: obj}:
+		case <-managerCtx.Done():
+		}
+	}()
+}
+
+// deploymentResult returns the VM deployed for the VSphereVM by a completed
+// deployment, or whether the deployment is still in progress. The result of a
+// completed deployment is only returned once.
+func deploymentResult(ctx *context.VMContext) (*types.ManagedObjectReference, bool, error) {
+	value, ok := deployments.Load(ctx.VSphereVM.UID)
+	if !ok {
+		return nil, false, nil
+	}
+	d := value.(*deployment) //nolint:forcetypeassert
+	select {
+	case <-d.done:
+		deployments.Delete(ctx.VSphereVM.UID)
+		return d.vmRef, false, d.err
+	default:
+		return nil, true, nil
+	}
+}
+
+// findDeployedVM returns the VM with the VSphereVM's name in the given folder
+// if it was deployed for the VSphereVM, as recognized by the given annotation.
+func findDeployedVM(ctx *context.VMContext, folder *object.Folder, annotation string) (*types.ManagedObjectReference, error) {
+	vm, err := ctx.Session.Finder.VirtualMachine(ctx, path.Join(folder.InventoryPath, ctx.VSphereVM.Name))
+	if err != nil {
+		if _, ok := err.(*find.NotFoundError); ok {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "error finding deployed VM for %q", ctx)
+	}
+
+	var obj mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.annotation"}, &obj); err != nil {
+		return nil, errors.Wrapf(err, "error getting annotation of VM %s", vm.InventoryPath)
+	}
+	if obj.Config == nil || obj.Config.Annotation != annotation {
+		return nil, errors.Errorf("a VM named %s which was not deployed for %q already exists", vm.InventoryPath, ctx)
+	}
+
+	ctx.Logger.Info("found previously deployed machine", "vm", vm.Reference().Value)
+	ref := vm.Reference()
+	return &ref, nil
+}
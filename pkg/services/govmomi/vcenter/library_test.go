@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
+	vapivcenter "github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+//nolint:forcetypeassert
+func TestCloneFromContentLibrary(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	ctx := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	ctx.VSphereVM.Spec.Server = simr.ServerURL().Host
+	ctx.VSphereVM.Spec.CloneMode = infrav1.LinkedClone
+
+	authSession, err := session.GetOrCreate(
+		ctx.Context,
+		session.NewParams().
+			WithInsecure(true).
+			WithServer(ctx.VSphereVM.Spec.Server).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx.Session = authSession
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	disk := object.VirtualDeviceList(vm.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
+	disk.CapacityInKB = int64(ctx.VSphereVM.Spec.DiskGiB) * 1024 * 1024
+
+	// Publish the VM as a VM template to a content library.
+	datastore, err := authSession.Finder.DefaultDatastore(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	folder, err := authSession.Finder.DefaultFolder(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	pool, err := authSession.Finder.DefaultResourcePool(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	libraryID, err := library.NewManager(authSession.TagManager.Client).CreateLibrary(ctx, library.Library{
+		Name:    "images",
+		Type:    "LOCAL",
+		Storage: []library.StorageBackings{{DatastoreID: datastore.Reference().Value, Type: "DATASTORE"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	itemID, err := vapivcenter.NewManager(authSession.TagManager.Client).CreateTemplate(ctx, vapivcenter.Template{
+		Name:     "ubuntu",
+		Library:  libraryID,
+		SourceVM: vm.Reference().Value,
+		Placement: &vapivcenter.Placement{
+			Folder:       folder.Reference().Value,
+			ResourcePool: pool.Reference().Value,
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	t.Run("finds library items by name and ID", func(t *testing.T) {
+		g := NewWithT(t)
+
+		item, err := template.FindLibraryItem(ctx, "library:images/ubuntu")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(item).NotTo(BeNil())
+		g.Expect(item.ID).To(Equal(itemID))
+		g.Expect(item.Type).To(Equal(library.ItemTypeVMTX))
+
+		item, err = template.FindLibraryItem(ctx, "library:"+itemID)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(item).NotTo(BeNil())
+		g.Expect(item.Name).To(Equal("ubuntu"))
+
+		cached, ok := authSession.LibraryItem(itemID)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(cached).To(Equal(item))
+	})
+
+	t.Run("does not find inventory VMs", func(t *testing.T) {
+		g := NewWithT(t)
+
+		item, err := template.FindLibraryItem(ctx, vm.Name)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(item).To(BeNil())
+
+		item, err = template.FindLibraryItem(ctx, vm.Config.InstanceUuid)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(item).To(BeNil())
+	})
+
+	t.Run("caches missing library items", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := template.FindLibraryItem(ctx, "library:images/missing")
+		g.Expect(err).To(HaveOccurred())
+		cached, ok := authSession.LibraryItem("images/missing")
+		g.Expect(ok).To(BeTrue())
+		g.Expect(cached).To(BeNil())
+
+		_, err = template.FindLibraryItem(ctx, "library:"+vm.Config.InstanceUuid)
+		g.Expect(err).To(HaveOccurred())
+		cached, ok = authSession.LibraryItem(vm.Config.InstanceUuid)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(cached).To(BeNil())
+	})
+
+	t.Run("deploys the library item in the background", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx.VSphereVM.Spec.Template = "library:images/ubuntu"
+
+		g.Expect(Clone(ctx, nil, "", acquire)).To(Succeed())
+		g.Expect(ctx.VSphereVM.Status.CloneMode).To(Equal(infrav1.FullClone))
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+
+		// The VM is reconfigured by the first attempt after the deployment
+		// completed.
+		g.Eventually(func() (string, error) {
+			err := Clone(ctx, nil, "", acquire)
+			return ctx.VSphereVM.Status.TaskRef, err
+		}).ShouldNot(BeEmpty())
+		g.Expect(Deploying(ctx)).To(BeFalse())
+
+		// The simulator deploys VM template items as templates, which cannot
+		// be reconfigured, so only the deployment itself is verified.
+		deployed, err := authSession.Finder.VirtualMachine(ctx, ctx.VSphereVM.Name)
+		g.Expect(err).NotTo(HaveOccurred())
+		var task mo.Task
+		g.Expect(authSession.RetrieveOne(ctx, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef}, []string{"info"}, &task)).To(Succeed())
+		g.Expect(task.Info.DescriptionId).To(Equal("VirtualMachine.reconfigVm"))
+		g.Expect(task.Info.Entity).To(Equal(types.NewReference(deployed.Reference())))
+	})
+}
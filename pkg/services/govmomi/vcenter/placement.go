@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// recommendDatastore asks Storage DRS for the datastore of the VSphereVM's
+// datastore cluster in which the clone should be placed.
+func recommendDatastore(ctx *context.VMContext, tpl *object.VirtualMachine, folder *object.Folder, spec *types.VirtualMachineCloneSpec) (*types.ManagedObjectReference, error) {
+	pod, err := ctx.Session.Inventory.DatastoreCluster(ctx, ctx.VSphereVM.Spec.DatastoreCluster)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get datastore cluster %s for %q", ctx.VSphereVM.Spec.DatastoreCluster, ctx)
+	}
+	podRef := pod.Reference()
+	tplRef := tpl.Reference()
+	folderRef := folder.Reference()
+
+	placementSpec := types.StoragePlacementSpec{
+		Type: string(types.StoragePlacementSpecPlacementTypeClone),
+		PodSelectionSpec: types.StorageDrsPodSelectionSpec{
+			StoragePod:      &podRef,
+			InitialVmConfig: []types.VmPodConfigForPlacement{{StoragePod: podRef}},
+		},
+		Vm:        &tplRef,
+		CloneName: ctx.VSphereVM.Name,
+		CloneSpec: spec,
+		Folder:    &folderRef,
+	}
+
+	result, err := object.NewStorageResourceManager(ctx.Session.Client.Client).RecommendDatastores(ctx, placementSpec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get datastore recommendations from datastore cluster %s for %q", ctx.VSphereVM.Spec.DatastoreCluster, ctx)
+	}
+	for _, recommendation := range result.Recommendations {
+		for _, action := range recommendation.Action {
+			if placement, ok := action.(*types.StoragePlacementAction); ok {
+				datastoreRef := placement.Destination
+				ctx.Logger.Info("datastore recommended by storage DRS", "datastore", datastoreRef.Value, "reason", recommendation.ReasonText)
+				return &datastoreRef, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("no datastore recommended by datastore cluster %s for %q", ctx.VSphereVM.Spec.DatastoreCluster, ctx)
+}
+
+// recommendPlacement asks DRS for the host and the datastore on which the
+// clone should be placed. The datastore is chosen from the given datastores,
+// or from all of the cluster's datastores if none are given.
+//
+// No host is returned when the resource pool does not belong to a cluster.
+func recommendPlacement(ctx *context.VMContext, tpl *object.VirtualMachine, pool *object.ResourcePool, spec *types.VirtualMachineCloneSpec, datastores []types.ManagedObjectReference) (*types.ManagedObjectReference, *types.ManagedObjectReference, error) {
+	owner, err := pool.Owner(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to get owner of resource pool for %q", ctx)
+	}
+	cluster, ok := owner.(*object.ClusterComputeResource)
+	if !ok {
+		ctx.Logger.Info("resource pool does not belong to a cluster, skipping DRS placement", "owner", owner.Reference().Value)
+		return nil, nil, nil
+	}
+
+	tplRef := tpl.Reference()
+	placementSpec := types.PlacementSpec{
+		PlacementType: string(types.PlacementSpecPlacementTypeClone),
+		Vm:            &tplRef,
+		CloneName:     ctx.VSphereVM.Name,
+		CloneSpec:     spec,
+		Datastores:    datastores,
+	}
+
+	result, err := cluster.PlaceVm(ctx, placementSpec)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to get placement recommendations from cluster %s for %q", cluster.Reference().Value, ctx)
+	}
+	for _, recommendation := range result.Recommendations {
+		for _, action := range recommendation.Action {
+			placement, ok := action.(*types.PlacementAction)
+			if !ok || placement.TargetHost == nil {
+				continue
+			}
+			var datastoreRef *types.ManagedObjectReference
+			if placement.RelocateSpec != nil {
+				datastoreRef = placement.RelocateSpec.Datastore
+			}
+			ctx.Logger.Info("placement recommended by DRS", "host", placement.TargetHost.Value, "reason", recommendation.ReasonText)
+			return placement.TargetHost, datastoreRef, nil
+		}
+	}
+	return nil, nil, errors.Errorf("no placement recommended by cluster %s for %q", cluster.Reference().Value, ctx)
+}
+
+// setPlacementStatus records the names of the host and the datastore chosen
+// for the clone in the VSphereVM's status.
+func setPlacementStatus(ctx *context.VMContext, hostRef, datastoreRef *types.ManagedObjectReference) error {
+	placement := &infrav1.VirtualMachinePlacementStatus{}
+	if hostRef != nil {
+		name, err := object.NewHostSystem(ctx.Session.Client.Client, *hostRef).ObjectName(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get name of host %s for %q", hostRef.Value, ctx)
+		}
+		placement.Host = name
+	}
+	if datastoreRef != nil {
+		name, err := object.NewDatastore(ctx.Session.Client.Client, *datastoreRef).ObjectName(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get name of datastore %s for %q", datastoreRef.Value, ctx)
+		}
+		placement.Datastore = name
+	}
+	ctx.VSphereVM.Status.Placement = placement
+	return nil
+}
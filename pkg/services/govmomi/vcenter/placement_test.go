@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+// acquire allows any clone to start.
+func acquire(types.ManagedObjectReference) bool {
+	return true
+}
+
+//nolint:forcetypeassert
+func TestClonePlacement(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+	model.Pod = 1
+
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	newVMContext := func(g *WithT, name string) *context.VMContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Name = name
+		vmContext.VSphereVM.Spec.Server = simr.ServerURL().Host
+
+		authSession, err := session.GetOrCreate(
+			vmContext.Context,
+			session.NewParams().
+				WithInsecure(true).
+				WithServer(vmContext.VSphereVM.Spec.Server).
+				WithUserInfo(simr.Username(), simr.Password()).
+				WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+		vmContext.VSphereVM.Spec.Template = vm.Name
+		disk := object.VirtualDeviceList(vm.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
+		disk.CapacityInKB = int64(vmContext.VSphereVM.Spec.DiskGiB) * 1024 * 1024
+		return vmContext
+	}
+
+	waitForClone := func(g *WithT, ctx *context.VMContext) {
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+	}
+
+	t.Run("records the datastore of a static placement", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, "static")
+
+		g.Expect(Clone(ctx, nil, "", acquire)).To(Succeed())
+		waitForClone(g, ctx)
+		g.Expect(ctx.VSphereVM.Status.Placement).To(Equal(&infrav1.VirtualMachinePlacementStatus{Datastore: "LocalDS_0"}))
+	})
+
+	t.Run("places the VM on the host recommended by DRS", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, "recommended")
+		ctx.VSphereVM.Spec.PlacementPolicy = infrav1.RecommendedPlacementPolicy
+
+		g.Expect(Clone(ctx, nil, "", acquire)).To(Succeed())
+		waitForClone(g, ctx)
+		g.Expect(ctx.VSphereVM.Status.Placement).NotTo(BeNil())
+		g.Expect(ctx.VSphereVM.Status.Placement.Host).To(HavePrefix("DC0_C0_H"))
+		g.Expect(ctx.VSphereVM.Status.Placement.Datastore).To(Equal("LocalDS_0"))
+	})
+
+	t.Run("places the VM on the datastore recommended by storage DRS", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, "datastore-cluster")
+		ctx.VSphereVM.Spec.DatastoreCluster = "DC0_POD0"
+
+		pod, err := ctx.Session.Finder.DatastoreCluster(ctx, ctx.VSphereVM.Spec.DatastoreCluster)
+		g.Expect(err).NotTo(HaveOccurred())
+		datastore, err := ctx.Session.Finder.Datastore(ctx, "LocalDS_0")
+		g.Expect(err).NotTo(HaveOccurred())
+		task, err := pod.MoveInto(ctx, []types.ManagedObjectReference{datastore.Reference()})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		var acquired []types.ManagedObjectReference
+		g.Expect(Clone(ctx, nil, "", func(datastore types.ManagedObjectReference) bool {
+			acquired = append(acquired, datastore)
+			return true
+		})).To(Succeed())
+		waitForClone(g, ctx)
+		g.Expect(acquired).To(Equal([]types.ManagedObjectReference{datastore.Reference()}))
+		g.Expect(ctx.VSphereVM.Status.Placement).To(Equal(&infrav1.VirtualMachinePlacementStatus{Datastore: "LocalDS_0"}))
+	})
+
+	t.Run("does not clone the VM without a slot for the default datastore", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, "waiting")
+
+		datastore, err := ctx.Session.Finder.DefaultDatastore(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		var acquired []types.ManagedObjectReference
+		g.Expect(Clone(ctx, nil, "", func(datastore types.ManagedObjectReference) bool {
+			acquired = append(acquired, datastore)
+			return false
+		})).To(Succeed())
+		g.Expect(acquired).To(Equal([]types.ManagedObjectReference{datastore.Reference()}))
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(ctx.VSphereVM.Status.Placement).To(BeNil())
+	})
+
+	t.Run("fails for a missing datastore cluster", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(g, "missing-datastore-cluster")
+		ctx.VSphereVM.Spec.DatastoreCluster = "missing"
+
+		g.Expect(Clone(ctx, nil, "", acquire)).To(MatchError(ContainSubstring("unable to get datastore cluster missing")))
+	})
+}
@@ -47,6 +47,17 @@ type VirtualMachineService interface {
 	DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine, error)
 }
 
+// IPAddressService is a service for claiming and releasing the IP addresses
+// of a VSphereVM's network devices from IP pools.
+type IPAddressService interface {
+	// ReconcileIPAddresses claims the IP addresses of the network devices
+	// which reference IP pools and adds them to the device specs.
+	ReconcileIPAddresses(ctx *context.VMContext) error
+
+	// ReleaseIPAddresses releases all of the IP addresses claimed by a VM.
+	ReleaseIPAddresses(ctx *context.VMContext) error
+}
+
 // ControlPlaneEndpointService is a service for reconciling load balanced control plane endpoints.
 type ControlPlaneEndpointService interface {
 	// ReconcileControlPlaneEndpointService manages the lifecycle of a
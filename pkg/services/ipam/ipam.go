@@ -0,0 +1,264 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apitypes "k8s.io/apimachinery/pkg/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// PoolService claims and releases the IP addresses of a VSphereVM's network
+// devices from the VSphereIPPools referenced by the devices.
+type PoolService struct{}
+
+// ReconcileIPAddresses claims an address from every pool referenced by the
+// VSphereVM's network devices and adds the claimed addresses, together with
+// the gateway and nameservers of the pools, to the device specs.
+//
+// Addresses which are already claimed by a device are reused, so this may be
+// called on every reconcile.
+func (s *PoolService) ReconcileIPAddresses(ctx *context.VMContext) error {
+	for i := range ctx.VSphereVM.Spec.Network.Devices {
+		device := &ctx.VSphereVM.Spec.Network.Devices[i]
+		for _, ref := range device.AddressesFromPools {
+			pool, err := getPool(ctx, ref)
+			if err != nil {
+				return err
+			}
+
+			address, err := claimAddress(ctx, pool, int32(i))
+			if err != nil {
+				return err
+			}
+
+			applyAddress(device, pool, address)
+		}
+	}
+	return nil
+}
+
+// ReleaseIPAddresses releases all of the addresses claimed by the VSphereVM
+// from the pools referenced by its network devices.
+func (s *PoolService) ReleaseIPAddresses(ctx *context.VMContext) error {
+	released := map[string]struct{}{}
+	for _, device := range ctx.VSphereVM.Spec.Network.Devices {
+		for _, ref := range device.AddressesFromPools {
+			if _, ok := released[ref.Name]; ok {
+				continue
+			}
+
+			pool, err := getPool(ctx, ref)
+			if err != nil {
+				if apierrors.IsNotFound(errors.Cause(err)) {
+					released[ref.Name] = struct{}{}
+					continue
+				}
+				return err
+			}
+
+			allocations := make([]infrav1.IPAddressAllocation, 0, len(pool.Status.Allocations))
+			for _, allocation := range pool.Status.Allocations {
+				if allocation.VSphereVM != ctx.VSphereVM.Name {
+					allocations = append(allocations, allocation)
+				}
+			}
+			if len(allocations) != len(pool.Status.Allocations) {
+				pool.Status.Allocations = allocations
+				if err := ctx.Client.Status().Update(ctx, pool); err != nil {
+					return errors.Wrapf(err, "failed to release IP addresses from VSphereIPPool %s/%s", pool.Namespace, pool.Name)
+				}
+				ctx.Logger.Info("released IP addresses", "pool", pool.Name)
+			}
+			released[ref.Name] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func getPool(ctx *context.VMContext, ref corev1.TypedLocalObjectReference) (*infrav1.VSphereIPPool, error) {
+	if ref.APIGroup == nil || *ref.APIGroup != infrav1.GroupVersion.Group || ref.Kind != "VSphereIPPool" {
+		return nil, errors.Errorf("unsupported IP pool kind %s", ref.Kind)
+	}
+
+	pool := &infrav1.VSphereIPPool{}
+	key := apitypes.NamespacedName{Namespace: ctx.VSphereVM.Namespace, Name: ref.Name}
+	if err := ctx.Client.Get(ctx, key, pool); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereIPPool %s", key)
+	}
+	return pool, nil
+}
+
+// claimAddress returns the address claimed by the given device of the
+// VSphereVM, claiming the next free address of the pool if there is none.
+// The pool's status is updated without retrying on conflicts, which ensures
+// that an address is never handed out twice.
+func claimAddress(ctx *context.VMContext, pool *infrav1.VSphereIPPool, deviceIndex int32) (string, error) {
+	used := map[string]struct{}{}
+	for _, allocation := range pool.Status.Allocations {
+		if allocation.VSphereVM == ctx.VSphereVM.Name && allocation.DeviceIndex == deviceIndex {
+			return allocation.Address, nil
+		}
+		used[allocation.Address] = struct{}{}
+	}
+	if gateway := net.ParseIP(pool.Spec.Gateway); gateway != nil {
+		used[gateway.String()] = struct{}{}
+	}
+
+	address, err := nextFreeAddress(pool.Spec.Addresses, used)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to claim IP address from VSphereIPPool %s/%s", pool.Namespace, pool.Name)
+	}
+
+	pool.Status.Allocations = append(pool.Status.Allocations, infrav1.IPAddressAllocation{
+		Address:     address,
+		VSphereVM:   ctx.VSphereVM.Name,
+		DeviceIndex: deviceIndex,
+	})
+	if err := ctx.Client.Status().Update(ctx, pool); err != nil {
+		return "", errors.Wrapf(err, "failed to claim IP address from VSphereIPPool %s/%s", pool.Namespace, pool.Name)
+	}
+	ctx.Logger.Info("claimed IP address", "pool", pool.Name, "address", address, "device", deviceIndex)
+
+	return address, nil
+}
+
+// applyAddress adds the address to the device's IP addresses and sets the
+// device's gateway and nameservers from the pool unless they are already set.
+func applyAddress(device *infrav1.NetworkDeviceSpec, pool *infrav1.VSphereIPPool, address string) {
+	ipAddr := fmt.Sprintf("%s/%d", address, pool.Spec.Prefix)
+	if !contains(device.IPAddrs, ipAddr) {
+		device.IPAddrs = append(device.IPAddrs, ipAddr)
+	}
+
+	if gateway := net.ParseIP(pool.Spec.Gateway); gateway != nil {
+		if gateway.To4() != nil {
+			if device.Gateway4 == "" {
+				device.Gateway4 = pool.Spec.Gateway
+			}
+		} else if device.Gateway6 == "" {
+			device.Gateway6 = pool.Spec.Gateway
+		}
+	}
+
+	if len(device.Nameservers) == 0 && len(pool.Spec.Nameservers) > 0 {
+		device.Nameservers = append([]string{}, pool.Spec.Nameservers...)
+	}
+}
+
+// nextFreeAddress returns the first address of the pool's addresses which is
+// not in use.
+func nextFreeAddress(addresses []string, used map[string]struct{}) (string, error) {
+	for _, address := range addresses {
+		first, last, err := addressRange(address)
+		if err != nil {
+			return "", err
+		}
+		for ip := first; bytes.Compare(ip, last) <= 0; ip = nextIP(ip) {
+			if _, ok := used[ip.String()]; !ok {
+				return ip.String(), nil
+			}
+			if ip.Equal(last) {
+				break
+			}
+		}
+	}
+	return "", errors.New("no free IP addresses left")
+}
+
+// addressRange returns the first and the last usable address of an entry of
+// VSphereIPPoolSpec.Addresses. The network and broadcast addresses of IPv4
+// CIDRs are not usable.
+func addressRange(address string) (net.IP, net.IP, error) {
+	if strings.Contains(address, "/") {
+		_, ipNet, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid address %q", address)
+		}
+		first, last := normalize(ipNet.IP), make(net.IP, len(ipNet.IP))
+		for i := range ipNet.IP {
+			last[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+		}
+		last = normalize(last)
+		if ones, bits := ipNet.Mask.Size(); bits == 32 && bits-ones > 1 {
+			first, last = nextIP(first), prevIP(last)
+		}
+		return first, last, nil
+	}
+
+	if parts := strings.SplitN(address, "-", 2); len(parts) == 2 {
+		first, last := net.ParseIP(strings.TrimSpace(parts[0])), net.ParseIP(strings.TrimSpace(parts[1]))
+		if first == nil || last == nil {
+			return nil, nil, errors.Errorf("invalid address range %q", address)
+		}
+		return normalize(first), normalize(last), nil
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, nil, errors.Errorf("invalid address %q", address)
+	}
+	return normalize(ip), normalize(ip), nil
+}
+
+// normalize returns the 16-byte representation of an IP address so that
+// addresses can be compared byte by byte.
+func normalize(ip net.IP) net.IP {
+	return ip.To16()
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func prevIP(ip net.IP) net.IP {
+	prev := make(net.IP, len(ip))
+	copy(prev, ip)
+	for i := len(prev) - 1; i >= 0; i-- {
+		prev[i]--
+		if prev[i] != 0xff {
+			break
+		}
+	}
+	return prev
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestPoolService(t *testing.T) {
+	newPool := func(allocations ...infrav1.IPAddressAllocation) *infrav1.VSphereIPPool {
+		return &infrav1.VSphereIPPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "pool",
+			},
+			Spec: infrav1.VSphereIPPoolSpec{
+				Addresses:   []string{"10.0.0.0/30", "10.0.1.10-10.0.1.11"},
+				Prefix:      24,
+				Gateway:     "10.0.0.1",
+				Nameservers: []string{"10.0.0.2"},
+			},
+			Status: infrav1.VSphereIPPoolStatus{
+				Allocations: allocations,
+			},
+		}
+	}
+
+	newVMContext := func(pool *infrav1.VSphereIPPool) *context.VMContext {
+		ctx := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext(pool)))
+		ctx.VSphereVM.Spec.Network.Devices[0] = infrav1.NetworkDeviceSpec{
+			NetworkName: "VM Network",
+			AddressesFromPools: []corev1.TypedLocalObjectReference{
+				{
+					APIGroup: pointer.String(infrav1.GroupVersion.Group),
+					Kind:     "VSphereIPPool",
+					Name:     pool.Name,
+				},
+			},
+		}
+		return ctx
+	}
+
+	getPool := func(g *WithT, ctx *context.VMContext) *infrav1.VSphereIPPool {
+		pool := &infrav1.VSphereIPPool{}
+		g.Expect(ctx.Client.Get(ctx, apitypes.NamespacedName{Namespace: fake.Namespace, Name: "pool"}, pool)).To(Succeed())
+		return pool
+	}
+
+	t.Run("claims the next free address", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(newPool())
+
+		g.Expect((&PoolService{}).ReconcileIPAddresses(ctx)).To(Succeed())
+
+		// 10.0.0.0 is the network address and 10.0.0.1 is the gateway.
+		device := ctx.VSphereVM.Spec.Network.Devices[0]
+		g.Expect(device.IPAddrs).To(ConsistOf("10.0.0.2/24"))
+		g.Expect(device.Gateway4).To(Equal("10.0.0.1"))
+		g.Expect(device.Nameservers).To(ConsistOf("10.0.0.2"))
+		g.Expect(getPool(g, ctx).Status.Allocations).To(ConsistOf(infrav1.IPAddressAllocation{
+			Address:     "10.0.0.2",
+			VSphereVM:   ctx.VSphereVM.Name,
+			DeviceIndex: 0,
+		}))
+
+		// Reconciling again reuses the claimed address.
+		g.Expect((&PoolService{}).ReconcileIPAddresses(ctx)).To(Succeed())
+		g.Expect(ctx.VSphereVM.Spec.Network.Devices[0].IPAddrs).To(ConsistOf("10.0.0.2/24"))
+		g.Expect(getPool(g, ctx).Status.Allocations).To(HaveLen(1))
+	})
+
+	t.Run("claims addresses from the next range", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(newPool(infrav1.IPAddressAllocation{Address: "10.0.0.2", VSphereVM: "other-vm"}))
+
+		g.Expect((&PoolService{}).ReconcileIPAddresses(ctx)).To(Succeed())
+		g.Expect(ctx.VSphereVM.Spec.Network.Devices[0].IPAddrs).To(ConsistOf("10.0.1.10/24"))
+	})
+
+	t.Run("fails when the pool is exhausted", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(newPool(
+			infrav1.IPAddressAllocation{Address: "10.0.0.2", VSphereVM: "vm-1"},
+			infrav1.IPAddressAllocation{Address: "10.0.1.10", VSphereVM: "vm-2"},
+			infrav1.IPAddressAllocation{Address: "10.0.1.11", VSphereVM: "vm-3"},
+		))
+
+		g.Expect((&PoolService{}).ReconcileIPAddresses(ctx)).To(MatchError(ContainSubstring("no free IP addresses left")))
+		g.Expect(ctx.VSphereVM.Spec.Network.Devices[0].IPAddrs).To(BeEmpty())
+	})
+
+	t.Run("releases the claimed addresses", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newVMContext(newPool(
+			infrav1.IPAddressAllocation{Address: "10.0.0.2", VSphereVM: "other-vm"},
+			infrav1.IPAddressAllocation{Address: "10.0.1.10", VSphereVM: fake.VSphereVMName},
+		))
+
+		g.Expect((&PoolService{}).ReleaseIPAddresses(ctx)).To(Succeed())
+		g.Expect(getPool(g, ctx).Status.Allocations).To(ConsistOf(infrav1.IPAddressAllocation{
+			Address:   "10.0.0.2",
+			VSphereVM: "other-vm",
+		}))
+	})
+}
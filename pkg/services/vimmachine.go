@@ -37,6 +37,7 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/tracing"
 	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
@@ -65,11 +66,18 @@ func (v *VimMachineService) FetchVSphereCluster(c client.Client, cluster *cluste
 	return ctx, err
 }
 
-func (v *VimMachineService) ReconcileDelete(c context.MachineContext) error {
+func (v *VimMachineService) ReconcileDelete(c context.MachineContext) (reterr error) {
 	ctx, ok := c.(*context.VIMMachineContext)
 	if !ok {
 		return errors.New("received unexpected VIMMachineContext type")
 	}
+	spanCtx, span := tracing.Start(ctx, "VimMachineService.ReconcileDelete")
+	controllerContext := ctx.ControllerContext
+	ctx.ControllerContext = controllerContext.WithContext(spanCtx)
+	defer func() {
+		ctx.ControllerContext = controllerContext
+		tracing.End(span, reterr)
+	}()
 
 	vm, err := v.findVMPre7(ctx)
 	// Attempt to find the associated VSphereVM resource.
@@ -110,16 +118,38 @@ func (v *VimMachineService) SyncFailureReason(c context.MachineContext) (bool, e
 	return ctx.VSphereMachine.Status.FailureReason != nil || ctx.VSphereMachine.Status.FailureMessage != nil, err
 }
 
-func (v *VimMachineService) ReconcileNormal(c context.MachineContext) (bool, error) {
+func (v *VimMachineService) ReconcileNormal(c context.MachineContext) (_ bool, reterr error) {
 	ctx, ok := c.(*context.VIMMachineContext)
 	if !ok {
 		return false, errors.New("received unexpected VIMMachineContext type")
 	}
+	spanCtx, span := tracing.Start(ctx, "VimMachineService.ReconcileNormal")
+	controllerContext := ctx.ControllerContext
+	ctx.ControllerContext = controllerContext.WithContext(spanCtx)
+	defer func() {
+		ctx.ControllerContext = controllerContext
+		tracing.End(span, reterr)
+	}()
 	vsphereVM, err := v.findVMPre7(ctx)
 	if err != nil && !apierrors.IsNotFound(err) {
 		return false, err
 	}
 
+	// Select the failure domain of worker machines which are not assigned one
+	// before their VM is created. The failure domain is recorded on the
+	// VSphereMachine, from where Cluster API copies it to the Machine.
+	if vsphereVM == nil && ctx.Machine.Spec.FailureDomain == nil && ctx.VSphereMachine.Spec.FailureDomain == nil &&
+		!clusterutilv1.IsControlPlaneMachine(ctx.Machine) {
+		failureDomain, err := selectFailureDomain(ctx)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to select failure domain for %s", ctx)
+		}
+		if failureDomain != nil {
+			ctx.Logger.Info("selected failure domain", "failureDomain", *failureDomain)
+			ctx.VSphereMachine.Spec.FailureDomain = failureDomain
+		}
+	}
+
 	vm, err := v.createOrUpdateVSPhereVM(ctx, vsphereVM)
 
 	if err != nil && !apierrors.IsAlreadyExists(err) {
@@ -350,6 +380,24 @@ func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineConte
 			vm.Labels[clusterv1.MachineControlPlaneLabelName] = val
 		}
 
+		// Add the name of the MachineDeployment the VSphereVM is part of, so
+		// that the VMs of a MachineDeployment can be kept apart from each
+		// other.
+		if val, ok := ctx.Machine.Labels[clusterv1.MachineDeploymentLabelName]; ok {
+			vm.Labels[clusterv1.MachineDeploymentLabelName] = val
+		}
+
+		// Propagate the annotation which allows power cycling the VSphereVM
+		// for an in-place resize.
+		if val, ok := ctx.VSphereMachine.Annotations[infrav1.AnnotationAllowResizePowerCycle]; ok {
+			if vm.Annotations == nil {
+				vm.Annotations = map[string]string{}
+			}
+			vm.Annotations[infrav1.AnnotationAllowResizePowerCycle] = val
+		} else {
+			delete(vm.Annotations, infrav1.AnnotationAllowResizePowerCycle)
+		}
+
 		// Copy the VSphereMachine's VM clone spec into the VSphereVM's
 		// clone spec.
 		ctx.VSphereMachine.Spec.VirtualMachineCloneSpec.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
@@ -373,6 +421,7 @@ func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineConte
 		}
 		if vsphereVM != nil {
 			vm.Spec.BiosUUID = vsphereVM.Spec.BiosUUID
+			preserveClaimedIPAddresses(vm.Spec.Network.Devices, vsphereVM.Spec.Network.Devices)
 		}
 		return nil
 	}
@@ -390,10 +439,14 @@ func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineConte
 }
 
 // generateOverrideFunc returns a function which can override the values in the VSphereVM Spec
-// with the values from the FailureDomain (if any) set on the owner CAPI machine.
+// with the values from the FailureDomain (if any) set on the owner CAPI machine or, failing
+// that, on the VSphereMachine.
 //nolint:nestif
 func (v *VimMachineService) generateOverrideFunc(ctx *context.VIMMachineContext) (func(vm *infrav1.VSphereVM), bool) {
 	failureDomainName := ctx.Machine.Spec.FailureDomain
+	if failureDomainName == nil {
+		failureDomainName = ctx.VSphereMachine.Spec.FailureDomain
+	}
 	if failureDomainName == nil {
 		return nil, false
 	}
@@ -456,3 +509,18 @@ func overrideNetworkDeviceSpecs(deviceSpecs []infrav1.NetworkDeviceSpec, network
 
 	return devices
 }
+
+// preserveClaimedIPAddresses copies the IP configuration of the devices which
+// reference IP pools from the existing VSphereVM, since the addresses claimed
+// by the VSphereVM controller are not part of the VSphereMachine's spec.
+func preserveClaimedIPAddresses(devices, existingDevices []infrav1.NetworkDeviceSpec) {
+	for i := range devices {
+		if len(devices[i].AddressesFromPools) == 0 || i >= len(existingDevices) {
+			continue
+		}
+		devices[i].IPAddrs = existingDevices[i].IPAddrs
+		devices[i].Gateway4 = existingDevices[i].Gateway4
+		devices[i].Gateway6 = existingDevices[i].Gateway6
+		devices[i].Nameservers = existingDevices[i].Nameservers
+	}
+}
@@ -76,6 +76,31 @@ var _ = Describe("VimMachineService_GenerateOverrideFunc", func() {
 		})
 	})
 
+	Context("When Failure Domain is present on the VSphereMachine", func() {
+		BeforeEach(func() {
+			machineCtx.VSphereMachine.Spec.FailureDomain = pointer.String("zone-two")
+		})
+
+		It("uses the deployment zone of the VSphereMachine for VM values", func() {
+			overrideFunc, ok := vimMachineService.generateOverrideFunc(machineCtx)
+			Expect(ok).To(BeTrue())
+
+			vm := &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{}}
+			overrideFunc(vm)
+			Expect(vm.Spec.Server).To(Equal("server-two"))
+		})
+
+		It("prefers the failure domain of the Machine", func() {
+			machineCtx.Machine.Spec.FailureDomain = pointer.String("zone-one")
+			overrideFunc, ok := vimMachineService.generateOverrideFunc(machineCtx)
+			Expect(ok).To(BeTrue())
+
+			vm := &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{}}
+			overrideFunc(vm)
+			Expect(vm.Spec.Server).To(Equal("server-one"))
+		})
+	})
+
 	Context("When Failure Domain is present", func() {
 		BeforeEach(func() {
 			machineCtx.Machine.Spec.FailureDomain = pointer.String("zone-one")
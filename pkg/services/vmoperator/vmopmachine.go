@@ -21,6 +21,7 @@ import (
 	goctx "context"
 	"encoding/base64"
 	"fmt"
+	"reflect"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -30,7 +31,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -177,13 +180,24 @@ func (v *VmopMachineService) ReconcileNormal(c context.MachineContext) (bool, er
 		return false, err
 	}
 
-	// Define the bootstrap data ConfigMap resource to reconcile.
-	bootstrapDataConfigMap := v.newBootstrapDataConfigMap(ctx)
+	// The bootstrap data Secret is only needed until the VM is ready, after
+	// which the VM Operator VirtualMachine no longer refers to it.
+	if ctx.VSphereMachine.Status.Ready {
+		if err := v.deleteBootstrapDataSecret(ctx); err != nil {
+			return false, err
+		}
+	} else {
+		// Reconcile the bootstrap data Secret.
+		if err := v.reconcileBootstrapDataSecret(ctx, v.newBootstrapDataSecret(ctx)); err != nil {
+			conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, vmwarev1.VMCreationFailedReason, clusterv1.ConditionSeverityWarning,
+				fmt.Sprintf("failed to create bootstrap secret: %v", err))
+			return false, err
+		}
+	}
 
-	// Reconcile the bootstrap data ConfigMap.
-	if err := v.reconcileBootstrapDataConfigMap(ctx, bootstrapDataConfigMap); err != nil {
-		conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, vmwarev1.VMCreationFailedReason, clusterv1.ConditionSeverityWarning,
-			fmt.Sprintf("failed to create or update bootstrap configmap: %v", err))
+	// Bootstrap data used to be stored in a ConfigMap, which is replaced by
+	// the Secret. VMs which were ready before then no longer refer to it.
+	if err := v.deleteBootstrapDataConfigMap(ctx); err != nil {
 		return false, err
 	}
 
@@ -282,9 +296,18 @@ func (v VmopMachineService) reconcileVMOperatorVM(ctx *vmware.SupervisorMachineC
 		vmOperatorVM.Spec.StorageClass = ctx.VSphereMachine.Spec.StorageClass
 		vmOperatorVM.Spec.PowerState = vmoprv1.VirtualMachinePoweredOn
 		vmOperatorVM.Spec.ResourcePolicyName = ctx.VSphereCluster.Status.ResourcePolicyName
-		vmOperatorVM.Spec.VmMetadata = &vmoprv1.VirtualMachineMetadata{
-			ConfigMapName: vmwareutil.GetBootstrapConfigMapName(ctx.VSphereMachine.Name),
-			Transport:     "ExtraConfig",
+		// The bootstrap data Secret, or the ConfigMap of VMs created before
+		// it was introduced, is dropped once the VM is ready. Metadata which
+		// refers to neither is left untouched.
+		secretName := vmwareutil.GetBootstrapSecretName(ctx.VSphereMachine.Name)
+		configMapName := vmwareutil.GetBootstrapConfigMapName(ctx.VSphereMachine.Name)
+		if !ctx.VSphereMachine.Status.Ready {
+			vmOperatorVM.Spec.VmMetadata = &vmoprv1.VirtualMachineMetadata{
+				SecretName: secretName,
+				Transport:  getVMMetadataTransport(ctx.VSphereMachine.Spec.BootstrapTransport),
+			}
+		} else if metadata := vmOperatorVM.Spec.VmMetadata; metadata != nil && (metadata.SecretName == secretName || metadata.ConfigMapName == configMapName) {
+			vmOperatorVM.Spec.VmMetadata = nil
 		}
 
 		// VMOperator supports readiness probe and will add/remove endpoints to a
@@ -342,49 +365,130 @@ func (v VmopMachineService) reconcileVMOperatorVM(ctx *vmware.SupervisorMachineC
 	return err
 }
 
-func (v VmopMachineService) newBootstrapDataConfigMap(ctx *vmware.SupervisorMachineContext) *corev1.ConfigMap {
-	return &corev1.ConfigMap{
+func (v VmopMachineService) newBootstrapDataSecret(ctx *vmware.SupervisorMachineContext) *corev1.Secret {
+	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: ctx.VSphereMachine.Namespace,
-			Name:      vmwareutil.GetBootstrapConfigMapName(ctx.VSphereMachine.Name),
+			Name:      vmwareutil.GetBootstrapSecretName(ctx.VSphereMachine.Name),
 		},
 	}
 }
 
-func (v VmopMachineService) reconcileBootstrapDataConfigMap(ctx *vmware.SupervisorMachineContext, configMap *corev1.ConfigMap) error {
-	bootstrapData, err := vmwareutil.GetBootstrapData(ctx, ctx.Client, ctx.Machine)
+// reconcileBootstrapDataSecret creates the immutable Secret holding the
+// bootstrap data of the VM. As it cannot be updated, an existing Secret with
+// stale bootstrap data is deleted and created again.
+func (v VmopMachineService) reconcileBootstrapDataSecret(ctx *vmware.SupervisorMachineContext, secret *corev1.Secret) error {
+	data, err := v.getBootstrapDataSecretData(ctx)
 	if err != nil {
 		return err
 	}
 
-	_, err = ctrlutil.CreateOrUpdate(ctx, ctx.Client, configMap, func() error {
-		// Make sure the VSphereMachine owns the bootstrap data ConfigMap.
-		if err := ctrlutil.SetControllerReference(ctx.VSphereMachine, configMap, ctx.Scheme); err != nil {
-			return errors.Wrapf(err, "failed to mark %s %s/%s as owner of %s %s/%s",
-				ctx.VSphereMachine.GroupVersionKind(),
-				ctx.VSphereMachine.Namespace,
-				ctx.VSphereMachine.Name,
-				configMap.GroupVersionKind(),
-				configMap.Namespace,
-				configMap.Name)
+	existing := &corev1.Secret{}
+	if err := ctx.Client.Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
 		}
-
-		metadata, err := v.getGuestInfoMetadata(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "failed to get guest info metadata for machine %s", ctx.Machine.Name)
+	} else {
+		if reflect.DeepEqual(existing.Data, data) {
+			return nil
 		}
+		ctx.Logger.Info("recreating bootstrap secret with stale data", "secret", secret.Name)
+		if err := ctx.Client.Delete(ctx, existing, client.Preconditions{UID: &existing.UID}); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete stale bootstrap secret for machine %s", ctx.Machine.Name)
+		}
+	}
+
+	// Make sure the VSphereMachine owns the bootstrap data Secret.
+	if err := ctrlutil.SetControllerReference(ctx.VSphereMachine, secret, ctx.Scheme); err != nil {
+		return errors.Wrapf(err, "failed to mark %s %s/%s as owner of %s %s/%s",
+			ctx.VSphereMachine.GroupVersionKind(),
+			ctx.VSphereMachine.Namespace,
+			ctx.VSphereMachine.Name,
+			secret.GroupVersionKind(),
+			secret.Namespace,
+			secret.Name)
+	}
+
+	secret.Data = data
+	secret.Immutable = pointer.Bool(true)
+	return ctx.Client.Create(ctx, secret)
+}
+
+// getBootstrapDataSecretData returns the keys of the bootstrap data Secret in
+// the format expected by the bootstrap transport of the VM.
+func (v VmopMachineService) getBootstrapDataSecretData(ctx *vmware.SupervisorMachineContext) (map[string][]byte, error) {
+	bootstrapData, format, err := vmwareutil.GetRawBootstrapDataWithFormat(ctx, ctx.Client, ctx.Machine)
+	if err != nil {
+		return nil, err
+	}
+	transport := ctx.VSphereMachine.Spec.BootstrapTransport
 
-		// The CAPI contract states that the string assigned to the field
-		// Machine.Spec.Bootstrap.Data will be base64 encoded.
-		configMap.Data = map[string]string{
-			"guestinfo.userdata":          bootstrapData,
-			"guestinfo.userdata.encoding": "base64",
-			"guestinfo.metadata":          metadata,
-			"guestinfo.metadata.encoding": "base64",
+	if format == bootstrapv1.Ignition {
+		// Ignition reads its config from the same key in ExtraConfig and in
+		// the OVF vApp properties.
+		if transport == vmwarev1.CloudInitBootstrapTransport {
+			return nil, errors.Errorf("bootstrap data format %s is not supported by bootstrap transport %s", format, transport)
 		}
-		return nil
-	})
-	return err
+		return map[string][]byte{
+			"guestinfo.ignition.config.data":          []byte(base64.StdEncoding.EncodeToString(bootstrapData)),
+			"guestinfo.ignition.config.data.encoding": []byte("base64"),
+		}, nil
+	}
+
+	switch transport {
+	case vmwarev1.CloudInitBootstrapTransport:
+		// VM Operator generates the cloud-init metadata itself and expects
+		// the user data as is.
+		return map[string][]byte{
+			"user-data": bootstrapData,
+		}, nil
+	case vmwarev1.VAppConfigBootstrapTransport:
+		// The cloud-init OVF datasource expects the user data base64 encoded
+		// and does not support any other metadata.
+		return map[string][]byte{
+			"user-data":   []byte(base64.StdEncoding.EncodeToString(bootstrapData)),
+			"instance-id": []byte(ctx.Machine.Name),
+			"hostname":    []byte(ctx.Machine.Name),
+		}, nil
+	}
+
+	metadata, err := v.getGuestInfoMetadata(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get guest info metadata for machine %s", ctx.Machine.Name)
+	}
+
+	// The CAPI contract states that the string assigned to the field
+	// Machine.Spec.Bootstrap.Data will be base64 encoded.
+	return map[string][]byte{
+		"guestinfo.userdata":          []byte(base64.StdEncoding.EncodeToString(bootstrapData)),
+		"guestinfo.userdata.encoding": []byte("base64"),
+		"guestinfo.metadata":          []byte(metadata),
+		"guestinfo.metadata.encoding": []byte("base64"),
+	}, nil
+}
+
+// deleteBootstrapDataSecret deletes the bootstrap data Secret of the VM, if
+// any.
+func (v VmopMachineService) deleteBootstrapDataSecret(ctx *vmware.SupervisorMachineContext) error {
+	if err := ctx.Client.Delete(ctx, v.newBootstrapDataSecret(ctx)); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete bootstrap secret for machine %s", ctx.Machine.Name)
+	}
+	return nil
+}
+
+// deleteBootstrapDataConfigMap deletes the bootstrap data ConfigMap created
+// for the VM by previous versions, if any.
+func (v VmopMachineService) deleteBootstrapDataConfigMap(ctx *vmware.SupervisorMachineContext) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ctx.VSphereMachine.Namespace,
+			Name:      vmwareutil.GetBootstrapConfigMapName(ctx.VSphereMachine.Name),
+		},
+	}
+	if err := ctx.Client.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete bootstrap configmap for machine %s", ctx.Machine.Name)
+	}
+	return nil
 }
 
 func (v VmopMachineService) getGuestInfoMetadata(ctx *vmware.SupervisorMachineContext) (string, error) {
@@ -412,6 +516,19 @@ func (v VmopMachineService) getGuestInfoMetadata(ctx *vmware.SupervisorMachineCo
 	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
 }
 
+// getVMMetadataTransport returns the VM Operator transport of the bootstrap
+// transport.
+func getVMMetadataTransport(transport vmwarev1.VirtualMachineBootstrapTransport) vmoprv1.VirtualMachineMetadataTransport {
+	switch transport {
+	case vmwarev1.VAppConfigBootstrapTransport:
+		return vmoprv1.VirtualMachineMetadataOvfEnvTransport
+	case vmwarev1.CloudInitBootstrapTransport:
+		return vmoprv1.VirtualMachineMetadataCloudInitTransport
+	default:
+		return vmoprv1.VirtualMachineMetadataExtraConfigTransport
+	}
+}
+
 func (v *VmopMachineService) reconcileNetwork(ctx *vmware.SupervisorMachineContext, vm *vmoprv1.VirtualMachine) bool {
 	if vm.Status.VmIp == "" {
 		return false
@@ -29,27 +29,30 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+	vmwareutil "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util/vmware"
 )
 
-func getBootstrapDataConfigMap(vmService VmopMachineService, ctx *vmware.SupervisorMachineContext) *corev1.ConfigMap {
-	cm := vmService.newBootstrapDataConfigMap(ctx)
+func getBootstrapDataSecret(vmService VmopMachineService, ctx *vmware.SupervisorMachineContext) *corev1.Secret {
+	secret := vmService.newBootstrapDataSecret(ctx)
 	nsname := types.NamespacedName{
-		Namespace: cm.Namespace,
-		Name:      cm.Name,
+		Namespace: secret.Namespace,
+		Name:      secret.Name,
 	}
-	err := ctx.Client.Get(ctx, nsname, cm)
+	err := ctx.Client.Get(ctx, nsname, secret)
 	if apierrors.IsNotFound(err) {
 		return nil
 	}
 	Expect(err).Should(BeNil())
-	return cm
+	return secret
 }
 
 func getReconciledVM(ctx *vmware.SupervisorMachineContext) *vmoprv1.VirtualMachine {
@@ -82,23 +85,23 @@ var _ = Describe("VirtualMachine tests", func() {
 		storageClass             = "test-storageClass"
 		vmIP                     = "127.0.0.1"
 		biosUUID                 = "test-biosUuid"
-		bootstrapDataFailure     = "failed to create or update bootstrap configmap"
+		bootstrapDataFailure     = "failed to create bootstrap secret"
 		missingK8SVersionFailure = "missing kubernetes version"
 	)
 	var (
 		bootstrapData = "test-bootstrap-data"
 
-		err                      error
-		requeue                  bool
-		expectedBiosUUID         string
-		expectedImageName        string
-		expectedVMIP             string
-		expectReconcileError     bool
-		expectBootstrapConfigMap bool
-		expectVMOpVM             bool
-		expectedState            vmwarev1.VirtualMachineState
-		expectedConditions       clusterv1.Conditions
-		expectedRequeue          bool
+		err                   error
+		requeue               bool
+		expectedBiosUUID      string
+		expectedImageName     string
+		expectedVMIP          string
+		expectReconcileError  bool
+		expectBootstrapSecret bool
+		expectVMOpVM          bool
+		expectedState         vmwarev1.VirtualMachineState
+		expectedConditions    clusterv1.Conditions
+		expectedRequeue       bool
 
 		cluster        *clusterv1.Cluster
 		vsphereCluster *vmwarev1.VSphereCluster
@@ -106,7 +109,7 @@ var _ = Describe("VirtualMachine tests", func() {
 		vsphereMachine *vmwarev1.VSphereMachine
 		ctx            *vmware.SupervisorMachineContext
 
-		cm *corev1.ConfigMap
+		bootstrapSecret *corev1.Secret
 		// vm     vmwarev1.VirtualMachine
 		vmopVM *vmoprv1.VirtualMachine
 
@@ -147,13 +150,15 @@ var _ = Describe("VirtualMachine tests", func() {
 			Expect(vsphereMachine.Status.IPAddr).Should(Equal(expectedVMIP))
 			Expect(vsphereMachine.Status.VMStatus).Should(Equal(expectedState))
 
-			cm = getBootstrapDataConfigMap(vmService, ctx)
-			Expect(cm != nil).Should(Equal(expectBootstrapConfigMap))
-			if cm != nil {
+			bootstrapSecret = getBootstrapDataSecret(vmService, ctx)
+			Expect(bootstrapSecret != nil).Should(Equal(expectBootstrapSecret))
+			if bootstrapSecret != nil {
 				expectedMeta, err := vmService.getGuestInfoMetadata(ctx)
 				Expect(err).Should(BeNil())
-				Expect(cm.Data["guestinfo.userdata"]).To(Equal(base64.StdEncoding.EncodeToString([]byte(bootstrapData))))
-				Expect(cm.Data["guestinfo.metadata"]).To(Equal(expectedMeta))
+				Expect(string(bootstrapSecret.Data["guestinfo.userdata"])).To(Equal(base64.StdEncoding.EncodeToString([]byte(bootstrapData))))
+				Expect(string(bootstrapSecret.Data["guestinfo.metadata"])).To(Equal(expectedMeta))
+				Expect(bootstrapSecret.Immutable).To(Equal(pointer.Bool(true)))
+				Expect(bootstrapSecret.OwnerReferences).To(HaveLen(1))
 			}
 
 			vmopVM = getReconciledVM(ctx)
@@ -166,6 +171,11 @@ var _ = Describe("VirtualMachine tests", func() {
 				Expect(vmopVM.Spec.ClassName).To(Equal(className))
 				Expect(vmopVM.Spec.StorageClass).To(Equal(storageClass))
 				Expect(vmopVM.Spec.PowerState).To(Equal(vmoprv1.VirtualMachinePoweredOn))
+				if bootstrapSecret != nil {
+					Expect(vmopVM.Spec.VmMetadata).NotTo(BeNil())
+					Expect(vmopVM.Spec.VmMetadata.SecretName).To(Equal(bootstrapSecret.Name))
+					Expect(vmopVM.Spec.VmMetadata.ConfigMapName).To(BeEmpty())
+				}
 				Expect(vmopVM.ObjectMeta.Annotations[ClusterModuleNameAnnotationKey]).To(Equal(ControlPlaneVMClusterModuleGroupName))
 				Expect(vmopVM.ObjectMeta.Annotations[ProviderTagsAnnotationKey]).To(Equal(ControlPlaneVMVMAntiAffinityTagValue))
 
@@ -193,7 +203,7 @@ var _ = Describe("VirtualMachine tests", func() {
 			// Reconcile should return an error up and until all prerequisites have been met
 			expectReconcileError = true
 			// There should be no bootstrap config until the prerequisites have been met
-			expectBootstrapConfigMap = false
+			expectBootstrapSecret = false
 			// A vmoperator VM should be created unless there is an error in configuration
 			expectVMOpVM = true
 			// We will mutate this later in the test
@@ -212,8 +222,8 @@ var _ = Describe("VirtualMachine tests", func() {
 			requeue, err = vmService.ReconcileNormal(ctx)
 			verifyOutput(ctx)
 
-			// Provide valid bootstrap data. Expect a bootstrap data ConfigMap
-			By("bootstrap data ConfigMap is created")
+			// Provide valid bootstrap data. Expect a bootstrap data Secret
+			By("bootstrap data Secret is created")
 			secretName := machine.GetName() + "-data"
 			secret := &corev1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
@@ -227,7 +237,7 @@ var _ = Describe("VirtualMachine tests", func() {
 			Expect(ctx.Client.Create(ctx, secret)).To(Succeed())
 
 			machine.Spec.Bootstrap.DataSecretName = &secretName
-			expectBootstrapConfigMap = true
+			expectBootstrapSecret = true
 			// we expect the reconciliation waiting for VM to be created
 			expectedConditions[0].Reason = vmwarev1.VMProvisionStartedReason
 			expectedConditions[0].Message = ""
@@ -299,6 +309,8 @@ var _ = Describe("VirtualMachine tests", func() {
 					return vm, nil
 				},
 			}
+			// The bootstrap data Secret is deleted once the machine is ready
+			expectBootstrapSecret = false
 			requeue, err = vmService.ReconcileNormal(ctx)
 			verifyOutput(ctx)
 		})
@@ -308,14 +320,14 @@ var _ = Describe("VirtualMachine tests", func() {
 			expectedRequeue = true
 			expectReconcileError = false
 			// There should be no bootstrap config until the prerequisites have been met
-			expectBootstrapConfigMap = false
+			expectBootstrapSecret = false
 			// A vmoperator VM should be created unless there is an error in configuration
 			expectVMOpVM = true
 			// We will mutate this later in the test
 			expectedImageName = imageName
 
-			// Provide valid bootstrap data. Expect a bootstrap data ConfigMap
-			By("bootstrap data ConfigMap is created")
+			// Provide valid bootstrap data. Expect a bootstrap data Secret
+			By("bootstrap data Secret is created")
 			secretName := machine.GetName() + "-data"
 			secret := &corev1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
@@ -329,7 +341,7 @@ var _ = Describe("VirtualMachine tests", func() {
 			Expect(ctx.Client.Create(ctx, secret)).To(Succeed())
 
 			machine.Spec.Bootstrap.DataSecretName = &secretName
-			expectBootstrapConfigMap = true
+			expectBootstrapSecret = true
 			expectedConditions = append(expectedConditions, clusterv1.Condition{
 				Type:    infrav1.VMProvisionedCondition,
 				Status:  corev1.ConditionFalse,
@@ -388,6 +400,7 @@ var _ = Describe("VirtualMachine tests", func() {
 			By("Setting cluster.Status.ControlPlaneReady to true")
 			// Set the control plane to be ready so that the new VM will have a probe
 			cluster.Status.ControlPlaneReady = true
+			expectBootstrapSecret = false
 
 			vmopVM = getReconciledVM(ctx)
 			vmopVM.Status.VmIp = vmIP
@@ -400,7 +413,7 @@ var _ = Describe("VirtualMachine tests", func() {
 
 		Specify("Reconcile invalid Machine", func() {
 			expectReconcileError = true
-			expectBootstrapConfigMap = false
+			expectBootstrapSecret = false
 			expectVMOpVM = false
 			expectedImageName = imageName
 
@@ -445,7 +458,7 @@ var _ = Describe("VirtualMachine tests", func() {
 			requeue, err = vmService.ReconcileNormal(ctx)
 
 			expectedImageName = imageName
-			expectBootstrapConfigMap = true
+			expectBootstrapSecret = true
 			expectReconcileError = true
 			expectVMOpVM = true
 			expectedConditions = append(expectedConditions, clusterv1.Condition{
@@ -460,7 +473,7 @@ var _ = Describe("VirtualMachine tests", func() {
 
 		Specify("Preserve changes made by other sources", func() {
 			expectReconcileError = true
-			expectBootstrapConfigMap = false
+			expectBootstrapSecret = false
 			expectVMOpVM = true
 			expectedImageName = imageName
 
@@ -494,7 +507,7 @@ var _ = Describe("VirtualMachine tests", func() {
 
 		Specify("Create and attach volumes", func() {
 			expectReconcileError = true
-			expectBootstrapConfigMap = false
+			expectBootstrapSecret = false
 			expectVMOpVM = true
 			expectedImageName = imageName
 
@@ -539,6 +552,128 @@ var _ = Describe("VirtualMachine tests", func() {
 		})
 	})
 
+	Context("Bootstrap transports", func() {
+		createBootstrapSecret := func(format string) {
+			secretName := machine.GetName() + "-data"
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      secretName,
+					Namespace: machine.GetNamespace(),
+				},
+				Data: map[string][]byte{
+					"value": []byte(bootstrapData),
+				},
+			}
+			if format != "" {
+				secret.Data["format"] = []byte(format)
+			}
+			Expect(ctx.Client.Create(ctx, secret)).To(Succeed())
+			machine.Spec.Bootstrap.DataSecretName = &secretName
+		}
+
+		reconcileWithTransport := func(transport vmwarev1.VirtualMachineBootstrapTransport) (*corev1.Secret, *vmoprv1.VirtualMachine) {
+			vsphereMachine.Spec.BootstrapTransport = transport
+
+			_, err = vmService.ReconcileNormal(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			bootstrapSecret := getBootstrapDataSecret(vmService, ctx)
+			Expect(bootstrapSecret).NotTo(BeNil())
+			vmopVM := getReconciledVM(ctx)
+			Expect(vmopVM).NotTo(BeNil())
+			Expect(vmopVM.Spec.VmMetadata).NotTo(BeNil())
+			Expect(vmopVM.Spec.VmMetadata.SecretName).To(Equal(bootstrapSecret.Name))
+			return bootstrapSecret, vmopVM
+		}
+
+		It("delivers the bootstrap data in ExtraConfig by default", func() {
+			createBootstrapSecret("")
+			bootstrapSecret, vmopVM := reconcileWithTransport("")
+			Expect(vmopVM.Spec.VmMetadata.Transport).To(Equal(vmoprv1.VirtualMachineMetadataExtraConfigTransport))
+			Expect(bootstrapSecret.Data).To(HaveKeyWithValue("guestinfo.userdata", []byte(base64.StdEncoding.EncodeToString([]byte(bootstrapData)))))
+			Expect(bootstrapSecret.Data).To(HaveKey("guestinfo.metadata"))
+		})
+
+		It("delivers the bootstrap data in OVF vApp properties", func() {
+			createBootstrapSecret("")
+			bootstrapSecret, vmopVM := reconcileWithTransport(vmwarev1.VAppConfigBootstrapTransport)
+			Expect(vmopVM.Spec.VmMetadata.Transport).To(Equal(vmoprv1.VirtualMachineMetadataOvfEnvTransport))
+			Expect(bootstrapSecret.Data).To(Equal(map[string][]byte{
+				"user-data":   []byte(base64.StdEncoding.EncodeToString([]byte(bootstrapData))),
+				"instance-id": []byte(machineName),
+				"hostname":    []byte(machineName),
+			}))
+		})
+
+		It("delivers the bootstrap data to the cloud-init datasource", func() {
+			createBootstrapSecret("")
+			bootstrapSecret, vmopVM := reconcileWithTransport(vmwarev1.CloudInitBootstrapTransport)
+			Expect(vmopVM.Spec.VmMetadata.Transport).To(Equal(vmoprv1.VirtualMachineMetadataCloudInitTransport))
+			Expect(bootstrapSecret.Data).To(Equal(map[string][]byte{
+				"user-data": []byte(bootstrapData),
+			}))
+		})
+
+		It("delivers Ignition bootstrap data in ExtraConfig", func() {
+			createBootstrapSecret("ignition")
+			bootstrapSecret, vmopVM := reconcileWithTransport("")
+			Expect(vmopVM.Spec.VmMetadata.Transport).To(Equal(vmoprv1.VirtualMachineMetadataExtraConfigTransport))
+			Expect(bootstrapSecret.Data).To(Equal(map[string][]byte{
+				"guestinfo.ignition.config.data":          []byte(base64.StdEncoding.EncodeToString([]byte(bootstrapData))),
+				"guestinfo.ignition.config.data.encoding": []byte("base64"),
+			}))
+		})
+
+		It("recreates a bootstrap data Secret with stale data", func() {
+			createBootstrapSecret("")
+			bootstrapSecret, _ := reconcileWithTransport("")
+			Expect(bootstrapSecret.Data).To(HaveKey("guestinfo.userdata"))
+
+			bootstrapSecret, _ = reconcileWithTransport(vmwarev1.CloudInitBootstrapTransport)
+			Expect(bootstrapSecret.Data).To(Equal(map[string][]byte{
+				"user-data": []byte(bootstrapData),
+			}))
+			Expect(bootstrapSecret.Immutable).To(Equal(pointer.Bool(true)))
+		})
+
+		It("deletes the bootstrap data ConfigMap of ready VMs", func() {
+			createBootstrapSecret("")
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      vmwareutil.GetBootstrapConfigMapName(vsphereMachine.Name),
+					Namespace: machine.GetNamespace(),
+				},
+			}
+			Expect(ctx.Client.Create(ctx, configMap)).To(Succeed())
+			vmMetadata := &vmoprv1.VirtualMachineMetadata{
+				ConfigMapName: configMap.Name,
+				Transport:     vmoprv1.VirtualMachineMetadataExtraConfigTransport,
+			}
+			vm := vmService.newVMOperatorVM(ctx)
+			vm.Spec.VmMetadata = vmMetadata
+			Expect(ctx.Client.Create(ctx, vm)).To(Succeed())
+			vsphereMachine.Status.Ready = true
+
+			_, err = vmService.ReconcileNormal(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			vmopVM := getReconciledVM(ctx)
+			Expect(vmopVM).NotTo(BeNil())
+			Expect(vmopVM.Spec.VmMetadata).To(BeNil())
+			err = ctx.Client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("does not deliver Ignition bootstrap data to the cloud-init datasource", func() {
+			createBootstrapSecret("ignition")
+			vsphereMachine.Spec.BootstrapTransport = vmwarev1.CloudInitBootstrapTransport
+
+			_, err = vmService.ReconcileNormal(ctx)
+			Expect(err).To(MatchError(ContainSubstring("bootstrap data format ignition is not supported by bootstrap transport CloudInit")))
+			Expect(getBootstrapDataSecret(vmService, ctx)).To(BeNil())
+		})
+	})
+
 	Context("Delete tests", func() {
 		timeout := time.Second * 5
 		interval := time.Second * 1
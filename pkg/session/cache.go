@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// propertyCacheTTL is the duration for which the retrieved properties of
+	// a VM are used before they are retrieved again.
+	propertyCacheTTL = 30 * time.Second
+
+	// propertyCacheTrackTTL is the duration for which the properties of a VM
+	// are retrieved along with the properties of other VMs after they were
+	// last requested.
+	propertyCacheTrackTTL = 5 * time.Minute
+
+	// propertyCacheMaxBatch is the maximum number of VMs whose properties are
+	// retrieved with a single call to the property collector.
+	propertyCacheMaxBatch = 100
+)
+
+// VMProperties are the properties of VMs retrieved by the property cache.
+// The extra config of a VM is retrieved separately, see ExtraConfig.
+var VMProperties = []string{
+	"config.cpuHotAddEnabled",
+	"config.cpuHotRemoveEnabled",
+	"config.hardware",
+	"config.memoryHotAddEnabled",
+	"config.uuid",
+	"guest.net",
+	"runtime.bootTime",
+	"runtime.powerState",
+}
+
+// PropertyCache caches the properties of VMs. When the properties of a VM
+// have to be retrieved, the properties of other VMs requested recently whose
+// cached properties expired are retrieved along with them, with a single call
+// to the property collector. Concurrent requests for VMs whose properties are
+// being retrieved wait for that retrieval.
+type PropertyCache struct {
+	client *vim25.Client
+	server string
+
+	mu      sync.Mutex
+	entries map[types.ManagedObjectReference]*propertyCacheEntry
+}
+
+type propertyCacheEntry struct {
+	vm          *mo.VirtualMachine
+	retrieved   time.Time
+	lastRequest time.Time
+	invalidated time.Time
+
+	// fetch is closed once the in-flight retrieval of the properties
+	// completes, with fetchErr as its error.
+	fetch    chan struct{}
+	fetchErr error
+
+	extraConfig          []types.BaseOptionValue
+	extraConfigRetrieved time.Time
+}
+
+func newPropertyCache(client *vim25.Client, server string) *PropertyCache {
+	return &PropertyCache{
+		client:  client,
+		server:  server,
+		entries: map[types.ManagedObjectReference]*propertyCacheEntry{},
+	}
+}
+
+// VirtualMachine returns the VMProperties of the VM. The returned object is
+// shared and must not be modified.
+func (c *PropertyCache) VirtualMachine(ctx context.Context, ref types.ManagedObjectReference) (*mo.VirtualMachine, error) {
+	c.mu.Lock()
+	now := time.Now()
+	entry := c.entry(ref)
+	entry.lastRequest = now
+	if entry.vm != nil && now.Sub(entry.retrieved) < propertyCacheTTL {
+		c.mu.Unlock()
+		cacheLookups.WithLabelValues(c.server, "properties", "hit").Inc()
+		return entry.vm, nil
+	}
+	cacheLookups.WithLabelValues(c.server, "properties", "miss").Inc()
+
+	if fetch := entry.fetch; fetch != nil {
+		c.mu.Unlock()
+		select {
+		case <-fetch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+		vm, err := entry.vm, entry.fetchErr
+		c.mu.Unlock()
+		if vm != nil || err != nil {
+			return vm, err
+		}
+		// The VM was invalidated while its properties were retrieved.
+		return c.VirtualMachine(ctx, ref)
+	}
+
+	refs := []types.ManagedObjectReference{ref}
+	for other, e := range c.entries {
+		switch {
+		case other == ref || e.fetch != nil:
+		case now.Sub(e.lastRequest) > propertyCacheTrackTTL:
+			delete(c.entries, other)
+		case (e.vm == nil || now.Sub(e.retrieved) >= propertyCacheTTL) && len(refs) < propertyCacheMaxBatch:
+			refs = append(refs, other)
+		}
+	}
+	fetch := make(chan struct{})
+	for _, r := range refs {
+		c.entries[r].fetch = fetch
+	}
+	c.mu.Unlock()
+
+	vms, notFound, err := c.retrieve(ctx, ref, refs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(fetch)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to fetch props %v for vm %v", VMProperties, ref)
+	}
+	for _, r := range refs {
+		if e, ok := c.entries[r]; ok && e.fetch == fetch {
+			e.fetch = nil
+			e.fetchErr = err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range notFound {
+		delete(c.entries, r)
+	}
+
+	var result *mo.VirtualMachine
+	for i := range vms {
+		if vms[i].Self == ref {
+			result = &vms[i]
+		}
+		// Properties retrieved before the VM was invalidated are not cached.
+		if e, ok := c.entries[vms[i].Self]; ok && e.invalidated.Before(now) {
+			e.vm = &vms[i]
+			e.retrieved = now
+		}
+	}
+	if result == nil {
+		return nil, errors.Errorf("unable to fetch props %v for vm %v", VMProperties, ref)
+	}
+	return result, nil
+}
+
+// retrieve retrieves the VMProperties of the VMs. The retrieval fails if any
+// of the VMs no longer exists. It is retried without the VM, unless it is the
+// requested one, and the VMs which no longer exist are returned.
+func (c *PropertyCache) retrieve(ctx context.Context, ref types.ManagedObjectReference, refs []types.ManagedObjectReference) ([]mo.VirtualMachine, []types.ManagedObjectReference, error) {
+	refs = append([]types.ManagedObjectReference{}, refs...)
+	var notFound []types.ManagedObjectReference
+	for {
+		var vms []mo.VirtualMachine
+		propertyRetrievals.WithLabelValues(c.server).Inc()
+		propertyRetrievalObjects.WithLabelValues(c.server).Observe(float64(len(refs)))
+		err := property.DefaultCollector(c.client).Retrieve(ctx, refs, VMProperties, &vms)
+		if err != nil {
+			if obj, ok := managedObjectNotFound(err); ok && obj != ref {
+				notFound = append(notFound, obj)
+				refs = removeRef(refs, obj)
+				continue
+			}
+			return nil, nil, err
+		}
+		return vms, notFound, nil
+	}
+}
+
+// ExtraConfig returns the extra config of the VM. It is only retrieved for
+// the requested VM, as it may be large. The returned values are shared and
+// must not be modified.
+func (c *PropertyCache) ExtraConfig(ctx context.Context, ref types.ManagedObjectReference) ([]types.BaseOptionValue, error) {
+	c.mu.Lock()
+	now := time.Now()
+	entry := c.entry(ref)
+	entry.lastRequest = now
+	if entry.extraConfigRetrieved.After(entry.invalidated) && now.Sub(entry.extraConfigRetrieved) < propertyCacheTTL {
+		extraConfig := entry.extraConfig
+		c.mu.Unlock()
+		cacheLookups.WithLabelValues(c.server, "extraconfig", "hit").Inc()
+		return extraConfig, nil
+	}
+	c.mu.Unlock()
+	cacheLookups.WithLabelValues(c.server, "extraconfig", "miss").Inc()
+
+	var vm mo.VirtualMachine
+	propertyRetrievals.WithLabelValues(c.server).Inc()
+	propertyRetrievalObjects.WithLabelValues(c.server).Observe(1)
+	if err := property.DefaultCollector(c.client).RetrieveOne(ctx, ref, []string{"config.extraConfig"}, &vm); err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch extra config for vm %v", ref)
+	}
+	var extraConfig []types.BaseOptionValue
+	if vm.Config != nil {
+		extraConfig = vm.Config.ExtraConfig
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[ref]; ok && entry.invalidated.Before(now) {
+		entry.extraConfig = extraConfig
+		entry.extraConfigRetrieved = now
+	}
+	return extraConfig, nil
+}
+
+// entry returns the entry of the VM, which is added if it does not exist.
+// The caller must hold the lock.
+func (c *PropertyCache) entry(ref types.ManagedObjectReference) *propertyCacheEntry {
+	entry, ok := c.entries[ref]
+	if !ok {
+		entry = &propertyCacheEntry{}
+		c.entries[ref] = entry
+	}
+	return entry
+}
+
+// Invalidate removes the cached properties of the VM, so that they are
+// retrieved again when they are next requested.
+func (c *PropertyCache) Invalidate(ref types.ManagedObjectReference) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[ref]; ok {
+		entry.vm = nil
+		entry.extraConfig = nil
+		entry.invalidated = time.Now()
+	}
+}
+
+func removeRef(refs []types.ManagedObjectReference, ref types.ManagedObjectReference) []types.ManagedObjectReference {
+	result := refs[:0]
+	for _, r := range refs {
+		if r != ref {
+			result = append(result, r)
+		}
+	}
+	return result
+}
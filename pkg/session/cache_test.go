@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestPropertyCache(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := vcsim.NewBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	server := simr.ServerURL().Host
+	s, err := GetOrCreate(context.Background(), NewParams().
+		WithInsecure(true).
+		WithServer(server).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	vms, err := s.Finder.VirtualMachineList(context.Background(), "*")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(vms)).To(BeNumerically(">=", 2))
+	first, second := vms[0].Reference(), vms[1].Reference()
+
+	retrievals := func() float64 {
+		return testutil.ToFloat64(propertyRetrievals.WithLabelValues(server))
+	}
+
+	t.Run("caches the properties", func(t *testing.T) {
+		g := NewWithT(t)
+		vm, err := s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vm.Self).To(Equal(first))
+		g.Expect(vm.Runtime.PowerState).To(Equal(types.VirtualMachinePowerStatePoweredOn))
+		g.Expect(vm.Config).NotTo(BeNil())
+		g.Expect(retrievals()).To(Equal(1.0))
+
+		cached, err := s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cached).To(BeIdenticalTo(vm))
+		g.Expect(retrievals()).To(Equal(1.0))
+	})
+
+	t.Run("retrieves the properties of expired VMs in one call", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := s.Properties.VirtualMachine(context.Background(), second)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(retrievals()).To(Equal(2.0))
+
+		s.Properties.Invalidate(first)
+		s.Properties.Invalidate(second)
+		_, err = s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = s.Properties.VirtualMachine(context.Background(), second)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(retrievals()).To(Equal(3.0))
+	})
+
+	t.Run("retrieves the properties once for concurrent requests", func(t *testing.T) {
+		g := NewWithT(t)
+		s.Properties.Invalidate(first)
+		s.Properties.Invalidate(second)
+		before := retrievals()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Properties.VirtualMachine(context.Background(), first)
+				g.Expect(err).NotTo(HaveOccurred())
+			}()
+		}
+		wg.Wait()
+		g.Expect(retrievals()).To(Equal(before + 1))
+	})
+
+	t.Run("retrieves the extra config of the requested VM only", func(t *testing.T) {
+		g := NewWithT(t)
+		vm, err := s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vm.Config.ExtraConfig).To(BeEmpty())
+		before := retrievals()
+
+		extraConfig, err := s.Properties.ExtraConfig(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(extraConfig).NotTo(BeEmpty())
+		_, err = s.Properties.ExtraConfig(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(retrievals()).To(Equal(before + 1))
+
+		s.Properties.Invalidate(first)
+		_, err = s.Properties.ExtraConfig(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(retrievals()).To(Equal(before + 3))
+	})
+
+	t.Run("reports the changes after the VM is invalidated", func(t *testing.T) {
+		g := NewWithT(t)
+		task, err := vms[0].PowerOff(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+
+		vm, err := s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vm.Runtime.PowerState).To(Equal(types.VirtualMachinePowerStatePoweredOn))
+
+		s.Properties.Invalidate(first)
+		vm, err = s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vm.Runtime.PowerState).To(Equal(types.VirtualMachinePowerStatePoweredOff))
+	})
+
+	t.Run("skips deleted VMs", func(t *testing.T) {
+		g := NewWithT(t)
+		task, err := vms[1].PowerOff(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+		task, err = vms[1].Destroy(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+
+		s.Properties.Invalidate(first)
+		s.Properties.Invalidate(second)
+		_, err = s.Properties.VirtualMachine(context.Background(), first)
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = s.Properties.VirtualMachine(context.Background(), second)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	simr, err := vcsim.NewBuilder().WithModel(model).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	server := simr.ServerURL().Host
+	s, err := GetOrCreate(context.Background(), NewParams().
+		WithInsecure(true).
+		WithServer(server).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	lookups := func(result string) float64 {
+		return testutil.ToFloat64(cacheLookups.WithLabelValues(server, "inventory", result))
+	}
+
+	pool, err := s.Inventory.ResourcePoolOrDefault(context.Background(), "")
+	g.Expect(err).NotTo(HaveOccurred())
+	cached, err := s.Inventory.ResourcePoolOrDefault(context.Background(), "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cached).To(BeIdenticalTo(pool))
+	g.Expect(lookups("miss")).To(Equal(1.0))
+	g.Expect(lookups("hit")).To(Equal(1.0))
+
+	_, err = s.Inventory.Network(context.Background(), "does-not-exist")
+	g.Expect(err).To(HaveOccurred())
+	_, err = s.Inventory.Network(context.Background(), "does-not-exist")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(lookups("miss")).To(Equal(3.0))
+
+	s.Inventory.Invalidate(pool.Reference())
+	found, err := s.Inventory.ResourcePoolOrDefault(context.Background(), "")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(found).NotTo(BeIdenticalTo(pool))
+	g.Expect(found.Reference()).To(Equal(pool.Reference()))
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// inventoryCacheTTL is the duration for which the objects found in the
+// inventory are cached.
+const inventoryCacheTTL = 5 * time.Minute
+
+// Inventory finds objects in the inventory of the session's datacenter, like
+// the Finder, and caches the objects it found. Objects which were not found
+// are not cached.
+type Inventory struct {
+	finder *find.Finder
+	server string
+
+	mu      sync.Mutex
+	entries map[inventoryKey]inventoryEntry
+}
+
+type inventoryKey struct {
+	kind string
+	path string
+}
+
+type inventoryEntry struct {
+	obj   object.Reference
+	found time.Time
+}
+
+func newInventory(finder *find.Finder, server string) *Inventory {
+	return &Inventory{
+		finder:  finder,
+		server:  server,
+		entries: map[inventoryKey]inventoryEntry{},
+	}
+}
+
+// FolderOrDefault finds the folder at the given path, or the default folder
+// if the path is empty.
+func (i *Inventory) FolderOrDefault(ctx context.Context, path string) (*object.Folder, error) {
+	obj, err := i.find("folder", path, func() (object.Reference, error) {
+		return i.finder.FolderOrDefault(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.Folder), nil //nolint:forcetypeassert
+}
+
+// ResourcePoolOrDefault finds the resource pool at the given path, or the
+// default resource pool if the path is empty.
+func (i *Inventory) ResourcePoolOrDefault(ctx context.Context, path string) (*object.ResourcePool, error) {
+	obj, err := i.find("resourcepool", path, func() (object.Reference, error) {
+		return i.finder.ResourcePoolOrDefault(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.ResourcePool), nil //nolint:forcetypeassert
+}
+
+// DatastoreOrDefault finds the datastore at the given path, or the default
+// datastore if the path is empty.
+func (i *Inventory) DatastoreOrDefault(ctx context.Context, path string) (*object.Datastore, error) {
+	obj, err := i.find("datastore", path, func() (object.Reference, error) {
+		return i.finder.DatastoreOrDefault(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.Datastore), nil //nolint:forcetypeassert
+}
+
+// DatastoreCluster finds the datastore cluster at the given path.
+func (i *Inventory) DatastoreCluster(ctx context.Context, path string) (*object.StoragePod, error) {
+	obj, err := i.find("datastorecluster", path, func() (object.Reference, error) {
+		return i.finder.DatastoreCluster(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.StoragePod), nil //nolint:forcetypeassert
+}
+
+// Network finds the network at the given path.
+func (i *Inventory) Network(ctx context.Context, path string) (object.NetworkReference, error) {
+	obj, err := i.find("network", path, func() (object.Reference, error) {
+		return i.finder.Network(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(object.NetworkReference), nil //nolint:forcetypeassert
+}
+
+// VirtualMachine finds the VM at the given path, such as a template. It must
+// not be used to find VMs which may be destroyed by CAPV.
+func (i *Inventory) VirtualMachine(ctx context.Context, path string) (*object.VirtualMachine, error) {
+	obj, err := i.find("virtualmachine", path, func() (object.Reference, error) {
+		return i.finder.VirtualMachine(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*object.VirtualMachine), nil //nolint:forcetypeassert
+}
+
+// Invalidate removes the cached entries of the object, so that it is found
+// again when it is next requested.
+func (i *Inventory) Invalidate(ref types.ManagedObjectReference) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, entry := range i.entries {
+		if entry.obj.Reference() == ref {
+			delete(i.entries, key)
+		}
+	}
+}
+
+// Reset removes all cached entries.
+func (i *Inventory) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.entries = map[inventoryKey]inventoryEntry{}
+}
+
+func (i *Inventory) find(kind, path string, lookup func() (object.Reference, error)) (object.Reference, error) {
+	key := inventoryKey{kind: kind, path: path}
+
+	i.mu.Lock()
+	entry, ok := i.entries[key]
+	i.mu.Unlock()
+	if ok && time.Since(entry.found) < inventoryCacheTTL {
+		cacheLookups.WithLabelValues(i.server, "inventory", "hit").Inc()
+		return entry.obj, nil
+	}
+	cacheLookups.WithLabelValues(i.server, "inventory", "miss").Inc()
+
+	obj, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	i.entries[key] = inventoryEntry{obj: obj, found: time.Now()}
+	i.mu.Unlock()
+	return obj, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "capv"
+	metricsSubsystem = "session"
+)
+
+var (
+	activeSessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "active",
+		Help:      "Number of cached vSphere sessions per server.",
+	}, []string{"server"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "logins_total",
+		Help:      "Total number of vSphere logins per server.",
+	}, []string{"server"})
+
+	loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "login_failures_total",
+		Help:      "Total number of failed vSphere logins per server.",
+	}, []string{"server"})
+
+	keepAliveFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "keepalive_failures_total",
+		Help:      "Total number of failed vSphere session keep alive requests per server.",
+	}, []string{"server"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "cache_lookups_total",
+		Help:      "Total number of lookups in the property and inventory caches of vSphere sessions per server, cache and result.",
+	}, []string{"server", "cache", "result"})
+
+	propertyRetrievals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "property_retrievals_total",
+		Help:      "Total number of VM property retrievals made by the property cache per server.",
+	}, []string{"server"})
+
+	propertyRetrievalObjects = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "property_retrieval_objects",
+		Help:      "Number of VMs whose properties are retrieved with a single property retrieval per server.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100},
+	}, []string{"server"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(activeSessions, logins, loginFailures, keepAliveFailures,
+		cacheLookups, propertyRetrievals, propertyRetrievalObjects)
+}
@@ -18,8 +18,14 @@ package session
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"net/url"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -29,6 +35,8 @@ import (
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/session"
 	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vapi/library"
 	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vapi/tags"
 	"github.com/vmware/govmomi/vim25"
@@ -37,22 +45,82 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/tracing"
 )
 
 // global Session map against sessionKeys
 // in map[sessionKey]Session.
 var sessionCache sync.Map
 
+// sessionKey identifies a cached session. It includes a hash of the
+// credentials, so that a session is not reused once they change, and a hash
+// of the settings the server's certificate is verified with, so that a
+// session is not reused with settings it was not verified with.
+type sessionKey struct {
+	server         string
+	username       string
+	datacenter     string
+	credentialHash string
+	trustHash      string
+}
+
+func newSessionKey(params *Params) sessionKey {
+	return sessionKey{
+		server:         params.server,
+		username:       params.principal(),
+		datacenter:     params.datacenter,
+		credentialHash: params.credentialHash(),
+		trustHash:      params.trustHash(),
+	}
+}
+
+// trustHash returns a hash of the settings in the params the server's
+// certificate is verified with.
+func (p *Params) trustHash() string {
+	hash := sha256.New()
+	hash.Write([]byte(p.thumbprint + "\x00" + strconv.FormatBool(p.insecure) + "\x00"))
+	hash.Write(p.caBundle)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 // Session is a vSphere session with a configured Finder.
 type Session struct {
 	*govmomi.Client
 	Finder     *find.Finder
 	datacenter *object.Datacenter
 	TagManager *tags.Manager
+
+	// Properties caches the properties of VMs, see PropertyCache.
+	Properties *PropertyCache
+	// Inventory caches the objects found in the inventory, see Inventory.
+	Inventory *Inventory
+
+	// libraryItems caches the content library items resolved from the
+	// templates of VSphereVMs, see LibraryItem.
+	libraryItems sync.Map
+
+	// lastUsed is the time, in Unix nanoseconds, at which the session was
+	// last returned by GetOrCreate.
+	lastUsed int64
+	idleTTL  time.Duration
+	// created is the time at which the session was logged in.
+	created time.Time
+
+	// signer holds the SAML token the session was logged in with, if any.
+	signer *sts.Signer
+
+	// watcher watches the objects managed through the session, see Watcher.
+	watcherMu sync.Mutex
+	watcher   *Watcher
 }
 
 type Feature struct {
 	KeepAliveDuration time.Duration
+
+	// IdleTTL is the duration after which a cached session which has not
+	// been used is logged out and evicted from the cache. Cached sessions
+	// are never evicted for being idle if zero.
+	IdleTTL time.Duration
 }
 
 func DefaultFeature() Feature {
@@ -60,11 +128,15 @@ func DefaultFeature() Feature {
 }
 
 type Params struct {
-	server     string
-	datacenter string
-	userinfo   *url.Userinfo
-	thumbprint string
-	feature    Feature
+	server      string
+	datacenter  string
+	userinfo    *url.Userinfo
+	certificate *tls.Certificate
+	token       string
+	thumbprint  string
+	caBundle    []byte
+	insecure    bool
+	feature     Feature
 }
 
 func NewParams() *Params {
@@ -88,11 +160,39 @@ func (p *Params) WithUserInfo(username, password string) *Params {
 	return p
 }
 
+// WithCertificate sets the solution user certificate for which SAML tokens
+// are issued to log in, instead of the user info.
+func (p *Params) WithCertificate(certificate *tls.Certificate) *Params {
+	p.certificate = certificate
+	return p
+}
+
+// WithToken sets the SAML bearer token used to log in, instead of the user
+// info or the certificate.
+func (p *Params) WithToken(token string) *Params {
+	p.token = token
+	return p
+}
+
 func (p *Params) WithThumbprint(thumbprint string) *Params {
 	p.thumbprint = thumbprint
 	return p
 }
 
+// WithCABundle sets the PEM encoded certificate authorities used to verify
+// the server's certificate instead of the system's certificate authorities.
+func (p *Params) WithCABundle(caBundle []byte) *Params {
+	p.caBundle = caBundle
+	return p
+}
+
+// WithInsecure allows connecting to the server without verifying its
+// certificate when neither a thumbprint nor a CA bundle is set.
+func (p *Params) WithInsecure(insecure bool) *Params {
+	p.insecure = insecure
+	return p
+}
+
 func (p *Params) WithFeatures(feature Feature) *Params {
 	p.feature = feature
 	return p
@@ -103,7 +203,9 @@ func (p *Params) WithFeatures(feature Feature) *Params {
 func GetOrCreate(ctx context.Context, params *Params) (*Session, error) {
 	logger := ctrl.LoggerFrom(ctx).WithName("session")
 
-	sessionKey := params.server + params.userinfo.Username() + params.datacenter
+	startJanitor()
+
+	sessionKey := newSessionKey(params)
 	if cachedSession, ok := sessionCache.Load(sessionKey); ok {
 		s := cachedSession.(*Session)
 		logger = logger.WithValues("server", params.server, "datacenter", params.datacenter)
@@ -113,13 +215,24 @@ func GetOrCreate(ctx context.Context, params *Params) (*Session, error) {
 			logger.Error(err, "unable to check if vim session is active")
 		}
 
-		tagManagerSession, err := s.TagManager.Session(ctx)
-		if err != nil {
-			logger.Error(err, "unable to check if rest session is active")
+		// ESXi hosts do not have a tag manager, see below.
+		tagManagerActive := s.TagManager == nil
+		if s.TagManager != nil {
+			tagManagerSession, err := s.TagManager.Session(ctx)
+			if err != nil {
+				logger.Error(err, "unable to check if rest session is active")
+			}
+			tagManagerActive = tagManagerSession != nil
+		}
+
+		tokenExpiresSoon := s.tokenExpiresSoon()
+		if tokenExpiresSoon {
+			logger.V(2).Info("token of cached vSphere client session expires soon, renewing session")
 		}
 
-		if vimSessionActive && tagManagerSession != nil {
+		if vimSessionActive && tagManagerActive && !tokenExpiresSoon {
 			logger.V(2).Info("found active cached vSphere client session")
+			s.touch()
 			return s, nil
 		}
 	}
@@ -134,22 +247,25 @@ func GetOrCreate(ctx context.Context, params *Params) (*Session, error) {
 	}
 
 	soapURL.User = params.userinfo
-	client, err := newClient(ctx, logger, sessionKey, soapURL, params.thumbprint, params.feature)
+	client, signer, err := newClient(ctx, logger, sessionKey, soapURL, params)
 	if err != nil {
 		return nil, err
 	}
 
-	session := Session{Client: client}
+	session := Session{Client: client, idleTTL: params.feature.IdleTTL, created: time.Now(), signer: signer}
 	session.UserAgent = v1beta1.GroupVersion.String()
 
 	// Assign the finder to the session.
 	session.Finder = find.NewFinder(session.Client.Client, false)
-	// Assign tag manager to the session.
-	manager, err := newManager(ctx, logger, sessionKey, client.Client, soapURL.User, params.feature)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to create tags manager")
+	// Assign tag manager to the session. The tagging API is only available
+	// on vCenter, standalone ESXi hosts do not expose it.
+	if client.IsVC() {
+		manager, err := newManager(ctx, logger, sessionKey, client.Client, soapURL.User, signer, params.feature)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create tags manager")
+		}
+		session.TagManager = manager
 	}
-	session.TagManager = manager
 
 	// Assign the datacenter if one was specified.
 	if params.datacenter != "" {
@@ -160,24 +276,41 @@ func GetOrCreate(ctx context.Context, params *Params) (*Session, error) {
 		session.datacenter = dc
 		session.Finder.SetDatacenter(dc)
 	}
+	session.Properties = newPropertyCache(session.Client.Client, sessionKey.server)
+	session.Inventory = newInventory(session.Finder, sessionKey.server)
+
 	// Cache the session.
+	session.touch()
 	sessionCache.Store(sessionKey, &session)
+	updateActiveSessions(sessionKey.server)
 
 	logger.V(2).Info("cached vSphere client session", "server", params.server, "datacenter", params.datacenter)
 
 	return &session, nil
 }
 
-func newClient(ctx context.Context, logger logr.Logger, sessionKey string, url *url.URL, thumbprint string, feature Feature) (*govmomi.Client, error) {
-	insecure := thumbprint == ""
+func newClient(ctx context.Context, logger logr.Logger, sessionKey sessionKey, url *url.URL, params *Params) (*govmomi.Client, *sts.Signer, error) {
+	// The server's certificate is verified against the given thumbprint,
+	// the given CA bundle or the system's certificate authorities, in this
+	// order. It is only skipped if explicitly allowed.
+	insecure := params.thumbprint == "" && len(params.caBundle) == 0 && params.insecure
 	soapClient := soap.NewClient(url, insecure)
-	if !insecure {
-		soapClient.SetThumbprint(url.Host, thumbprint)
+	switch {
+	case params.thumbprint != "":
+		soapClient.SetThumbprint(url.Host, params.thumbprint)
+	case len(params.caBundle) > 0:
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(params.caBundle) {
+			return nil, nil, errors.Errorf("invalid CA bundle for %q, no PEM encoded certificates found", url.Host)
+		}
+		soapClient.DefaultTransport().TLSClientConfig.RootCAs = pool
+	case insecure:
+		logger.Info("WARNING: connecting without verifying the server's certificate", "server", url.Host)
 	}
 
 	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	c := &govmomi.Client{
@@ -185,7 +318,7 @@ func newClient(ctx context.Context, logger logr.Logger, sessionKey string, url *
 		SessionManager: session.NewManager(vimClient),
 	}
 
-	vimClient.RoundTripper = session.KeepAliveHandler(vimClient.RoundTripper, feature.KeepAliveDuration, func(tripper soap.RoundTripper) error {
+	vimClient.RoundTripper = session.KeepAliveHandler(vimClient.RoundTripper, params.feature.KeepAliveDuration, func(tripper soap.RoundTripper) error {
 		// we tried implementing
 		// c.Login here but the client once logged out
 		// keeps errong in invalid username or password
@@ -195,24 +328,56 @@ func newClient(ctx context.Context, logger logr.Logger, sessionKey string, url *
 		_, err := methods.GetCurrentTime(ctx, tripper)
 		if err != nil {
 			logger.Error(err, "failed to keep alive govmomi client")
+			keepAliveFailures.WithLabelValues(sessionKey.server).Inc()
 			clearCache(logger, sessionKey)
 		}
 		return err
 	})
+	vimClient.RoundTripper = tracing.RoundTripper(vimClient.RoundTripper, url.Host)
 
-	if err := c.Login(ctx, url.User); err != nil {
-		return nil, err
+	logins.WithLabelValues(sessionKey.server).Inc()
+	signer, err := newSigner(ctx, vimClient, params)
+	if err != nil {
+		loginFailures.WithLabelValues(sessionKey.server).Inc()
+		return nil, nil, err
+	}
+	if signer != nil {
+		err = c.SessionManager.LoginByToken(c.WithHeader(ctx, soap.Header{Security: signer}))
+	} else {
+		err = c.Login(ctx, url.User)
+	}
+	if err != nil {
+		loginFailures.WithLabelValues(sessionKey.server).Inc()
+		return nil, nil, err
 	}
 
-	return c, nil
+	return c, signer, nil
 }
 
-func clearCache(logger logr.Logger, sessionKey string) {
-	if cachedSession, ok := sessionCache.Load(sessionKey); ok {
-		s := cachedSession.(*Session)
+func clearCache(logger logr.Logger, sessionKey sessionKey) {
+	if s := removeSession(sessionKey); s != nil {
+		s.logout(logger)
+	}
+}
+
+// removeSession removes the session cached for the given key, if any, from
+// the cache.
+func removeSession(sessionKey sessionKey) *Session {
+	cachedSession, ok := sessionCache.LoadAndDelete(sessionKey)
+	updateActiveSessions(sessionKey.server)
+	if !ok {
+		return nil
+	}
+	return cachedSession.(*Session)
+}
 
-		// check for the presence of tagmanager session
-		// since calling Logout on an expired session blocks
+// logout stops the watcher of the session and logs out its sessions.
+func (s *Session) logout(logger logr.Logger) {
+	s.StopWatcher()
+
+	// check for the presence of tagmanager session
+	// since calling Logout on an expired session blocks
+	if s.TagManager != nil {
 		session, err := s.TagManager.Session(context.Background())
 		if err != nil {
 			logger.Error(err, "unable to get tag manager session")
@@ -224,27 +389,116 @@ func clearCache(logger logr.Logger, sessionKey string) {
 				logger.Error(err, "unable to logout tag manager session")
 			}
 		}
+	}
 
-		vimSessionActive, err := s.SessionManager.SessionIsActive(context.Background())
+	vimSessionActive, err := s.SessionManager.SessionIsActive(context.Background())
+	if err != nil {
+		logger.Error(err, "unable to get vim client session")
+	} else if vimSessionActive {
+		logger.V(6).Info("found active vim session, logging out")
+		err := s.SessionManager.Logout(context.Background())
 		if err != nil {
-			logger.Error(err, "unable to get vim client session")
-		} else if vimSessionActive {
-			logger.V(6).Info("found active vim session, logging out")
-			err := s.SessionManager.Logout(context.Background())
-			if err != nil {
-				logger.Error(err, "unable to logout vim session")
+			logger.Error(err, "unable to logout vim session")
+		}
+	}
+}
+
+const (
+	// janitorInterval is the interval at which the cached sessions are
+	// checked for eviction.
+	janitorInterval = time.Minute
+
+	// supersededIdleTimeout is the duration after which a cached session
+	// which was superseded by a session logged in with other credentials is
+	// evicted, unless it was used in the meantime.
+	supersededIdleTimeout = time.Minute
+)
+
+var janitorOnce sync.Once
+
+// startJanitor starts the goroutine which periodically evicts cached
+// sessions, so that the requests for sessions do not wait for logouts.
+func startJanitor() {
+	janitorOnce.Do(func() {
+		logger := ctrl.Log.WithName("session")
+		ticker := time.NewTicker(janitorInterval)
+		go func() {
+			for now := range ticker.C {
+				evictSessions(logger, now)
 			}
+		}()
+	})
+}
+
+// evictSessions logs out and evicts the cached sessions which have been idle
+// for longer than their idle TTL, as well as the sessions which have been
+// superseded by a newer session for the same server, user and datacenter
+// with different credentials, such as after a password rotation, and which
+// have not been used since. Sessions which are still used with their
+// credentials are kept, so that the sessions of clusters which use different
+// credentials for the same user do not evict each other.
+func evictSessions(logger logr.Logger, now time.Time) {
+	var evicted []sessionKey
+	sessionCache.Range(func(k, v interface{}) bool {
+		cachedKey := k.(sessionKey)
+		s := v.(*Session)
+		idle := now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastUsed)))
+		switch {
+		case s.idleTTL > 0 && idle > s.idleTTL:
+			logger.V(2).Info("evicting idle cached vSphere client session", "server", cachedKey.server, "datacenter", cachedKey.datacenter)
+			evicted = append(evicted, cachedKey)
+		case idle > supersededIdleTimeout && superseded(cachedKey, s):
+			logger.V(2).Info("credentials changed, evicting cached vSphere client session", "server", cachedKey.server, "datacenter", cachedKey.datacenter)
+			evicted = append(evicted, cachedKey)
 		}
+		return true
+	})
+
+	// The sessions are logged out once they are removed from the cache.
+	for _, key := range evicted {
+		clearCache(logger, key)
 	}
-	sessionCache.Delete(sessionKey)
+}
+
+// superseded returns whether a newer session for the same server, user and
+// datacenter as the given session is cached, which was logged in with
+// different credentials.
+func superseded(key sessionKey, s *Session) bool {
+	var found bool
+	sessionCache.Range(func(k, v interface{}) bool {
+		cachedKey := k.(sessionKey)
+		found = cachedKey.server == key.server && cachedKey.username == key.username &&
+			cachedKey.datacenter == key.datacenter && cachedKey.credentialHash != key.credentialHash &&
+			v.(*Session).created.After(s.created)
+		return !found
+	})
+	return found
+}
+
+// updateActiveSessions sets the number of sessions cached for the given
+// server.
+func updateActiveSessions(server string) {
+	var count int
+	sessionCache.Range(func(k, _ interface{}) bool {
+		if k.(sessionKey).server == server {
+			count++
+		}
+		return true
+	})
+	activeSessions.WithLabelValues(server).Set(float64(count))
+}
+
+func (s *Session) touch() {
+	atomic.StoreInt64(&s.lastUsed, time.Now().UnixNano())
 }
 
 // newManager creates a Manager that encompasses the REST Client for the VSphere tagging API.
-func newManager(ctx context.Context, logger logr.Logger, sessionKey string, client *vim25.Client, user *url.Userinfo, feature Feature) (*tags.Manager, error) {
+func newManager(ctx context.Context, logger logr.Logger, sessionKey sessionKey, client *vim25.Client, user *url.Userinfo, signer *sts.Signer, feature Feature) (*tags.Manager, error) {
 	rc := rest.NewClient(client)
 	rc.Transport = keepalive.NewHandlerREST(rc, feature.KeepAliveDuration, func() error {
 		s, err := rc.Session(ctx)
 		if err != nil {
+			keepAliveFailures.WithLabelValues(sessionKey.server).Inc()
 			return err
 		}
 		if s != nil {
@@ -252,15 +506,54 @@ func newManager(ctx context.Context, logger logr.Logger, sessionKey string, clie
 		}
 
 		logger.V(6).Info("rest client session expired, clearing cache")
+		keepAliveFailures.WithLabelValues(sessionKey.server).Inc()
 		clearCache(logger, sessionKey)
 		return errors.New("rest client session expired")
 	})
-	if err := rc.Login(ctx, user); err != nil {
+	logins.WithLabelValues(sessionKey.server).Inc()
+	var err error
+	if signer != nil {
+		err = rc.LoginByToken(rc.WithSigner(ctx, signer))
+	} else {
+		err = rc.Login(ctx, user)
+	}
+	if err != nil {
+		loginFailures.WithLabelValues(sessionKey.server).Inc()
 		return nil, err
 	}
 	return tags.NewManager(rc), nil
 }
 
+// libraryItemTTL is the duration for which the content library item a
+// template refers to, or the absence of such an item, is cached.
+const libraryItemTTL = 10 * time.Minute
+
+type libraryItemEntry struct {
+	item    *library.Item
+	expires time.Time
+}
+
+// LibraryItem returns the content library item cached for the given template.
+// The item is nil if the template was found not to refer to an existing item.
+func (s *Session) LibraryItem(template string) (*library.Item, bool) {
+	value, ok := s.libraryItems.Load(template)
+	if !ok {
+		return nil, false
+	}
+	entry := value.(libraryItemEntry) //nolint:forcetypeassert
+	if time.Now().After(entry.expires) {
+		s.libraryItems.Delete(template)
+		return nil, false
+	}
+	return entry.item, true
+}
+
+// CacheLibraryItem caches the content library item resolved for the given
+// template, or its absence if the item is nil, for a limited time.
+func (s *Session) CacheLibraryItem(template string, item *library.Item) {
+	s.libraryItems.Store(template, libraryItemEntry{item: item, expires: time.Now().Add(libraryItemTTL)})
+}
+
 // FindByBIOSUUID finds an object by its BIOS UUID.
 //
 // To avoid comments about this function's name, please see the Golang
@@ -19,14 +19,24 @@ package session
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
 	"testing"
 	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	_ "github.com/vmware/govmomi/lookup/simulator"
 	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/sts/simulator"
 	"k8s.io/klog/v2/klogr"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -50,6 +60,7 @@ func TestGetSession(t *testing.T) {
 	defer simr.Destroy()
 
 	params := NewParams().
+		WithInsecure(true).
 		WithServer(simr.ServerURL().Host).
 		WithUserInfo(simr.Username(), simr.Password()).WithDatacenter("*")
 
@@ -129,6 +140,7 @@ func TestGetSessionWithKeepAlive(t *testing.T) {
 	defer simr.Destroy()
 
 	params := NewParams().
+		WithInsecure(true).
 		WithServer(simr.ServerURL().Host).
 		WithUserInfo(simr.Username(), simr.Password()).
 		WithDatacenter("*")
@@ -190,6 +202,7 @@ func TestGetSessionWithKeepAliveTagManagerLogout(t *testing.T) {
 	defer simr.Destroy()
 
 	params := NewParams().
+		WithInsecure(true).
 		WithServer(simr.ServerURL().Host).
 		WithUserInfo(simr.Username(), simr.Password()).
 		WithFeatures(Feature{KeepAliveDuration: 400 * time.Millisecond}).WithDatacenter("*")
@@ -236,3 +249,218 @@ func TestGetSessionWithKeepAliveTagManagerLogout(t *testing.T) {
 	g.Expect(sessionInfo.Key).ToNot(BeEquivalentTo(sessionKey))
 	assertSessionCountEqualTo(g, simr, 1)
 }
+
+func TestGetSessionWithCABundle(t *testing.T) {
+	g := NewWithT(t)
+	log := klogr.New()
+	ctrllog.SetLogger(log)
+
+	simr, err := vcsim.NewBuilder().
+		WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	defer simr.Destroy()
+
+	newParams := func() *Params {
+		return NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*")
+	}
+
+	// The simulator's self-signed certificate is not trusted by default.
+	_, err = GetOrCreate(context.Background(), newParams())
+	g.Expect(err).To(MatchError(ContainSubstring("x509")))
+
+	_, err = GetOrCreate(context.Background(), newParams().WithCABundle([]byte("not a certificate")))
+	g.Expect(err).To(MatchError(ContainSubstring("no PEM encoded certificates found")))
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: simr.Certificate().Raw})
+	s, err := GetOrCreate(context.Background(), newParams().WithCABundle(caBundle))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s).ToNot(BeNil())
+	assertSessionCountEqualTo(g, simr, 1)
+
+	// The cached session is not reused without the CA bundle it was
+	// verified with.
+	_, err = GetOrCreate(context.Background(), newParams())
+	g.Expect(err).To(MatchError(ContainSubstring("x509")))
+	_, err = GetOrCreate(context.Background(), newParams().WithThumbprint("AA:BB"))
+	g.Expect(err).To(HaveOccurred())
+	assertSessionCountEqualTo(g, simr, 1)
+}
+
+func TestGetSessionWithRotatedCredentials(t *testing.T) {
+	g := NewWithT(t)
+	log := klogr.New()
+	ctrllog.SetLogger(log)
+
+	simr, err := vcsim.NewBuilder().
+		WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	defer simr.Destroy()
+	server := simr.ServerURL().Host
+
+	params := NewParams().
+		WithInsecure(true).
+		WithServer(server).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*")
+	s, err := GetOrCreate(context.Background(), params)
+	g.Expect(err).ToNot(HaveOccurred())
+	assertSessionCountEqualTo(g, simr, 1)
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(logins.WithLabelValues(server))).To(Equal(2.0))
+
+	// The session is reused for the same credentials.
+	cached, err := GetOrCreate(context.Background(), params)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached).To(BeIdenticalTo(s))
+
+	// A new session is created once the password changes. The session for
+	// the previous password is kept while it is still used.
+	rotated, err := GetOrCreate(context.Background(), NewParams().
+		WithInsecure(true).
+		WithServer(server).
+		WithUserInfo(simr.Username(), "rotated").
+		WithDatacenter("*"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rotated).ToNot(BeIdenticalTo(s))
+	assertSessionCountEqualTo(g, simr, 2)
+	g.Expect(testutil.ToFloat64(logins.WithLabelValues(server))).To(Equal(4.0))
+
+	cached, err = GetOrCreate(context.Background(), params)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached).To(BeIdenticalTo(s))
+	evictSessions(log, time.Now())
+	assertSessionCountEqualTo(g, simr, 2)
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server))).To(Equal(2.0))
+
+	// The session for the previous password is logged out once it is idle,
+	// while the newer session is kept even if it is idle.
+	idle := time.Now().Add(2 * supersededIdleTimeout)
+	evictSessions(log, idle)
+	assertSessionCountEqualTo(g, simr, 1)
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(logins.WithLabelValues(server))).To(Equal(4.0))
+
+	cached, err = GetOrCreate(context.Background(), NewParams().
+		WithInsecure(true).
+		WithServer(server).
+		WithUserInfo(simr.Username(), "rotated").
+		WithDatacenter("*"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached).To(BeIdenticalTo(rotated))
+}
+
+func TestGetSessionWithIdleTTL(t *testing.T) {
+	g := NewWithT(t)
+	log := klogr.New()
+	ctrllog.SetLogger(log)
+
+	// Sessions must not be expired by the simulator itself.
+	simulator.SessionIdleTimeout = 0
+
+	simr, err := vcsim.NewBuilder().
+		WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	defer simr.Destroy()
+	server := simr.ServerURL().Host
+
+	newParams := func(datacenter string) *Params {
+		return NewParams().
+			WithInsecure(true).
+			WithServer(server).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithFeatures(Feature{IdleTTL: time.Second}).
+			WithDatacenter(datacenter)
+	}
+	_, err = GetOrCreate(context.Background(), newParams("DC0"))
+	g.Expect(err).ToNot(HaveOccurred())
+	assertSessionCountEqualTo(g, simr, 1)
+
+	_, err = GetOrCreate(context.Background(), newParams("*"))
+	g.Expect(err).ToNot(HaveOccurred())
+	assertSessionCountEqualTo(g, simr, 2)
+
+	// The idle session is logged out by the janitor, while the session which
+	// is still used is kept.
+	time.Sleep(2 * time.Second)
+	_, err = GetOrCreate(context.Background(), newParams("*"))
+	g.Expect(err).ToNot(HaveOccurred())
+	evictSessions(log, time.Now())
+	assertSessionCountEqualTo(g, simr, 1)
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server))).To(Equal(1.0))
+}
+
+func TestGetSessionWithToken(t *testing.T) {
+	g := NewWithT(t)
+	log := klogr.New()
+	ctrllog.SetLogger(log)
+
+	simr, err := vcsim.NewBuilder().
+		WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	defer simr.Destroy()
+
+	certificate := newSolutionUserCertificate(g)
+	params := NewParams().
+		WithInsecure(true).
+		WithServer(simr.ServerURL().Host).
+		WithCertificate(&certificate).
+		WithDatacenter("*")
+
+	// A token is issued for the certificate to log in.
+	s, err := GetOrCreate(context.Background(), params)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s.signer).ToNot(BeNil())
+	g.Expect(s.signer.Token).ToNot(BeEmpty())
+	g.Expect(s.TagManager).ToNot(BeNil())
+	assertSessionCountEqualTo(g, simr, 1)
+
+	cached, err := GetOrCreate(context.Background(), params)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached).To(BeIdenticalTo(s))
+
+	// The session is renewed with a new token before the token expires.
+	s.signer.Lifetime.Created = time.Now().Add(-time.Hour)
+	s.signer.Lifetime.Expires = time.Now().Add(time.Minute)
+	renewed, err := GetOrCreate(context.Background(), params)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(renewed).ToNot(BeIdenticalTo(s))
+	g.Expect(renewed.signer.Lifetime.Expires).To(BeTemporally(">", s.signer.Lifetime.Expires))
+	assertSessionCountEqualTo(g, simr, 1)
+
+	// A bearer token is used to log in as is.
+	s, err = GetOrCreate(context.Background(), NewParams().
+		WithInsecure(true).
+		WithServer(simr.ServerURL().Host).
+		WithToken(renewed.signer.Token).
+		WithDatacenter("*"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s.signer.Certificate).To(BeNil())
+	sessionInfo, err := s.SessionManager.UserSession(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sessionInfo.UserName).To(Equal("Administrator@VSPHERE.LOCAL"))
+}
+
+func newSolutionUserCertificate(g *WithT) tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "capv"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).ToNot(HaveOccurred())
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/xml"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vim25"
+)
+
+const (
+	// tokenLifetime is the lifetime of the SAML tokens issued for
+	// certificates.
+	tokenLifetime = time.Hour
+
+	// tokenRenewalFraction is the fraction of a token's lifetime before its
+	// expiry at which the session logged in with it is renewed.
+	tokenRenewalFraction = 5
+)
+
+// principal returns the name of the user the params authenticate as.
+func (p *Params) principal() string {
+	switch {
+	case p.token != "":
+		var assertion struct {
+			NameID string `xml:"Subject>NameID"`
+		}
+		if err := xml.Unmarshal([]byte(p.token), &assertion); err == nil {
+			return assertion.NameID
+		}
+		return ""
+	case p.certificate != nil && len(p.certificate.Certificate) > 0:
+		if cert, err := x509.ParseCertificate(p.certificate.Certificate[0]); err == nil {
+			return cert.Subject.String()
+		}
+		return ""
+	default:
+		return p.userinfo.Username()
+	}
+}
+
+// credentialHash returns a hash of the credentials in the params.
+func (p *Params) credentialHash() string {
+	hash := sha256.New()
+	password, _ := p.userinfo.Password()
+	hash.Write([]byte(p.userinfo.Username() + "\x00" + password + "\x00" + p.token))
+	if p.certificate != nil {
+		for _, der := range p.certificate.Certificate {
+			hash.Write(der)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// newSigner returns the signer holding the SAML token with which the session
+// is logged in, or nil if the params authenticate with a username and
+// password. A token is issued by the vCenter Security Token Service if the
+// params authenticate with a certificate.
+func newSigner(ctx context.Context, client *vim25.Client, params *Params) (*sts.Signer, error) {
+	switch {
+	case params.token != "":
+		return &sts.Signer{Token: params.token}, nil
+	case params.certificate != nil:
+		stsClient, err := sts.NewClient(ctx, client)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create security token service client")
+		}
+		signer, err := stsClient.Issue(ctx, sts.TokenRequest{
+			Certificate: params.certificate,
+			Lifetime:    tokenLifetime,
+			Delegatable: true,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to issue token for certificate")
+		}
+		return signer, nil
+	default:
+		return nil, nil
+	}
+}
+
+// tokenExpiresSoon returns true if the session was logged in with a SAML
+// token issued for a certificate which is about to expire. Such sessions are
+// renewed by logging in with a newly issued token.
+func (s *Session) tokenExpiresSoon() bool {
+	if s.signer == nil || s.signer.Certificate == nil || s.signer.Lifetime.Expires.IsZero() {
+		return false
+	}
+	lifetime := s.signer.Lifetime.Expires.Sub(s.signer.Lifetime.Created)
+	return time.Until(s.signer.Lifetime.Expires) < lifetime/tokenRenewalFraction
+}
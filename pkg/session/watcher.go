@@ -0,0 +1,382 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// watcherMaxWaitSeconds is the maximum time a single WaitForUpdatesEx
+	// call blocks before it is issued again.
+	watcherMaxWaitSeconds = 60
+
+	// watcherRetryInterval is the time to wait before waiting for updates
+	// again after an error.
+	watcherRetryInterval = 10 * time.Second
+
+	// watchTimeout is the maximum time Watch waits for vCenter to retrieve
+	// the initial values of an object and to create its filter.
+	watchTimeout = 30 * time.Second
+)
+
+// WatchHandler is called with the updates of a watched object. The first
+// update of an object has the kind types.ObjectUpdateKindEnter and reports the
+// current values of the watched properties before Watch returns. An update of
+// the kind types.ObjectUpdateKindLeave reports that the object no longer
+// exists. The object is not watched anymore after it left or once the handler
+// returns true. The handler must not call the methods of the watcher.
+type WatchHandler func(update types.ObjectUpdate) (done bool)
+
+// Watcher watches the properties of managed objects, such as virtual machines
+// and tasks, with a single property collector and WaitForUpdatesEx loop,
+// instead of waiting on every object separately. Handlers are called from
+// the loop, or from Watch for the initial values, and must not block.
+type Watcher struct {
+	client    *vim25.Client
+	collector *property.Collector
+	logger    logr.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	watches map[types.ManagedObjectReference]*watch
+	stopped bool
+}
+
+type watch struct {
+	// filter is the property filter of the object, which is unset while
+	// Watch creates it.
+	filter  types.ManagedObjectReference
+	handler WatchHandler
+
+	// initial are the values of the properties reported by Watch, until the
+	// collector reports its first update of the object.
+	initial map[string]interface{}
+}
+
+// Watcher returns the watcher of the session. The watcher is started when it
+// is first requested and stopped when the session is evicted from the cache.
+func (s *Session) Watcher(ctx context.Context) (*Watcher, error) {
+	s.watcherMu.Lock()
+	defer s.watcherMu.Unlock()
+
+	if s.watcher != nil {
+		return s.watcher, nil
+	}
+	logger := ctrl.LoggerFrom(ctx).WithName("session").WithName("watcher").WithValues("server", s.Client.URL().Host)
+	watcher, err := newWatcher(ctx, logger, s.Client.Client)
+	if err != nil {
+		return nil, err
+	}
+	s.watcher = watcher
+	return watcher, nil
+}
+
+// StopWatcher stops the watcher of the session, if it was started. A new
+// watcher is started when the watcher is requested again.
+func (s *Session) StopWatcher() {
+	s.watcherMu.Lock()
+	defer s.watcherMu.Unlock()
+
+	if s.watcher != nil {
+		s.watcher.stop()
+		s.watcher = nil
+	}
+}
+
+func newWatcher(ctx context.Context, logger logr.Logger, client *vim25.Client) (*Watcher, error) {
+	collector, err := property.DefaultCollector(client).Create(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create property collector")
+	}
+
+	watcherCtx, cancel := context.WithCancel(context.Background())
+	w := &Watcher{
+		client:    client,
+		collector: collector,
+		logger:    logger,
+		ctx:       watcherCtx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		watches:   map[types.ManagedObjectReference]*watch{},
+	}
+	go w.run()
+	return w, nil
+}
+
+// Watch watches the given properties of the object and calls the handler
+// with their updates, starting with their current values. If the object is
+// already watched, only its handler is replaced. The initial values are
+// retrieved with the given context.
+func (w *Watcher) Watch(ctx context.Context, obj types.ManagedObjectReference, pathSet []string, handler WatchHandler) error {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return errors.Errorf("unable to watch %s, the watcher is stopped", obj)
+	}
+	if existing, ok := w.watches[obj]; ok {
+		existing.handler = handler
+		w.mu.Unlock()
+		return nil
+	}
+	// The watch is registered before its filter is created, so that the
+	// object is watched only once and no lock is held while calling vCenter.
+	pending := &watch{handler: handler}
+	w.watches[obj] = pending
+	w.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, watchTimeout)
+	defer cancel()
+
+	// The collector only reports the initial values of a new filter once it
+	// waits for updates again, so they are retrieved and reported right away
+	// instead.
+	spec := types.PropertyFilterSpec{
+		ObjectSet: []types.ObjectSpec{{Obj: obj}},
+		PropSet:   []types.PropertySpec{{Type: obj.Type, PathSet: pathSet}},
+	}
+	content, err := methods.RetrieveProperties(ctx, w.client, &types.RetrieveProperties{
+		This:    w.collector.Reference(),
+		SpecSet: []types.PropertyFilterSpec{spec},
+	})
+	if err != nil {
+		w.removePending(obj, pending)
+		return errors.Wrapf(err, "unable to retrieve properties of %s", obj)
+	}
+	initial := map[string]interface{}{}
+	update := types.ObjectUpdate{Kind: types.ObjectUpdateKindEnter, Obj: obj}
+	for _, oc := range content.Returnval {
+		for _, prop := range oc.PropSet {
+			initial[prop.Name] = prop.Val
+			update.ChangeSet = append(update.ChangeSet, types.PropertyChange{
+				Name: prop.Name,
+				Op:   types.PropertyChangeOpAssign,
+				Val:  prop.Val,
+			})
+		}
+	}
+	if handler(update) {
+		w.removePending(obj, pending)
+		return nil
+	}
+
+	w.mu.Lock()
+	pending.initial = initial
+	w.mu.Unlock()
+
+	res, err := methods.CreateFilter(ctx, w.client, &types.CreateFilter{
+		This: w.collector.Reference(),
+		Spec: spec,
+	})
+	if err != nil {
+		w.removePending(obj, pending)
+		return errors.Wrapf(err, "unable to create property filter for %s", obj)
+	}
+
+	w.mu.Lock()
+	// The object may have been unwatched or the watcher stopped meanwhile.
+	if w.stopped || w.watches[obj] != pending {
+		w.mu.Unlock()
+		w.destroyFilter(obj, res.Returnval)
+		return nil
+	}
+	pending.filter = res.Returnval
+	w.mu.Unlock()
+	return nil
+}
+
+// removePending removes the watch of the object if it is the given watch
+// whose filter is not created.
+func (w *Watcher) removePending(obj types.ManagedObjectReference, pending *watch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watches[obj] == pending {
+		delete(w.watches, obj)
+	}
+}
+
+// Unwatch stops watching the object.
+func (w *Watcher) Unwatch(obj types.ManagedObjectReference) {
+	w.mu.Lock()
+	filter := w.unwatch(obj)
+	w.mu.Unlock()
+
+	w.destroyFilter(obj, filter)
+}
+
+// Watching returns whether the object is watched.
+func (w *Watcher) Watching(obj types.ManagedObjectReference) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.watches[obj]
+	return ok
+}
+
+// unwatch removes the watch of the object and returns its filter, which has
+// to be destroyed once the lock is released.
+func (w *Watcher) unwatch(obj types.ManagedObjectReference) types.ManagedObjectReference {
+	existing, ok := w.watches[obj]
+	if !ok {
+		return types.ManagedObjectReference{}
+	}
+	delete(w.watches, obj)
+	return existing.filter
+}
+
+// destroyFilter destroys the property filter of the object, if any.
+func (w *Watcher) destroyFilter(obj, filter types.ManagedObjectReference) {
+	if filter.Value == "" {
+		return
+	}
+	if _, err := methods.DestroyPropertyFilter(w.ctx, w.client, &types.DestroyPropertyFilter{This: filter}); err != nil {
+		w.logger.V(4).Info("unable to destroy property filter", "object", obj, "error", err.Error())
+	}
+}
+
+// run waits for updates of the watched objects and dispatches them to their
+// handlers until the watcher is stopped.
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	var version string
+	for {
+		set, err := w.collector.WaitForUpdates(w.ctx, version, &types.WaitOptions{
+			MaxWaitSeconds: pointer.Int32(watcherMaxWaitSeconds),
+		})
+		if err != nil {
+			if w.ctx.Err() != nil {
+				return
+			}
+			// Waiting for updates may fail if a watched object no longer
+			// exists, the object is reported to have left instead. Failures
+			// for objects which are not watched anymore are retried later.
+			if obj, ok := managedObjectNotFound(err); ok && w.Watching(obj) {
+				w.dispatch(types.ObjectUpdate{Kind: types.ObjectUpdateKindLeave, Obj: obj})
+				continue
+			}
+			w.logger.Error(err, "failed to wait for updates")
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(watcherRetryInterval):
+			}
+			continue
+		}
+		// An empty update set is returned once MaxWaitSeconds is exceeded.
+		if set == nil {
+			continue
+		}
+
+		version = set.Version
+		for _, filterSet := range set.FilterSet {
+			for _, update := range filterSet.ObjectSet {
+				w.dispatch(update)
+			}
+		}
+	}
+}
+
+func (w *Watcher) dispatch(update types.ObjectUpdate) {
+	w.mu.Lock()
+	existing, ok := w.watches[update.Obj]
+	if !ok {
+		w.mu.Unlock()
+		return
+	}
+	// The initial values have already been reported by Watch, only the
+	// properties which changed since are reported.
+	if update.Kind == types.ObjectUpdateKindEnter {
+		var changes []types.PropertyChange
+		for _, change := range update.ChangeSet {
+			if !reflect.DeepEqual(existing.initial[change.Name], change.Val) {
+				changes = append(changes, change)
+			}
+		}
+		update.Kind = types.ObjectUpdateKindModify
+		update.ChangeSet = changes
+	}
+	existing.initial = nil
+	handler := existing.handler
+	w.mu.Unlock()
+
+	if len(update.ChangeSet) == 0 && update.Kind != types.ObjectUpdateKindLeave {
+		return
+	}
+	if done := handler(update); done || update.Kind == types.ObjectUpdateKindLeave {
+		var filter types.ManagedObjectReference
+		w.mu.Lock()
+		if w.watches[update.Obj] == existing {
+			filter = w.unwatch(update.Obj)
+		}
+		w.mu.Unlock()
+		w.destroyFilter(update.Obj, filter)
+	}
+}
+
+// managedObjectNotFound returns the object of a ManagedObjectNotFound fault.
+func managedObjectNotFound(err error) (types.ManagedObjectReference, bool) {
+	if !soap.IsSoapFault(err) {
+		return types.ManagedObjectReference{}, false
+	}
+	switch fault := soap.ToSoapFault(err).VimFault().(type) {
+	case types.ManagedObjectNotFound:
+		return fault.Obj, true
+	case *types.ManagedObjectNotFound:
+		return fault.Obj, true
+	}
+	return types.ManagedObjectReference{}, false
+}
+
+// stop stops waiting for updates and destroys the property collector, along
+// with the filters of all watched objects.
+func (w *Watcher) stop() {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.stopped = true
+	w.watches = map[types.ManagedObjectReference]*watch{}
+	w.mu.Unlock()
+
+	w.cancel()
+	<-w.done
+
+	// The watcher's context is canceled, the collector is destroyed using
+	// the background context instead.
+	if err := w.collector.Destroy(context.Background()); err != nil {
+		w.logger.V(4).Info("unable to destroy property collector", "error", err.Error())
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers/vcsim"
+)
+
+func TestWatcher(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := vcsim.NewBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	params := NewParams().
+		WithInsecure(true).
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*")
+	s, err := GetOrCreate(context.Background(), params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	watcher, err := s.Watcher(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	otherWatcher, err := s.Watcher(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(otherWatcher).To(BeIdenticalTo(watcher))
+
+	vms, err := s.Finder.VirtualMachineList(context.Background(), "*")
+	g.Expect(err).NotTo(HaveOccurred())
+	vm := vms[0]
+
+	updates := make(chan types.ObjectUpdate, 10)
+	g.Expect(watcher.Watch(context.Background(), vm.Reference(), []string{"runtime.powerState"}, func(update types.ObjectUpdate) bool {
+		updates <- update
+		return false
+	})).To(Succeed())
+	g.Expect(watcher.Watching(vm.Reference())).To(BeTrue())
+
+	t.Run("reports the initial values", func(t *testing.T) {
+		g := NewWithT(t)
+		var update types.ObjectUpdate
+		g.Expect(updates).To(Receive(&update))
+		g.Expect(update.Kind).To(Equal(types.ObjectUpdateKindEnter))
+		g.Expect(update.ChangeSet).To(ContainElement(types.PropertyChange{
+			Name: "runtime.powerState",
+			Op:   types.PropertyChangeOpAssign,
+			Val:  types.VirtualMachinePowerStatePoweredOn,
+		}))
+	})
+
+	t.Run("reports changes", func(t *testing.T) {
+		g := NewWithT(t)
+		task, err := vm.PowerOff(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+
+		var update types.ObjectUpdate
+		g.Eventually(updates).Should(Receive(&update))
+		g.Expect(update.Kind).To(Equal(types.ObjectUpdateKindModify))
+		g.Expect(update.ChangeSet).To(ContainElement(types.PropertyChange{
+			Name: "runtime.powerState",
+			Op:   types.PropertyChangeOpAssign,
+			Val:  types.VirtualMachinePowerStatePoweredOff,
+		}))
+	})
+
+	t.Run("stops watching tasks once the handler is done", func(t *testing.T) {
+		g := NewWithT(t)
+		task, err := vm.PowerOn(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+
+		// The task already succeeded, its initial state is reported.
+		states := make(chan interface{}, 10)
+		g.Expect(watcher.Watch(context.Background(), task.Reference(), []string{"info.state"}, func(update types.ObjectUpdate) bool {
+			for _, change := range update.ChangeSet {
+				states <- change.Val
+				if change.Val == types.TaskInfoStateSuccess {
+					return true
+				}
+			}
+			return false
+		})).To(Succeed())
+
+		g.Expect(states).To(Receive(Equal(types.TaskInfoStateSuccess)))
+		g.Expect(watcher.Watching(task.Reference())).To(BeFalse())
+	})
+
+	t.Run("retrieves the initial values with the given context", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		g.Expect(watcher.Watch(ctx, vms[1].Reference(), []string{"runtime.powerState"}, func(types.ObjectUpdate) bool {
+			return false
+		})).NotTo(Succeed())
+		g.Expect(watcher.Watching(vms[1].Reference())).To(BeFalse())
+	})
+
+	t.Run("stops watching deleted objects", func(t *testing.T) {
+		g := NewWithT(t)
+		vm := vms[1]
+		kinds := make(chan types.ObjectUpdateKind, 10)
+		g.Expect(watcher.Watch(context.Background(), vm.Reference(), []string{"runtime.powerState"}, func(update types.ObjectUpdate) bool {
+			kinds <- update.Kind
+			return false
+		})).To(Succeed())
+		g.Expect(kinds).To(Receive(Equal(types.ObjectUpdateKindEnter)))
+
+		// A VM has to be powered off to be destroyed.
+		task, err := vm.PowerOff(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+		g.Eventually(kinds).Should(Receive(Equal(types.ObjectUpdateKindModify)))
+
+		task, err = vm.Destroy(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+
+		g.Eventually(kinds).Should(Receive(Equal(types.ObjectUpdateKindLeave)))
+		g.Eventually(func() bool { return watcher.Watching(vm.Reference()) }).Should(BeFalse())
+	})
+
+	t.Run("waits before retrying objects which are not watched anymore", func(t *testing.T) {
+		g := NewWithT(t)
+		client := *s.Client.Client
+		recorder := &waitRecorder{RoundTripper: client.RoundTripper}
+		client.RoundTripper = recorder
+		watcher, err := newWatcher(context.Background(), logr.Discard(), &client)
+		g.Expect(err).NotTo(HaveOccurred())
+		defer watcher.stop()
+
+		// The filter of a VM which is not watched is left behind, the
+		// collector fails to report updates once the VM is destroyed.
+		vm := vms[2]
+		_, err = methods.CreateFilter(context.Background(), &client, &types.CreateFilter{
+			This: watcher.collector.Reference(),
+			Spec: types.PropertyFilterSpec{
+				ObjectSet: []types.ObjectSpec{{Obj: vm.Reference()}},
+				PropSet:   []types.PropertySpec{{Type: "VirtualMachine", PathSet: []string{"runtime.powerState"}}},
+			},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		task, err := vm.PowerOff(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+		task, err = vm.Destroy(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(context.Background())).To(Succeed())
+		folder, err := s.Finder.DefaultFolder(context.Background())
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = folder.CreateFolder(context.Background(), "watcher")
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Eventually(recorder.notFound).Should(BeTrue())
+		calls := recorder.calls()
+		g.Consistently(recorder.calls).Should(Equal(calls))
+	})
+
+	t.Run("stops when the session is evicted", func(t *testing.T) {
+		g := NewWithT(t)
+		clearCache(logr.Discard(), newSessionKey(params))
+
+		g.Expect(watcher.done).To(BeClosed())
+		g.Expect(watcher.Watching(vm.Reference())).To(BeFalse())
+		g.Expect(watcher.Watch(context.Background(), vm.Reference(), []string{"runtime.powerState"}, func(types.ObjectUpdate) bool {
+			return false
+		})).NotTo(Succeed())
+	})
+}
+
+// waitRecorder records the WaitForUpdatesEx calls of a watcher.
+type waitRecorder struct {
+	soap.RoundTripper
+
+	mu         sync.Mutex
+	waits      int
+	objMissing bool
+}
+
+func (r *waitRecorder) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	_, wait := req.(*methods.WaitForUpdatesExBody)
+	if wait {
+		r.mu.Lock()
+		r.waits++
+		r.mu.Unlock()
+	}
+	err := r.RoundTripper.RoundTrip(ctx, req, res)
+	if _, ok := managedObjectNotFound(err); ok && wait {
+		r.mu.Lock()
+		r.objMissing = true
+		r.mu.Unlock()
+	}
+	return err
+}
+
+func (r *waitRecorder) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.waits
+}
+
+func (r *waitRecorder) notFound() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.objMissing
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package throttle limits the number of concurrent operations, such as clone,
+// reconfigure and power-on tasks, per vSphere server and per datastore.
+package throttle
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// waiterTTL is the duration after which an operation which waits for a
+	// slot is forgotten if its slot was not requested again.
+	waiterTTL = 5 * time.Minute
+
+	// slotTTL is the duration after which a slot is released if it was not
+	// released by its holder, such as when the holder was deleted.
+	slotTTL = time.Hour
+)
+
+// Limits are the maximum numbers of concurrent operations. Zero means no
+// limit.
+type Limits struct {
+	// Server is the maximum number of concurrent operations per server.
+	Server int
+
+	// Datastore is the maximum number of concurrent operations per
+	// datastore.
+	Datastore int
+}
+
+// Priority is the priority of an operation. Operations with a higher
+// priority get a slot before operations with a lower priority.
+type Priority int
+
+const (
+	// PriorityDefault is the priority of the operations of worker machines.
+	PriorityDefault Priority = iota
+
+	// PriorityControlPlane is the priority of the operations of control
+	// plane machines.
+	PriorityControlPlane
+)
+
+// Operation is an operation which requires a slot.
+type Operation struct {
+	// Key identifies the holder of the slot, such as a VSphereVM. A holder
+	// holds at most one slot at a time.
+	Key string
+
+	// Server is the vSphere server the operation is performed on.
+	Server string
+
+	// Datastore identifies the datastore the operation is performed on, such
+	// as by its MoRef value, if known.
+	Datastore string
+
+	// Priority is the priority of the operation.
+	Priority Priority
+
+	// Wake is called when a slot is released while the operation waits for
+	// one, so that it can be requested again.
+	Wake func()
+}
+
+// Throttle hands out slots to operations within its limits. Operations which
+// do not get a slot wait for one, ordered by their priority and the time
+// they first requested a slot.
+type Throttle struct {
+	limits Limits
+
+	mu      sync.Mutex
+	slots   map[string]*slot
+	waiters map[string]*waiter
+}
+
+type slot struct {
+	server    string
+	datastore string
+	acquired  time.Time
+}
+
+type waiter struct {
+	op       Operation
+	since    time.Time
+	lastSeen time.Time
+}
+
+// New returns a throttle with the given limits.
+func New(limits Limits) *Throttle {
+	return &Throttle{
+		limits:  limits,
+		slots:   map[string]*slot{},
+		waiters: map[string]*waiter{},
+	}
+}
+
+// Acquire returns whether the operation got a slot. It also returns true if
+// the holder of the operation already holds a slot. Otherwise the operation
+// waits for a slot until it requests one again.
+func (t *Throttle) Acquire(op Operation) bool {
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.expire(now)
+	if _, ok := t.slots[op.Key]; ok {
+		return true
+	}
+
+	w, ok := t.waiters[op.Key]
+	if !ok {
+		w = &waiter{since: now}
+		t.waiters[op.Key] = w
+	}
+	w.op = op
+	w.lastSeen = now
+
+	if !t.fits(w) {
+		return false
+	}
+	delete(t.waiters, op.Key)
+	t.slots[op.Key] = &slot{server: op.Server, datastore: op.Datastore, acquired: now}
+	return true
+}
+
+// Holds returns whether the holder with the given key holds a slot.
+func (t *Throttle) Holds(key string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.slots[key]
+	return ok
+}
+
+// Hold makes the holder of the operation hold a slot regardless of the
+// limits, such as for a task which was started before the process
+// restarted.
+func (t *Throttle) Hold(op Operation) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.slots[op.Key]; ok {
+		return
+	}
+	delete(t.waiters, op.Key)
+	t.slots[op.Key] = &slot{server: op.Server, datastore: op.Datastore, acquired: time.Now()}
+}
+
+// Release releases the slot held by the holder with the given key, if any,
+// and wakes the operations which may get the slot.
+func (t *Throttle) Release(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	s, ok := t.slots[key]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.slots, key)
+	wake := t.wakeable(s.server)
+	t.mu.Unlock()
+
+	for _, fn := range wake {
+		fn()
+	}
+}
+
+// Forget forgets the operation of the holder with the given key which waits
+// for a slot, such as when the holder was deleted, and wakes the operations
+// which may get a slot in its place. A slot held by the holder is kept until
+// it is released.
+func (t *Throttle) Forget(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	w, ok := t.waiters[key]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.waiters, key)
+	wake := t.wakeable(w.op.Server)
+	t.mu.Unlock()
+
+	for _, fn := range wake {
+		fn()
+	}
+}
+
+// Waits returns whether the holder with the given key waits for a slot.
+func (t *Throttle) Waits(key string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.waiters[key]
+	return ok
+}
+
+// Waiting returns the number of operations which wait for a slot.
+func (t *Throttle) Waiting() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.waiters)
+}
+
+// fits returns whether the waiter gets a slot.
+func (t *Throttle) fits(w *waiter) bool {
+	for _, o := range t.admissible(w.op.Server) {
+		if o == w {
+			return true
+		}
+	}
+	return false
+}
+
+// admissible returns the waiters of the server which get a slot. The waiters
+// are considered in order, and each one gets a slot if both its server and
+// its datastore have a free slot left after the waiters ahead of it got
+// theirs. A waiter whose datastore is full does not hold up the waiters on
+// other datastores.
+func (t *Throttle) admissible(server string) []*waiter {
+	var waiters []*waiter
+	for _, w := range t.waiters {
+		if w.op.Server == server {
+			waiters = append(waiters, w)
+		}
+	}
+	sort.Slice(waiters, func(i, j int) bool { return before(waiters[i], waiters[j]) })
+
+	serverSlots := 0
+	datastoreSlots := map[string]int{}
+	for _, s := range t.slots {
+		if s.server == server {
+			serverSlots++
+			datastoreSlots[s.datastore]++
+		}
+	}
+
+	var admitted []*waiter
+	for _, w := range waiters {
+		if t.limits.Server > 0 && serverSlots >= t.limits.Server {
+			break
+		}
+		if t.limits.Datastore > 0 && w.op.Datastore != "" && datastoreSlots[w.op.Datastore] >= t.limits.Datastore {
+			continue
+		}
+		admitted = append(admitted, w)
+		serverSlots++
+		datastoreSlots[w.op.Datastore]++
+	}
+	return admitted
+}
+
+func before(a, b *waiter) bool {
+	if a.op.Priority != b.op.Priority {
+		return a.op.Priority > b.op.Priority
+	}
+	return a.since.Before(b.since)
+}
+
+// wakeable returns the wake functions of the waiters of the server which get
+// a slot.
+func (t *Throttle) wakeable(server string) []func() {
+	var wake []func()
+	for _, w := range t.admissible(server) {
+		if w.op.Wake != nil {
+			wake = append(wake, w.op.Wake)
+		}
+	}
+	return wake
+}
+
+// expire forgets the waiters which did not request a slot again and
+// releases the slots which were not released by their holders.
+func (t *Throttle) expire(now time.Time) {
+	for key, w := range t.waiters {
+		if now.Sub(w.lastSeen) > waiterTTL {
+			delete(t.waiters, key)
+		}
+	}
+	for key, s := range t.slots {
+		if now.Sub(s.acquired) > slotTTL {
+			delete(t.slots, key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestThrottle(t *testing.T) {
+	op := func(key, server, datastore string, priority Priority, woken *[]string) Operation {
+		return Operation{
+			Key:       key,
+			Server:    server,
+			Datastore: datastore,
+			Priority:  priority,
+			Wake:      func() { *woken = append(*woken, key) },
+		}
+	}
+
+	t.Run("limits the operations per server", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{Server: 2})
+
+		g.Expect(throttle.Acquire(op("a", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("b", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("c", "vc1", "", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("d", "vc2", "", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Waiting()).To(Equal(1))
+
+		// The holder of a slot keeps it.
+		g.Expect(throttle.Acquire(op("a", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+
+		throttle.Release("a")
+		g.Expect(woken).To(Equal([]string{"c"}))
+		g.Expect(throttle.Acquire(op("c", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Waiting()).To(Equal(0))
+	})
+
+	t.Run("limits the operations per datastore", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{Datastore: 1})
+
+		g.Expect(throttle.Acquire(op("a", "vc1", "ds1", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("b", "vc1", "ds1", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("c", "vc1", "ds2", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("d", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+	})
+
+	t.Run("wakes only the operations whose server and datastore have a free slot", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{Server: 2, Datastore: 1})
+
+		g.Expect(throttle.Acquire(op("a", "vc1", "ds1", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("b", "vc1", "ds1", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("c", "vc1", "ds2", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("d", "vc1", "ds3", PriorityDefault, &woken))).To(BeFalse())
+
+		// The operation on the full datastore does not hold up the operation
+		// on another datastore.
+		throttle.Release("c")
+		g.Expect(woken).To(Equal([]string{"d"}))
+		g.Expect(throttle.Acquire(op("b", "vc1", "ds1", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("d", "vc1", "ds3", PriorityDefault, &woken))).To(BeTrue())
+
+		throttle.Release("a")
+		g.Expect(woken).To(Equal([]string{"d", "b"}))
+	})
+
+	t.Run("forgets the operations of deleted holders", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{Server: 1})
+
+		g.Expect(throttle.Acquire(op("a", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("b", "vc1", "", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("c", "vc1", "", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Waits("b")).To(BeTrue())
+
+		throttle.Release("a")
+		g.Expect(woken).To(Equal([]string{"b"}))
+
+		// The slot is handed to the next operation if the woken one was
+		// deleted in the meantime.
+		throttle.Forget("b")
+		g.Expect(throttle.Waits("b")).To(BeFalse())
+		g.Expect(woken).To(Equal([]string{"b", "c"}))
+		g.Expect(throttle.Acquire(op("c", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+
+		// Forgetting a holder keeps its slot.
+		throttle.Forget("c")
+		g.Expect(throttle.Holds("c")).To(BeTrue())
+	})
+
+	t.Run("prioritizes control plane operations", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{Server: 1})
+
+		g.Expect(throttle.Acquire(op("worker-1", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+		g.Expect(throttle.Acquire(op("worker-2", "vc1", "", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("control-plane", "vc1", "", PriorityControlPlane, &woken))).To(BeFalse())
+
+		throttle.Release("worker-1")
+		g.Expect(woken).To(Equal([]string{"control-plane"}))
+
+		// The worker which waited longer does not get the slot ahead of the
+		// control plane machine.
+		g.Expect(throttle.Acquire(op("worker-2", "vc1", "", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Acquire(op("control-plane", "vc1", "", PriorityControlPlane, &woken))).To(BeTrue())
+
+		throttle.Release("control-plane")
+		g.Expect(throttle.Acquire(op("worker-2", "vc1", "", PriorityDefault, &woken))).To(BeTrue())
+	})
+
+	t.Run("holds slots regardless of the limits", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{Server: 1})
+
+		throttle.Hold(op("a", "vc1", "", PriorityDefault, &woken))
+		throttle.Hold(op("b", "vc1", "", PriorityDefault, &woken))
+		g.Expect(throttle.Acquire(op("c", "vc1", "", PriorityDefault, &woken))).To(BeFalse())
+		g.Expect(throttle.Holds("a")).To(BeTrue())
+		g.Expect(throttle.Holds("c")).To(BeFalse())
+
+		throttle.Release("a")
+		g.Expect(woken).To(BeEmpty())
+		throttle.Release("b")
+		g.Expect(woken).To(Equal([]string{"c"}))
+	})
+
+	t.Run("does not limit the operations without limits", func(t *testing.T) {
+		g := NewWithT(t)
+		var woken []string
+		throttle := New(Limits{})
+		for _, key := range []string{"a", "b", "c"} {
+			g.Expect(throttle.Acquire(op(key, "vc1", "ds1", PriorityDefault, &woken))).To(BeTrue())
+		}
+
+		var nilThrottle *Throttle
+		g.Expect(nilThrottle.Acquire(op("a", "vc1", "ds1", PriorityDefault, &woken))).To(BeTrue())
+		nilThrottle.Release("a")
+	})
+}
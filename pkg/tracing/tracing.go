@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing exports OpenTelemetry traces of reconciles and vCenter
+// calls over OTLP. Tracing is a no-op unless it is set up with an endpoint.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "sigs.k8s.io/cluster-api-provider-vsphere"
+	serviceName = "capv-controller-manager"
+)
+
+// Options configure the export of traces.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC endpoint the traces are
+	// exported to. Tracing is disabled if it is empty.
+	Endpoint string
+
+	// Insecure exports the traces without TLS.
+	Insecure bool
+
+	// SamplingRatio is the ratio of the traces which are sampled, unless
+	// their parent was sampled.
+	SamplingRatio float64
+}
+
+// Setup sets up the global tracer provider according to the options and
+// returns a function which flushes and stops the export of traces. Nothing
+// is set up if no endpoint is configured.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create trace exporter for %q", opts.Endpoint)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SamplingRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// Start starts a span with the given name as a child of the span in the
+// context, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// RoundTripper returns a SOAP round tripper which traces each call to the
+// server with a span. The trace context of the span is sent as the
+// operationID of the call, so that the call can be found in the logs of the
+// server.
+func RoundTripper(rt soap.RoundTripper, server string) soap.RoundTripper {
+	return &roundTripper{RoundTripper: rt, server: server}
+}
+
+type roundTripper struct {
+	soap.RoundTripper
+	server string
+}
+
+func (r *roundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	method := methodName(req)
+	ctx, span := Start(ctx, "vcenter."+method,
+		attribute.String("vsphere.server", r.server),
+		attribute.String("vsphere.method", method))
+
+	if sc := span.SpanContext(); sc.IsValid() {
+		if _, ok := ctx.Value(types.ID{}).(string); !ok {
+			ctx = context.WithValue(ctx, types.ID{}, operationID(sc))
+		}
+	}
+
+	err := r.RoundTripper.RoundTrip(ctx, req, res)
+	End(span, err)
+	return err
+}
+
+// operationID returns the operationID of a call traced with a span of the
+// given span context.
+func operationID(sc trace.SpanContext) string {
+	return fmt.Sprintf("%s-%s", sc.TraceID(), sc.SpanID())
+}
+
+// methodName returns the name of the method of the request body, such as
+// RetrieveProperties for a RetrievePropertiesBody.
+func methodName(req soap.HasFault) string {
+	return strings.TrimSuffix(reflect.Indirect(reflect.ValueOf(req)).Type().Name(), "Body")
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeRoundTripper struct {
+	operationID string
+	err         error
+}
+
+func (f *fakeRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	f.operationID, _ = ctx.Value(types.ID{}).(string)
+	return f.err
+}
+
+func TestRoundTripper(t *testing.T) {
+	t.Run("does not set an operationID without tracer provider", func(t *testing.T) {
+		g := NewWithT(t)
+		fake := &fakeRoundTripper{}
+		rt := RoundTripper(fake, "vcenter")
+
+		g.Expect(rt.RoundTrip(context.Background(), &methods.RetrievePropertiesBody{}, &methods.RetrievePropertiesBody{})).To(Succeed())
+		g.Expect(fake.operationID).To(BeEmpty())
+	})
+
+	t.Run("traces calls with spans", func(t *testing.T) {
+		g := NewWithT(t)
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		otel.SetTracerProvider(provider)
+		defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+		fake := &fakeRoundTripper{}
+		rt := RoundTripper(fake, "vcenter")
+
+		ctx, span := Start(context.Background(), "VSphereVM.Reconcile")
+		g.Expect(rt.RoundTrip(ctx, &methods.RetrievePropertiesBody{}, &methods.RetrievePropertiesBody{})).To(Succeed())
+		fake.err = errors.New("fault")
+		g.Expect(rt.RoundTrip(ctx, &methods.PowerOnVM_TaskBody{}, &methods.PowerOnVM_TaskBody{})).NotTo(Succeed())
+		span.End()
+
+		spans := recorder.Ended()
+		g.Expect(spans).To(HaveLen(3))
+		g.Expect(spans[0].Name()).To(Equal("vcenter.RetrieveProperties"))
+		g.Expect(spans[0].Parent().SpanID()).To(Equal(span.SpanContext().SpanID()))
+		g.Expect(spans[1].Name()).To(Equal("vcenter.PowerOnVM_Task"))
+		g.Expect(spans[1].Status().Code).To(Equal(codes.Error))
+		g.Expect(fake.operationID).To(Equal(operationID(spans[1].SpanContext())))
+		g.Expect(fake.operationID).To(HavePrefix(span.SpanContext().TraceID().String()))
+	})
+
+	t.Run("keeps an operationID set by the caller", func(t *testing.T) {
+		g := NewWithT(t)
+		provider := sdktrace.NewTracerProvider()
+		otel.SetTracerProvider(provider)
+		defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+		fake := &fakeRoundTripper{}
+		rt := RoundTripper(fake, "vcenter")
+
+		ctx := context.WithValue(context.Background(), types.ID{}, "caller")
+		g.Expect(rt.RoundTrip(ctx, &methods.RetrievePropertiesBody{}, &methods.RetrievePropertiesBody{})).To(Succeed())
+		g.Expect(fake.operationID).To(Equal("caller"))
+	})
+}
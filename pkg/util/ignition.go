@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/utils/integer"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+const networkdLinkFormat = `[Match]
+MACAddress={{ .MACAddr }}
+
+[Link]
+Name={{ .Name }}
+`
+
+const networkdNetworkFormat = `[Match]
+MACAddress={{ .Device.MACAddr }}
+
+[Network]
+DHCP={{ dhcp .Device }}
+{{- range .Device.IPAddrs }}
+Address={{ . }}
+{{- end }}
+{{- if .Device.Gateway4 }}
+Gateway={{ .Device.Gateway4 }}
+{{- end }}
+{{- if .Device.Gateway6 }}
+Gateway={{ .Device.Gateway6 }}
+{{- end }}
+{{- range .Device.Nameservers }}
+DNS={{ . }}
+{{- end }}
+{{- if .Device.SearchDomains }}
+Domains={{ join .Device.SearchDomains " " }}
+{{- end }}
+{{- if .Device.MTU }}
+
+[Link]
+MTUBytes={{ .Device.MTU }}
+{{- end }}
+{{- range .Routes }}
+
+[Route]
+Destination={{ .To }}
+Gateway={{ .Via }}
+Metric={{ .Metric }}
+{{- end }}
+`
+
+var networkdTemplate = template.Must(template.New("network").Funcs(template.FuncMap{
+	"dhcp": func(spec infrav1.NetworkDeviceSpec) string {
+		switch {
+		case spec.DHCP4 && spec.DHCP6:
+			return "yes"
+		case spec.DHCP4:
+			return "ipv4"
+		case spec.DHCP6:
+			return "ipv6"
+		default:
+			return "no"
+		}
+	},
+	"join": strings.Join,
+}).Parse(networkdNetworkFormat))
+
+var networkdLinkTemplate = template.Must(template.New("link").Parse(networkdLinkFormat))
+
+// ignitionConfig is the subset of the Ignition config used to extend the
+// bootstrap Ignition config. The same fields are valid in spec version 2 and
+// 3 of the Ignition config, except for the ones noted.
+type ignitionConfig struct {
+	Ignition struct {
+		Version string `json:"version"`
+		Config  struct {
+			// Append is only valid in spec version 2.
+			Append []ignitionResource `json:"append,omitempty"`
+			// Merge is only valid in spec version 3.
+			Merge []ignitionResource `json:"merge,omitempty"`
+		} `json:"config"`
+	} `json:"ignition"`
+	Storage struct {
+		Files []ignitionFile `json:"files,omitempty"`
+	} `json:"storage"`
+}
+
+type ignitionResource struct {
+	Source string `json:"source"`
+}
+
+type ignitionFile struct {
+	// Filesystem is only valid, and required, in spec version 2.
+	Filesystem string `json:"filesystem,omitempty"`
+	Path       string `json:"path"`
+	Mode       int    `json:"mode"`
+	// Overwrite is only valid in spec version 3.
+	Overwrite *bool            `json:"overwrite,omitempty"`
+	Contents  ignitionResource `json:"contents"`
+}
+
+// GetMachineIgnitionConfig returns the Ignition config for a given VSphereVM.
+// The config sets the hostname and configures the network devices with
+// systemd-networkd units before applying the bootstrap Ignition config.
+// Spec version 2 and 3 of the Ignition config are supported.
+func GetMachineIgnitionConfig(bootstrapData []byte, hostname string, vsphereVM infrav1.VSphereVM, networkStatuses ...infrav1.NetworkStatus) ([]byte, error) {
+	var bootstrapConfig ignitionConfig
+	if err := json.Unmarshal(bootstrapData, &bootstrapConfig); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse bootstrap Ignition config for vsphereVM %s/%s", vsphereVM.Namespace, vsphereVM.Name)
+	}
+	version := bootstrapConfig.Ignition.Version
+
+	var config ignitionConfig
+	config.Ignition.Version = version
+	source := ignitionResource{Source: dataURL(bootstrapData)}
+	switch {
+	case strings.HasPrefix(version, "2."):
+		config.Ignition.Config.Append = []ignitionResource{source}
+	case strings.HasPrefix(version, "3."):
+		config.Ignition.Config.Merge = []ignitionResource{source}
+	default:
+		return nil, errors.Errorf("unsupported Ignition config version %q for vsphereVM %s/%s", version, vsphereVM.Namespace, vsphereVM.Name)
+	}
+
+	addFile := func(path string, contents []byte) {
+		file := ignitionFile{
+			Path:     path,
+			Mode:     0644,
+			Contents: ignitionResource{Source: dataURL(contents)},
+		}
+		if config.Ignition.Config.Append != nil {
+			file.Filesystem = "root"
+		} else {
+			file.Overwrite = pointer.Bool(true)
+		}
+		config.Storage.Files = append(config.Storage.Files, file)
+	}
+
+	// note that hostname determines the Kubernetes node name
+	addFile("/etc/hostname", []byte(hostname+"\n"))
+
+	// Create a copy of the devices and add their MAC addresses from a network status.
+	devices := make([]infrav1.NetworkDeviceSpec, integer.IntMax(len(vsphereVM.Spec.Network.Devices), len(networkStatuses)))
+	for i := range vsphereVM.Spec.Network.Devices {
+		vsphereVM.Spec.Network.Devices[i].DeepCopyInto(&devices[i])
+	}
+	for i, status := range networkStatuses {
+		devices[i].MACAddr = status.MACAddr
+	}
+
+	for i, device := range devices {
+		name := device.DeviceName
+		if name == "" {
+			name = fmt.Sprintf("eth%d", i)
+		}
+		buf := &bytes.Buffer{}
+		if err := networkdLinkTemplate.Execute(buf, struct {
+			MACAddr string
+			Name    string
+		}{
+			MACAddr: device.MACAddr,
+			Name:    name,
+		}); err != nil {
+			return nil, errors.Wrapf(err, "error getting networkd link unit for vsphereVM %s/%s", vsphereVM.Namespace, vsphereVM.Name)
+		}
+		addFile(fmt.Sprintf("/etc/systemd/network/10-id%d.link", i), buf.Bytes())
+
+		// The routes of the VM are applied to its first device.
+		routes := device.Routes
+		if i == 0 {
+			routes = append(routes, vsphereVM.Spec.Network.Routes...)
+		}
+		buf = &bytes.Buffer{}
+		if err := networkdTemplate.Execute(buf, struct {
+			Device infrav1.NetworkDeviceSpec
+			Routes []infrav1.NetworkRouteSpec
+		}{
+			Device: device,
+			Routes: routes,
+		}); err != nil {
+			return nil, errors.Wrapf(err, "error getting networkd network unit for vsphereVM %s/%s", vsphereVM.Namespace, vsphereVM.Name)
+		}
+		addFile(fmt.Sprintf("/etc/systemd/network/10-id%d.network", i), buf.Bytes())
+	}
+
+	return json.Marshal(config)
+}
+
+func dataURL(data []byte) string {
+	return "data:;base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+func Test_GetMachineIgnitionConfig(t *testing.T) {
+	vsphereVM := infrav1.VSphereVM{
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+				Network: infrav1.NetworkSpec{
+					Devices: []infrav1.NetworkDeviceSpec{
+						{
+							NetworkName:   "network1",
+							IPAddrs:       []string{"192.168.4.21/24"},
+							Gateway4:      "192.168.4.1",
+							Nameservers:   []string{"8.8.8.8"},
+							SearchDomains: []string{"vmware.ci", "example.com"},
+							MTU:           pointer.Int64(9000),
+							Routes: []infrav1.NetworkRouteSpec{
+								{To: "10.0.0.0/8", Via: "192.168.4.254", Metric: 10},
+							},
+						},
+						{
+							NetworkName: "network2",
+							DeviceName:  "ens224",
+							DHCP4:       true,
+							DHCP6:       true,
+						},
+					},
+				},
+			},
+		},
+	}
+	networkStatuses := []infrav1.NetworkStatus{
+		{MACAddr: "00:00:00:00:00:01"},
+		{MACAddr: "00:00:00:00:00:02"},
+	}
+
+	// decodeFiles returns the contents of the files of the Ignition config.
+	decodeFiles := func(g *gomega.WithT, config map[string]interface{}) map[string]string {
+		files := map[string]string{}
+		for _, f := range config["storage"].(map[string]interface{})["files"].([]interface{}) {
+			file := f.(map[string]interface{})
+			source := file["contents"].(map[string]interface{})["source"].(string)
+			g.Expect(source).To(gomega.HavePrefix("data:;base64,"))
+			contents, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(source, "data:;base64,"))
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			files[file["path"].(string)] = string(contents)
+		}
+		return files
+	}
+
+	t.Run("spec version 3", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		bootstrapData := []byte(`{"ignition":{"version":"3.1.0"},"passwd":{}}`)
+
+		data, err := util.GetMachineIgnitionConfig(bootstrapData, "test-vm", vsphereVM, networkStatuses...)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		var config map[string]interface{}
+		g.Expect(json.Unmarshal(data, &config)).To(gomega.Succeed())
+		ignition := config["ignition"].(map[string]interface{})
+		g.Expect(ignition["version"]).To(gomega.Equal("3.1.0"))
+		g.Expect(ignition["config"]).To(gomega.Equal(map[string]interface{}{
+			"merge": []interface{}{
+				map[string]interface{}{"source": "data:;base64," + base64.StdEncoding.EncodeToString(bootstrapData)},
+			},
+		}))
+		for _, f := range config["storage"].(map[string]interface{})["files"].([]interface{}) {
+			g.Expect(f).To(gomega.HaveKeyWithValue("overwrite", true))
+			g.Expect(f).NotTo(gomega.HaveKey("filesystem"))
+		}
+
+		g.Expect(decodeFiles(g, config)).To(gomega.Equal(map[string]string{
+			"/etc/hostname": "test-vm\n",
+			"/etc/systemd/network/10-id0.link": `[Match]
+MACAddress=00:00:00:00:00:01
+
+[Link]
+Name=eth0
+`,
+			"/etc/systemd/network/10-id0.network": `[Match]
+MACAddress=00:00:00:00:00:01
+
+[Network]
+DHCP=no
+Address=192.168.4.21/24
+Gateway=192.168.4.1
+DNS=8.8.8.8
+Domains=vmware.ci example.com
+
+[Link]
+MTUBytes=9000
+
+[Route]
+Destination=10.0.0.0/8
+Gateway=192.168.4.254
+Metric=10
+`,
+			"/etc/systemd/network/10-id1.link": `[Match]
+MACAddress=00:00:00:00:00:02
+
+[Link]
+Name=ens224
+`,
+			"/etc/systemd/network/10-id1.network": `[Match]
+MACAddress=00:00:00:00:00:02
+
+[Network]
+DHCP=yes
+`,
+		}))
+	})
+
+	t.Run("spec version 2", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		bootstrapData := []byte(`{"ignition":{"version":"2.3.0"}}`)
+
+		data, err := util.GetMachineIgnitionConfig(bootstrapData, "test-vm", vsphereVM, networkStatuses...)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		var config map[string]interface{}
+		g.Expect(json.Unmarshal(data, &config)).To(gomega.Succeed())
+		ignition := config["ignition"].(map[string]interface{})
+		g.Expect(ignition["version"]).To(gomega.Equal("2.3.0"))
+		g.Expect(ignition["config"]).To(gomega.HaveKey("append"))
+		g.Expect(ignition["config"]).NotTo(gomega.HaveKey("merge"))
+		for _, f := range config["storage"].(map[string]interface{})["files"].([]interface{}) {
+			g.Expect(f).To(gomega.HaveKeyWithValue("filesystem", "root"))
+			g.Expect(f).NotTo(gomega.HaveKey("overwrite"))
+		}
+		g.Expect(decodeFiles(g, config)).To(gomega.HaveLen(5))
+	})
+
+	t.Run("unsupported bootstrap data", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+
+		_, err := util.GetMachineIgnitionConfig([]byte("#cloud-config"), "test-vm", vsphereVM)
+		g.Expect(err).To(gomega.HaveOccurred())
+
+		_, err = util.GetMachineIgnitionConfig([]byte(`{"ignition":{"version":"1.0.0"}}`), "test-vm", vsphereVM)
+		g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("unsupported Ignition config version")))
+	})
+}
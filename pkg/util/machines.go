@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"regexp"
 	"text/template"
@@ -152,9 +153,88 @@ func IsControlPlaneMachine(machine metav1.Object) bool {
 	return ok
 }
 
+// MetadataTemplateData is the data passed to cloud-init metadata templates.
+type MetadataTemplateData struct {
+	// Hostname is the hostname of the machine. Note that the hostname
+	// determines the Kubernetes node name.
+	Hostname string
+	// Devices are the network devices of the machine, with the MAC addresses
+	// reported by the network status of the virtual machine.
+	Devices []infrav1.NetworkDeviceSpec
+	// Routes are the routes of the machine, in addition to the routes of its
+	// devices.
+	Routes []infrav1.NetworkRouteSpec
+	// WaitForIPv4 is true if any device has a static IPv4 address or uses
+	// DHCPv4.
+	WaitForIPv4 bool
+	// WaitForIPv6 is true if any device has a static IPv6 address or uses
+	// DHCPv6.
+	WaitForIPv6 bool
+}
+
+var metadataTemplateFuncs = template.FuncMap{
+	"nameservers": func(spec infrav1.NetworkDeviceSpec) bool {
+		return len(spec.Nameservers) > 0 || len(spec.SearchDomains) > 0
+	},
+}
+
+var defaultMetadataTemplate = template.Must(ParseMetadataTemplate(metadataFormat))
+
+// ParseMetadataTemplate parses a cloud-init metadata template. The template
+// is executed with MetadataTemplateData.
+func ParseMetadataTemplate(text string) (*template.Template, error) {
+	return template.New("metadata").Funcs(metadataTemplateFuncs).Parse(text)
+}
+
+// ValidateMetadataTemplate returns an error if the cloud-init metadata
+// template cannot be parsed or cannot be executed with a machine using each
+// kind of network configuration.
+func ValidateMetadataTemplate(text string) error {
+	tpl, err := ParseMetadataTemplate(text)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(io.Discard, MetadataTemplateData{
+		Hostname: "machine",
+		Devices: []infrav1.NetworkDeviceSpec{
+			{
+				NetworkName:   "network",
+				MACAddr:       "00:00:00:00:00:00",
+				IPAddrs:       []string{"192.168.0.2/24", "fd00::2/64"},
+				Gateway4:      "192.168.0.1",
+				Gateway6:      "fd00::1",
+				Nameservers:   []string{"192.168.0.1"},
+				SearchDomains: []string{"example.com"},
+				Routes:        []infrav1.NetworkRouteSpec{{To: "10.0.0.0/8", Via: "192.168.0.254", Metric: 10}},
+			},
+			{
+				NetworkName: "network",
+				DeviceName:  "eth1",
+				MACAddr:     "00:00:00:00:00:01",
+				DHCP4:       true,
+				DHCP6:       true,
+			},
+		},
+		Routes:      []infrav1.NetworkRouteSpec{{To: "172.16.0.0/12", Via: "192.168.0.254", Metric: 20}},
+		WaitForIPv4: true,
+		WaitForIPv6: true,
+	})
+}
+
 // GetMachineMetadata returns the cloud-init metadata as a base-64 encoded
 // string for a given VSphereMachine.
 func GetMachineMetadata(hostname string, vsphereVM infrav1.VSphereVM, networkStatuses ...infrav1.NetworkStatus) ([]byte, error) {
+	return GetMachineMetadataWithTemplate(nil, hostname, vsphereVM, networkStatuses...)
+}
+
+// GetMachineMetadataWithTemplate returns the cloud-init metadata rendered
+// with a template parsed by ParseMetadataTemplate for a given VSphereMachine.
+// The built-in template is used if the template is nil.
+func GetMachineMetadataWithTemplate(tpl *template.Template, hostname string, vsphereVM infrav1.VSphereVM, networkStatuses ...infrav1.NetworkStatus) ([]byte, error) {
+	if tpl == nil {
+		tpl = defaultMetadataTemplate
+	}
+
 	// Create a copy of the devices and add their MAC addresses from a network status.
 	devices := make([]infrav1.NetworkDeviceSpec, integer.IntMax(len(vsphereVM.Spec.Network.Devices), len(networkStatuses)))
 
@@ -193,19 +273,7 @@ func GetMachineMetadata(hostname string, vsphereVM infrav1.VSphereVM, networkSta
 	}
 
 	buf := &bytes.Buffer{}
-	tpl := template.Must(template.New("t").Funcs(
-		template.FuncMap{
-			"nameservers": func(spec infrav1.NetworkDeviceSpec) bool {
-				return len(spec.Nameservers) > 0 || len(spec.SearchDomains) > 0
-			},
-		}).Parse(metadataFormat))
-	if err := tpl.Execute(buf, struct {
-		Hostname    string
-		Devices     []infrav1.NetworkDeviceSpec
-		Routes      []infrav1.NetworkRouteSpec
-		WaitForIPv4 bool
-		WaitForIPv6 bool
-	}{
+	if err := tpl.Execute(buf, MetadataTemplateData{
 		Hostname:    hostname, // note that hostname determines the Kubernetes node name
 		Devices:     devices,
 		Routes:      vsphereVM.Spec.Network.Routes,
@@ -658,6 +658,103 @@ network:
 	}
 }
 
+func Test_GetMachineMetadataWithTemplate(t *testing.T) {
+	g := gomega.NewWithT(t)
+	vsphereVM := infrav1.VSphereVM{
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+				Network: infrav1.NetworkSpec{
+					Devices: []infrav1.NetworkDeviceSpec{
+						{
+							NetworkName: "network1",
+							IPAddrs:     []string{"192.168.4.21"},
+						},
+						{
+							NetworkName: "network2",
+							DHCP6:       true,
+						},
+					},
+					Routes: []infrav1.NetworkRouteSpec{
+						{To: "10.0.0.0/8", Via: "192.168.4.254", Metric: 10},
+					},
+				},
+			},
+		},
+	}
+	networkStatuses := []infrav1.NetworkStatus{
+		{MACAddr: "00:00:00:00:00:01"},
+		{MACAddr: "00:00:00:00:00:02"},
+	}
+
+	tpl, err := util.ParseMetadataTemplate(`local-hostname: {{ .Hostname }}.example.com
+ipv4: {{ .WaitForIPv4 }}
+ipv6: {{ .WaitForIPv6 }}
+{{- range .Devices }}
+mac: {{ .MACAddr }}
+{{- end }}
+{{- range .Routes }}
+route: {{ .To }} via {{ .Via }}
+{{- end }}
+`)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	metadata, err := util.GetMachineMetadataWithTemplate(tpl, "test-vm", vsphereVM, networkStatuses...)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(metadata)).To(gomega.Equal(`local-hostname: test-vm.example.com
+ipv4: true
+ipv6: true
+mac: 00:00:00:00:00:01
+mac: 00:00:00:00:00:02
+route: 10.0.0.0/8 via 192.168.4.254
+`))
+
+	defaultMetadata, err := util.GetMachineMetadata("test-vm", vsphereVM, networkStatuses...)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	metadata, err = util.GetMachineMetadataWithTemplate(nil, "test-vm", vsphereVM, networkStatuses...)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(metadata).To(gomega.Equal(defaultMetadata))
+}
+
+func Test_ValidateMetadataTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{
+			name:     "valid template",
+			template: `local-hostname: {{ .Hostname }}{{ range .Devices }}{{ if nameservers . }}{{ .Nameservers }}{{ end }}{{ end }}`,
+		},
+		{
+			name:     "syntax error",
+			template: `local-hostname: {{ .Hostname }`,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			template: `local-hostname: {{ .HostName }}`,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown device field",
+			template: `{{ range .Devices }}{{ .IPAddr }}{{ end }}`,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			err := util.ValidateMetadataTemplate(tc.template)
+			if tc.wantErr {
+				g.Expect(err).To(gomega.HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			}
+		})
+	}
+}
+
 func TestConvertProviderIDToUUID(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
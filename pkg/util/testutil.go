@@ -179,8 +179,8 @@ func CreateMachineContext(clusterContext *vmware.ClusterContext, machine *cluste
 	}
 }
 
-// GetBootstrapConfigMapName returns the name of the bootstrap data ConfigMap
+// GetBootstrapSecretName returns the name of the bootstrap data Secret
 // for a VM Operator VirtualMachine.
-func GetBootstrapConfigMapName(machineName string) string {
+func GetBootstrapSecretName(machineName string) string {
 	return fmt.Sprintf("%s-cloud-init", machineName)
 }
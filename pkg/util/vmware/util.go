@@ -25,6 +25,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -35,11 +36,18 @@ func GetMachineDeploymentNameForCluster(cluster *clusterv1.Cluster) string {
 }
 
 // GetBootstrapConfigMapName returns the name of the bootstrap data ConfigMap
-// for a VM Operator VirtualMachine.
+// for a VM Operator VirtualMachine. The ConfigMap has been replaced by the
+// Secret named by GetBootstrapSecretName.
 func GetBootstrapConfigMapName(machineName string) string {
 	return fmt.Sprintf("%s-cloud-init", machineName)
 }
 
+// GetBootstrapSecretName returns the name of the bootstrap data Secret for a
+// VM Operator VirtualMachine.
+func GetBootstrapSecretName(machineName string) string {
+	return fmt.Sprintf("%s-cloud-init", machineName)
+}
+
 func GetBootstrapData(ctx context.Context, c client.Client, machine *clusterv1.Machine) (string, error) {
 	value, err := GetRawBootstrapData(ctx, c, machine)
 	if err != nil {
@@ -51,20 +59,32 @@ func GetBootstrapData(ctx context.Context, c client.Client, machine *clusterv1.M
 // GetRawBootstrapData returns the bootstrap data from the secret in the
 // Machine's bootstrap.dataSecretName.
 func GetRawBootstrapData(ctx context.Context, c client.Client, machine *clusterv1.Machine) ([]byte, error) {
+	value, _, err := GetRawBootstrapDataWithFormat(ctx, c, machine)
+	return value, err
+}
+
+// GetRawBootstrapDataWithFormat returns the bootstrap data from the secret in
+// the Machine's bootstrap.dataSecretName and its format, which defaults to
+// cloud-config.
+func GetRawBootstrapDataWithFormat(ctx context.Context, c client.Client, machine *clusterv1.Machine) ([]byte, bootstrapv1.Format, error) {
 	if machine.Spec.Bootstrap.DataSecretName == nil {
-		return nil, errors.New("error retrieving bootstrap data: linked Machine's bootstrap.dataSecretName is nil")
+		return nil, "", errors.New("error retrieving bootstrap data: linked Machine's bootstrap.dataSecretName is nil")
 	}
 
 	secret := &corev1.Secret{}
 	key := apitypes.NamespacedName{Namespace: machine.GetNamespace(), Name: *machine.Spec.Bootstrap.DataSecretName}
 	if err := c.Get(ctx, key, secret); err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve bootstrap data secret for Machine %s/%s", machine.GetNamespace(), machine.GetName())
+		return nil, "", errors.Wrapf(err, "failed to retrieve bootstrap data secret for Machine %s/%s", machine.GetNamespace(), machine.GetName())
 	}
 
 	value, ok := secret.Data["value"]
 	if !ok {
-		return nil, errors.New("error retrieving bootstrap data: secret value key is missing")
+		return nil, "", errors.New("error retrieving bootstrap data: secret value key is missing")
 	}
 
-	return value, nil
+	format := bootstrapv1.Format(secret.Data["format"])
+	if format == "" {
+		format = bootstrapv1.CloudConfig
+	}
+	return value, format, nil
 }
@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	goctx "context"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+const metadataTemplateWebhookPath = "/validate-v1-configmap-metadatatemplate"
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-v1-configmap-metadatatemplate,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups="",resources=configmaps,versions=v1,name=validation.metadatatemplate.vsphere.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+// MetadataTemplateValidator validates the cloud-init metadata templates of
+// ConfigMaps labeled with infrav1.LabelMetadataTemplate. Other ConfigMaps are
+// always allowed.
+type MetadataTemplateValidator struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &MetadataTemplateValidator{}
+var _ admission.DecoderInjector = &MetadataTemplateValidator{}
+
+// SetupWebhookWithManager registers the webhook with the webhook server of
+// the manager.
+func (v *MetadataTemplateValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(metadataTemplateWebhookPath, &webhook.Admission{Handler: v})
+	return nil
+}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (v *MetadataTemplateValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (v *MetadataTemplateValidator) Handle(_ goctx.Context, req admission.Request) admission.Response {
+	configMap := &corev1.ConfigMap{}
+	if err := v.decoder.Decode(req, configMap); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if _, ok := configMap.Labels[infrav1.LabelMetadataTemplate]; !ok {
+		return admission.Allowed("")
+	}
+
+	text, ok := configMap.Data[infrav1.MetadataTemplateKey]
+	if !ok {
+		return admission.Denied(fmt.Sprintf("metadata template ConfigMap must have a %q key", infrav1.MetadataTemplateKey))
+	}
+	if err := util.ValidateMetadataTemplate(text); err != nil {
+		return admission.Denied(fmt.Sprintf("invalid metadata template: %v", err))
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	goctx "context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestMetadataTemplateValidator_Handle(t *testing.T) {
+	g := NewWithT(t)
+
+	decoder, err := admission.NewDecoder(clientgoscheme.Scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+	validator := &MetadataTemplateValidator{}
+	g.Expect(validator.InjectDecoder(decoder)).To(Succeed())
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		data    map[string]string
+		allowed bool
+	}{
+		{
+			name:    "ConfigMap without label",
+			data:    map[string]string{infrav1.MetadataTemplateKey: "{{ .Unknown }}"},
+			allowed: true,
+		},
+		{
+			name:    "valid template",
+			labels:  map[string]string{infrav1.LabelMetadataTemplate: ""},
+			data:    map[string]string{infrav1.MetadataTemplateKey: "local-hostname: {{ .Hostname }}"},
+			allowed: true,
+		},
+		{
+			name:    "missing template key",
+			labels:  map[string]string{infrav1.LabelMetadataTemplate: ""},
+			data:    map[string]string{"metadata": "local-hostname: {{ .Hostname }}"},
+			allowed: false,
+		},
+		{
+			name:    "invalid template",
+			labels:  map[string]string{infrav1.LabelMetadataTemplate: ""},
+			data:    map[string]string{infrav1.MetadataTemplateKey: "{{ .Unknown }}"},
+			allowed: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			raw, err := json.Marshal(&corev1.ConfigMap{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "metadata-template",
+					Namespace: "default",
+					Labels:    tc.labels,
+				},
+				Data: tc.data,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			resp := validator.Handle(goctx.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			g.Expect(resp.Allowed).To(Equal(tc.allowed))
+		})
+	}
+}
@@ -119,10 +119,10 @@ var _ = Describe("Cluster lifecycle tests", func() {
 				createResource(kubeadmconfigtemplateResource, worker.KubeadmConfigTemplate)
 
 				// ASSERT the CAPI Machine, VSphereMachine, KubeadmConfig, and VM
-				// Operator VirtualMachine, and bootstrap data ConfigMap
+				// Operator VirtualMachine, and bootstrap data Secret
 				// resources for the control plane machine eventually exist, the
 				// VSphereMachine and KubeadmConfig resources have OwnerRefs that
-				// point to the CAPI Machine, and the ConfigMap resource has a
+				// point to the CAPI Machine, and the Secret resource has a
 				// controller OwnerRef that points to the VSphereMachine.
 				machine := assertEventuallyExists(machinesResource, controlPlane.Machine.Name, testNamespace, nil)
 				machineOwnerRef := toOwnerRef(machine)
@@ -134,7 +134,7 @@ var _ = Describe("Cluster lifecycle tests", func() {
 				vsphereMachineOwnerRef := toControllerOwnerRef(vsphereMachine)
 
 				assertEventuallyExists(virtualmachinesResource, controlPlane.Machine.Name, testNamespace, nil)
-				assertEventuallyExists(configmapsResource, infrautilv1.GetBootstrapConfigMapName(controlPlane.Machine.Name), testNamespace, vsphereMachineOwnerRef)
+				assertEventuallyExists(secretsResource, infrautilv1.GetBootstrapSecretName(controlPlane.Machine.Name), testNamespace, vsphereMachineOwnerRef)
 
 				// TODO: gab-satchi these should also be looking for correct ownerReferences before proceeding to a delete in the AfterEach
 				assertEventuallyExists(machinedeploymentResource, worker.MachineDeployment.Name, testNamespace, nil)
@@ -143,10 +143,10 @@ var _ = Describe("Cluster lifecycle tests", func() {
 			})
 			AfterEach(func() {
 				// ASSERT the CAPI Machine, VSphereMachine, KubeadmConfig, VM
-				// Operator VirtualMachine, and bootstrap data ConfigMap
+				// Operator VirtualMachine, and bootstrap data Secret
 				// resources for the control plane machine are eventually
 				// deleted.
-				assertEventuallyDoesNotExist(configmapsResource, infrautilv1.GetBootstrapConfigMapName(controlPlane.Machine.Name), testNamespace)
+				assertEventuallyDoesNotExist(secretsResource, infrautilv1.GetBootstrapSecretName(controlPlane.Machine.Name), testNamespace)
 				assertEventuallyDoesNotExist(virtualmachinesResource, controlPlane.Machine.Name, testNamespace)
 				assertEventuallyDoesNotExist(vspheremachinesResource, controlPlane.Machine.Name, testNamespace)
 				assertEventuallyDoesNotExist(kubeadmconfigResources, controlPlane.Machine.Name, testNamespace)
@@ -136,10 +136,10 @@ var (
 		Resource: "namespaces",
 	}
 
-	configmapsResource = schema.GroupVersionResource{
+	secretsResource = schema.GroupVersionResource{
 		Group:    "",
 		Version:  "v1",
-		Resource: "configmaps",
+		Resource: "secrets",
 	}
 
 	eventsResource = schema.GroupVersionResource{
@@ -729,7 +729,7 @@ func assertVirtualMachineState(machine *clusterv1.Machine, vm *vmoprv1.VirtualMa
 	Expect(vm.Spec.VmMetadata).NotTo(BeNil())
 	// TODO: Aarti: not sure where to get this varaible from
 	//Expect(vm.Spec.VmMetadata.Transport).To(Equal(vmoprv1.VirtualMachineMetadataExtraConfigTransport))
-	Expect(vm.Spec.VmMetadata.ConfigMapName).ToNot(BeNil())
+	Expect(vm.Spec.VmMetadata.SecretName).ToNot(BeNil())
 }
 
 // assertClusterEventuallyGetsControlPlaneEndpoint ensures that the cluster
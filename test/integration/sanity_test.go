@@ -106,10 +106,10 @@ var _ = Describe("Sanity tests", func() {
 		deleteResource(machinesResource, controlPlane.Machine.Name, controlPlane.Machine.Namespace, nil)
 
 		// ASSERT the CAPI Machine, VSphereMachine, KubeadmConfig, VM
-		// Operator VirtualMachine, and bootstrap data ConfigMap
+		// Operator VirtualMachine, and bootstrap data Secret
 		// resources for the control plane machine are eventually
 		// deleted.
-		assertEventuallyDoesNotExist(configmapsResource, infrautilv1.GetBootstrapConfigMapName(controlPlane.Machine.Name), controlPlane.Machine.Namespace)
+		assertEventuallyDoesNotExist(secretsResource, infrautilv1.GetBootstrapSecretName(controlPlane.Machine.Name), controlPlane.Machine.Namespace)
 		assertEventuallyDoesNotExist(virtualmachinesResource, controlPlane.Machine.Name, controlPlane.Machine.Namespace)
 		assertEventuallyDoesNotExist(vspheremachinesResource, controlPlane.Machine.Name, controlPlane.Machine.Namespace)
 		assertEventuallyDoesNotExist(kubeadmconfigResources, controlPlane.Machine.Name, controlPlane.Machine.Namespace)
@@ -137,10 +137,10 @@ var _ = Describe("Sanity tests", func() {
 			vsphereMachine := &infrav1.VSphereMachine{}
 			getResource(vspheremachinesResource, controlPlane.Machine.Name, controlPlane.Machine.Namespace, vsphereMachine)
 
-			// ASSERT the VirtualMachine and bootstrap data ConfigMap resources
-			// eventually exist. Ensure ConfigMap has OwnerRef set to the VSphereMachine.
+			// ASSERT the VirtualMachine and bootstrap data Secret resources
+			// eventually exist. Ensure Secret has OwnerRef set to the VSphereMachine.
 			vmObj := assertEventuallyExists(virtualmachinesResource, controlPlane.Machine.Name, controlPlane.Machine.Namespace, nil)
-			assertEventuallyExists(configmapsResource, infrautilv1.GetBootstrapConfigMapName(controlPlane.Machine.Name), controlPlane.Machine.Namespace, toControllerOwnerRef(vsphereMachine))
+			assertEventuallyExists(secretsResource, infrautilv1.GetBootstrapSecretName(controlPlane.Machine.Name), controlPlane.Machine.Namespace, toControllerOwnerRef(vsphereMachine))
 			vm := &vmoprv1.VirtualMachine{}
 			toStructured(controlPlane.Machine.Name, vm, vmObj)
 